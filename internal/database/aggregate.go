@@ -0,0 +1,355 @@
+package database
+
+import (
+	"fmt"
+	"math/big"
+	"regexp"
+	"strings"
+	"time"
+)
+
+var aggregateRegex = regexp.MustCompile(`(?i)^(COUNT|SUM|AVG|MIN|MAX)\s*\(\s*(DISTINCT\s+)?(\*|[\w.]+)\s*\)$`)
+
+// aggregate is an aggregate function in a SELECT column list, such as COUNT(*)
+type aggregate struct {
+	fn       string // upper case function name
+	arg      string // column name or *
+	distinct bool   // COUNT(DISTINCT col) counts each value once
+	alias    string // name given with AS, if any
+}
+
+// name returns the key of the result, the alias or else the expression
+func (a aggregate) name() string {
+	if a.alias != "" {
+		return a.alias
+	}
+	return a.String()
+}
+
+// String returns the expression of the aggregate, normalized
+func (a aggregate) String() string {
+	if a.distinct {
+		return a.fn + "(DISTINCT " + a.arg + ")"
+	}
+	return a.fn + "(" + a.arg + ")"
+}
+
+// parseAggregates returns the aggregates of a column list, or nil when it has
+// none. Plain columns cannot be mixed with aggregates since there is no GROUP BY.
+func parseAggregates(projections []projection) ([]aggregate, error) {
+	var aggregates []aggregate
+	var plain string
+	for _, p := range projections {
+		agg, ok, err := p.aggregate()
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			if plain == "" {
+				plain = p.expr
+			}
+			continue
+		}
+		aggregates = append(aggregates, agg)
+	}
+	if aggregates != nil && plain != "" {
+		return nil, fmt.Errorf("column %s must be used in an aggregate function", plain)
+	}
+	return aggregates, nil
+}
+
+// parseAggregate parses a column of a SELECT list, ok is false when it is
+// not an aggregate
+func parseAggregate(col string) (agg aggregate, ok bool, err error) {
+	matches := aggregateRegex.FindStringSubmatch(col)
+	if matches == nil {
+		return aggregate{}, false, nil
+	}
+	agg = aggregate{fn: strings.ToUpper(matches[1]), arg: matches[3], distinct: matches[2] != ""}
+	if agg.arg == "*" && (agg.fn != "COUNT" || agg.distinct) {
+		return aggregate{}, false, fmt.Errorf("%s is not supported, use a column name", agg)
+	}
+	if agg.distinct && agg.fn != "COUNT" {
+		return aggregate{}, false, fmt.Errorf("DISTINCT is only supported in COUNT, not %s", agg.fn)
+	}
+	return agg, true, nil
+}
+
+// aggregate parses the projection as an aggregate, named by its alias if it
+// has one
+func (p projection) aggregate() (aggregate, bool, error) {
+	agg, ok, err := parseAggregate(p.expr)
+	if ok && p.alias {
+		agg.alias = p.name
+	}
+	return agg, ok, err
+}
+
+// argType returns the type of the column the aggregate reads, empty for *
+func (a aggregate) argType(tables []*Table) ColumnType {
+	if a.arg == "*" {
+		return ""
+	}
+	column, _ := findColumn(tables, a.arg)
+	return column.Type
+}
+
+// compute applies the aggregate to the rows matched by a SELECT. Rows where
+// the column is null are skipped; on no values every function but COUNT
+// returns null. MIN and MAX order values as ORDER BY does for the column type.
+func (a aggregate) compute(rows []Row, colType ColumnType) (any, error) {
+	if a.fn == "COUNT" && a.arg == "*" {
+		return int64(len(rows)), nil
+	}
+
+	var values []any
+	for _, row := range rows {
+		if val := row[a.arg]; val != nil {
+			values = append(values, val)
+		}
+	}
+	if a.fn == "COUNT" && a.distinct {
+		return int64(countDistinct(values)), nil
+	}
+	if a.fn == "COUNT" {
+		return int64(len(values)), nil
+	}
+	if len(values) == 0 {
+		return nil, nil
+	}
+
+	switch a.fn {
+	case "SUM", "AVG":
+		if colType == COLUMN_TYPE_DECIMAL {
+			return sumDecimals(a, values)
+		}
+		var intSum int64
+		var floatSum float64
+		isFloat := false
+		for _, val := range values {
+			switch v := val.(type) {
+			case int64:
+				intSum += v
+				floatSum += float64(v)
+			case float32:
+				floatSum += float64(v)
+				isFloat = true
+			case float64:
+				floatSum += v
+				isFloat = true
+			default:
+				return nil, fmt.Errorf("%s requires a numeric column, %s holds %v", a.fn, a.arg, val)
+			}
+		}
+		if a.fn == "AVG" {
+			return floatSum / float64(len(values)), nil
+		}
+		if isFloat {
+			return floatSum, nil
+		}
+		return intSum, nil
+	default: // MIN, MAX
+		result := values[0]
+		for _, val := range values[1:] {
+			order := compareTyped(colType, val, result)
+			if (a.fn == "MIN" && order < 0) || (a.fn == "MAX" && order > 0) {
+				result = val
+			}
+		}
+		return result, nil
+	}
+}
+
+// sumDecimals adds DECIMAL values exactly. SUM is a decimal of the column
+// scale, AVG the nearest float64 of the exact sum divided by the count.
+func sumDecimals(a aggregate, values []any) (any, error) {
+	var sum Decimal
+	for _, val := range values {
+		d, ok := val.(Decimal)
+		if !ok {
+			return nil, fmt.Errorf("%s requires a numeric column, %s holds %v", a.fn, a.arg, val)
+		}
+		var err error
+		if sum, err = sum.add(d); err != nil {
+			return nil, err
+		}
+	}
+	if a.fn == "AVG" {
+		avg, _ := sum.rat().Quo(sum.rat(), new(big.Rat).SetInt64(int64(len(values)))).Float64()
+		return avg, nil
+	}
+	return sum, nil
+}
+
+// countDistinct returns the number of different values. Numbers are equal
+// by value whatever their type, so 1 and 1.0 count once.
+func countDistinct(values []any) int {
+	seen := make(map[any]bool)
+	for _, val := range values {
+		if f, ok := toFloat64(val); ok {
+			val = f
+		}
+		seen[val] = true
+	}
+	return len(seen)
+}
+
+// validate checks that the column of the aggregate belongs to one of the
+// tables and, for SUM and AVG, that it is numeric
+func (a aggregate) validate(tables []*Table) error {
+	if a.arg == "*" {
+		return nil
+	}
+	column, err := findColumn(tables, a.arg)
+	if err != nil {
+		return err
+	}
+	if a.fn == "SUM" || a.fn == "AVG" {
+		switch column.Type {
+		case COLUMN_TYPE_INT, COLUMN_TYPE_DOUBLE, COLUMN_TYPE_FLOAT, COLUMN_TYPE_DECIMAL:
+		default:
+			return fmt.Errorf("%s requires a numeric column, %s is %s", a.fn, a.arg, column.Type)
+		}
+	}
+	return nil
+}
+
+// column describes the result of the aggregate
+func (a aggregate) column(tables []*Table) Column {
+	column := Column{Name: a.name(), Type: COLUMN_TYPE_INT}
+	if a.fn == "COUNT" {
+		return column
+	}
+	arg, _ := findColumn(tables, a.arg)
+	switch {
+	case a.fn == "MIN", a.fn == "MAX", a.fn == "SUM" && arg.Type == COLUMN_TYPE_DECIMAL:
+		column.Type = arg.Type
+		column.Scale = arg.Scale
+	case a.fn == "AVG", a.fn == "SUM" && arg.Type != COLUMN_TYPE_INT:
+		column.Type = COLUMN_TYPE_DOUBLE
+	}
+	return column
+}
+
+// aggregateColumns describes the results of the aggregates
+func aggregateColumns(aggregates []aggregate, tables []*Table) []Column {
+	columns := make([]Column, len(aggregates))
+	for i, agg := range aggregates {
+		columns[i] = agg.column(tables)
+	}
+	return columns
+}
+
+// aggregateRows computes the aggregates over the rows matched in the tables
+// and returns the single result row
+func aggregateRows(aggregates []aggregate, tables []*Table, rows []Row) (Row, error) {
+	result := make(Row)
+	for _, agg := range aggregates {
+		if err := agg.validate(tables); err != nil {
+			return nil, err
+		}
+		val, err := agg.compute(rows, agg.argType(tables))
+		if err != nil {
+			return nil, err
+		}
+		result[agg.name()] = val
+	}
+	return result, nil
+}
+
+// Count returns the number of rows of the table matching the WHERE clause,
+// or of all rows when it is empty. Subqueries in the clause can only read
+// the table itself.
+func (t *Table) Count(whereClause string) (int, error) {
+	db := &Database{Tables: map[string]*Table{t.Name: t}}
+	return db.countRows(t, whereClause, time.Now())
+}
+
+// countRows counts the rows of a table matching the WHERE clause without
+// copying or projecting them, for SELECT COUNT(*) without a JOIN
+func (db *Database) countRows(table *Table, whereClause string, now time.Time) (int, error) {
+	whereClause, err := db.resolveInSubqueries(whereClause, now)
+	if err != nil {
+		return 0, err
+	}
+	if err := checkWhereColumns([]*Table{table}, whereClause); err != nil {
+		return 0, err
+	}
+	if whereClause == "" {
+		return len(table.Rows), nil
+	}
+	qualified := mentionsTable(table.Name, whereClause)
+	types := typesOf(table)
+	count := 0
+	for _, i := range table.candidates(whereClause, now) {
+		row := table.Rows[i]
+		if qualified {
+			row = qualifiedRow(table.Name, row)
+		}
+		matched, err := db.evaluateWhere(row, whereClause, types, now)
+		if err != nil {
+			return 0, err
+		}
+		if matched {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// matchedRows returns the rows of a SELECT that satisfy its WHERE clause,
+// before any projection, along with the tables they come from. Rows hold the
+// columns of their tables, also under their table.column names.
+func (db *Database) matchedRows(mainTable *Table, whereClause string, joinClause string, now time.Time) ([]Row, []*Table, error) {
+	var rows []Row
+	if joinClause == "" {
+		if err := checkWhereColumns([]*Table{mainTable}, whereClause); err != nil {
+			return nil, nil, err
+		}
+		types := typesOf(mainTable)
+		for _, i := range mainTable.candidates(whereClause, now) {
+			row := qualifiedRow(mainTable.Name, mainTable.Rows[i])
+			matched, err := db.evaluateWhere(row, whereClause, types, now)
+			if err != nil {
+				return nil, nil, err
+			}
+			if matched {
+				rows = append(rows, row)
+			}
+		}
+		return rows, []*Table{mainTable}, nil
+	}
+
+	joinTableRef, joinCondition, kind, err := parseJoinClause(joinClause)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid join clause: %v", err)
+	}
+	joinTable, err := db.tableRef(joinTableRef)
+	if err != nil {
+		return nil, nil, fmt.Errorf("join %v", err)
+	}
+	on, err := parseJoinOn(kind, joinCondition, mainTable, joinTable)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := checkAmbiguous([]*Table{mainTable, joinTable}, whereClause); err != nil {
+		return nil, nil, err
+	}
+	if err := checkWhereColumns([]*Table{mainTable, joinTable}, whereClause); err != nil {
+		return nil, nil, err
+	}
+	types := typesOf(mainTable, joinTable)
+	addJoined := func(mainRow Row, joinRow Row) error {
+		combinedRow := joinedRow(mainTable.Name, mainRow, joinTable.Name, joinRow)
+		matched, err := db.evaluateWhere(combinedRow, whereClause, types, now)
+		if err != nil || !matched {
+			return err
+		}
+		rows = append(rows, combinedRow)
+		return nil
+	}
+	if err := db.joinPairs(mainTable, joinTable, on, kind, addJoined, now); err != nil {
+		return nil, nil, err
+	}
+	return rows, []*Table{mainTable, joinTable}, nil
+}
@@ -0,0 +1,98 @@
+package database
+
+import (
+	"fmt"
+	"regexp"
+)
+
+var (
+	renameTableRegex  = regexp.MustCompile(`(?i)^ALTER\s+TABLE\s+(\w+)\s+RENAME\s+TO\s+(\w+)\s*$`)
+	renameColumnRegex = regexp.MustCompile(`(?i)^ALTER\s+TABLE\s+(\w+)\s+RENAME\s+COLUMN\s+(\w+)\s+TO\s+(\w+)\s*$`)
+)
+
+// RenameTable gives a table a new name, foreign keys of other tables that
+// reference it follow the rename
+func (db *Database) RenameTable(oldName string, newName string) (string, error) {
+	if err := db.lock(); err != nil {
+		return "", err
+	}
+	defer db.mu.Unlock()
+	table, err := db.getTable(oldName)
+	if err != nil {
+		return "", err
+	}
+	if db.tableExists(newName) {
+		return "", fmt.Errorf("table %s already exists", newName)
+	}
+	delete(db.Tables, oldName)
+	table.Name = newName
+	db.Tables[newName] = table
+
+	for _, other := range db.Tables {
+		for i, column := range other.Columns {
+			if column.ReferenceTable != oldName {
+				continue
+			}
+			other.Columns[i].ReferenceTable = newName
+			other.ForeignKeys[column.Name] = newName + "." + column.ReferenceColumn
+		}
+	}
+	if err := db.save(); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("Table %s renamed to %s", oldName, newName), nil
+}
+
+// RenameColumn gives a column a new name in its definition, in every row and
+// in the primary and foreign keys that name it
+func (db *Database) RenameColumn(tableName string, oldName string, newName string) (string, error) {
+	if err := db.lock(); err != nil {
+		return "", err
+	}
+	defer db.mu.Unlock()
+	table, err := db.getTable(tableName)
+	if err != nil {
+		return "", err
+	}
+	i := table.columnIndex(oldName)
+	if i < 0 {
+		return "", fmt.Errorf("column %s does not exist in table %s", oldName, tableName)
+	}
+	if table.columnExists(newName) {
+		return "", fmt.Errorf("column %s already exists in table %s", newName, tableName)
+	}
+
+	table.Columns[i].Name = newName
+	for _, row := range table.Rows {
+		if val, exists := row[oldName]; exists {
+			row[newName] = val
+			delete(row, oldName)
+		}
+	}
+	if table.PrimaryKey == oldName {
+		table.PrimaryKey = newName
+	}
+	for _, index := range table.Indexes {
+		if index.Column == oldName {
+			index.Column = newName
+		}
+	}
+	table.reindex()
+	if ref, exists := table.ForeignKeys[oldName]; exists {
+		table.ForeignKeys[newName] = ref
+		delete(table.ForeignKeys, oldName)
+	}
+	for _, other := range db.Tables {
+		for j, column := range other.Columns {
+			if column.ReferenceTable != tableName || column.ReferenceColumn != oldName {
+				continue
+			}
+			other.Columns[j].ReferenceColumn = newName
+			other.ForeignKeys[column.Name] = tableName + "." + newName
+		}
+	}
+	if err := db.save(); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("Column %s renamed to %s in table %s", oldName, newName, tableName), nil
+}
@@ -0,0 +1,329 @@
+package database
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// arithCache holds parsed arithmetic expressions, keyed by their source, so
+// a WHERE clause or a projection is parsed once rather than for every row
+var arithCache = newParseCache[string, arithExpr]()
+
+// arithExpr is an arithmetic expression over the columns of a row, built from
+// column names, numeric literals, parentheses and + - * /
+type arithExpr interface {
+	// eval returns the value of the expression, an int64 when its operands
+	// are integers and it divides nothing, otherwise a float64. ok is false
+	// when a column it reads has no value in the row.
+	eval(row Row) (val any, ok bool, err error)
+}
+
+type integerLiteral int64
+
+type numberLiteral float64
+
+type columnRef string
+
+type negation struct {
+	operand arithExpr
+}
+
+type binaryOp struct {
+	op          string
+	left, right arithExpr
+}
+
+// placeholder is a ? of a prepared statement, numbered from 0 within the
+// expression. bindArith replaces it with its argument.
+type placeholder int
+
+// nullLiteral is a NULL argument, which makes the expression null
+type nullLiteral struct{}
+
+func (n integerLiteral) eval(Row) (any, bool, error) {
+	return int64(n), true, nil
+}
+
+func (n numberLiteral) eval(Row) (any, bool, error) {
+	return float64(n), true, nil
+}
+
+func (c columnRef) eval(row Row) (any, bool, error) {
+	val, exists := row[string(c)]
+	if !exists || val == nil {
+		return nil, false, nil
+	}
+	if num, ok := val.(int64); ok {
+		return num, true, nil
+	}
+	num, ok := toFloat64(val)
+	if !ok {
+		return nil, false, fmt.Errorf("column %s is not numeric and cannot be used in arithmetic", c)
+	}
+	return num, true, nil
+}
+
+func (p placeholder) eval(Row) (any, bool, error) {
+	return nil, false, fmt.Errorf("no argument is bound to placeholder %d", int(p)+1)
+}
+
+func (nullLiteral) eval(Row) (any, bool, error) {
+	return nil, false, nil
+}
+
+func (n negation) eval(row Row) (any, bool, error) {
+	val, ok, err := n.operand.eval(row)
+	if !ok || err != nil {
+		return nil, ok, err
+	}
+	if num, isInt := val.(int64); isInt {
+		return -num, true, nil
+	}
+	return -val.(float64), true, nil
+}
+
+func (b binaryOp) eval(row Row) (any, bool, error) {
+	left, ok, err := b.left.eval(row)
+	if !ok || err != nil {
+		return nil, ok, err
+	}
+	right, ok, err := b.right.eval(row)
+	if !ok || err != nil {
+		return nil, ok, err
+	}
+	leftInt, leftIsInt := left.(int64)
+	rightInt, rightIsInt := right.(int64)
+	if leftIsInt && rightIsInt {
+		switch b.op {
+		case "+":
+			return leftInt + rightInt, true, nil
+		case "-":
+			return leftInt - rightInt, true, nil
+		case "*":
+			return leftInt * rightInt, true, nil
+		}
+	}
+	leftNum, _ := toFloat64(left)
+	rightNum, _ := toFloat64(right)
+	switch b.op {
+	case "+":
+		return leftNum + rightNum, true, nil
+	case "-":
+		return leftNum - rightNum, true, nil
+	case "*":
+		return leftNum * rightNum, true, nil
+	default:
+		if rightNum == 0 {
+			return nil, false, fmt.Errorf("division by zero")
+		}
+		return leftNum / rightNum, true, nil
+	}
+}
+
+// arithType returns the type of the values of an expression over the
+// columns of the tables, INT or DOUBLE, and checks that every column it
+// reads exists and is numeric
+func arithType(expr arithExpr, tables []*Table) (ColumnType, error) {
+	switch e := expr.(type) {
+	case integerLiteral:
+		return COLUMN_TYPE_INT, nil
+	case numberLiteral:
+		return COLUMN_TYPE_DOUBLE, nil
+	case columnRef:
+		column, err := findColumn(tables, string(e))
+		if err != nil {
+			return "", err
+		}
+		switch column.Type {
+		case COLUMN_TYPE_INT:
+			return COLUMN_TYPE_INT, nil
+		case COLUMN_TYPE_DOUBLE, COLUMN_TYPE_FLOAT, COLUMN_TYPE_DECIMAL:
+			return COLUMN_TYPE_DOUBLE, nil
+		default:
+			return "", fmt.Errorf("column %s is not numeric and cannot be used in arithmetic", e)
+		}
+	case nullLiteral:
+		// NULL fits a column of any numeric type
+		return COLUMN_TYPE_INT, nil
+	case placeholder:
+		return "", fmt.Errorf("no argument is bound to placeholder %d", int(e)+1)
+	case negation:
+		return arithType(e.operand, tables)
+	case binaryOp:
+		left, err := arithType(e.left, tables)
+		if err != nil {
+			return "", err
+		}
+		right, err := arithType(e.right, tables)
+		if err != nil {
+			return "", err
+		}
+		if e.op == "/" || left != COLUMN_TYPE_INT || right != COLUMN_TYPE_INT {
+			return COLUMN_TYPE_DOUBLE, nil
+		}
+		return COLUMN_TYPE_INT, nil
+	}
+	return "", fmt.Errorf("invalid expression")
+}
+
+// bindArith returns expr with its placeholders replaced by args, numbers or
+// text holding a number
+func bindArith(expr arithExpr, args []any) (arithExpr, error) {
+	switch e := expr.(type) {
+	case placeholder:
+		switch arg := normalizeValue(args[e]).(type) {
+		case nil:
+			return nullLiteral{}, nil
+		case int64:
+			return integerLiteral(arg), nil
+		case string:
+			if num, err := strconv.ParseInt(arg, 10, 64); err == nil {
+				return integerLiteral(num), nil
+			}
+			if num, err := strconv.ParseFloat(arg, 64); err == nil {
+				return numberLiteral(num), nil
+			}
+		default:
+			if num, ok := toFloat64(arg); ok {
+				return numberLiteral(num), nil
+			}
+		}
+		return nil, fmt.Errorf("cannot use %T value %v in arithmetic", args[e], args[e])
+	case negation:
+		operand, err := bindArith(e.operand, args)
+		if err != nil {
+			return nil, err
+		}
+		return negation{operand}, nil
+	case binaryOp:
+		left, err := bindArith(e.left, args)
+		if err != nil {
+			return nil, err
+		}
+		right, err := bindArith(e.right, args)
+		if err != nil {
+			return nil, err
+		}
+		return binaryOp{e.op, left, right}, nil
+	}
+	return expr, nil
+}
+
+// parseArithmetic parses an arithmetic expression, * and / bind tighter than + and -
+func parseArithmetic(src string) (arithExpr, error) {
+	if expr, ok := arithCache.Load(src); ok {
+		return expr, nil
+	}
+	tokens, err := tokenize(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &arithParser{tokens: tokens}
+	expr, err := p.parseSum()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != tokenEOF {
+		return nil, fmt.Errorf("invalid expression %q: unexpected %q", src, tok.text)
+	}
+	arithCache.Store(src, expr)
+	return expr, nil
+}
+
+type arithParser struct {
+	tokens []token
+	pos    int
+	params int // placeholders read so far
+}
+
+func (p *arithParser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *arithParser) next() token {
+	tok := p.tokens[p.pos]
+	if tok.kind != tokenEOF {
+		p.pos++
+	}
+	return tok
+}
+
+func (p *arithParser) parseSum() (arithExpr, error) {
+	left, err := p.parseProduct()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().is("+") || p.peek().is("-") {
+		op := p.next().text
+		right, err := p.parseProduct()
+		if err != nil {
+			return nil, err
+		}
+		left = binaryOp{op, left, right}
+	}
+	return left, nil
+}
+
+func (p *arithParser) parseProduct() (arithExpr, error) {
+	left, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().is("*") || p.peek().is("/") {
+		op := p.next().text
+		right, err := p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		left = binaryOp{op, left, right}
+	}
+	return left, nil
+}
+
+func (p *arithParser) parseOperand() (arithExpr, error) {
+	tok := p.next()
+	switch {
+	case tok.is("-"):
+		operand, err := p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		return negation{operand}, nil
+	case tok.is("("):
+		expr, err := p.parseSum()
+		if err != nil {
+			return nil, err
+		}
+		if !p.next().is(")") {
+			return nil, fmt.Errorf("invalid expression: missing closing parenthesis")
+		}
+		return expr, nil
+	case tok.is("?"):
+		p.params++
+		return placeholder(p.params - 1), nil
+	case tok.kind == tokenNumber:
+		if num, err := strconv.ParseInt(tok.text, 10, 64); err == nil {
+			return integerLiteral(num), nil
+		}
+		num, err := strconv.ParseFloat(tok.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %s", tok.text)
+		}
+		return numberLiteral(num), nil
+	case tok.kind == tokenIdent:
+		name := tok.text
+		if p.peek().is(".") {
+			p.next()
+			col := p.next()
+			if col.kind != tokenIdent {
+				return nil, fmt.Errorf("invalid column name %s.%s", name, col.text)
+			}
+			name += "." + col.text
+		}
+		return columnRef(name), nil
+	case tok.kind == tokenEOF:
+		return nil, fmt.Errorf("invalid expression: missing operand at end")
+	default:
+		return nil, fmt.Errorf("invalid expression: unexpected %q", tok.text)
+	}
+}
@@ -0,0 +1,59 @@
+package database
+
+import (
+	"container/list"
+	"sync"
+)
+
+// parseCacheSize is the number of entries a parseCache keeps
+const parseCacheSize = 256
+
+// parseCache holds the parsed form of recently used source text, such as a
+// WHERE clause, so that a statement parses it once rather than for every
+// row. Only the most recently used entries are kept, so a process that sees
+// ever new statements does not grow without bound.
+type parseCache[K comparable, V any] struct {
+	mu      sync.Mutex
+	order   *list.List // of cacheEntry, most recently used first
+	entries map[K]*list.Element
+}
+
+type cacheEntry[K comparable, V any] struct {
+	key K
+	val V
+}
+
+func newParseCache[K comparable, V any]() *parseCache[K, V] {
+	return &parseCache[K, V]{order: list.New(), entries: make(map[K]*list.Element)}
+}
+
+// Load returns the value stored for key, and whether there is one
+func (c *parseCache[K, V]) Load(key K) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	elem, ok := c.entries[key]
+	if !ok {
+		var zero V
+		return zero, false
+	}
+	c.order.MoveToFront(elem)
+	return elem.Value.(cacheEntry[K, V]).val, true
+}
+
+// Store sets the value for key, dropping the least recently used entry once
+// the cache is full
+func (c *parseCache[K, V]) Store(key K, val V) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.entries[key]; ok {
+		elem.Value = cacheEntry[K, V]{key, val}
+		c.order.MoveToFront(elem)
+		return
+	}
+	c.entries[key] = c.order.PushFront(cacheEntry[K, V]{key, val})
+	if c.order.Len() > parseCacheSize {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(cacheEntry[K, V]).key)
+	}
+}
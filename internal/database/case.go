@@ -0,0 +1,173 @@
+package database
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// caseCache holds parsed CASE expressions, keyed by their source, so a
+// projection is parsed once rather than for every row
+var caseCache = newParseCache[string, *caseExpr]()
+
+// caseExpr is a CASE WHEN expression in a SELECT column list. The first
+// branch whose condition holds gives the value, without a match the ELSE
+// value, or null when there is no ELSE.
+type caseExpr struct {
+	name      string // key of the value in result rows
+	branches  []caseBranch
+	otherwise *exprValue
+}
+
+type caseBranch struct {
+	condition string // a WHERE condition
+	result    exprValue
+}
+
+// isCase reports whether a projected column is a CASE expression
+func isCase(col string) bool {
+	tokens, err := tokenize(col)
+	return err == nil && tokens[0].is("CASE")
+}
+
+// parseCase parses CASE WHEN cond THEN result [WHEN ...] [ELSE result] END,
+// optionally followed by an AS alias. Without an alias the result is named
+// after the expression.
+func parseCase(src string) (*caseExpr, error) {
+	if expr, ok := caseCache.Load(src); ok {
+		return expr, nil
+	}
+	tokens, err := tokenize(src)
+	if err != nil {
+		return nil, err
+	}
+
+	expr := &caseExpr{}
+	keyword, start, depth := "CASE", tokens[0].pos+len(tokens[0].text), 0
+	var condition string
+	end := -1
+	for i := 1; end < 0; i++ {
+		t := tokens[i]
+		switch {
+		case t.kind == tokenEOF:
+			return nil, fmt.Errorf("CASE expression is missing END: %s", src)
+		case t.is("("):
+			depth++
+			continue
+		case t.is(")"):
+			depth--
+			continue
+		case depth > 0:
+			continue
+		case t.is("CASE"):
+			return nil, fmt.Errorf("nested CASE expressions are not supported")
+		case !t.is("WHEN") && !t.is("THEN") && !t.is("ELSE") && !t.is("END"):
+			continue
+		}
+
+		next := strings.ToUpper(t.text)
+		part := strings.TrimSpace(src[start:t.pos])
+		if part == "" && keyword != "CASE" {
+			return nil, fmt.Errorf("missing expression after %s in CASE", keyword)
+		}
+		switch {
+		case keyword == "CASE" && next == "WHEN" && part == "":
+		case keyword == "WHEN" && next == "THEN":
+			condition = part
+		case keyword == "THEN" && (next == "WHEN" || next == "ELSE" || next == "END"):
+			result, err := parseExprValue(part)
+			if err != nil {
+				return nil, err
+			}
+			expr.branches = append(expr.branches, caseBranch{condition, result})
+		case keyword == "ELSE" && next == "END":
+			result, err := parseExprValue(part)
+			if err != nil {
+				return nil, err
+			}
+			expr.otherwise = &result
+		default:
+			return nil, fmt.Errorf("unexpected %s in CASE expression: %s", next, src)
+		}
+		keyword, start = next, t.pos+len(t.text)
+		if next == "END" {
+			end = i
+		}
+	}
+
+	// The alias, with or without AS
+	rest := tokens[end+1:]
+	if rest[0].is("AS") {
+		rest = rest[1:]
+	}
+	switch {
+	case rest[0].kind == tokenEOF:
+		expr.name = strings.Join(strings.Fields(src[:start]), " ")
+	case rest[0].kind == tokenIdent && rest[1].kind == tokenEOF:
+		expr.name = rest[0].text
+	default:
+		return nil, fmt.Errorf("unexpected %q after CASE expression", rest[0].text)
+	}
+	caseCache.Store(src, expr)
+	return expr, nil
+}
+
+// evaluateCase returns the value of a CASE expression for a row in a
+// statement started at now
+func (db *Database) evaluateCase(row Row, expr *caseExpr, now time.Time) (any, error) {
+	for _, branch := range expr.branches {
+		matched, err := db.evaluateWhere(row, branch.condition, nil, now)
+		if err != nil {
+			return nil, err
+		}
+		if matched {
+			return branch.result.value(row, now)
+		}
+	}
+	if expr.otherwise == nil {
+		return nil, nil
+	}
+	return expr.otherwise.value(row, now)
+}
+
+// column returns the column a CASE expression produces, typed after the
+// first of its results that is not NULL. The columns its conditions and
+// results read are checked against the tables.
+func (expr *caseExpr) column(tables []*Table) (Column, error) {
+	column := Column{Name: expr.name}
+	results := make([]exprValue, 0, len(expr.branches)+1)
+	for _, branch := range expr.branches {
+		if err := checkWhereColumns(tables, branch.condition); err != nil {
+			return Column{}, err
+		}
+		results = append(results, branch.result)
+	}
+	if expr.otherwise != nil {
+		results = append(results, *expr.otherwise)
+	}
+	for _, result := range results {
+		colType, err := result.columnType(tables)
+		if err != nil {
+			return Column{}, err
+		}
+		if column.Type == "" {
+			column.Type = colType
+		}
+	}
+	return column, nil
+}
+
+// projectCase evaluates the CASE expression col for a row and stores the
+// value in the result row
+func (db *Database) projectCase(resultRow Row, row Row, col string, now time.Time) error {
+	expr, err := parseCase(col)
+	if err != nil {
+		return err
+	}
+	val, err := db.evaluateCase(row, expr, now)
+	if err != nil {
+		return err
+	}
+	resultRow[expr.name] = val
+	return nil
+}
@@ -3,7 +3,10 @@ package database
 import (
 	"fmt"
 	"slices"
+	"strconv"
 	"strings"
+	"time"
+	"unicode/utf8"
 )
 
 type ColumnType string
@@ -16,6 +19,10 @@ const (
 	COLUMN_TYPE_BOOL    ColumnType = "BOOL"
 	COLUMN_TYPE_DATE    ColumnType = "DATE"
 	COLUMN_TYPE_ENUM    ColumnType = "ENUM"
+	COLUMN_TYPE_DECIMAL ColumnType = "DECIMAL"
+	// TIMESTAMP holds a date and a time of day, stored as text in the
+	// timestampLayout format as DATE is in the dateLayout one
+	COLUMN_TYPE_TIMESTAMP ColumnType = "TIMESTAMP"
 )
 
 type ColumnConstraint string
@@ -29,6 +36,15 @@ const (
 	COLUMN_CONSTRAINT_AUTO_INCREMENT ColumnConstraint = "AUTO_INCREMENT"
 )
 
+// ForeignKeyAction is what deleting a referenced row does to the rows that
+// reference it
+type ForeignKeyAction string
+
+const (
+	FOREIGN_KEY_RESTRICT ForeignKeyAction = "RESTRICT"
+	FOREIGN_KEY_CASCADE  ForeignKeyAction = "CASCADE"
+)
+
 // Column represents a table column
 type Column struct {
 	Name            string
@@ -36,6 +52,20 @@ type Column struct {
 	Constraints     []ColumnConstraint
 	ReferenceTable  string
 	ReferenceColumn string
+	// OnDelete applies when a referenced row is deleted, empty means RESTRICT
+	OnDelete ForeignKeyAction
+	// Default is the value an INSERT that leaves the column out stores, nil
+	// when there is none
+	Default any
+	// EnumValues are the values an ENUM column accepts, an ENUM declared
+	// without them accepts any string
+	EnumValues []string
+	// MaxLength is the most characters a VARCHAR(n) column holds, 0 for no limit
+	MaxLength int
+	// Precision and Scale are the total digits and the digits after the point
+	// of a DECIMAL(p,s) column
+	Precision int
+	Scale     int
 }
 
 func (c *Column) String() string {
@@ -43,6 +73,14 @@ func (c *Column) String() string {
 }
 
 func (c *Column) parseColumnDef(columnDef string) error {
+	columnDef, defaultValue, err := cutDefault(columnDef)
+	if err != nil {
+		return err
+	}
+	columnDef, typeArgs, err := cutTypeArgs(columnDef)
+	if err != nil {
+		return err
+	}
 	parts := strings.Fields(strings.TrimSpace(columnDef))
 	if len(parts) < 2 {
 		return fmt.Errorf("invalid column definition")
@@ -50,18 +88,174 @@ func (c *Column) parseColumnDef(columnDef string) error {
 
 	colName := parts[0]
 	colType := ColumnType(strings.ToUpper(parts[1]))
+	switch colType {
+	case "NUMERIC":
+		colType = COLUMN_TYPE_DECIMAL
+	case "DATETIME":
+		colType = COLUMN_TYPE_TIMESTAMP
+	}
 	if !isValidColumnType(ColumnType(colType)) {
 		return fmt.Errorf("invalid column type")
 	}
 
+	switch {
+	case colType == COLUMN_TYPE_DECIMAL && typeArgs == nil:
+		c.Precision, c.Scale = 10, 0
+	case colType == COLUMN_TYPE_DECIMAL:
+		if err := c.parseDecimalArgs(typeArgs); err != nil {
+			return err
+		}
+	case typeArgs == nil:
+	case colType == COLUMN_TYPE_ENUM:
+		if err := c.parseEnumValues(typeArgs); err != nil {
+			return err
+		}
+	case colType == COLUMN_TYPE_VARCHAR:
+		length, err := strconv.Atoi(strings.TrimSpace(strings.Join(typeArgs, ",")))
+		if err != nil || length <= 0 {
+			return fmt.Errorf("VARCHAR length must be a positive integer")
+		}
+		c.MaxLength = length
+	default:
+		return fmt.Errorf("type %s takes no arguments", colType)
+	}
 	if err := c.parseConstraints(parts[2:]); err != nil {
 		return err
 	}
 	c.Name = colName
 	c.Type = colType
+	if defaultValue != "" {
+		val, err := columnTypeConversion(colType, defaultValue, time.Now())
+		if err == nil {
+			val, err = c.checkValue(val)
+		}
+		if err != nil {
+			return fmt.Errorf("invalid default %s: %v", defaultValue, err)
+		}
+		c.Default = normalizeValue(val)
+	}
+	return nil
+}
+
+// cutTypeArgs removes the parenthesized arguments that follow the type of a
+// column definition, such as the values of an ENUM, and returns them split at
+// their commas. The arguments are nil when the type has none.
+func cutTypeArgs(columnDef string) (string, []string, error) {
+	tokens, err := tokenize(columnDef)
+	if err != nil {
+		return "", nil, err
+	}
+	if len(tokens) < 3 || !tokens[2].is("(") {
+		return columnDef, nil, nil
+	}
+	open := tokens[2].pos
+	for i, depth := 2, 0; tokens[i].kind != tokenEOF; i++ {
+		if tokens[i].is("(") {
+			depth++
+		} else if tokens[i].is(")") {
+			if depth--; depth == 0 {
+				close := tokens[i].pos
+				args := []string{}
+				if strings.TrimSpace(columnDef[open+1:close]) != "" {
+					args = splitList(columnDef[open+1 : close])
+				}
+				return columnDef[:open] + " " + columnDef[close+1:], args, nil
+			}
+		}
+	}
+	return "", nil, fmt.Errorf("unclosed parenthesis after type %s", tokens[1].text)
+}
+
+// parseEnumValues sets the values an ENUM accepts from its quoted arguments
+func (c *Column) parseEnumValues(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("ENUM requires at least one value")
+	}
+	for _, arg := range args {
+		arg = strings.TrimSpace(arg)
+		tokens, err := tokenize(arg)
+		if err != nil {
+			return err
+		}
+		if tokens[0].kind != tokenString || tokens[1].kind != tokenEOF {
+			return fmt.Errorf("ENUM values must be quoted strings, got %s", arg)
+		}
+		val := unquote(arg)
+		if slices.ContainsFunc(c.EnumValues, func(member string) bool { return strings.EqualFold(member, val) }) {
+			return fmt.Errorf("duplicate ENUM value %s", arg)
+		}
+		c.EnumValues = append(c.EnumValues, val)
+	}
 	return nil
 }
 
+// checkValue checks a value against the limits of the column type: the
+// values of an ENUM, which match ignoring case and are returned spelled as
+// declared, the length of a VARCHAR(n) and the digits of a DECIMAL(p,s),
+// which is returned rounded to its scale
+func (c *Column) checkValue(val any) (any, error) {
+	if d, ok := val.(Decimal); ok && c.Type == COLUMN_TYPE_DECIMAL {
+		d, err := d.rescale(c.Scale)
+		if err == nil && d.digits() > c.Precision {
+			err = fmt.Errorf("value %s does not fit column %s DECIMAL(%d,%d)", d, c.Name, c.Precision, c.Scale)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return d, nil
+	}
+	text, ok := val.(string)
+	if !ok {
+		return val, nil
+	}
+	switch {
+	case c.Type == COLUMN_TYPE_ENUM && len(c.EnumValues) > 0:
+		for _, member := range c.EnumValues {
+			if strings.EqualFold(member, text) {
+				return member, nil
+			}
+		}
+		return nil, fmt.Errorf("value %q is not allowed for column %s, expected one of '%s'", text, c.Name, strings.Join(c.EnumValues, "', '"))
+	case c.Type == COLUMN_TYPE_VARCHAR && c.MaxLength > 0:
+		if length := utf8.RuneCountInString(text); length > c.MaxLength {
+			return nil, fmt.Errorf("value for column %s is %d characters long, the limit is %d", c.Name, length, c.MaxLength)
+		}
+	}
+	return val, nil
+}
+
+// cutDefault removes the DEFAULT clause from a column definition and returns
+// its literal, which may be a quoted string holding spaces
+func cutDefault(columnDef string) (string, string, error) {
+	tokens, err := tokenize(columnDef)
+	if err != nil {
+		return "", "", err
+	}
+	for i, t := range tokens {
+		if !t.is("DEFAULT") {
+			continue
+		}
+		j := i + 1
+		if tokens[j].is("-") {
+			j++
+		}
+		if tokens[j].kind == tokenEOF || tokens[j].kind == tokenSymbol {
+			return "", "", fmt.Errorf("DEFAULT requires a value")
+		}
+		end := tokens[j].pos + len(tokens[j].text)
+		if tokens[j].kind == tokenString {
+			// The token holds the value without its quotes
+			end = tokens[j+1].pos
+		}
+		if tokens[j+1].is("(") && tokens[j+2].is(")") {
+			end = tokens[j+2].pos + 1
+		}
+		literal := strings.TrimSpace(columnDef[tokens[i+1].pos:end])
+		return columnDef[:t.pos] + columnDef[end:], literal, nil
+	}
+	return columnDef, "", nil
+}
+
 func (c *Column) parseConstraints(parts []string) error {
 	for i := 0; i < len(parts); i++ {
 		constraint := strings.ToUpper(parts[i])
@@ -88,6 +282,19 @@ func (c *Column) parseConstraints(parts []string) error {
 			c.ReferenceTable = ref[:open]
 			c.ReferenceColumn = ref[open+1 : close]
 			i += 3
+
+			if i+2 < len(parts) && strings.ToUpper(parts[i+1]) == "ON" && strings.ToUpper(parts[i+2]) == "DELETE" {
+				if i+3 >= len(parts) {
+					return fmt.Errorf("ON DELETE requires CASCADE or RESTRICT")
+				}
+				switch action := ForeignKeyAction(strings.ToUpper(parts[i+3])); action {
+				case FOREIGN_KEY_CASCADE, FOREIGN_KEY_RESTRICT:
+					c.OnDelete = action
+				default:
+					return fmt.Errorf("invalid ON DELETE action: %s", parts[i+3])
+				}
+				i += 3
+			}
 		default:
 			if !isValidColumnConstraint(ColumnConstraint(constraint)) {
 				return fmt.Errorf("invalid constraint: %s", constraint)
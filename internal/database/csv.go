@@ -0,0 +1,132 @@
+package database
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+)
+
+var (
+	// selectFormatRegex matches a SELECT ending in FORMAT CSV or FORMAT JSON
+	selectFormatRegex = regexp.MustCompile(`(?i)^(SELECT\s+.+?)\s+FORMAT\s+(CSV|JSON)\s*$`)
+	importRegex       = regexp.MustCompile(`(?i)^IMPORT\s+INTO\s+(\w+)\s+FROM\s+('(?:[^']|'')*')\s*$`)
+)
+
+// WriteCSV writes rows as CSV, with a header row naming the columns and the
+// values in the same order. Values are written as Row.String prints them and
+// nulls as empty fields. Fields holding commas, quotes or line breaks are
+// quoted as RFC 4180 describes.
+func WriteCSV(w io.Writer, rows []Row, columns []Column) error {
+	writer := csv.NewWriter(w)
+	record := make([]string, len(columns))
+	for i, column := range columns {
+		record[i] = column.Name
+	}
+	if err := writer.Write(record); err != nil {
+		return err
+	}
+	for _, row := range rows {
+		for i, column := range columns {
+			record[i] = ""
+			if val := row[column.Name]; val != nil {
+				record[i] = formatValue(val)
+			}
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// selectCSV runs a SELECT and returns its result as CSV. Unlike JSON output,
+// a SELECT matching no rows gives the header row alone.
+func (db *Database) selectCSV(stmt *selectStatement) (string, error) {
+	if err := db.rlock(); err != nil {
+		return "", err
+	}
+	defer db.mu.RUnlock()
+	now := time.Now()
+	rows, columns, err := db.selectRows(stmt.table, stmt.columns, stmt.where, stmt.join, stmt.groupBy, stmt.orderBy, stmt.limit, stmt.offset, now)
+	if err != nil {
+		return "", err
+	}
+	var out strings.Builder
+	if err := WriteCSV(&out, rows, columns); err != nil {
+		return "", err
+	}
+	return out.String(), nil
+}
+
+// Import adds the rows of a CSV file to a table. The header row names the
+// columns of the table the fields go to, in any order, and an empty field
+// leaves its column unset. The rows are added with a single save, and a row
+// that cannot be read, converted or added stops the import before any row
+// is kept, with an error giving its line in the file.
+func (db *Database) Import(tableName string, path string) (string, error) {
+	if err := db.lock(); err != nil {
+		return "", err
+	}
+	defer db.mu.Unlock()
+	now := time.Now()
+	table, exists := db.Tables[tableName]
+	if !exists {
+		return "", fmt.Errorf("table %s does not exist", tableName)
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return "", fmt.Errorf("%s is empty, expected a header row", path)
+	}
+	if err != nil {
+		return "", err
+	}
+	for i, col := range header {
+		header[i] = strings.TrimSpace(col)
+		if !table.columnExists(header[i]) {
+			return "", fmt.Errorf("%s, line 1: table %s has no column %s", path, tableName, header[i])
+		}
+	}
+
+	var records [][]string
+	var lines []int
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			// A csv.ParseError already gives the line
+			return "", fmt.Errorf("%s: %v", path, err)
+		}
+		line, _ := reader.FieldPos(0)
+		records = append(records, record)
+		lines = append(lines, line)
+	}
+
+	inserted, rejected, err := db.insertRows(tableName, header, records, false, now)
+	if err != nil {
+		return "", err
+	}
+	if len(rejected) > 0 {
+		first := rejected[0]
+		return "", fmt.Errorf("%s, line %d: %v, nothing was imported", path, lines[first.Index], first.Err)
+	}
+	if inserted == 1 {
+		return "1 row imported", nil
+	}
+	return fmt.Sprintf("%d rows imported", inserted), nil
+}
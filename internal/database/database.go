@@ -1,13 +1,17 @@
 package database
 
 import (
+	"cmp"
 	"encoding/gob"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"maps"
+	"math/big"
 	"os"
 	"reflect"
 	"regexp"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
@@ -20,105 +24,321 @@ func init() {
 	gob.Register(Table{})
 	gob.Register(Database{})
 	gob.Register(time.Time{})
+	gob.Register(Decimal{})
 }
 
+// Basic SQL parsing
+var (
+	createRegex    = regexp.MustCompile(`(?i)^CREATE\s+TABLE\s+(\w+)\s*\((.+)\)\s*$`)
+	insertRegex    = regexp.MustCompile(`(?i)^INSERT\s+INTO\s+(\w+)\s*(?:\(([^)]+)\))?\s*VALUES\s*\((.+?)\)\s*$`)
+	selectRegex    = regexp.MustCompile(`(?i)^SELECT\s+(.+?)\s+FROM\s+(\w+(?:\s+(?:AS\s+)?\w+)??)(?:\s+((?:(?:LEFT|FULL)\s+(?:OUTER\s+)?|INNER\s+)?JOIN\s+.+?\s+(?:ON\s+.+?|USING\s*\(.*?\))|CROSS\s+JOIN\s+\w+(?:\s+(?:AS\s+)?\w+)??))?(?:\s+WHERE\s+(.+?))?(?:\s+GROUP\s+BY\s+(.+?))?(?:\s+ORDER BY\s+(.+?))?(?:\s+LIMIT\s+(\d+(?:\s*,\s*\d+)?))?(?:\s+OFFSET\s+(\S+))?\s*$`)
+	deleteRegex    = regexp.MustCompile(`(?i)^DELETE\s+FROM\s+(\w+)(?:\s+WHERE\s+(.+?))?\s*$`)
+	updateRegex    = regexp.MustCompile(`(?i)^UPDATE\s+(\w+)\s+SET\s+(.+?)(?:\s+WHERE\s+(.+?))?\s*$`)
+	dropTableRegex = regexp.MustCompile(`(?i)^DROP\s+TABLE\s+(\w+)\s*$`)
+	truncateRegex  = regexp.MustCompile(`(?i)^TRUNCATE\s+(?:TABLE\s+)?(\w+)\s*$`)
+	addColumnRegex = regexp.MustCompile(`(?i)^ALTER\s+TABLE\s+(\w+)\s+ADD\s+(?:COLUMN\s+)?(.+?)\s*$`)
+	betweenRegex   = regexp.MustCompile(`(?i)^([\w.]+)\s+(NOT\s+)?BETWEEN\s+(.+?)\s+AND\s+(.+?)$`)
+	betweenWord    = regexp.MustCompile(`(?i)\sBETWEEN\s`)
+	likeRegex      = regexp.MustCompile(`(?i)^([\w.]+)\s+(NOT\s+)?(I?LIKE)\s+(.+)$`)
+	inRegex        = regexp.MustCompile(`(?i)^([\w.]+)\s+(NOT\s+)?IN\s*\((.*)\)$`)
+	columnRegex    = regexp.MustCompile(`^[\w.]+$`)
+	isNullRegex    = regexp.MustCompile(`(?i)^([\w.]+)\s+IS\s+(NOT\s+)?NULL$`)
+	joinRegex      = regexp.MustCompile(`(?i)^(?:(LEFT|FULL)\s+(?:OUTER\s+)?|INNER\s+)?JOIN\s+(\w+(?:\s+(?:AS\s+)?\w+)?)\s+(?:ON\s+(.+)|(USING\s*\(.*\)))$`)
+	usingRegex     = regexp.MustCompile(`(?i)^USING\s*\((.*)\)$`)
+	crossJoinRegex = regexp.MustCompile(`(?i)^CROSS\s+JOIN\s+(\w+(?:\s+(?:AS\s+)?\w+)?)$`)
+)
+
 type Database struct {
 	Name   string
 	Tables map[string]*Table
-	mu     sync.RWMutex
+	// mu is taken once by each exported method, for writing by those that
+	// change the database and for reading by the rest. Unexported methods
+	// expect their caller to hold it and never take it themselves.
+	mu sync.RWMutex
+	// snapshot holds the tables as they were at BEGIN, it is nil outside a
+	// transaction
+	snapshot map[string]*Table
+	storage  StorageBackend
+	// path is the file the database is saved in, when set with WithPath
+	path string
+	// closed is set by Close, after which statements fail with ErrClosed
+	closed bool
+	// dirty is set while the database has changes that are not saved, Close
+	// only saves it then
+	dirty bool
+	// readOnly is set by WithReadOnly, statements that would change the
+	// database fail with ErrReadOnly
+	readOnly bool
+	// maxCrossJoinRows caps the rows of a CROSS JOIN, see WithMaxCrossJoinRows
+	maxCrossJoinRows int
+}
+
+// DefaultMaxCrossJoinRows is the number of rows a CROSS JOIN may produce
+// unless WithMaxCrossJoinRows sets another limit
+const DefaultMaxCrossJoinRows = 1_000_000
+
+// WithMaxCrossJoinRows limits the rows a CROSS JOIN may produce, before
+// WHERE and LIMIT apply. A larger product is an error rather than being built.
+func WithMaxCrossJoinRows(n int) Option {
+	return func(db *Database) {
+		db.maxCrossJoinRows = n
+	}
+}
+
+// ErrClosed is returned for statements run after Close
+var ErrClosed = errors.New("database closed")
+
+// ErrReadOnly is returned for statements that would change a database opened
+// with WithReadOnly
+var ErrReadOnly = errors.New("database is opened read-only")
+
+// WithReadOnly opens the database for reading only. Statements that would
+// change it fail with ErrReadOnly, so its file is never written, not even by
+// Close.
+func WithReadOnly() Option {
+	return func(db *Database) {
+		db.readOnly = true
+	}
 }
 
 // NewDatabase creates or loads a database
-func NewDatabase(name string) (*Database, error) {
+func NewDatabase(name string, opts ...Option) (*Database, error) {
 	db := &Database{
-		Name:   name,
-		Tables: make(map[string]*Table),
+		Name:             name,
+		Tables:           make(map[string]*Table),
+		storage:          GobStorage{},
+		maxCrossJoinRows: DefaultMaxCrossJoinRows,
+	}
+	for _, opt := range opts {
+		opt(db)
 	}
 	// Try to load existing database
-	if err := db.loadFromFileGob(); err != nil && !os.IsNotExist(err) {
+	if err := db.storage.Load(db); err != nil && !os.IsNotExist(err) {
 		return nil, err
 	}
 	return db, nil
 }
 
-func (db *Database) saveToFileGob() error {
+// Close saves the changes that are not saved yet and closes the database,
+// later statements return ErrClosed. A transaction still in progress is
+// rolled back. A database that has not changed since it was last saved or
+// loaded is not written, and closing a closed database does nothing.
+func (db *Database) Close() error {
 	db.mu.Lock()
 	defer db.mu.Unlock()
-
-	file, err := os.Create(db.Name + ".gob")
-	if err != nil {
+	if db.closed {
+		return nil
+	}
+	if db.snapshot != nil {
+		db.Tables = db.snapshot
+		db.snapshot = nil
+	}
+	// Closed first, so that nothing changes the database after it is saved.
+	// A failed save leaves it open to be closed again.
+	db.closed = true
+	if !db.dirty {
+		return nil
+	}
+	if err := db.storage.Save(db); err != nil {
+		db.closed = false
 		return err
 	}
-	defer file.Close()
-	return gob.NewEncoder(file).Encode(db)
+	db.dirty = false
+	return nil
 }
 
-func (db *Database) loadFromFileGob() error {
-	file, err := os.Open(db.Name + ".gob")
-	if err != nil {
-		return err
+// checkOpen returns ErrClosed once the database is closed
+func (db *Database) checkOpen() error {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	if db.closed {
+		return ErrClosed
+	}
+	return nil
+}
+
+// lock takes the write lock for an exported method. Once the database is
+// closed it returns ErrClosed instead, and ErrReadOnly when it was opened
+// read-only, without holding the lock, so nothing is read or changed.
+func (db *Database) lock() error {
+	db.mu.Lock()
+	if db.closed {
+		db.mu.Unlock()
+		return ErrClosed
 	}
-	defer file.Close()
+	if db.readOnly {
+		db.mu.Unlock()
+		return ErrReadOnly
+	}
+	return nil
+}
 
-	return gob.NewDecoder(file).Decode(db)
+// rlock takes the read lock for an exported method, or returns ErrClosed
+// like lock
+func (db *Database) rlock() error {
+	db.mu.RLock()
+	if db.closed {
+		db.mu.RUnlock()
+		return ErrClosed
+	}
+	return nil
+}
+
+// save writes the database through its storage backend, the caller holds
+// the write lock
+func (db *Database) save() error {
+	if db.closed {
+		return ErrClosed
+	}
+	db.dirty = true
+	// Inside a transaction changes are saved by COMMIT
+	if db.snapshot != nil {
+		return nil
+	}
+	if err := db.storage.Save(db); err != nil {
+		return err
+	}
+	db.dirty = false
+	return nil
 }
 
 // Execute processes SQL commands
 func (db *Database) Execute(sql string) (string, error) {
+	return outputOf(db.execute(sql))
+}
+
+// execute runs a statement and describes its outcome, along with the rows an
+// INSERT, UPDATE, DELETE or TRUNCATE affected
+func (db *Database) execute(sql string) (*Result, error) {
+	if err := db.checkOpen(); err != nil {
+		return nil, err
+	}
 	// Normalize SQL
 	sql = strings.TrimSpace(sql)
 	if sql == "" {
-		return "", fmt.Errorf("empty SQL statement")
+		return nil, fmt.Errorf("empty SQL statement")
 	}
-
-	// Basic SQL parsing
-	createRegex := regexp.MustCompile(`(?i)^CREATE\s+TABLE\s+(\w+)\s*\((.+)\)\s*$`)
-	insertRegex := regexp.MustCompile(`(?i)^INSERT\s+INTO\s+(\w+)\s*(?:\((.+?)\))?\s*VALUES\s*\((.+?)\)\s*$`)
-	selectRegex := regexp.MustCompile(`(?i)^SELECT\s+(.+?)\s+FROM\s+(\w+)(?:\s+(JOIN\s+.+?\s+ON\s+.+?))?(?:\s+WHERE\s+(.+?))?(?:\s+ORDER BY\s+(.+?))?(?:\s+LIMIT\s+(\d+))?\s*$`)
-	deleteRegex := regexp.MustCompile(`(?i)^DELETE\s+FROM\s+(\w+)(?:\s+WHERE\s+(.+?))?\s*$`)
-	updateRegex := regexp.MustCompile(`(?i)^UPDATE\s+(\w+)\s+SET\s+(.+?)\s+WHERE\s+(.+?)\s*$`)
-	dropTableRegex := regexp.MustCompile(`(?i)^DROP\s+TABLE\s+(\w+)\s*$`)
+	tokens, err := tokenize(sql)
+	if err != nil {
+		return nil, err
+	}
+	masked := maskStrings(sql)
 
 	switch {
+	case transactionRegex.MatchString(sql):
+		switch strings.ToUpper(transactionRegex.FindStringSubmatch(sql)[1]) {
+		case "BEGIN":
+			return output(db.Begin())
+		case "COMMIT":
+			return output(db.Commit())
+		default:
+			return output(db.Rollback())
+		}
 	case createRegex.MatchString(sql):
 		matches := createRegex.FindStringSubmatch(sql)
-		return db.CreateTable(matches[1], strings.Split(matches[2], ","))
+		columnDefs := splitList(matches[2])
+		if err := checkColumnDefs(columnDefs, createRegex.FindStringSubmatchIndex(sql)[4]); err != nil {
+			return nil, err
+		}
+		return output(db.CreateTable(matches[1], columnDefs))
+	case createIndexRegex.MatchString(sql):
+		matches := createIndexRegex.FindStringSubmatch(sql)
+		return output(db.CreateIndex(matches[1], matches[2], matches[3]))
+	case renameTableRegex.MatchString(sql):
+		matches := renameTableRegex.FindStringSubmatch(sql)
+		return output(db.RenameTable(matches[1], matches[2]))
+	case renameColumnRegex.MatchString(sql):
+		matches := renameColumnRegex.FindStringSubmatch(sql)
+		return output(db.RenameColumn(matches[1], matches[2], matches[3]))
+	case addColumnRegex.MatchString(sql):
+		matches := addColumnRegex.FindStringSubmatch(sql)
+		return output(db.AddColumn(matches[1], matches[2]))
 	case dropTableRegex.MatchString(sql):
 		matches := dropTableRegex.FindStringSubmatch(sql)
-		return db.DropTable(matches[1])
-	case deleteRegex.MatchString(sql):
-		matches := deleteRegex.FindStringSubmatch(sql)
-		return db.Delete(matches[1], matches[2])
+		return output(db.DropTable(matches[1]))
+	case truncateRegex.MatchString(sql):
+		return db.TruncateResult(truncateRegex.FindStringSubmatch(sql)[1])
+	case deleteRegex.MatchString(masked):
+		matches := findClauses(deleteRegex, sql)
+		return db.DeleteResult(matches[1], matches[2])
 	case insertRegex.MatchString(sql):
 		matches := insertRegex.FindStringSubmatch(sql)
 		var columns []string
 		if matches[2] != "" {
-			columns = strings.Split(matches[2], ",")
-		}
-		values := strings.Split(matches[3], ",")
-		return db.Insert(matches[1], columns, values)
-	case updateRegex.MatchString(sql):
-		matches := updateRegex.FindStringSubmatch(sql)
-		return db.Update(matches[1], matches[2], matches[3])
-	case selectRegex.MatchString(sql):
-		matches := selectRegex.FindStringSubmatch(sql)
-		columns := strings.Split(matches[1], ",")
-		// NOTE: FindStringSubmatch always returns a slice with len = 1 + number of capture groups.
-		// If a capture group doesn't match, its value will be an empty string (""),
-		// so accessing matches[3] or matches[4] is safe as long as the regex matched.
-		tableName := matches[2]
-		joinClause := matches[3]
-		whereClause := matches[4]
-		orderByClause := matches[5]
-		limitClause := matches[6]
-		return db.Select(tableName, columns, whereClause, joinClause, orderByClause, limitClause)
+			columns = splitList(matches[2])
+		}
+		values := splitList(matches[3])
+		return db.InsertResult(matches[1], columns, values)
+	case updateRegex.MatchString(masked):
+		matches := findClauses(updateRegex, sql)
+		return db.UpdateResult(matches[1], matches[2], matches[3])
+	case dumpRegex.MatchString(sql):
+		var out strings.Builder
+		if err := db.Dump(&out); err != nil {
+			return nil, err
+		}
+		return &Result{Output: out.String()}, nil
+	case exportRegex.MatchString(sql):
+		return output(db.Export(unquote(exportRegex.FindStringSubmatch(sql)[1])))
+	case importRegex.MatchString(sql):
+		matches := importRegex.FindStringSubmatch(sql)
+		return output(db.Import(matches[1], unquote(matches[2])))
+	case selectFormatRegex.MatchString(masked):
+		matches := findClauses(selectFormatRegex, sql)
+		stmt, ok := parseSelect(matches[1])
+		if !ok {
+			return nil, diagnose(tokens)
+		}
+		if strings.EqualFold(matches[2], "CSV") {
+			return output(db.selectCSV(stmt))
+		}
+		return output(db.Select(stmt.table, stmt.columns, stmt.where, stmt.join, stmt.groupBy, stmt.orderBy, stmt.limit, stmt.offset))
+	case explainRegex.MatchString(sql):
+		return output(db.Explain(explainRegex.FindStringSubmatch(sql)[1]))
+	case tokens[0].is("SELECT"):
+		stmt, ok := parseSelect(sql)
+		if !ok {
+			return nil, diagnose(tokens)
+		}
+		result, err := db.Select(stmt.table, stmt.columns, stmt.where, stmt.join, stmt.groupBy, stmt.orderBy, stmt.limit, stmt.offset)
+		// A SELECT without FROM that fails, such as SELECT id, name users,
+		// is more likely missing its FROM than meant to compute values
+		if err != nil && stmt.table == "" {
+			if syntaxErr := diagnose(tokens); syntaxErr != nil {
+				return nil, syntaxErr
+			}
+		}
+		return output(result, err)
 	default:
-		return "", fmt.Errorf("unsupported SQL command")
+		return nil, diagnose(tokens)
+	}
+}
+
+// checkColumnDefs reports the first invalid column definition as a syntax
+// error, start is the offset of the first definition in the statement
+func checkColumnDefs(columnDefs []string, start int) error {
+	pos := start
+	for _, def := range columnDefs {
+		trimmed := strings.TrimSpace(def)
+		column := &Column{}
+		if err := column.parseColumnDef(trimmed); err != nil {
+			return &ErrSyntax{
+				Position: pos + strings.Index(def, trimmed),
+				Near:     trimmed,
+				Msg:      err.Error(),
+			}
+		}
+		pos += len(def) + 1
 	}
+	return nil
 }
 
 // CreateTable creates a new table
 func (db *Database) CreateTable(name string, columnDefs []string) (string, error) {
+	if err := db.lock(); err != nil {
+		return "", err
+	}
+	defer db.mu.Unlock()
 	if _, exists := db.Tables[name]; exists {
 		return "", fmt.Errorf("table %s already exists", name)
 	}
@@ -131,27 +351,96 @@ func (db *Database) CreateTable(name string, columnDefs []string) (string, error
 		if err := column.parseColumnDef(def); err != nil {
 			return "", fmt.Errorf("error parsing column definition '%s': %v", def, err)
 		}
-		if column.ReferenceColumn != "" && column.ReferenceTable != "" {
-			if !db.tableExists(column.ReferenceTable) {
-				return "", fmt.Errorf("foreign key reference to unknown table '%s' in column '%s'", column.ReferenceTable, column.Name)
-			}
+		if err := db.defineColumn(table, *column); err != nil {
+			return "", err
 		}
-		table.addColumn(*column)
 	}
 
 	db.Tables[name] = table
 
-	if err := db.saveToFileGob(); err != nil {
+	if err := db.save(); err != nil {
 		return "", err
 	}
 
 	return fmt.Sprintf("Table %s created", name), nil
 }
 
-// DropTable removes a table
+// defineColumn adds a column to a table along with its key constraints
+func (db *Database) defineColumn(table *Table, column Column) error {
+	if table.columnExists(column.Name) {
+		return fmt.Errorf("column %s already exists in table %s", column.Name, table.Name)
+	}
+	if column.ReferenceColumn != "" && column.ReferenceTable != "" {
+		if !db.tableExists(column.ReferenceTable) {
+			return fmt.Errorf("foreign key reference to unknown table '%s' in column '%s'", column.ReferenceTable, column.Name)
+		}
+		if table.ForeignKeys == nil {
+			table.ForeignKeys = make(map[string]string)
+		}
+		table.ForeignKeys[column.Name] = column.ReferenceTable + "." + column.ReferenceColumn
+	}
+	if column.HasConstraint(COLUMN_CONSTRAINT_PRIMARY_KEY) {
+		if table.PrimaryKey != "" {
+			return fmt.Errorf("table %s has more than one primary key", table.Name)
+		}
+		table.PrimaryKey = column.Name
+	}
+	table.addColumn(column)
+	table.reindex()
+	return nil
+}
+
+// AddColumn adds a column to an existing table, existing rows get its
+// default, or null without one. A NOT NULL column without a default or a
+// PRIMARY KEY column can only be added to an empty table, and a UNIQUE
+// column with a default to a table of at most one row.
+func (db *Database) AddColumn(tableName string, columnDef string) (string, error) {
+	if err := db.lock(); err != nil {
+		return "", err
+	}
+	defer db.mu.Unlock()
+	table, err := db.getTable(tableName)
+	if err != nil {
+		return "", err
+	}
+	column := &Column{}
+	if err := column.parseColumnDef(columnDef); err != nil {
+		return "", fmt.Errorf("error parsing column definition '%s': %v", columnDef, err)
+	}
+	if len(table.Rows) > 0 {
+		for _, constraint := range []ColumnConstraint{COLUMN_CONSTRAINT_NOT_NULL, COLUMN_CONSTRAINT_PRIMARY_KEY} {
+			if column.HasConstraint(constraint) && (column.Default == nil || constraint == COLUMN_CONSTRAINT_PRIMARY_KEY) {
+				return "", fmt.Errorf("cannot add %s column %s to table %s, its rows would have no value", constraint, column.Name, table.Name)
+			}
+		}
+	}
+	if len(table.Rows) > 1 && column.HasConstraint(COLUMN_CONSTRAINT_UNIQUE) && column.Default != nil {
+		return "", fmt.Errorf("cannot add UNIQUE column %s with a default to table %s, its %d rows would share the value", column.Name, table.Name, len(table.Rows))
+	}
+	if err := db.defineColumn(table, *column); err != nil {
+		return "", err
+	}
+	for _, row := range table.Rows {
+		row[column.Name] = column.Default
+	}
+	table.reindex()
+	if err := db.save(); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("Column %s added to table %s", column.Name, table.Name), nil
+}
+
+// DropTable removes a table, it fails if the table does not exist
 func (db *Database) DropTable(name string) (string, error) {
+	if err := db.lock(); err != nil {
+		return "", err
+	}
+	defer db.mu.Unlock()
+	if _, err := db.getTable(name); err != nil {
+		return "", err
+	}
 	delete(db.Tables, name)
-	err := db.saveToFileGob()
+	err := db.save()
 	if err != nil {
 		return "", err
 	}
@@ -160,264 +449,821 @@ func (db *Database) DropTable(name string) (string, error) {
 
 // Insert adds a new row to a table
 func (db *Database) Insert(tableName string, columns []string, values []string) (string, error) {
+	return outputOf(db.InsertResult(tableName, columns, values))
+}
+
+// InsertResult adds a new row to a table like Insert and returns the
+// AUTO_INCREMENT value it was given
+func (db *Database) InsertResult(tableName string, columns []string, values []string) (*Result, error) {
+	if err := db.lock(); err != nil {
+		return nil, err
+	}
+	defer db.mu.Unlock()
+	now := time.Now()
 	table, exists := db.Tables[tableName]
 	if !exists {
-		return "", fmt.Errorf("table %s does not exist", tableName)
+		return nil, fmt.Errorf("table %s does not exist", tableName)
 	}
 
-	if len(columns) != len(values) {
-		return "", fmt.Errorf("column count does not match value count")
+	if len(columns) == 0 {
+		var err error
+		columns, err = table.insertColumns(len(values))
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	row := make(Row)
-	for i, col := range columns {
-		col = strings.TrimSpace(col)
-		val := strings.TrimSpace(values[i])
+	row, err := table.buildRow(columns, values, columnTypeConversion, now)
+	if err != nil {
+		return nil, err
+	}
+	return db.insertRow(table, row)
+}
+
+// insertRow checks the constraints of a converted row, adds it and saves
+func (db *Database) insertRow(table *Table, row Row) (*Result, error) {
+	if err := table.addRow(row); err != nil {
+		return nil, err
+	}
+	if err := db.save(); err != nil {
+		return nil, err
+	}
+	result := &Result{Output: "1 row inserted", RowsAffected: 1}
+	for _, column := range table.Columns {
+		if id, ok := row[column.Name].(int64); ok && column.HasConstraint(COLUMN_CONSTRAINT_AUTO_INCREMENT) {
+			result.LastInsertId = id
+			break
+		}
+	}
+	return result, nil
+}
+
+// RowError describes a row rejected by InsertRows
+type RowError struct {
+	Index int // position of the row in the slice given to InsertRows
+	Err   error
+}
+
+func (e RowError) Error() string {
+	return fmt.Sprintf("row %d: %v", e.Index+1, e.Err)
+}
+
+// InsertRows adds many rows to a table and saves once at the end.
+// Values are plain text rather than SQL literals, so quotes are kept as
+// they are, and an empty value leaves its column unset. Rows that fail conversion or
+// constraint checks are returned as RowErrors; unless partial is true a
+// single rejected row means none of the rows are kept.
+func (db *Database) InsertRows(tableName string, columns []string, rows [][]string, partial bool) (int, []RowError, error) {
+	if err := db.lock(); err != nil {
+		return 0, nil, err
+	}
+	defer db.mu.Unlock()
+	now := time.Now()
+	return db.insertRows(tableName, columns, rows, partial, now)
+}
 
-		// Find column type
-		var colType ColumnType
-		for _, column := range table.Columns {
-			if column.Name == col {
-				colType = column.Type
+func (db *Database) insertRows(tableName string, columns []string, rows [][]string, partial bool, now time.Time) (int, []RowError, error) {
+	table, exists := db.Tables[tableName]
+	if !exists {
+		return 0, nil, fmt.Errorf("table %s does not exist", tableName)
+	}
+
+	original := len(table.Rows)
+	var rejected []RowError
+	for i, values := range rows {
+		if len(values) != len(columns) {
+			rejected = append(rejected, RowError{i, fmt.Errorf("expected %d values, got %d", len(columns), len(values))})
+			continue
+		}
+		var rowColumns, rowValues []string
+		for j, val := range values {
+			if val != "" {
+				rowColumns = append(rowColumns, columns[j])
+				rowValues = append(rowValues, val)
 			}
 		}
-		// Simple type conversion
-		convertedVal, err := columnTypeConversion(colType, val)
+		row, err := table.buildRow(rowColumns, rowValues, rawValueConversion, now)
+		if err == nil {
+			err = table.addRow(row)
+		}
 		if err != nil {
-			return "", err
+			rejected = append(rejected, RowError{i, err})
 		}
-		row[col] = convertedVal
 	}
 
-	table.addRow(row)
-	err := db.saveToFileGob()
-	if err != nil {
-		return "", err
+	if len(rejected) > 0 && !partial {
+		table.Rows = table.Rows[:original]
+		table.reindex()
+		return 0, rejected, nil
 	}
-	return "1 row inserted", nil
+	inserted := len(table.Rows) - original
+	if inserted > 0 {
+		if err := db.save(); err != nil {
+			return 0, rejected, err
+		}
+	}
+	return inserted, rejected, nil
 }
 
-// Delete removes a row from a table
+// Delete removes the rows matching whereClause from a table. Rows of other
+// tables that reference them are removed with them when their foreign key
+// is ON DELETE CASCADE, otherwise the delete fails.
 func (db *Database) Delete(tableName string, whereClause string) (string, error) {
+	return outputOf(db.DeleteResult(tableName, whereClause))
+}
+
+// DeleteResult removes the rows matching whereClause from a table like Delete
+// and returns how many it removed
+func (db *Database) DeleteResult(tableName string, whereClause string) (*Result, error) {
+	if err := db.lock(); err != nil {
+		return nil, err
+	}
+	defer db.mu.Unlock()
+	now := time.Now()
 	table, exists := db.Tables[tableName]
 	if !exists {
-		return "", fmt.Errorf("table %s does not exist", tableName)
-	} else if len(table.Rows) == 0 {
-		return "", fmt.Errorf("table %s is empty", tableName)
+		return nil, fmt.Errorf("table %s does not exist", tableName)
 	}
-	var results []Row
-	for _, row := range table.Rows {
-		if whereClause == "" || !db.evaluateWhere(row, whereClause) {
-			results = append(results, row)
+	whereClause, err := db.resolveInSubqueries(whereClause, now)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkWhereColumns([]*Table{table}, whereClause); err != nil {
+		return nil, err
+	}
+	types := typesOf(table)
+	matched := make(map[int]bool)
+	for _, i := range table.candidates(whereClause, now) {
+		ok, err := db.evaluateWhere(table.Rows[i], whereClause, types, now)
+		if err != nil {
+			return nil, err
 		}
+		if ok {
+			matched[i] = true
+		}
+	}
+	plan, err := db.planDelete(table, matched)
+	if err != nil {
+		return nil, err
+	}
+	deleted := len(matched)
+	if deleted == 0 {
+		return &Result{Output: "0 rows deleted"}, nil
+	}
+	for t, positions := range plan {
+		t.removeRows(positions)
+	}
+	err = db.save()
+	if err != nil {
+		return nil, err
+	}
+	result := &Result{Output: fmt.Sprintf("%d rows deleted", deleted), RowsAffected: int64(deleted)}
+	if deleted == 1 {
+		result.Output = "1 row deleted"
+	}
+	return result, nil
+}
+
+// Truncate removes every row of a table and keeps its schema, so
+// AUTO_INCREMENT columns start again from 1. Foreign keys are followed as
+// for DELETE: referencing rows are removed when their key is ON DELETE
+// CASCADE, otherwise the table cannot be truncated while rows reference it.
+func (db *Database) Truncate(tableName string) (string, error) {
+	return outputOf(db.TruncateResult(tableName))
+}
+
+// TruncateResult removes every row of a table like Truncate and returns how
+// many it removed
+func (db *Database) TruncateResult(tableName string) (*Result, error) {
+	if err := db.lock(); err != nil {
+		return nil, err
+	}
+	defer db.mu.Unlock()
+	table, exists := db.Tables[tableName]
+	if !exists {
+		return nil, fmt.Errorf("table %s does not exist", tableName)
+	}
+	all := make(map[int]bool, len(table.Rows))
+	for i := range table.Rows {
+		all[i] = true
+	}
+	plan, err := db.planDelete(table, all)
+	if err != nil {
+		return nil, err
+	}
+	for t, positions := range plan {
+		t.removeRows(positions)
+	}
+	if err := db.save(); err != nil {
+		return nil, err
+	}
+	return &Result{Output: fmt.Sprintf("Table %s truncated", tableName), RowsAffected: int64(len(all))}, nil
+}
+
+// Select retrieves data from a table and formats it as JSON. Rows are sorted
+// before OFFSET skips rows and LIMIT caps what remains. A query that matches
+// no rows returns an empty array.
+func (db *Database) Select(tableName string, columns []string, whereClause string, joinClause string, groupByClause string, orderByClause string, limitClause string, offsetClause string) (string, error) {
+	if err := db.rlock(); err != nil {
+		return "", err
 	}
-	table.Rows = results
-	err := db.saveToFileGob()
+	defer db.mu.RUnlock()
+	now := time.Now()
+	results, resultColumns, err := db.selectRows(tableName, columns, whereClause, joinClause, groupByClause, orderByClause, limitClause, offsetClause, now)
 	if err != nil {
 		return "", err
 	}
-	return fmt.Sprintf("%d rows deleted", len(results)), nil
+	// Keys follow the order of the selected columns
+	names := make([]string, len(resultColumns))
+	for i, column := range resultColumns {
+		names[i] = column.Name
+	}
+	ordered := make([]orderedRow, len(results))
+	for i, row := range results {
+		ordered[i] = orderedRow{row: row, columns: names}
+	}
+	jsonData, err := json.MarshalIndent(ordered, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal results: %v", err)
+	}
+	return string(jsonData), nil
 }
 
-// Select retrieves data from a table
-func (db *Database) Select(tableName string, columns []string, whereClause string, joinClause string, orderByClause string, limitClause string) (string, error) {
+// selectRows runs a SELECT and returns the resulting rows along with the
+// projected columns, in the order they were selected
+func (db *Database) selectRows(tableName string, columns []string, whereClause string, joinClause string, groupByClause string, orderByClause string, limitClause string, offsetClause string, now time.Time) ([]Row, []Column, error) {
+	if tableName == "" {
+		projections, err := parseProjections(columns)
+		if err != nil {
+			return nil, nil, err
+		}
+		return db.selectValues(projections, now)
+	}
 	// Get the main table
-	mainTable, err := db.getTable(tableName)
+	mainTable, err := db.tableRef(tableName)
+	if err != nil {
+		return nil, nil, err
+	}
+	whereClause, err = db.resolveInSubqueries(whereClause, now)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	projections, err := parseProjections(columns)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if groupByClause != "" {
+		results, resultColumns, err := db.groupRows(mainTable, projections, whereClause, joinClause, groupByClause, now)
+		if err != nil {
+			return nil, nil, err
+		}
+		results, err = sortAndPage(results, nil, resultColumns, nil, orderByClause, limitClause, offsetClause, now)
+		if err != nil {
+			return nil, nil, err
+		}
+		return results, resultColumns, nil
+	}
+
+	aggregates, err := parseAggregates(projections)
 	if err != nil {
-		return "", fmt.Errorf("table %s does not exist", tableName)
+		return nil, nil, err
+	}
+	// A lone COUNT(*) only needs the number of matched rows
+	if len(aggregates) == 1 && aggregates[0].fn == "COUNT" && aggregates[0].arg == "*" && joinClause == "" {
+		count, err := db.countRows(mainTable, whereClause, now)
+		if err != nil {
+			return nil, nil, err
+		}
+		tables := []*Table{mainTable}
+		return []Row{{aggregates[0].name(): int64(count)}}, aggregateColumns(aggregates, tables), nil
+	}
+	if aggregates != nil {
+		rows, tables, err := db.matchedRows(mainTable, whereClause, joinClause, now)
+		if err != nil {
+			return nil, nil, err
+		}
+		result, err := aggregateRows(aggregates, tables, rows)
+		if err != nil {
+			return nil, nil, err
+		}
+		return []Row{result}, aggregateColumns(aggregates, tables), nil
 	}
 
-	var results []Row
+	results := []Row{}
+	var sources []Row // the matched row of each result, for ORDER BY
+	var resultColumns []Column
+	tables := []*Table{mainTable}
 
 	if joinClause == "" {
+		if projections, err = expandTableStars(projections, tables); err != nil {
+			return nil, nil, err
+		}
+		resultColumns, err = projectedColumns(projections, tables)
+		if err != nil {
+			return nil, nil, err
+		}
+		if err := checkWhereColumns([]*Table{mainTable}, whereClause); err != nil {
+			return nil, nil, err
+		}
+		// Columns named as table.column are only looked up when the query uses them
+		clauses := []string{whereClause, orderByClause}
+		for _, p := range projections {
+			clauses = append(clauses, p.expr)
+		}
+		qualified := mentionsTable(mainTable.Name, clauses...)
+		types := typesOf(mainTable)
 		// Simple SELECT without JOIN
-		for _, row := range mainTable.Rows {
-			if whereClause == "" || db.evaluateWhere(row, whereClause) {
+		for _, i := range mainTable.candidates(whereClause, now) {
+			row, source := mainTable.Rows[i], mainTable.Rows[i]
+			if qualified {
+				source = qualifiedRow(mainTable.Name, row)
+			}
+			matched, err := db.evaluateWhere(source, whereClause, types, now)
+			if err != nil {
+				return nil, nil, err
+			}
+			if matched {
 				resultRow := make(Row)
-				for _, col := range columns {
-					col = strings.TrimSpace(col)
-					if col == "*" {
+				for _, p := range projections {
+					if p.expr == "*" {
 						maps.Copy(resultRow, row)
-					} else if val, exists := row[col]; exists {
-						resultRow[col] = val
-					} else {
-						return "", fmt.Errorf("column %s not found", col)
-					}
-				}
-
-				if limitClause != "" {
-					limit, err := parseLimitClause(limitClause)
-					if err != nil {
-						return "", err
-					}
-					if limit > 0 && len(results) >= limit {
-						break
+					} else if err := db.project(resultRow, source, p, now); err != nil {
+						return nil, nil, err
 					}
 				}
 				results = append(results, resultRow)
-
+				sources = append(sources, source)
 			}
 		}
 	} else if joinClause != "" {
 		// Handle JOIN
-		joinTableName, joinCondition, err := parseJoinClause(joinClause)
+		joinTableRef, joinCondition, kind, err := parseJoinClause(joinClause)
 		if err != nil {
-			return "", fmt.Errorf("invalid join clause: %v", err)
+			return nil, nil, fmt.Errorf("invalid join clause: %v", err)
 		}
 
-		joinTable, err := db.getTable(joinTableName)
+		joinTable, err := db.tableRef(joinTableRef)
 		if err != nil {
-			return "", fmt.Errorf("join table %s does not exist", joinTableName)
+			return nil, nil, fmt.Errorf("join %v", err)
 		}
 
-		leftCol, rightCol, err := parseJoinCondition(joinCondition)
+		on, err := parseJoinOn(kind, joinCondition, mainTable, joinTable)
 		if err != nil {
-			return "", fmt.Errorf("invalid join condition: %v", err)
+			return nil, nil, err
+		}
+		tables = append(tables, joinTable)
+		if projections, err = expandTableStars(projections, tables); err != nil {
+			return nil, nil, err
+		}
+		resultColumns, err = projectedColumns(projections, tables)
+		if err != nil {
+			return nil, nil, err
 		}
 
-		// Perform the actual join
-	outer:
-		for _, mainRow := range mainTable.Rows {
-			for _, joinRow := range joinTable.Rows {
-				if mainRow[leftCol] == joinRow[rightCol] {
-					// Combine rows
-					combinedRow := make(Row)
-					maps.Copy(combinedRow, mainRow)
-					maps.Copy(combinedRow, joinRow)
-
-					// Apply WHERE clause if present
-					if whereClause == "" || db.evaluateWhere(combinedRow, whereClause) {
-						// Select only requested columns
-						resultRow := make(Row)
-						for _, col := range columns {
-							col = strings.TrimSpace(col)
-							if col == "*" {
-								maps.Copy(resultRow, combinedRow)
-							} else if val, exists := combinedRow[col]; exists {
-								resultRow[col] = val
-							} else {
-								// Handle table.column
-								if parts := strings.Split(col, "."); len(parts) == 2 {
-									tablePrefix := parts[0]
-									colName := parts[1]
-									if tablePrefix == tableName {
-										if val, exists := mainRow[colName]; exists {
-											resultRow[col] = val
-											continue
-										}
-									} else if tablePrefix == joinTableName {
-										if val, exists := joinRow[colName]; exists {
-											resultRow[col] = val
-											continue
-										}
-									}
-								}
-								return "", fmt.Errorf("column %s not found", col)
-							}
-						}
-						if limitClause != "" {
-							limit, err := parseLimitClause(limitClause)
-							if err != nil {
-								return "", err
-							}
-							if limit > 0 && len(results) >= limit {
-								break outer
-							}
-						}
-						results = append(results, resultRow)
-					}
+		if err := checkAmbiguous([]*Table{mainTable, joinTable}, whereClause); err != nil {
+			return nil, nil, err
+		}
+		if err := checkWhereColumns([]*Table{mainTable, joinTable}, whereClause); err != nil {
+			return nil, nil, err
+		}
+
+		// addJoined applies the WHERE clause to a pair of rows and projects it
+		types := typesOf(mainTable, joinTable)
+		addJoined := func(mainRow Row, joinRow Row) error {
+			combinedRow := joinedRow(mainTable.Name, mainRow, joinTable.Name, joinRow)
+
+			// Apply WHERE clause if present
+			matched, err := db.evaluateWhere(combinedRow, whereClause, types, now)
+			if err != nil || !matched {
+				return err
+			}
+			// Select only requested columns
+			resultRow := make(Row)
+			for _, p := range projections {
+				if p.expr == "*" {
+					maps.Copy(resultRow, combineRows(mainRow, joinRow))
+				} else if err := db.project(resultRow, combinedRow, p, now); err != nil {
+					return err
 				}
 			}
+			results = append(results, resultRow)
+			sources = append(sources, combinedRow)
+			return nil
+		}
+
+		// Perform the actual join
+		if err := db.joinPairs(mainTable, joinTable, on, kind, addJoined, now); err != nil {
+			return nil, nil, err
 		}
 	}
-	if len(results) == 0 {
-		return "", fmt.Errorf("no results found")
+	results, err = sortAndPage(results, sources, resultColumns, tables, orderByClause, limitClause, offsetClause, now)
+	if err != nil {
+		return nil, nil, err
 	}
+	return results, resultColumns, nil
+}
+
+// sortAndPage applies ORDER BY, OFFSET and LIMIT to the result rows. An
+// ORDER BY column is one of the result columns, such as an alias or an
+// aggregate of a grouped SELECT, or else a column of the tables that need not
+// be selected, or a function call over them such as ROUND(price, -1): sources
+// holds the matched row each result was projected from.
+// Grouped results have no sources and sort by result columns only.
+func sortAndPage(results []Row, sources []Row, resultColumns []Column, tables []*Table, orderByClause string, limitClause string, offsetClause string, now time.Time) ([]Row, error) {
 	if orderByClause != "" {
-		orderByCol, orderByDir, err := parseOrderByClause(orderByClause)
-		if err != nil {
-			return "", err
-		}
-		table, err := db.getTable(tableName)
+		terms, err := parseOrderByClause(orderByClause)
 		if err != nil {
-			return "", err
+			return nil, err
 		}
-		if !table.columnExists(orderByCol) {
-			return "", fmt.Errorf("column %s does not exist", orderByCol)
+		keys := make([]sortKey, len(terms))
+		fromSources := false
+		computed := make(map[string]exprValue) // function calls to evaluate on the sources
+		for k, term := range terms {
+			orderByCol := term.column
+			if agg, ok, _ := parseAggregate(orderByCol); ok {
+				orderByCol = agg.String()
+			}
+			i := slices.IndexFunc(resultColumns, func(column Column) bool {
+				return column.Name == orderByCol
+			})
+			var col Column
+			switch {
+			case i >= 0:
+				col = resultColumns[i]
+			case sources == nil:
+				return nil, fmt.Errorf("ORDER BY column %s must be selected in a grouped query", orderByCol)
+			case isColumnName(orderByCol):
+				if col, err = findColumn(tables, orderByCol); err != nil {
+					return nil, err
+				}
+				col.Name = orderByCol
+				fromSources = true
+			default:
+				value, err := parseExprValue(orderByCol)
+				if err != nil {
+					return nil, err
+				}
+				if col.Type, err = value.columnType(tables); err != nil {
+					return nil, err
+				}
+				col.Name = orderByCol
+				computed[orderByCol] = value
+				fromSources = true
+			}
+			keys[k] = sortKey{col, term.dir, term.nulls}
 		}
-		col, err := table.GetColumn(orderByCol)
-		if err != nil {
-			return "", err
+		keyRows := slices.Clone(results)
+		if fromSources {
+			// Result columns take precedence over the source columns they rename
+			keyRows = make([]Row, len(results))
+			for i, result := range results {
+				keyRows[i] = maps.Clone(sources[i])
+				maps.Copy(keyRows[i], result)
+				for name, value := range computed {
+					if keyRows[i][name], err = value.value(sources[i], now); err != nil {
+						return nil, err
+					}
+				}
+			}
 		}
-		results = sortRows(results, col, orderByDir)
+		sortRows(results, keyRows, keys)
 	}
 
-	jsonData, err := json.MarshalIndent(results, "", "  ")
+	offset, err := parseOffsetClause(offsetClause)
 	if err != nil {
-		return "", fmt.Errorf("failed to marshal results: %v", err)
+		return nil, err
 	}
-	return string(jsonData), nil
+	limit, err := parseLimitClause(limitClause)
+	if err != nil {
+		return nil, err
+	}
+	results = results[min(offset, len(results)):]
+	if limit >= 0 && len(results) > limit {
+		results = results[:limit]
+	}
+	return results, nil
 }
 
-// evaluateWhere handles simple WHERE clause evaluation
-func (db *Database) evaluateWhere(row Row, whereClause string) bool {
-	if whereClause == "" {
-		return true
+// evaluateCondition evaluates a single WHERE condition. A row with a null
+// value for the column never matches, not even the negated forms such as
+// NOT LIKE and NOT IN, and neither does a comparison with NULL; only IS NULL
+// finds nulls. Such a condition is unknown rather than false, so that a NOT
+// around it does not match either.
+// The left side of a comparison may be a function call such as UPPER(name)
+// or an arithmetic expression such as price * quantity, errors in evaluating
+// it are returned. Values compared with a column of a type in types are read
+// as that type, see compareAs, and a BOOL column on its own is compared with
+// true.
+func (db *Database) evaluateCondition(row Row, whereClause string, types columnTypes, now time.Time) (truth, error) {
+
+	// EXISTS comes first, the subquery may hold any of the forms below
+	if matches := existsRegex.FindStringSubmatch(whereClause); matches != nil {
+		q, err := parseSubquery(matches[2])
+		if err != nil {
+			return truthUnknown, err
+		}
+		found, err := db.exists(q, row, now)
+		if err != nil {
+			return truthUnknown, err
+		}
+		return truthOf(found != (matches[1] != "")), nil
 	}
 
-	// Check for multi-character operators (<=, >=, !=, =) first
-	operators := []string{"<=", ">=", "!=", "=", "<", ">", "LIKE"}
-	var op string
-	var parts []string
-
-	// Find which operator is being used
-	for _, operator := range operators {
-		if strings.Contains(whereClause, operator) {
-			op = operator
-			parts = strings.SplitN(whereClause, operator, 2)
-			break
+	// A column is null when the row has no value for it
+	if matches := isNullRegex.FindStringSubmatch(whereClause); matches != nil {
+		isNull := row[matches[1]] == nil
+		return truthOf(isNull != (matches[2] != "")), nil
+	}
+
+	if matches := findClauses(betweenRegex, whereClause); matches != nil {
+		rowVal := row[matches[1]]
+		if rowVal == nil {
+			return truthUnknown, nil
+		}
+		low, err := parseBound(matches[3], now)
+		if err != nil {
+			return truthUnknown, err
+		}
+		high, err := parseBound(matches[4], now)
+		if err != nil {
+			return truthUnknown, err
 		}
+		fromLow, err := compareAs(matches[1], types[matches[1]], rowVal, low)
+		if err != nil {
+			return truthUnknown, err
+		}
+		toHigh, err := compareAs(matches[1], types[matches[1]], rowVal, high)
+		if err != nil {
+			return truthUnknown, err
+		}
+		inRange := fromLow >= 0 && toHigh <= 0
+		return truthOf(inRange != (matches[2] != "")), nil
+	}
+	if betweenWord.MatchString(whereClause) {
+		return truthUnknown, fmt.Errorf("malformed BETWEEN in %q, expected: column BETWEEN low AND high", whereClause)
 	}
 
-	if len(parts) != 2 {
-		return false
+	// The two-word NOT forms are part of these patterns, so "name NOT LIKE x"
+	// is not read as column "name NOT"
+	if matches := likeRegex.FindStringSubmatch(whereClause); matches != nil {
+		rowVal := row[matches[1]]
+		if rowVal == nil {
+			return truthUnknown, nil
+		}
+		pattern := unquote(strings.TrimSpace(matches[4]))
+		matched := matchLike(formatValue(rowVal), pattern, strings.EqualFold(matches[3], "ILIKE"))
+		return truthOf(matched != (matches[2] != "")), nil
 	}
 
-	col := strings.TrimSpace(parts[0])
-	val := strings.TrimSpace(parts[1])
-	val = strings.Trim(val, "'\"")
+	if matches := inRegex.FindStringSubmatch(whereClause); matches != nil {
+		rowVal := row[matches[1]]
+		if rowVal == nil {
+			return truthUnknown, nil
+		}
+		members, err := parseValueList(matches[3], now)
+		if err != nil {
+			return truthUnknown, err
+		}
+		found := false
+		for _, member := range members {
+			cmp, err := compareAs(matches[1], types[matches[1]], rowVal, member)
+			if err != nil {
+				return truthUnknown, err
+			}
+			if cmp == 0 {
+				found = true
+				break
+			}
+		}
+		return truthOf(found != (matches[2] != "")), nil
+	}
 
-	rowVal, exists := row[col]
-	if !exists {
-		return false
+	col, op, val, ok := splitComparison(whereClause)
+	// Without types, as in CASE, a column holding a bool is taken for a BOOL one
+	if _, isBool := row[whereClause].(bool); !ok && (types[whereClause] == COLUMN_TYPE_BOOL || isBool) {
+		col, op, val, ok = whereClause, "=", "true", true
+	}
+	if !ok {
+		return truthFalse, nil
+	}
+	val = joinSign(val)
+	if strings.EqualFold(val, "NULL") {
+		return truthUnknown, nil
+	}
+	// A table.column on the right compares against that column, as the
+	// outer reference of a correlated subquery does
+	if ref, exists := row[val]; exists && qualifiedNameRegex.FindString(val) == val {
+		if ref == nil {
+			return truthUnknown, nil
+		}
+		val = formatValue(ref)
+	}
+	// and a function call such as DATE_ADD(NOW(), 7) or CURRENT_DATE against
+	// its result
+	var call *functionCall
+	var isCall bool
+	if strings.HasSuffix(val, ")") || isNiladicCall(val) {
+		var err error
+		if call, isCall, err = parseFunctionCall(val); err != nil {
+			return truthUnknown, err
+		}
+	}
+	if isCall {
+		result, err := call.eval(row, now)
+		if err != nil || result == nil {
+			return truthUnknown, err
+		}
+		val = formatValue(result)
+	} else {
+		val = unquote(val)
 	}
 
-	// Convert both values to string for comparison
-	rowStr := fmt.Sprint(rowVal)
-	valStr := val
+	rowVal, exists, err := evaluateOperand(row, col, now)
+	if err != nil {
+		return truthUnknown, err
+	}
+	if !exists || rowVal == nil {
+		return truthUnknown, nil
+	}
 
+	// Numbers compare by value, so 10 matches 10.0 and 01 matches 1
+	cmp, err := compareAs(col, types[col], rowVal, val)
+	if err != nil {
+		return truthUnknown, err
+	}
 	switch op {
 	case "=":
-		return rowStr == valStr
+		return truthOf(cmp == 0), nil
 	case "!=":
-		return rowStr != valStr
+		return truthOf(cmp != 0), nil
 	case "<":
-		return compareValues(rowVal, valStr) < 0
+		return truthOf(cmp < 0), nil
 	case ">":
-		return compareValues(rowVal, valStr) > 0
+		return truthOf(cmp > 0), nil
 	case "<=":
-		return compareValues(rowVal, valStr) <= 0
+		return truthOf(cmp <= 0), nil
 	case ">=":
-		return compareValues(rowVal, valStr) >= 0
-	case "LIKE":
-		return strings.Contains(rowStr, valStr)
+		return truthOf(cmp >= 0), nil
 	default:
-		return false
+		return truthFalse, nil
+	}
+}
+
+// compareAs compares a value of the column col with a literal read as a
+// value of the column type, a literal that is not of the type is an error.
+// DATE and TIMESTAMP values compare in time, so a DATE compares with a
+// timestamp as its midnight. Numbers and booleans compare as compareValues
+// does once the literal is known to be one; text, and values of columns
+// whose type is not known, compare as compareValues does too.
+func compareAs(col string, colType ColumnType, rowVal any, valStr string) (int, error) {
+	switch colType {
+	case COLUMN_TYPE_INT, COLUMN_TYPE_DOUBLE, COLUMN_TYPE_FLOAT, COLUMN_TYPE_DECIMAL:
+		if _, err := strconv.ParseFloat(joinSign(valStr), 64); err != nil {
+			return 0, fmt.Errorf("invalid %s value %q for column %s, expected a number", colType, valStr, col)
+		}
+	case COLUMN_TYPE_BOOL:
+		if _, ok := parseBool(valStr); !ok {
+			return 0, fmt.Errorf("invalid %s value %q for column %s, expected true or false", colType, valStr, col)
+		}
+	case COLUMN_TYPE_DATE, COLUMN_TYPE_TIMESTAMP:
+		rowTime, ok := rowVal.(time.Time)
+		if !ok {
+			parsed, err := parseTime(formatValue(rowVal))
+			if err != nil {
+				return compareValues(rowVal, valStr), nil
+			}
+			rowTime = parsed
+		}
+		valTime, err := parseTime(valStr)
+		if err != nil {
+			return 0, fmt.Errorf("invalid %s value %q for column %s, expected %s or %s", colType, valStr, col, dateLayout, timestampLayout)
+		}
+		return rowTime.Compare(valTime), nil
+	}
+	return compareValues(rowVal, valStr), nil
+}
+
+// splitComparison splits a condition at its comparison operator, operators
+// inside quoted strings are part of the string
+func splitComparison(condition string) (left, op, right string, ok bool) {
+	// Check for multi-character operators (<=, >=, !=, =) first
+	for _, operator := range []string{"<=", ">=", "!=", "=", "<", ">"} {
+		for i := 0; i < len(condition); i++ {
+			switch {
+			case condition[i] == '\'' || condition[i] == '"':
+				if end, _, ok := scanString(condition, i); ok {
+					i = end - 1
+				}
+			case strings.HasPrefix(condition[i:], operator):
+				return strings.TrimSpace(condition[:i]), operator, strings.TrimSpace(condition[i+len(operator):]), true
+			}
+		}
+	}
+	return "", "", "", false
+}
+
+// unquote removes the quotes around a string literal, a doubled quote inside
+// it stands for one quote
+func unquote(val string) string {
+	if len(val) >= 2 && (val[0] == '\'' || val[0] == '"') && val[len(val)-1] == val[0] {
+		quote := val[:1]
+		return strings.ReplaceAll(val[1:len(val)-1], quote+quote, quote)
+	}
+	return strings.Trim(val, "'\"")
+}
+
+// parseBound returns the value of a BETWEEN bound, which must be a single literal
+func parseBound(bound string, now time.Time) (string, error) {
+	values, err := parseValueList(bound, now)
+	if err != nil || len(values) != 1 {
+		return "", fmt.Errorf("malformed BETWEEN bound %q, expected a single value", strings.TrimSpace(bound))
+	}
+	return values[0], nil
+}
+
+// parseValueList splits the values of an IN list, commas inside quoted
+// strings do not separate values and quotes are removed
+func parseValueList(list string, now time.Time) ([]string, error) {
+	tokens, err := tokenize(list)
+	if err != nil {
+		return nil, err
+	}
+	var values []string
+	for i := 0; tokens[i].kind != tokenEOF; {
+		if len(values) > 0 {
+			if !tokens[i].is(",") {
+				return nil, fmt.Errorf("invalid IN list (%s): expected a comma before %q", list, tokens[i].text)
+			}
+			i++
+		}
+		tok := tokens[i]
+		switch {
+		case tok.is("NOW") && tokens[i+1].is("(") && tokens[i+2].is(")"):
+			values = append(values, currentTimestamp(now))
+			i += 3
+		case isNiladicCall(tok.text) && tok.kind == tokenIdent:
+			current, _ := currentTime(tok.text, now)
+			values = append(values, current)
+			i++
+		case tok.is("-") && tokens[i+1].kind == tokenNumber:
+			values = append(values, "-"+tokens[i+1].text)
+			i += 2
+		case tok.kind == tokenEOF:
+			return nil, fmt.Errorf("invalid IN list (%s): missing value after the last comma", list)
+		case tok.kind == tokenSymbol:
+			return nil, fmt.Errorf("invalid IN list (%s): unexpected %q", list, tok.text)
+		default:
+			values = append(values, tok.text)
+			i++
+		}
+	}
+	return values, nil
+}
+
+// parseBool reads a boolean literal: TRUE or FALSE in any case, or 1 or 0
+func parseBool(val string) (bool, bool) {
+	switch strings.ToUpper(unquote(val)) {
+	case "TRUE", "1":
+		return true, true
+	case "FALSE", "0":
+		return false, true
+	default:
+		return false, false
 	}
 }
 
 // Helper function to compare values with proper type handling
 func compareValues(rowVal interface{}, valStr string) int {
+	// Booleans compare with any spelling of a boolean literal, false first
+	if rowBool, ok := rowVal.(bool); ok {
+		if valBool, ok := parseBool(valStr); ok {
+			switch {
+			case rowBool == valBool:
+				return 0
+			case valBool:
+				return -1
+			default:
+				return 1
+			}
+		}
+	}
+
+	// Decimals compare exactly with a decimal literal
+	if rowDec, ok := rowVal.(Decimal); ok {
+		if valDec, err := parseDecimal(joinSign(valStr)); err == nil {
+			return rowDec.Cmp(valDec)
+		}
+	}
+
+	// Integers compare exactly, also beyond the 2^53 a float64 holds
+	if rowInt, ok := rowVal.(int64); ok {
+		if valInt, err := strconv.ParseInt(joinSign(valStr), 10, 64); err == nil {
+			return cmp.Compare(rowInt, valInt)
+		}
+	}
+
 	// Try to convert both to numbers first
 	if rowNum, valNum, err := convertToNumbers(rowVal, valStr); err == nil {
 		if rowNum == valNum {
@@ -429,8 +1275,13 @@ func compareValues(rowVal interface{}, valStr string) int {
 		}
 	}
 
+	// Dates compare chronologically
+	if rowDate, valDate, ok := convertToDates(rowVal, valStr); ok {
+		return rowDate.Compare(valDate)
+	}
+
 	// Fall back to string comparison
-	rowStr := fmt.Sprint(rowVal)
+	rowStr := formatValue(rowVal)
 	if rowStr == valStr {
 		return 0
 	} else if rowStr < valStr {
@@ -442,212 +1293,823 @@ func compareValues(rowVal interface{}, valStr string) int {
 
 // Helper function to convert values to numbers if possible
 func convertToNumbers(rowVal interface{}, valStr string) (float64, float64, error) {
-	var rowNum, valNum float64
+	var valNum float64
 	var err error
 
 	// Convert row value
-	switch v := rowVal.(type) {
+	rowNum, ok := toFloat64(rowVal)
+	if !ok {
+		return 0, 0, fmt.Errorf("not a number")
+	}
+
+	// Convert comparison value, rounded as a FLOAT column stores it so that
+	// 1.1 matches the float32 1.1
+	valNum, err = strconv.ParseFloat(joinSign(valStr), 64)
+	if err != nil {
+		return 0, 0, err
+	}
+	if _, ok := rowVal.(float32); ok {
+		valNum = float64(float32(valNum))
+	}
+
+	return rowNum, valNum, nil
+}
+
+// toFloat64 converts any numeric value to float64
+func toFloat64(val any) (float64, bool) {
+	switch v := val.(type) {
 	case int, int8, int16, int32, int64:
-		rowNum = float64(reflect.ValueOf(v).Int())
+		return float64(reflect.ValueOf(v).Int()), true
 	case uint, uint8, uint16, uint32, uint64:
-		rowNum = float64(reflect.ValueOf(v).Uint())
+		return float64(reflect.ValueOf(v).Uint()), true
 	case float32:
-		rowNum = float64(v)
+		return float64(v), true
 	case float64:
-		rowNum = v
+		return v, true
+	case Decimal:
+		return v.Float64(), true
 	default:
-		return 0, 0, fmt.Errorf("not a number")
+		return 0, false
+	}
+}
+
+// joinSign removes the spaces between a leading sign and the number after
+// it, so that "- 10" reads as -10
+func joinSign(val string) string {
+	if len(val) > 1 && (val[0] == '-' || val[0] == '+') {
+		return val[:1] + strings.TrimSpace(val[1:])
+	}
+	return val
+}
+
+const (
+	dateLayout      = "2006-01-02"
+	timestampLayout = "2006-01-02 15:04:05"
+)
+
+// parseTime parses a timestamp, or a date which is taken as its midnight
+func parseTime(val string) (time.Time, error) {
+	if t, err := time.Parse(timestampLayout, val); err == nil {
+		return t, nil
+	}
+	return time.Parse(dateLayout, val)
+}
+
+// dateOf returns the midnight UTC of the date of t, as DATE values are stored
+func dateOf(t time.Time) time.Time {
+	year, month, day := t.Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+// formatTime writes a DATE or TIMESTAMP value, a time at midnight as its
+// date alone, which parseTime reads back as the same time
+func formatTime(t time.Time) string {
+	if hour, min, sec := t.Clock(); hour == 0 && min == 0 && sec == 0 {
+		return t.Format(dateLayout)
 	}
+	return t.Format(timestampLayout)
+}
 
-	// Convert comparison value
-	valNum, err = strconv.ParseFloat(valStr, 64)
+// Helper function to parse both values as dates if possible
+func convertToDates(rowVal any, valStr string) (time.Time, time.Time, bool) {
+	rowDate, ok := rowVal.(time.Time)
+	if !ok {
+		rowStr, ok := rowVal.(string)
+		if !ok {
+			return time.Time{}, time.Time{}, false
+		}
+		var err error
+		if rowDate, err = parseTime(rowStr); err != nil {
+			return time.Time{}, time.Time{}, false
+		}
+	}
+	valDate, err := parseTime(valStr)
 	if err != nil {
-		return 0, 0, err
+		return time.Time{}, time.Time{}, false
 	}
+	return rowDate, valDate, true
+}
 
-	return rowNum, valNum, nil
+// orderTerm is a column of an ORDER BY clause, its direction, ASC or DESC,
+// and where nulls go, FIRST, LAST or empty for the default
+type orderTerm struct {
+	column string
+	dir    string
+	nulls  string
 }
 
-func parseOrderByClause(orderByClause string) (string, string, error) {
+// parseOrderByClause parses a comma separated list of columns, each
+// optionally followed by its direction and by NULLS FIRST or NULLS LAST
+func parseOrderByClause(orderByClause string) ([]orderTerm, error) {
 	if orderByClause == "" {
-		return "", "", fmt.Errorf("empty order by clause")
+		return nil, fmt.Errorf("empty order by clause")
+	}
+	var terms []orderTerm
+	for _, part := range splitList(orderByClause) {
+		term, err := parseOrderTerm(part)
+		if err != nil {
+			return nil, err
+		}
+		terms = append(terms, term)
 	}
+	return terms, nil
+}
 
-	parts := strings.Fields(strings.TrimSpace(orderByClause))
+func parseOrderTerm(clause string) (orderTerm, error) {
+	parts := strings.Fields(strings.TrimSpace(clause))
 	if len(parts) == 0 {
-		return "", "", fmt.Errorf("invalid order by clause")
+		return orderTerm{}, fmt.Errorf("invalid order by clause")
 	}
 
 	col := parts[0]
+	// An aggregate such as COUNT(DISTINCT id) or a function call such as
+	// ROUND(price, 1) holds spaces up to its closing parenthesis
+	if strings.Contains(col, "(") && !strings.HasSuffix(col, ")") {
+		clause := strings.TrimSpace(clause)
+		end, depth := -1, 0
+		for i := 0; i < len(clause) && end < 0; i++ {
+			switch clause[i] {
+			case '(':
+				depth++
+			case ')':
+				if depth--; depth == 0 {
+					end = i
+				}
+			}
+		}
+		if end < 0 {
+			return orderTerm{}, fmt.Errorf("invalid order by clause")
+		}
+		col = clause[:end+1]
+		parts = append([]string{col}, strings.Fields(clause[end+1:])...)
+	}
 	direction := "ASC" // Default direction
 
+	var nulls string
+	if n := len(parts); n > 2 && strings.EqualFold(parts[n-2], "NULLS") {
+		nulls = strings.ToUpper(parts[n-1])
+		if nulls != "FIRST" && nulls != "LAST" {
+			return orderTerm{}, fmt.Errorf("NULLS must be followed by FIRST or LAST, got %s", parts[n-1])
+		}
+		parts = parts[:n-2]
+	}
+	if len(parts) > 2 {
+		return orderTerm{}, fmt.Errorf("invalid order by clause")
+	}
 	if len(parts) > 1 {
 		upperDir := strings.ToUpper(parts[1])
 		if upperDir == "ASC" || upperDir == "DESC" {
 			direction = upperDir
 		} else {
-			return "", "", fmt.Errorf("invalid order by direction")
+			return orderTerm{}, fmt.Errorf("invalid order by direction")
 		}
 	}
 
-	return col, direction, nil
+	return orderTerm{column: col, dir: direction, nulls: nulls}, nil
 }
 
+// parseLimitClause returns the row count of a LIMIT clause, or -1 when there
+// is none. LIMIT 0 returns no rows.
 func parseLimitClause(limitClause string) (int, error) {
 	if limitClause != "" {
+		if strings.Contains(limitClause, ",") {
+			return 0, fmt.Errorf("invalid limit clause: LIMIT offset, count cannot be used with OFFSET")
+		}
 		limit, err := strconv.Atoi(limitClause)
 		if err != nil {
 			return 0, fmt.Errorf("invalid limit clause: %v", err)
 		}
 		return limit, nil
 	}
+	return -1, nil
+}
+
+func parseOffsetClause(offsetClause string) (int, error) {
+	if offsetClause != "" {
+		offset, err := strconv.Atoi(offsetClause)
+		if err != nil {
+			return 0, fmt.Errorf("invalid offset clause: %v", err)
+		}
+		if offset < 0 {
+			return 0, fmt.Errorf("invalid offset clause: %d is negative", offset)
+		}
+		return offset, nil
+	}
 	return 0, nil
 }
 
 // Helper functions for join processing
-func parseJoinClause(joinClause string) (string, string, error) {
-	// Expected format: "JOIN table ON condition"
-	parts := strings.SplitN(joinClause, "ON", 2)
-	if len(parts) != 2 {
-		return "", "", fmt.Errorf("invalid join syntax")
+
+// parseJoinClause returns the table reference and ON condition of a join,
+// along with its kind: "" for an inner join, LEFT, FULL or CROSS, which has
+// no condition. A USING clause is returned whole as the condition.
+func parseJoinClause(joinClause string) (string, string, string, error) {
+	// Expected format: "[INNER | LEFT|FULL [OUTER]] JOIN table [[AS] alias] ON condition",
+	// the same with "USING (column, ...)" instead of the ON condition,
+	// or "CROSS JOIN table [[AS] alias]"
+	joinClause = strings.TrimSpace(joinClause)
+	if matches := crossJoinRegex.FindStringSubmatch(joinClause); matches != nil {
+		return matches[1], "", "CROSS", nil
+	}
+	matches := joinRegex.FindStringSubmatch(joinClause)
+	if matches == nil {
+		return "", "", "", fmt.Errorf("invalid join syntax")
+	}
+	condition := matches[3]
+	if matches[4] != "" {
+		condition = matches[4]
+	}
+	return matches[2], strings.TrimSpace(condition), strings.ToUpper(matches[1]), nil
+}
+
+// joinOn is the ON condition of a join between two tables. An equality of
+// a column of each table, written in either order, matches rows by value
+// through a hash map; any other condition is evaluated as a WHERE clause on
+// each pair of rows. A CROSS JOIN has no condition and matches every pair.
+type joinOn struct {
+	leftCol  string // column of the main table of an equality
+	rightCol string // column of the join table of an equality
+	keys     joinKeys
+	where    string // any other condition
+	types    columnTypes
+}
+
+// parseJoinOn parses the ON condition of a join of kind between the tables
+func parseJoinOn(kind string, condition string, mainTable *Table, joinTable *Table) (*joinOn, error) {
+	if kind == "CROSS" {
+		return &joinOn{}, nil
+	}
+	if strings.TrimSpace(condition) == "" {
+		return nil, fmt.Errorf("invalid join condition: missing ON condition")
+	}
+	if matches := usingRegex.FindStringSubmatch(condition); matches != nil {
+		var err error
+		if condition, err = usingCondition(matches[1], mainTable, joinTable); err != nil {
+			return nil, err
+		}
 	}
+	if left, right, ok := equiJoinColumns(condition, mainTable.Name, joinTable.Name); ok && mainTable.columnExists(left) && joinTable.columnExists(right) {
+		if keys, ok := joinKeysOf(typesOf(mainTable)[left], typesOf(joinTable)[right]); ok {
+			return &joinOn{leftCol: left, rightCol: right, keys: keys}, nil
+		}
+	}
+	tables := []*Table{mainTable, joinTable}
+	if _, err := parseWhere(condition); err != nil {
+		return nil, fmt.Errorf("invalid join condition: %v", err)
+	}
+	if err := checkAmbiguous(tables, condition); err != nil {
+		return nil, err
+	}
+	if err := checkWhereColumns(tables, condition); err != nil {
+		return nil, err
+	}
+	// checkWhereColumns looks at the left side of each comparison, while
+	// here the right side names columns as well
+	tokens, err := tokenize(condition)
+	if err != nil {
+		return nil, err
+	}
+	for i := 0; i+2 < len(tokens); i++ {
+		if tokens[i].kind != tokenIdent || !tokens[i+1].is(".") || tokens[i+2].kind != tokenIdent {
+			continue
+		}
+		for _, table := range tables {
+			if table.Name == tokens[i].text && !table.columnExists(tokens[i+2].text) {
+				return nil, fmt.Errorf("column %s.%s does not exist in table %s", table.Name, tokens[i+2].text, table.Name)
+			}
+		}
+	}
+	return &joinOn{where: condition, types: typesOf(tables...)}, nil
+}
+
+// usingCondition translates the column list of JOIN ... USING into the ON
+// condition it stands for, an equality of each column of the main table with
+// the same column of the join table
+func usingCondition(list string, mainTable *Table, joinTable *Table) (string, error) {
+	columns := splitList(list)
+	if strings.TrimSpace(list) == "" {
+		return "", fmt.Errorf("invalid join condition: USING needs at least one column")
+	}
+	var equalities []string
+	for i, col := range columns {
+		col = strings.TrimSpace(col)
+		if !columnRegex.MatchString(col) || strings.Contains(col, ".") {
+			return "", fmt.Errorf("invalid join condition: USING takes column names, not %q", col)
+		}
+		if slices.Contains(columns[:i], col) {
+			return "", fmt.Errorf("invalid join condition: column %s is listed twice in USING", col)
+		}
+		columns[i] = col
+		for _, table := range []*Table{mainTable, joinTable} {
+			if !table.columnExists(col) {
+				return "", fmt.Errorf("column %s in USING does not exist in table %s", col, table.Name)
+			}
+		}
+		equalities = append(equalities, fmt.Sprintf("%s.%s = %s.%s", mainTable.Name, col, joinTable.Name, col))
+	}
+	return strings.Join(equalities, " AND "), nil
+}
+
+// equiJoinColumns returns the columns of the main and join tables that a
+// condition of the form a.x = b.y compares, whichever table is named first
+func equiJoinColumns(condition string, mainTable string, joinTable string) (string, string, bool) {
+	left, op, right, ok := splitComparison(condition)
+	if !ok || op != "=" || mainTable == joinTable {
+		return "", "", false
+	}
+	leftRef := qualifiedNameRegex.FindStringSubmatch(left)
+	rightRef := qualifiedNameRegex.FindStringSubmatch(right)
+	if leftRef == nil || rightRef == nil || leftRef[0] != left || rightRef[0] != right {
+		return "", "", false
+	}
+	switch {
+	case leftRef[1] == mainTable && rightRef[1] == joinTable:
+		return leftRef[2], rightRef[2], true
+	case leftRef[1] == joinTable && rightRef[1] == mainTable:
+		return rightRef[2], leftRef[2], true
+	}
+	return "", "", false
+}
+
+// matches reports whether a row of the main table and a row of the join
+// table satisfy a condition that is not an equality
+func (on *joinOn) matches(db *Database, mainTable string, mainRow Row, joinTable string, joinRow Row, now time.Time) (bool, error) {
+	if on.where == "" {
+		return true, nil
+	}
+	return db.evaluateWhere(joinedRow(mainTable, mainRow, joinTable, joinRow), on.where, on.types, now)
+}
+
+// hashMatches returns the positions of the rows of the join table that equal
+// each row of the main table on the columns of an equality, in table order.
+// The smaller table goes into a hash map by its column and the rows of the
+// larger one look their value up in it.
+func (on *joinOn) hashMatches(mainTable *Table, joinTable *Table) [][]int {
+	matches := make([][]int, len(mainTable.Rows))
+	if len(mainTable.Rows) < len(joinTable.Rows) {
+		positions := hashRows(mainTable.Rows, on.leftCol, on.keys)
+		for j, joinRow := range joinTable.Rows {
+			for _, i := range positions[joinKey(joinRow[on.rightCol], on.keys)] {
+				matches[i] = append(matches[i], j)
+			}
+		}
+		return matches
+	}
+	positions := hashRows(joinTable.Rows, on.rightCol, on.keys)
+	for i, mainRow := range mainTable.Rows {
+		matches[i] = positions[joinKey(mainRow[on.leftCol], on.keys)]
+	}
+	return matches
+}
+
+// hashRows maps the values of a column to the positions of the rows holding
+// them, nulls are left out as in WHERE they equal nothing
+func hashRows(rows []Row, col string, keys joinKeys) map[any][]int {
+	positions := make(map[any][]int)
+	for i, row := range rows {
+		if val := row[col]; val != nil {
+			key := joinKey(val, keys)
+			positions[key] = append(positions[key], i)
+		}
+	}
+	return positions
+}
+
+// joinKeys is how a hash join keys the values of its columns, chosen by their
+// types so that values WHERE finds equal get the same key
+type joinKeys int
+
+const (
+	keysAsStored joinKeys = iota // values as stored, so integers exactly
+	keysExact                    // numbers as exact fractions, when a column is DECIMAL
+	keysFloat                    // numbers as float64, when a column is DOUBLE or FLOAT
+)
+
+// joinKeysOf returns how a hash join of columns of the types keys their
+// values. It is false for types of different kinds, such as VARCHAR and
+// DATE, whose values only the nested loop compares by converting them.
+func joinKeysOf(left, right ColumnType) (joinKeys, bool) {
+	if typeKind(left) != typeKind(right) {
+		return 0, false
+	}
+	switch {
+	case left == COLUMN_TYPE_DOUBLE, left == COLUMN_TYPE_FLOAT, right == COLUMN_TYPE_DOUBLE, right == COLUMN_TYPE_FLOAT:
+		return keysFloat, true
+	case left == COLUMN_TYPE_DECIMAL, right == COLUMN_TYPE_DECIMAL:
+		return keysExact, true
+	}
+	return keysAsStored, true
+}
 
-	joinPart := strings.TrimSpace(parts[0])
-	joinTable := strings.TrimSpace(strings.TrimPrefix(joinPart, "JOIN"))
-	if joinTable == "" {
-		return "", "", fmt.Errorf("missing join table name")
+// typeKind groups the column types whose values compare with each other
+func typeKind(colType ColumnType) string {
+	switch colType {
+	case COLUMN_TYPE_INT, COLUMN_TYPE_DOUBLE, COLUMN_TYPE_FLOAT, COLUMN_TYPE_DECIMAL:
+		return "number"
+	case COLUMN_TYPE_DATE, COLUMN_TYPE_TIMESTAMP:
+		return "time"
+	case COLUMN_TYPE_VARCHAR, COLUMN_TYPE_ENUM:
+		return "text"
 	}
+	return string(colType)
+}
+
+// timeKey is the key of a DATE or TIMESTAMP value, its instant, so a DATE
+// joins the TIMESTAMP of its midnight
+type timeKey struct {
+	sec  int64
+	nsec int
+}
 
-	return joinTable, strings.TrimSpace(parts[1]), nil
+// exactKey is the key of a number joined with a DECIMAL, the fraction it
+// equals in lowest terms
+type exactKey string
+
+// joinKey returns the key a value is hashed by in a join whose columns are
+// keyed as keys
+func joinKey(val any, keys joinKeys) any {
+	if t, ok := val.(time.Time); ok {
+		return timeKey{t.Unix(), t.Nanosecond()}
+	}
+	switch keys {
+	case keysFloat:
+		if f, ok := toFloat64(val); ok {
+			return f
+		}
+	case keysExact:
+		switch v := val.(type) {
+		case int64:
+			return exactKey(big.NewRat(v, 1).RatString())
+		case Decimal:
+			return exactKey(v.rat().RatString())
+		}
+	}
+	return val
 }
 
-func parseJoinCondition(condition string) (string, string, error) {
-	// Expected format: "table1.column = table2.column"
-	parts := strings.Split(condition, "=")
-	if len(parts) != 2 {
-		return "", "", fmt.Errorf("invalid join condition")
+// joinPairs calls add for every pair of rows that satisfy the ON condition,
+// which is every pair in a CROSS JOIN as long as there are no more than the
+// limit set with WithMaxCrossJoinRows. A LEFT or FULL join also adds each
+// unmatched row of the main table with nulls for the join table, and a FULL
+// join each unmatched row of the join table with nulls for the main table.
+// Pairs come in the order of the main table, then of the join table, however
+// they are found.
+func (db *Database) joinPairs(mainTable *Table, joinTable *Table, on *joinOn, kind string, add func(mainRow Row, joinRow Row) error, now time.Time) error {
+	if kind == "CROSS" {
+		limit := db.maxCrossJoinRows
+		if limit <= 0 {
+			limit = DefaultMaxCrossJoinRows
+		}
+		if len(mainTable.Rows) > 0 && len(joinTable.Rows) > limit/len(mainTable.Rows) {
+			return fmt.Errorf("CROSS JOIN of %s and %s would produce %d rows, more than the limit of %d", mainTable.Name, joinTable.Name, len(mainTable.Rows)*len(joinTable.Rows), limit)
+		}
+	}
+	var hashed [][]int
+	if on.leftCol != "" {
+		hashed = on.hashMatches(mainTable, joinTable)
 	}
+	joinMatched := make([]bool, len(joinTable.Rows))
+	for i, mainRow := range mainTable.Rows {
+		found := false
+		addMatch := func(j int) error {
+			found = true
+			joinMatched[j] = true
+			return add(mainRow, joinTable.Rows[j])
+		}
+		if hashed != nil {
+			for _, j := range hashed[i] {
+				if err := addMatch(j); err != nil {
+					return err
+				}
+			}
+		} else {
+			for j, joinRow := range joinTable.Rows {
+				matched, err := on.matches(db, mainTable.Name, mainRow, joinTable.Name, joinRow, now)
+				if err != nil {
+					return err
+				}
+				if matched {
+					if err := addMatch(j); err != nil {
+						return err
+					}
+				}
+			}
+		}
+		if !found && (kind == "LEFT" || kind == "FULL") {
+			if err := add(mainRow, joinTable.nullRow()); err != nil {
+				return err
+			}
+		}
+	}
+	if kind != "FULL" {
+		return nil
+	}
+	for j, joinRow := range joinTable.Rows {
+		if !joinMatched[j] {
+			if err := add(mainTable.nullRow(), joinRow); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
 
-	left := strings.TrimSpace(parts[0])
-	right := strings.TrimSpace(parts[1])
+// combineRows merges a row of the main table with a row of the join table.
+// Values of the join table win, except nulls filling in for a missing match.
+func combineRows(mainRow Row, joinRow Row) Row {
+	combinedRow := make(Row)
+	maps.Copy(combinedRow, mainRow)
+	for col, val := range joinRow {
+		if _, exists := combinedRow[col]; !exists || val != nil {
+			combinedRow[col] = val
+		}
+	}
+	return combinedRow
+}
 
-	// Extract column names
-	leftParts := strings.Split(left, ".")
-	if len(leftParts) != 2 {
-		return "", "", fmt.Errorf("invalid left side of join condition")
+// joinedRow combines a pair of joined rows like combineRows and also holds
+// every value under its table.column name, so either table can be named
+func joinedRow(mainTable string, mainRow Row, joinTable string, joinRow Row) Row {
+	row := combineRows(mainRow, joinRow)
+	for col, val := range mainRow {
+		row[mainTable+"."+col] = val
 	}
-	rightParts := strings.Split(right, ".")
-	if len(rightParts) != 2 {
-		return "", "", fmt.Errorf("invalid right side of join condition")
+	for col, val := range joinRow {
+		row[joinTable+"."+col] = val
 	}
+	return row
+}
 
-	return leftParts[1], rightParts[1], nil
+// qualifiedRow returns a copy of a row of a single table that also holds
+// every value under its table.column name
+func qualifiedRow(table string, row Row) Row {
+	qualified := maps.Clone(row)
+	for col, val := range row {
+		qualified[table+"."+col] = val
+	}
+	return qualified
 }
 
 // Update updates rows in a table
 func (db *Database) Update(tableName string, setClause string, whereClause string) (string, error) {
+	return outputOf(db.UpdateResult(tableName, setClause, whereClause))
+}
+
+// UpdateResult updates rows in a table like Update and returns how many it
+// changed
+func (db *Database) UpdateResult(tableName string, setClause string, whereClause string) (*Result, error) {
+	if err := db.lock(); err != nil {
+		return nil, err
+	}
+	defer db.mu.Unlock()
+	now := time.Now()
 	table, exists := db.Tables[tableName]
 	if !exists {
-		return "", fmt.Errorf("table %s does not exist", tableName)
+		return nil, fmt.Errorf("table %s does not exist", tableName)
+	}
+	// Convert every assignment before changing any row
+	assignments := make(Row)
+	var computed map[string]arithExpr
+	for _, setPart := range splitList(setClause) {
+		parts := strings.SplitN(setPart, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid set clause: %s", setPart)
+		}
+		col := strings.TrimSpace(parts[0])
+		val := strings.TrimSpace(parts[1])
+		column, err := table.GetColumn(col)
+		if err != nil {
+			return nil, err
+		}
+		colType := column.Type
+		if !isValidColumnType(colType) {
+			return nil, fmt.Errorf("invalid column type: %s", colType)
+		}
+
+		// simple type conversion
+		convertedVal, err := columnTypeConversion(colType, val, now)
+		if err == nil {
+			assignments[col] = convertedVal
+			continue
+		}
+		// A value that is not a literal of a numeric column may be
+		// arithmetic over the row, as in count = count + 1
+		if !isNumericType(colType) {
+			return nil, err
+		}
+		expr, parseErr := parseArithmetic(val)
+		if parseErr != nil {
+			return nil, err
+		}
+		if err := checkArithAssignment(table, column, expr, val); err != nil {
+			return nil, err
+		}
+		if computed == nil {
+			computed = make(map[string]arithExpr)
+		}
+		computed[col] = expr
+	}
+	return db.updateRows(table, assignments, computed, whereClause, now)
+}
+
+// checkArithAssignment checks that the values of expr, the arithmetic src,
+// can be stored in column
+func checkArithAssignment(table *Table, column Column, expr arithExpr, src string) error {
+	if !isNumericType(column.Type) {
+		return fmt.Errorf("cannot assign %s to %s column %s, arithmetic needs a numeric column", src, column.Type, column.Name)
+	}
+	exprType, err := arithType(expr, []*Table{table})
+	if err != nil {
+		return err
+	}
+	if column.Type == COLUMN_TYPE_INT && exprType != COLUMN_TYPE_INT {
+		return fmt.Errorf("cannot assign %s to INT column %s, the expression is not an integer", src, column.Name)
+	}
+	return nil
+}
+
+// isNumericType reports whether arithmetic results can be stored in columns of the type
+func isNumericType(colType ColumnType) bool {
+	switch colType {
+	case COLUMN_TYPE_INT, COLUMN_TYPE_DOUBLE, COLUMN_TYPE_FLOAT, COLUMN_TYPE_DECIMAL:
+		return true
+	}
+	return false
+}
+
+// arithValue converts the value of an arithmetic expression to the type
+// stored in columns of colType
+func arithValue(colType ColumnType, val any) (any, error) {
+	switch colType {
+	case COLUMN_TYPE_INT:
+		return val, nil
+	case COLUMN_TYPE_DECIMAL:
+		text := fmt.Sprint(val)
+		if num, ok := val.(float64); ok {
+			text = strconv.FormatFloat(num, 'f', -1, 64)
+		}
+		num, err := parseDecimal(text)
+		if err != nil {
+			return nil, fmt.Errorf("invalid decimal value %s", text)
+		}
+		return num, nil
+	}
+	num, _ := toFloat64(val)
+	if colType == COLUMN_TYPE_FLOAT {
+		return float32(num), nil
+	}
+	return num, nil
+}
+
+// updateRows applies converted assignments to the rows matching whereClause.
+// The computed expressions are evaluated against each row before it changes.
+func (db *Database) updateRows(table *Table, assignments Row, computed map[string]arithExpr, whereClause string, now time.Time) (*Result, error) {
+	whereClause, err := db.resolveInSubqueries(whereClause, now)
+	if err != nil {
+		return nil, err
 	}
-	if len(table.Rows) == 0 {
-		return "", fmt.Errorf("table %s is empty", tableName)
+	if err := checkWhereColumns([]*Table{table}, whereClause); err != nil {
+		return nil, err
 	}
+	types := typesOf(table)
 	var rowCount int
 	var updatedIndices []int
-	for i, row := range table.Rows {
-		if db.evaluateWhere(row, whereClause) {
+	for _, i := range table.candidates(whereClause, now) {
+		matched, err := db.evaluateWhere(table.Rows[i], whereClause, types, now)
+		if err != nil {
+			return nil, err
+		}
+		if matched {
 			updatedIndices = append(updatedIndices, i)
 			rowCount++
 		}
 	}
+	if err := table.validateNotNull(assignments, false); err != nil {
+		return nil, err
+	}
+	if err := table.validateValues(assignments); err != nil {
+		return nil, err
+	}
 	if rowCount == 0 {
-		return "", fmt.Errorf("no rows found")
+		return &Result{Output: "0 rows updated"}, nil
 	}
-	setParts := strings.SplitSeq(setClause, ",")
-	for setPart := range setParts {
-		parts := strings.Split(setPart, "=")
-		if len(parts) != 2 {
-			return "", fmt.Errorf("invalid set clause: %s", setPart)
+	rowAssignments := make([]Row, len(updatedIndices))
+	for n, i := range updatedIndices {
+		rowAssignments[n] = assignments
+		if len(computed) == 0 {
+			continue
 		}
-		col := strings.TrimSpace(parts[0])
-		val := strings.TrimSpace(parts[1])
-		// find column type
-		var colType ColumnType
-		for _, column := range table.Columns {
-			if column.Name == col {
-				colType = column.Type
-				break
+		values := maps.Clone(assignments)
+		for col, expr := range computed {
+			val, ok, err := expr.eval(table.Rows[i])
+			if err != nil {
+				return nil, err
 			}
+			if ok {
+				column, _ := table.GetColumn(col)
+				if val, err = arithValue(column.Type, val); err != nil {
+					return nil, err
+				}
+			}
+			values[col] = val
 		}
-		if !isValidColumnType(colType) {
-			return "", fmt.Errorf("invalid column type: %s", colType)
-		}
-
-		// simple type conversion
-		convertedVal, err := columnTypeConversion(colType, val)
-		if err != nil {
-			return "", err
+		if err := table.validateNotNull(values, false); err != nil {
+			return nil, err
 		}
-		for _, i := range updatedIndices {
-			table.Rows[i][col] = convertedVal
+		if err := table.validateValues(values); err != nil {
+			return nil, err
 		}
+		rowAssignments[n] = values
+	}
+	if err := table.validateKeyChanges(updatedIndices, rowAssignments); err != nil {
+		return nil, err
 	}
-	err := db.saveToFileGob()
+	for n, i := range updatedIndices {
+		maps.Copy(table.Rows[i], rowAssignments[n])
+	}
+	table.reindex()
+	err = db.save()
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-	return fmt.Sprintf("%d rows updated", rowCount), nil
+	return &Result{Output: fmt.Sprintf("%d rows updated", rowCount), RowsAffected: int64(rowCount)}, nil
 }
 
-// columnTypeConversion converts a string value to the appropriate type
-func columnTypeConversion(colType ColumnType, val string) (any, error) {
+// columnTypeConversion converts a string value to the appropriate type, now
+// is the time the statement started, the value of CURRENT_DATE and NOW()
+func columnTypeConversion(colType ColumnType, val string, now time.Time) (any, error) {
+	// An unquoted NULL is no value, whatever the column type
+	if strings.EqualFold(val, "NULL") {
+		return nil, nil
+	}
 	switch colType {
 	case COLUMN_TYPE_INT:
-		var num int64
-		_, err := fmt.Sscanf(val, "%d", &num)
+		num, err := strconv.ParseInt(joinSign(val), 10, 64)
 		if err != nil {
 			return nil, fmt.Errorf("invalid integer value for column type %s", colType)
 		}
 		return num, nil
-	case COLUMN_TYPE_VARCHAR:
-		return strings.Trim(val, "'\""), nil
+	case COLUMN_TYPE_VARCHAR, COLUMN_TYPE_ENUM:
+		return unquote(val), nil
 	case COLUMN_TYPE_DOUBLE:
-		var num float64
-		_, err := fmt.Sscanf(val, "%f", &num)
+		num, err := strconv.ParseFloat(joinSign(val), 64)
 		if err != nil {
 			return nil, fmt.Errorf("invalid double value for column type %s", colType)
 		}
 		return num, nil
 	case COLUMN_TYPE_FLOAT:
-		var num float32
-		_, err := fmt.Sscanf(val, "%f", &num)
+		num, err := strconv.ParseFloat(joinSign(val), 32)
 		if err != nil {
 			return nil, fmt.Errorf("invalid float value for column type %s", colType)
 		}
+		return float32(num), nil
+	case COLUMN_TYPE_DECIMAL:
+		num, err := parseDecimal(joinSign(val))
+		if err != nil {
+			return nil, fmt.Errorf("invalid decimal value for column type %s", colType)
+		}
 		return num, nil
 	case COLUMN_TYPE_BOOL:
-		var boolean bool
-		_, err := fmt.Sscanf(val, "%t", &boolean)
-		if err != nil {
+		boolean, ok := parseBool(val)
+		if !ok {
 			return nil, fmt.Errorf("invalid boolean value for column type %s", colType)
 		}
 		return boolean, nil
 	case COLUMN_TYPE_DATE:
-		const layout = "2006-01-02"
-		val = strings.Trim(val, "'\"")
-		parsed_Date, err := time.Parse(layout, val)
+		if current, ok := currentTime(val, now); ok {
+			val = current
+		}
+		// A timestamp, such as that of NOW(), keeps only its date
+		parsed, err := parseTime(unquote(val))
 		if err != nil {
 			return nil, fmt.Errorf("invalid date value for column type %s", colType)
 		}
-		formatted_Date := parsed_Date.Format(layout)
-		return formatted_Date, nil
+		return dateOf(parsed), nil
+	case COLUMN_TYPE_TIMESTAMP:
+		if current, ok := currentTime(val, now); ok {
+			val = current
+		}
+		parsed, err := parseTime(unquote(val))
+		if err != nil {
+			return nil, fmt.Errorf("invalid timestamp value for column type %s", colType)
+		}
+		return parsed, nil
 	default:
 		return val, nil
 	}
 }
 
+// rawValueConversion converts plain text, such as a CSV field, to the column type
+func rawValueConversion(colType ColumnType, val string, now time.Time) (any, error) {
+	if colType == COLUMN_TYPE_VARCHAR || colType == COLUMN_TYPE_ENUM {
+		return val, nil
+	}
+	return columnTypeConversion(colType, val, now)
+}
+
 func (db *Database) String() string {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
 	tables := "Tables:\n"
 	for _, table := range db.Tables {
 		tables += fmt.Sprintf("%s\n", table)
@@ -657,7 +2119,9 @@ func (db *Database) String() string {
 
 // AllTables returns all tables in the database
 func (db *Database) AllTables() (map[string]*Table, error) {
-	db.mu.RLock()
+	if err := db.rlock(); err != nil {
+		return nil, err
+	}
 	defer db.mu.RUnlock()
 
 	return db.Tables, nil
@@ -665,19 +2129,54 @@ func (db *Database) AllTables() (map[string]*Table, error) {
 
 // tableExists checks if a table exists
 func (db *Database) tableExists(name string) bool {
-	db.mu.RLock()
-	defer db.mu.RUnlock()
 	_, exists := db.Tables[name]
 	return exists
 }
 
 // getTable retrieves a table by name
 func (db *Database) getTable(name string) (*Table, error) {
-	db.mu.RLock()
-	defer db.mu.RUnlock()
 	table, exists := db.Tables[name]
 	if !exists {
 		return nil, fmt.Errorf("table %s does not exist", name)
 	}
 	return table, nil
 }
+
+// tableRef returns the table a FROM or JOIN clause names as "table",
+// "table alias" or "table AS alias". An aliased table is returned as a copy
+// named after its alias, so for the rest of the query its columns are
+// qualified by the alias, and a table can be joined with itself.
+func (db *Database) tableRef(ref string) (*Table, error) {
+	name, alias, err := splitTableRef(ref)
+	if err != nil {
+		return nil, err
+	}
+	table, err := db.getTable(name)
+	if err != nil || alias == name {
+		return table, err
+	}
+	aliased := *table
+	aliased.Name = alias
+	return &aliased, nil
+}
+
+// splitTableRef splits a table reference into the table name and the name
+// the query knows it by, which is the table name when there is no alias
+func splitTableRef(ref string) (string, string, error) {
+	fields := strings.Fields(ref)
+	if len(fields) == 3 && strings.EqualFold(fields[1], "AS") {
+		fields = []string{fields[0], fields[2]}
+	}
+	if len(fields) == 0 || len(fields) > 2 || strings.EqualFold(fields[len(fields)-1], "AS") {
+		return "", "", fmt.Errorf("invalid table reference %s", ref)
+	}
+	alias := fields[len(fields)-1]
+	if len(fields) == 2 && slices.ContainsFunc(reservedAliases, func(kw string) bool { return strings.EqualFold(kw, alias) }) {
+		return "", "", fmt.Errorf("invalid table reference %s: %s is a keyword", ref, alias)
+	}
+	return fields[0], alias, nil
+}
+
+// reservedAliases are the keywords that may follow a table name in a FROM
+// clause and so cannot alias it
+var reservedAliases = []string{"CROSS", "FULL", "INNER", "JOIN", "LEFT", "ON", "OUTER", "RIGHT", "USING"}
@@ -0,0 +1,163 @@
+package database
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// maxDecimalPrecision is the most digits a DECIMAL holds, as many as an
+// int64 always fits
+const maxDecimalPrecision = 18
+
+// Decimal is an exact decimal number, Unscaled / 10^Scale. A DECIMAL(p,s)
+// column stores its values with scale s, so equal values are equal structs.
+type Decimal struct {
+	Unscaled int64
+	Scale    int
+}
+
+// parseDecimal parses a decimal literal such as -12.50, keeping the digits
+// after the point as its scale
+func parseDecimal(s string) (Decimal, error) {
+	text := s
+	negative := false
+	if text != "" && (text[0] == '-' || text[0] == '+') {
+		negative = text[0] == '-'
+		text = text[1:]
+	}
+	whole, frac, _ := strings.Cut(text, ".")
+	digits := whole + frac
+	if digits == "" || strings.Trim(digits, "0123456789") != "" {
+		return Decimal{}, fmt.Errorf("invalid decimal value %s", s)
+	}
+	if len(strings.TrimLeft(digits, "0")) > maxDecimalPrecision {
+		return Decimal{}, fmt.Errorf("decimal value %s has more than %d digits", s, maxDecimalPrecision)
+	}
+	unscaled, err := strconv.ParseInt(digits, 10, 64)
+	if err != nil {
+		return Decimal{}, fmt.Errorf("invalid decimal value %s", s)
+	}
+	if negative {
+		unscaled = -unscaled
+	}
+	return Decimal{Unscaled: unscaled, Scale: len(frac)}, nil
+}
+
+// String formats the decimal with all the digits of its scale
+func (d Decimal) String() string {
+	digits := strconv.FormatUint(absInt64(d.Unscaled), 10)
+	sign := ""
+	if d.Unscaled < 0 {
+		sign = "-"
+	}
+	if d.Scale <= 0 {
+		return sign + digits
+	}
+	if len(digits) <= d.Scale {
+		digits = strings.Repeat("0", d.Scale-len(digits)+1) + digits
+	}
+	point := len(digits) - d.Scale
+	return sign + digits[:point] + "." + digits[point:]
+}
+
+// MarshalJSON writes the decimal as a JSON number, without going through a float
+func (d Decimal) MarshalJSON() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+// Float64 returns the nearest float64
+func (d Decimal) Float64() float64 {
+	f, _ := strconv.ParseFloat(d.String(), 64)
+	return f
+}
+
+// Cmp compares two decimals by value, whatever their scales
+func (d Decimal) Cmp(other Decimal) int {
+	if d.Scale == other.Scale {
+		return cmp.Compare(d.Unscaled, other.Unscaled)
+	}
+	return d.rat().Cmp(other.rat())
+}
+
+func (d Decimal) rat() *big.Rat {
+	denom := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(d.Scale)), nil)
+	return new(big.Rat).SetFrac(big.NewInt(d.Unscaled), denom)
+}
+
+// rescale returns the decimal with the given scale, rounding half away from
+// zero when digits are dropped
+func (d Decimal) rescale(scale int) (Decimal, error) {
+	unscaled := d.Unscaled
+	for s := d.Scale; s < scale; s++ {
+		if absInt64(unscaled) > math.MaxInt64/10 {
+			return Decimal{}, fmt.Errorf("decimal value %s is out of range", d)
+		}
+		unscaled *= 10
+	}
+	for s := d.Scale; s > scale; s-- {
+		rest := unscaled % 10
+		unscaled /= 10
+		if rest >= 5 {
+			unscaled++
+		} else if rest <= -5 {
+			unscaled--
+		}
+	}
+	return Decimal{Unscaled: unscaled, Scale: scale}, nil
+}
+
+// add returns the exact sum of two decimals, at the larger of their scales
+func (d Decimal) add(other Decimal) (Decimal, error) {
+	scale := max(d.Scale, other.Scale)
+	a, err := d.rescale(scale)
+	if err != nil {
+		return Decimal{}, err
+	}
+	b, err := other.rescale(scale)
+	if err != nil {
+		return Decimal{}, err
+	}
+	sum := a.Unscaled + b.Unscaled
+	if (b.Unscaled > 0 && sum < a.Unscaled) || (b.Unscaled < 0 && sum > a.Unscaled) {
+		return Decimal{}, fmt.Errorf("decimal sum of %s and %s is out of range", d, other)
+	}
+	return Decimal{Unscaled: sum, Scale: scale}, nil
+}
+
+// digits returns the number of digits of the unscaled value
+func (d Decimal) digits() int {
+	return len(strconv.FormatUint(absInt64(d.Unscaled), 10))
+}
+
+func absInt64(n int64) uint64 {
+	if n < 0 {
+		return uint64(-(n + 1)) + 1
+	}
+	return uint64(n)
+}
+
+// parseDecimalArgs sets the precision and scale of a DECIMAL(p,s) column,
+// DECIMAL(p) has scale 0
+func (c *Column) parseDecimalArgs(args []string) error {
+	if len(args) == 0 || len(args) > 2 {
+		return fmt.Errorf("DECIMAL takes a precision and an optional scale")
+	}
+	precision, err := strconv.Atoi(strings.TrimSpace(args[0]))
+	if err != nil || precision <= 0 || precision > maxDecimalPrecision {
+		return fmt.Errorf("DECIMAL precision must be an integer from 1 to %d", maxDecimalPrecision)
+	}
+	scale := 0
+	if len(args) == 2 {
+		scale, err = strconv.Atoi(strings.TrimSpace(args[1]))
+		if err != nil || scale < 0 || scale > precision {
+			return fmt.Errorf("DECIMAL scale must be an integer from 0 to the precision %d", precision)
+		}
+	}
+	c.Precision = precision
+	c.Scale = scale
+	return nil
+}
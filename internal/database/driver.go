@@ -0,0 +1,197 @@
+package database
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"io"
+	"sync"
+)
+
+func init() {
+	sql.Register("godb", Driver{})
+}
+
+// Driver makes databases available through database/sql under the name
+// "godb". The data source name is the name given to NewDatabase:
+//
+//	db, err := sql.Open("godb", "app")
+//
+// Connections to the same name share one Database, so they see each other's
+// changes, and a transaction started on one of them covers them all. The
+// Database is closed with the last connection to it.
+type Driver struct{}
+
+// driverDatabase is a database opened by the driver and its number of open
+// connections
+type driverDatabase struct {
+	db    *Database
+	conns int
+}
+
+var (
+	driverMu        sync.Mutex
+	driverDatabases = make(map[string]*driverDatabase)
+)
+
+// Open returns a connection to the database with the given name, loading it
+// when no other connection has it open
+func (Driver) Open(name string) (driver.Conn, error) {
+	driverMu.Lock()
+	defer driverMu.Unlock()
+	opened, ok := driverDatabases[name]
+	if !ok {
+		db, err := NewDatabase(name)
+		if err != nil {
+			return nil, err
+		}
+		opened = &driverDatabase{db: db}
+		driverDatabases[name] = opened
+	}
+	opened.conns++
+	return &driverConn{db: opened.db, name: name}, nil
+}
+
+type driverConn struct {
+	db     *Database
+	name   string
+	closed bool
+}
+
+func (c *driverConn) Prepare(query string) (driver.Stmt, error) {
+	stmt, err := c.db.Prepare(query)
+	if err != nil {
+		return nil, err
+	}
+	return &driverStmt{stmt}, nil
+}
+
+// Close closes the database when no other connection has it open
+func (c *driverConn) Close() error {
+	driverMu.Lock()
+	defer driverMu.Unlock()
+	if c.closed {
+		return nil
+	}
+	c.closed = true
+	opened := driverDatabases[c.name]
+	if opened.conns--; opened.conns > 0 {
+		return nil
+	}
+	delete(driverDatabases, c.name)
+	return opened.db.Close()
+}
+
+func (c *driverConn) Begin() (driver.Tx, error) {
+	if _, err := c.db.Begin(); err != nil {
+		return nil, err
+	}
+	return driverTx{c.db}, nil
+}
+
+type driverTx struct {
+	db *Database
+}
+
+func (tx driverTx) Commit() error {
+	_, err := tx.db.Commit()
+	return err
+}
+
+func (tx driverTx) Rollback() error {
+	_, err := tx.db.Rollback()
+	return err
+}
+
+type driverStmt struct {
+	stmt *Stmt
+}
+
+func (s *driverStmt) Close() error {
+	return nil
+}
+
+func (s *driverStmt) NumInput() int {
+	return s.stmt.NumInput()
+}
+
+func (s *driverStmt) Exec(args []driver.Value) (driver.Result, error) {
+	result, err := s.stmt.Exec(driverArgs(args)...)
+	if err != nil {
+		return nil, err
+	}
+	return driverResult{result}, nil
+}
+
+func (s *driverStmt) Query(args []driver.Value) (driver.Rows, error) {
+	rows, columns, err := s.stmt.Query(driverArgs(args)...)
+	if err != nil {
+		return nil, err
+	}
+	return &driverRows{rows: rows, columns: columns}, nil
+}
+
+func driverArgs(args []driver.Value) []any {
+	values := make([]any, len(args))
+	for i, arg := range args {
+		values[i] = arg
+	}
+	return values
+}
+
+// driverResult is the outcome of a statement run by the driver
+type driverResult struct {
+	result *Result
+}
+
+func (r driverResult) LastInsertId() (int64, error) {
+	return r.result.LastInsertId, nil
+}
+
+func (r driverResult) RowsAffected() (int64, error) {
+	return r.result.RowsAffected, nil
+}
+
+type driverRows struct {
+	rows    []Row
+	columns []Column
+	next    int
+}
+
+func (r *driverRows) Columns() []string {
+	names := make([]string, len(r.columns))
+	for i, column := range r.columns {
+		names[i] = column.Name
+	}
+	return names
+}
+
+// ColumnTypeDatabaseTypeName returns the type of a column, such as VARCHAR
+func (r *driverRows) ColumnTypeDatabaseTypeName(index int) string {
+	return string(r.columns[index].Type)
+}
+
+func (r *driverRows) Close() error {
+	r.next = len(r.rows)
+	return nil
+}
+
+// Next converts the values of the next row to the types database/sql
+// accepts: FLOAT values become float64 and DECIMAL values their exact text.
+func (r *driverRows) Next(dest []driver.Value) error {
+	if r.next >= len(r.rows) {
+		return io.EOF
+	}
+	row := r.rows[r.next]
+	r.next++
+	for i, column := range r.columns {
+		switch val := row[column.Name].(type) {
+		case float32:
+			dest[i] = float64(val)
+		case Decimal:
+			dest[i] = val.String()
+		default:
+			dest[i] = val
+		}
+	}
+	return nil
+}
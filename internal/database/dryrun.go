@@ -0,0 +1,140 @@
+package database
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Impact describes what a statement would change if it ran
+type Impact struct {
+	Statement string // "DROP TABLE", "TRUNCATE TABLE", "DELETE" or "UPDATE"
+	Table     string
+	Rows      int  // rows that would be removed or changed
+	Filtered  bool // whether the statement has a WHERE clause
+}
+
+// DryRun parses a statement and reports what it would delete or update
+// without running it. It returns nil for statements that do not remove or
+// change existing data. The statement is read token by token, so a WHERE
+// inside a quoted string or a subquery does not count as its WHERE clause.
+func (db *Database) DryRun(sql string) (*Impact, error) {
+	sql = strings.TrimSpace(sql)
+	tokens, err := tokenize(sql)
+	if err != nil {
+		return nil, err
+	}
+	if err := db.rlock(); err != nil {
+		return nil, err
+	}
+	defer db.mu.RUnlock()
+	now := time.Now()
+	c := &tokenCursor{tokens: tokens}
+	switch first := c.next(); {
+	case first.is("DROP"):
+		if !c.next().is("TABLE") {
+			return nil, nil
+		}
+		return db.tableImpact("DROP TABLE", c)
+	case first.is("TRUNCATE"):
+		if c.peek().is("TABLE") {
+			c.next()
+		}
+		return db.tableImpact("TRUNCATE TABLE", c)
+	case first.is("DELETE"):
+		if err := c.expectKeyword("FROM"); err != nil {
+			return nil, err
+		}
+		table := c.next()
+		if table.kind != tokenIdent {
+			return nil, errorAt(table, "expected table name")
+		}
+		where, err := c.whereClause(sql)
+		if err != nil {
+			return nil, err
+		}
+		return db.countAffected("DELETE", table.text, where, now)
+	case first.is("UPDATE"):
+		table := c.next()
+		if table.kind != tokenIdent {
+			return nil, errorAt(table, "expected table name")
+		}
+		if err := c.expectKeyword("SET"); err != nil {
+			return nil, err
+		}
+		for depth := 0; c.peek().kind != tokenEOF && (depth > 0 || !c.peek().is("WHERE")); {
+			switch t := c.next(); {
+			case t.is("("):
+				depth++
+			case t.is(")"):
+				depth--
+			}
+		}
+		where, err := c.whereClause(sql)
+		if err != nil {
+			return nil, err
+		}
+		return db.countAffected("UPDATE", table.text, where, now)
+	default:
+		return nil, nil
+	}
+}
+
+// tableImpact reports the rows of the table named at the end of a DROP TABLE
+// or TRUNCATE TABLE statement
+func (db *Database) tableImpact(statement string, c *tokenCursor) (*Impact, error) {
+	name := c.next()
+	if name.kind != tokenIdent {
+		return nil, errorAt(name, "expected table name")
+	}
+	if err := c.expectEnd(); err != nil {
+		return nil, err
+	}
+	table, err := db.getTable(name.text)
+	if err != nil {
+		return nil, err
+	}
+	return &Impact{Statement: statement, Table: table.Name, Rows: len(table.Rows)}, nil
+}
+
+// whereClause returns the text of the WHERE clause that ends the statement,
+// or an empty string when there is none
+func (c *tokenCursor) whereClause(sql string) (string, error) {
+	where := c.next()
+	if where.kind == tokenEOF {
+		return "", nil
+	}
+	if !where.is("WHERE") {
+		return "", errorAt(where, "expected WHERE")
+	}
+	if t := c.peek(); t.kind == tokenEOF {
+		return "", errorAt(t, "expected condition")
+	}
+	return strings.TrimSpace(sql[where.pos+len(where.text):]), nil
+}
+
+func (db *Database) countAffected(statement string, tableName string, whereClause string, now time.Time) (*Impact, error) {
+	table, err := db.getTable(tableName)
+	if err != nil {
+		return nil, fmt.Errorf("table %s does not exist", tableName)
+	}
+	impact := &Impact{Statement: statement, Table: tableName, Filtered: whereClause != ""}
+	whereClause, err = db.resolveInSubqueries(whereClause, now)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkWhereColumns([]*Table{table}, whereClause); err != nil {
+		return nil, err
+	}
+	types := typesOf(table)
+	for _, i := range table.candidates(whereClause, now) {
+		matched, err := db.evaluateWhere(table.Rows[i], whereClause, types, now)
+		if err != nil {
+			return nil, err
+		}
+		if matched {
+			impact.Rows++
+		}
+	}
+	return impact, nil
+}
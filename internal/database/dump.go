@@ -0,0 +1,140 @@
+package database
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+var (
+	dumpRegex   = regexp.MustCompile(`(?i)^DUMP(?:\s+DATABASE)?\s*$`)
+	exportRegex = regexp.MustCompile(`(?i)^EXPORT\s+DATABASE\s+TO\s+('(?:[^']|'')*')\s*$`)
+)
+
+// Dump writes the database as SQL statements that recreate it when run
+// through Execute one at a time: CREATE TABLE with the types, constraints
+// and defaults of the columns, CREATE INDEX and an INSERT for every row.
+// Each statement ends with a semicolon and a line break. Tables come after
+// the tables their foreign keys reference.
+func (db *Database) Dump(w io.Writer) error {
+	if err := db.rlock(); err != nil {
+		return err
+	}
+	defer db.mu.RUnlock()
+	return db.dump(w)
+}
+
+func (db *Database) dump(w io.Writer) error {
+	for _, table := range db.dumpOrder() {
+		if _, err := io.WriteString(w, table.dump()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Export writes the statements of Dump to a file, replacing it only once
+// they are all written
+func (db *Database) Export(path string) (string, error) {
+	if err := db.rlock(); err != nil {
+		return "", err
+	}
+	defer db.mu.RUnlock()
+	err := writeFileAtomic(path, db.dump)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("Database exported to %s", path), nil
+}
+
+// dumpOrder returns the tables by name, moving each table after the tables
+// it references. Tables that reference each other keep their name order.
+func (db *Database) dumpOrder() []*Table {
+	var ordered []*Table
+	done := make(map[string]bool)
+	pending := slices.Sorted(maps.Keys(db.Tables))
+	for len(pending) > 0 {
+		var blocked []string
+		for _, name := range pending {
+			ready := true
+			for _, column := range db.Tables[name].Columns {
+				if ref := column.ReferenceTable; ref != "" && ref != name && !done[ref] && db.tableExists(ref) {
+					ready = false
+				}
+			}
+			if ready {
+				ordered = append(ordered, db.Tables[name])
+				done[name] = true
+			} else {
+				blocked = append(blocked, name)
+			}
+		}
+		if len(blocked) == len(pending) {
+			for _, name := range blocked {
+				ordered = append(ordered, db.Tables[name])
+			}
+			break
+		}
+		pending = blocked
+	}
+	return ordered
+}
+
+// dump returns the statements that recreate the table and its rows
+func (t *Table) dump() string {
+	var out strings.Builder
+	defs := make([]string, len(t.Columns))
+	for i, column := range t.Columns {
+		defs[i] = column.definition()
+	}
+	fmt.Fprintf(&out, "CREATE TABLE %s (%s);\n", t.Name, strings.Join(defs, ", "))
+	for _, index := range t.Indexes {
+		fmt.Fprintf(&out, "CREATE INDEX %s ON %s (%s);\n", index.Name, t.Name, index.Column)
+	}
+	for _, row := range t.Rows {
+		// Columns without a value are left out, so they stay unset rather than NULL
+		var columns, values []string
+		for _, column := range t.Columns {
+			if val, exists := row[column.Name]; exists {
+				columns = append(columns, column.Name)
+				values = append(values, sqlLiteral(val))
+			}
+		}
+		fmt.Fprintf(&out, "INSERT INTO %s (%s) VALUES (%s);\n", t.Name, strings.Join(columns, ", "), strings.Join(values, ", "))
+	}
+	out.WriteString("\n")
+	return out.String()
+}
+
+// definition returns the column as it is written in CREATE TABLE
+func (c Column) definition() string {
+	def := c.Name + " " + string(c.Type)
+	switch {
+	case c.Type == COLUMN_TYPE_DECIMAL:
+		def += fmt.Sprintf("(%d,%d)", c.Precision, c.Scale)
+	case c.Type == COLUMN_TYPE_VARCHAR && c.MaxLength > 0:
+		def += fmt.Sprintf("(%d)", c.MaxLength)
+	case c.Type == COLUMN_TYPE_ENUM && len(c.EnumValues) > 0:
+		values := make([]string, len(c.EnumValues))
+		for i, val := range c.EnumValues {
+			values[i] = sqlLiteral(val)
+		}
+		def += "(" + strings.Join(values, ", ") + ")"
+	}
+	for _, constraint := range c.Constraints {
+		def += " " + string(constraint)
+		if constraint == COLUMN_CONSTRAINT_FOREIGN_KEY {
+			def += fmt.Sprintf(" REFERENCES %s(%s)", c.ReferenceTable, c.ReferenceColumn)
+			if c.OnDelete != "" {
+				def += " ON DELETE " + string(c.OnDelete)
+			}
+		}
+	}
+	if c.Default != nil {
+		def += " DEFAULT " + sqlLiteral(c.Default)
+	}
+	return def
+}
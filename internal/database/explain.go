@@ -0,0 +1,152 @@
+package database
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+var explainRegex = regexp.MustCompile(`(?is)^EXPLAIN\s+(.+)$`)
+
+// Explain describes how a SELECT would run without running it, one step per
+// line: the table that is scanned or searched through an index, the joined
+// table, the filter, grouping and aggregates, the sort and the paging.
+func (db *Database) Explain(query string) (string, error) {
+	query = strings.TrimSpace(query)
+	tokens, err := tokenize(query)
+	if err != nil {
+		return "", err
+	}
+	stmt, ok := parseSelect(query)
+	if !ok {
+		if tokens[0].is("SELECT") {
+			return "", diagnose(tokens)
+		}
+		return "", fmt.Errorf("only SELECT statements can be explained: %s", query)
+	}
+	if err := db.rlock(); err != nil {
+		return "", err
+	}
+	defer db.mu.RUnlock()
+	now := time.Now()
+	steps, err := db.plan(stmt, now)
+	if err != nil {
+		// As in Execute, a SELECT without FROM that fails is more likely
+		// missing its FROM
+		if stmt.table == "" {
+			if syntaxErr := diagnose(tokens); syntaxErr != nil {
+				return "", syntaxErr
+			}
+		}
+		return "", err
+	}
+	return strings.Join(steps, "\n"), nil
+}
+
+// plan returns the steps of a SELECT, in the order selectRows takes them
+func (db *Database) plan(stmt *selectStatement, now time.Time) ([]string, error) {
+	projections, err := parseProjections(stmt.columns)
+	if err != nil {
+		return nil, err
+	}
+	if stmt.table == "" {
+		for _, p := range projections {
+			if p.value == nil && !isCase(p.expr) {
+				return nil, fmt.Errorf("%s needs a FROM clause", p.expr)
+			}
+		}
+		return []string{"VALUES one row, no table is read"}, nil
+	}
+	mainTable, err := db.tableRef(stmt.table)
+	if err != nil {
+		return nil, err
+	}
+	where, err := db.resolveInSubqueries(stmt.where, now)
+	if err != nil {
+		return nil, err
+	}
+
+	var steps []string
+	if stmt.join == "" {
+		if index, condition, _, ok := mainTable.indexFor(where, now); ok {
+			steps = append(steps, fmt.Sprintf("SEARCH %s USING %s FOR %s", mainTable.Name, index.describe(), condition))
+		} else {
+			steps = append(steps, "SCAN "+mainTable.Name)
+		}
+	} else {
+		joinTableRef, joinCondition, kind, err := parseJoinClause(stmt.join)
+		if err != nil {
+			return nil, fmt.Errorf("invalid join clause: %v", err)
+		}
+		joinTable, err := db.tableRef(joinTableRef)
+		if err != nil {
+			return nil, fmt.Errorf("join %v", err)
+		}
+		on, err := parseJoinOn(kind, joinCondition, mainTable, joinTable)
+		if err != nil {
+			return nil, err
+		}
+		join := "JOIN"
+		if kind != "" {
+			join = kind + " JOIN"
+		}
+		condition := "ON " + joinCondition
+		if usingRegex.MatchString(joinCondition) {
+			condition = joinCondition
+		}
+		steps = append(steps, "SCAN "+mainTable.Name)
+		switch {
+		case kind == "CROSS":
+			steps = append(steps, fmt.Sprintf("NESTED LOOP CROSS JOIN %s, every row of %s for each row of %s", joinTable.Name, joinTable.Name, mainTable.Name))
+		case on.leftCol != "":
+			// As in hashMatches, the smaller table is hashed
+			hashed, hashCol, probe := joinTable.Name, on.rightCol, mainTable.Name
+			if len(mainTable.Rows) < len(joinTable.Rows) {
+				hashed, hashCol, probe = mainTable.Name, on.leftCol, joinTable.Name
+			}
+			steps = append(steps, fmt.Sprintf("HASH %s %s %s, hashing %s by %s and probing with each row of %s", join, joinTable.Name, condition, hashed, hashCol, probe))
+		default:
+			steps = append(steps, fmt.Sprintf("NESTED LOOP %s %s %s, scanning %s for each row of %s", join, joinTable.Name, condition, joinTable.Name, mainTable.Name))
+		}
+		switch kind {
+		case "LEFT":
+			steps = append(steps, fmt.Sprintf("KEEP unmatched rows of %s", mainTable.Name))
+		case "FULL":
+			steps = append(steps, fmt.Sprintf("KEEP unmatched rows of %s and %s", mainTable.Name, joinTable.Name))
+		}
+	}
+	if stmt.where != "" {
+		steps = append(steps, "FILTER "+stmt.where)
+	}
+	if stmt.groupBy != "" {
+		steps = append(steps, "GROUP BY "+stmt.groupBy)
+	}
+	var aggregates []string
+	for _, p := range projections {
+		if agg, ok, err := p.aggregate(); err == nil && ok {
+			aggregates = append(aggregates, agg.String())
+		}
+	}
+	if aggregates != nil {
+		steps = append(steps, "AGGREGATE "+strings.Join(aggregates, ", "))
+	}
+	if stmt.orderBy != "" {
+		steps = append(steps, "SORT BY "+stmt.orderBy)
+	}
+	if stmt.offset != "" {
+		steps = append(steps, "OFFSET "+stmt.offset)
+	}
+	if stmt.limit != "" {
+		steps = append(steps, "LIMIT "+stmt.limit)
+	}
+	return steps, nil
+}
+
+// describe names an index in a plan, the primary key's has no name of its own
+func (idx *Index) describe() string {
+	if idx.Name == "" {
+		return fmt.Sprintf("PRIMARY KEY (%s)", idx.Column)
+	}
+	return fmt.Sprintf("INDEX %s (%s)", idx.Name, idx.Column)
+}
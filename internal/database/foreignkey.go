@@ -0,0 +1,78 @@
+package database
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+)
+
+// reference is a foreign key column of a table, seen from the table it references
+type reference struct {
+	table  *Table
+	column Column
+}
+
+// referencesTo returns the foreign key columns that reference a table, in
+// table order so that errors are reported consistently
+func (db *Database) referencesTo(tableName string) []reference {
+	var refs []reference
+	for _, name := range slices.Sorted(maps.Keys(db.Tables)) {
+		table := db.Tables[name]
+		for _, column := range table.Columns {
+			if column.ReferenceTable == tableName && column.ReferenceColumn != "" {
+				refs = append(refs, reference{table, column})
+			}
+		}
+	}
+	return refs
+}
+
+// planDelete returns the positions of the rows a DELETE removes, by table,
+// starting from the matched rows of table. Rows that reference a removed row
+// are removed as well when their foreign key is ON DELETE CASCADE; otherwise
+// the delete is refused, before anything changed.
+func (db *Database) planDelete(table *Table, matched map[int]bool) (map[*Table]map[int]bool, error) {
+	plan := map[*Table]map[int]bool{table: matched}
+	for queue := []*Table{table}; len(queue) > 0; queue = queue[1:] {
+		parent := queue[0]
+		for _, ref := range db.referencesTo(parent.Name) {
+			removed := make(map[any]bool)
+			for i := range plan[parent] {
+				if val := parent.Rows[i][ref.column.ReferenceColumn]; val != nil {
+					removed[val] = true
+				}
+			}
+			added := false
+			for i, row := range ref.table.Rows {
+				val := row[ref.column.Name]
+				if val == nil || !removed[val] || plan[ref.table][i] {
+					continue
+				}
+				if ref.column.OnDelete != FOREIGN_KEY_CASCADE {
+					return nil, fmt.Errorf("cannot delete from %s: %s %v is referenced by %s.%s", parent.Name, ref.column.ReferenceColumn, val, ref.table.Name, ref.column.Name)
+				}
+				if plan[ref.table] == nil {
+					plan[ref.table] = make(map[int]bool)
+				}
+				plan[ref.table][i] = true
+				added = true
+			}
+			if added {
+				queue = append(queue, ref.table)
+			}
+		}
+	}
+	return plan, nil
+}
+
+// removeRows removes the rows at the given positions from the table
+func (t *Table) removeRows(positions map[int]bool) {
+	var kept []Row
+	for i, row := range t.Rows {
+		if !positions[i] {
+			kept = append(kept, row)
+		}
+	}
+	t.Rows = kept
+	t.reindex()
+}
@@ -0,0 +1,486 @@
+package database
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// scalarFunction is a function that can be called on the values of a row,
+// in a WHERE clause or a SELECT list
+type scalarFunction struct {
+	minArgs, maxArgs int // maxArgs is -1 when there is no limit
+	resultType       ColumnType
+	// call computes the result, none of its arguments is null unless
+	// takesNulls is set, otherwise a null argument makes the result null
+	call       func(args []any) (any, error)
+	takesNulls bool
+	numeric    bool // the first argument must be a number
+	dateArgs   int  // the number of leading arguments that must be dates
+	// at computes the result of a function of the time the statement
+	// started, such as NOW(), in place of call
+	at func(now time.Time) any
+}
+
+// scalarFunctions holds the functions, keyed by upper case name. Adding an
+// entry is all a new function needs.
+var scalarFunctions = map[string]scalarFunction{
+	"UPPER": {minArgs: 1, maxArgs: 1, resultType: COLUMN_TYPE_VARCHAR, call: func(args []any) (any, error) {
+		return strings.ToUpper(formatValue(args[0])), nil
+	}},
+	"LOWER": {minArgs: 1, maxArgs: 1, resultType: COLUMN_TYPE_VARCHAR, call: func(args []any) (any, error) {
+		return strings.ToLower(formatValue(args[0])), nil
+	}},
+	"LENGTH": {minArgs: 1, maxArgs: 1, resultType: COLUMN_TYPE_INT, call: func(args []any) (any, error) {
+		return int64(utf8.RuneCountInString(formatValue(args[0]))), nil
+	}},
+	"SUBSTR": {minArgs: 2, maxArgs: 3, resultType: COLUMN_TYPE_VARCHAR, call: substr},
+	"CONCAT": {minArgs: 1, maxArgs: -1, resultType: COLUMN_TYPE_VARCHAR, call: concat, takesNulls: true},
+	"ROUND":  {minArgs: 1, maxArgs: 2, resultType: COLUMN_TYPE_DOUBLE, call: round, numeric: true},
+	"ABS": {minArgs: 1, maxArgs: 1, resultType: COLUMN_TYPE_DOUBLE, numeric: true, call: func(args []any) (any, error) {
+		f, _ := toFloat64(args[0])
+		return math.Abs(f), nil
+	}},
+	"DATE_ADD":          {minArgs: 2, maxArgs: 2, resultType: COLUMN_TYPE_DATE, call: dateAdd, dateArgs: 1},
+	"DATEDIFF":          {minArgs: 2, maxArgs: 2, resultType: COLUMN_TYPE_INT, call: dateDiff, dateArgs: 2},
+	"NOW":               {resultType: COLUMN_TYPE_TIMESTAMP, at: timestampAt},
+	"CURRENT_TIMESTAMP": {resultType: COLUMN_TYPE_TIMESTAMP, at: timestampAt},
+	"CURRENT_DATE":      {resultType: COLUMN_TYPE_DATE, at: dateAt},
+}
+
+// niladicFunctions are the functions that may be called by their name alone,
+// without parentheses, as CURRENT_DATE is
+var niladicFunctions = []string{"CURRENT_DATE", "CURRENT_TIMESTAMP"}
+
+// isNiladicCall reports whether src is the bare name of a niladic function,
+// which is not read as a column
+func isNiladicCall(src string) bool {
+	return slices.ContainsFunc(niladicFunctions, func(name string) bool {
+		return strings.EqualFold(strings.TrimSpace(src), name)
+	})
+}
+
+// timestampAt returns the date and time of now, to the second, as TIMESTAMP
+// columns store it
+func timestampAt(now time.Time) any {
+	t, _ := parseTime(currentTimestamp(now))
+	return t
+}
+
+// dateAt returns the date of now, as DATE columns store it
+func dateAt(now time.Time) any {
+	t, _ := time.Parse(dateLayout, currentDate(now))
+	return t
+}
+
+// dateAdd returns the date a number of days after a date, or before it when
+// the number is negative. The time of day of a timestamp is dropped.
+func dateAdd(args []any) (any, error) {
+	date, err := toDate(args[0])
+	if err != nil {
+		return nil, err
+	}
+	days, ok := args[1].(int64)
+	if !ok {
+		return nil, fmt.Errorf("number of days must be an integer, got %v", args[1])
+	}
+	return date.AddDate(0, 0, int(days)), nil
+}
+
+// dateDiff returns the number of days from the second date to the first,
+// ignoring the time of day
+func dateDiff(args []any) (any, error) {
+	end, err := toDate(args[0])
+	if err != nil {
+		return nil, err
+	}
+	start, err := toDate(args[1])
+	if err != nil {
+		return nil, err
+	}
+	return int64(end.Sub(start).Hours() / 24), nil
+}
+
+// toDate returns the date of a DATE value, or of a date or timestamp held as
+// text, at midnight UTC
+func toDate(val any) (time.Time, error) {
+	date, ok := val.(time.Time)
+	if !ok {
+		text, _ := val.(string)
+		var err error
+		if date, err = parseTime(text); err != nil {
+			return time.Time{}, fmt.Errorf("invalid date %s", sqlLiteral(val))
+		}
+	}
+	year, month, day := date.Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC), nil
+}
+
+// round rounds a number to the given count of decimal places, 0 by default,
+// halves away from zero. A negative count rounds to tens, hundreds and so on.
+func round(args []any) (any, error) {
+	f, _ := toFloat64(args[0])
+	var places int64
+	if len(args) == 2 {
+		n, ok := args[1].(int64)
+		if !ok {
+			return nil, fmt.Errorf("decimal places must be an integer, got %v", args[1])
+		}
+		places = n
+	}
+	if places < 0 {
+		scale := math.Pow(10, float64(-places))
+		return math.Round(f/scale) * scale, nil
+	}
+	scale := math.Pow(10, float64(places))
+	return math.Round(f*scale) / scale, nil
+}
+
+// concat joins its arguments as Row.String formats them. Null arguments,
+// such as columns a row has no value for, add nothing.
+func concat(args []any) (any, error) {
+	var result strings.Builder
+	for _, arg := range args {
+		if arg != nil {
+			result.WriteString(formatValue(arg))
+		}
+	}
+	return result.String(), nil
+}
+
+// substr returns the characters of a string from a 1-based position, up to
+// the given count or else to the end. Bounds outside the string are clamped
+// to it, so SUBSTR('abc', 0, 2) is 'a' as in SQL.
+func substr(args []any) (any, error) {
+	runes := []rune(formatValue(args[0]))
+	start, ok := args[1].(int64)
+	if !ok {
+		return nil, fmt.Errorf("start position must be an integer, got %v", args[1])
+	}
+	end := int64(len(runes)) + 1
+	if len(args) == 3 {
+		count, ok := args[2].(int64)
+		if !ok {
+			return nil, fmt.Errorf("length must be an integer, got %v", args[2])
+		}
+		end = min(end, start+max(count, 0))
+	}
+	start = max(start, 1)
+	if start >= end {
+		return "", nil
+	}
+	return string(runes[start-1 : end-1]), nil
+}
+
+// callCache holds parsed function calls, keyed by their source
+var callCache = newParseCache[string, *functionCall]()
+
+// functionCall is a call of a scalar function, such as SUBSTR(title, 1, 10)
+type functionCall struct {
+	name string // upper case
+	fn   scalarFunction
+	args []exprValue
+}
+
+// parseFunctionCall parses src when it is a single function call, ok is
+// false for anything else. Its arguments may be any expression over the row.
+func parseFunctionCall(src string) (call *functionCall, ok bool, err error) {
+	if call, ok := callCache.Load(src); ok {
+		return call, true, nil
+	}
+	tokens, err := tokenize(src)
+	if err != nil || tokens[0].kind != tokenIdent {
+		return nil, false, nil
+	}
+	if isNiladicCall(src) {
+		name := strings.ToUpper(tokens[0].text)
+		call = &functionCall{name: name, fn: scalarFunctions[name]}
+		callCache.Store(src, call)
+		return call, true, nil
+	}
+	if !tokens[1].is("(") {
+		return nil, false, nil
+	}
+	// The parenthesis opened after the name must close at the end
+	depth, start := 0, tokens[1].pos+1
+	var args []string
+	for i := 1; tokens[i].kind != tokenEOF; i++ {
+		switch t := tokens[i]; {
+		case t.is("("):
+			depth++
+		case t.is(")"):
+			if depth--; depth == 0 {
+				if tokens[i+1].kind != tokenEOF {
+					return nil, false, nil
+				}
+				if arg := strings.TrimSpace(src[start:t.pos]); arg != "" || len(args) > 0 {
+					args = append(args, arg)
+				}
+			}
+		case t.is(",") && depth == 1:
+			args = append(args, strings.TrimSpace(src[start:t.pos]))
+			start = t.pos + 1
+		}
+	}
+	if depth != 0 {
+		return nil, false, nil
+	}
+
+	name := strings.ToUpper(tokens[0].text)
+	fn, exists := scalarFunctions[name]
+	if !exists {
+		return nil, false, fmt.Errorf("unknown function %s", tokens[0].text)
+	}
+	if len(args) < fn.minArgs || (fn.maxArgs >= 0 && len(args) > fn.maxArgs) {
+		switch {
+		case fn.maxArgs < 0:
+			return nil, false, fmt.Errorf("%s takes at least %d argument(s), got %d", name, fn.minArgs, len(args))
+		case fn.minArgs == fn.maxArgs:
+			return nil, false, fmt.Errorf("%s takes %d argument(s), got %d", name, fn.minArgs, len(args))
+		default:
+			return nil, false, fmt.Errorf("%s takes %d to %d arguments, got %d", name, fn.minArgs, fn.maxArgs, len(args))
+		}
+	}
+	call = &functionCall{name: name, fn: fn}
+	for _, arg := range args {
+		if arg == "" {
+			return nil, false, fmt.Errorf("missing argument in %s", src)
+		}
+		val, err := parseExprValue(arg)
+		if err != nil {
+			return nil, false, err
+		}
+		call.args = append(call.args, val)
+	}
+	callCache.Store(src, call)
+	return call, true, nil
+}
+
+// eval calls the function on the values of its arguments in the row, the
+// result is null when one of them is unless the function takes nulls. now is
+// the time the statement started.
+func (c *functionCall) eval(row Row, now time.Time) (any, error) {
+	if c.fn.at != nil {
+		return c.fn.at(now), nil
+	}
+	args := make([]any, len(c.args))
+	for i, arg := range c.args {
+		val, err := arg.value(row, now)
+		if err != nil || (val == nil && !c.fn.takesNulls) {
+			return nil, err
+		}
+		if _, ok := toFloat64(val); i == 0 && c.fn.numeric && !ok {
+			return nil, fmt.Errorf("%s needs a number, %s is %v", c.name, arg.source(), formatValue(val))
+		}
+		args[i] = val
+	}
+	val, err := c.fn.call(args)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", c.name, err)
+	}
+	return val, nil
+}
+
+// columnType returns the type of the result, after checking the arguments
+// against the tables
+func (c *functionCall) columnType(tables []*Table) (ColumnType, error) {
+	for i, arg := range c.args {
+		colType, err := arg.columnType(tables)
+		if err != nil {
+			return "", err
+		}
+		switch {
+		case i == 0 && c.fn.numeric:
+			switch colType {
+			case "", COLUMN_TYPE_INT, COLUMN_TYPE_FLOAT, COLUMN_TYPE_DOUBLE, COLUMN_TYPE_DECIMAL:
+			default:
+				return "", fmt.Errorf("%s needs a number, %s is %s", c.name, arg.source(), colType)
+			}
+		case i < c.fn.dateArgs:
+			// Text is checked when the function is called
+			switch colType {
+			case "", COLUMN_TYPE_DATE, COLUMN_TYPE_TIMESTAMP, COLUMN_TYPE_VARCHAR:
+			default:
+				return "", fmt.Errorf("%s needs a date, %s is %s", c.name, arg.source(), colType)
+			}
+		}
+	}
+	return c.fn.resultType, nil
+}
+
+// evaluateOperand returns the value of the left side of a comparison, which
+// is a column, a function call such as UPPER(name) or an arithmetic
+// expression. exists is false when a column it reads has no value.
+func evaluateOperand(row Row, operand string, now time.Time) (val any, exists bool, err error) {
+	// A literal such as the 1 of 1 = 1 is its own value, not a column
+	if isLiteral(operand) {
+		v, err := parseExprValue(operand)
+		if err != nil {
+			return nil, false, err
+		}
+		return v.literal, v.literal != nil, nil
+	}
+	if columnRegex.MatchString(operand) && !isNiladicCall(operand) {
+		val, exists = row[operand]
+		return val, exists, nil
+	}
+
+	call, ok, err := parseFunctionCall(operand)
+	if err != nil {
+		return nil, false, err
+	}
+	if ok {
+		val, err := call.eval(row, now)
+		if err != nil || val == nil {
+			return nil, false, err
+		}
+		return val, true, nil
+	}
+
+	expr, err := parseArithmetic(operand)
+	if err != nil {
+		return nil, false, err
+	}
+	num, ok, err := expr.eval(row)
+	if err != nil || !ok {
+		return nil, false, err
+	}
+	return num, true, nil
+}
+
+// isLiteral reports whether an operand is a single number, string, boolean or
+// NULL literal
+func isLiteral(operand string) bool {
+	if operand == "" {
+		return false
+	}
+	switch c := operand[0]; {
+	case c == '\'' || c == '"':
+		end, _, ok := scanString(operand, 0)
+		return ok && end == len(operand)
+	case c >= '0' && c <= '9':
+		_, err := strconv.ParseFloat(operand, 64)
+		return err == nil
+	}
+	return strings.EqualFold(operand, "TRUE") || strings.EqualFold(operand, "FALSE") || strings.EqualFold(operand, "NULL")
+}
+
+// exprValue is a literal or an expression over the row such as a column
+// name, as the result of a CASE branch or the argument of a function
+type exprValue struct {
+	literal any
+	operand string // empty for a literal
+}
+
+// parseExprValue parses a value. Strings, numbers, booleans and NULL are
+// literals, anything else is evaluated against the row.
+func parseExprValue(src string) (exprValue, error) {
+	tokens, err := tokenize(src)
+	if err != nil {
+		return exprValue{}, err
+	}
+	if tokens[1].kind != tokenEOF {
+		return exprValue{operand: src}, nil
+	}
+	switch t := tokens[0]; {
+	case t.kind == tokenString:
+		return exprValue{literal: unquote(src)}, nil
+	case t.kind == tokenNumber:
+		if n, err := strconv.ParseInt(src, 10, 64); err == nil {
+			return exprValue{literal: n}, nil
+		}
+		f, err := strconv.ParseFloat(src, 64)
+		if err != nil {
+			return exprValue{}, fmt.Errorf("invalid number %s", src)
+		}
+		return exprValue{literal: f}, nil
+	case t.is("NULL"):
+		return exprValue{}, nil
+	case t.is("TRUE"), t.is("FALSE"):
+		return exprValue{literal: t.is("TRUE")}, nil
+	default:
+		return exprValue{operand: src}, nil
+	}
+}
+
+// source returns the value as written, for error messages
+func (v exprValue) source() string {
+	if v.operand == "" {
+		return sqlLiteral(v.literal)
+	}
+	return v.operand
+}
+
+func (v exprValue) value(row Row, now time.Time) (any, error) {
+	if v.operand == "" {
+		return v.literal, nil
+	}
+	val, exists, err := evaluateOperand(row, v.operand, now)
+	if err != nil || !exists {
+		return nil, err
+	}
+	return val, nil
+}
+
+// columnType returns the type of the value, checking the columns it reads
+// against the tables. A NULL literal has no type.
+func (v exprValue) columnType(tables []*Table) (ColumnType, error) {
+	switch v.literal.(type) {
+	case string:
+		return COLUMN_TYPE_VARCHAR, nil
+	case int64:
+		return COLUMN_TYPE_INT, nil
+	case float64:
+		return COLUMN_TYPE_DOUBLE, nil
+	case bool:
+		return COLUMN_TYPE_BOOL, nil
+	}
+	if v.operand == "" {
+		return "", nil
+	}
+	if columnRegex.MatchString(v.operand) && !isNiladicCall(v.operand) {
+		column, err := findColumn(tables, v.operand)
+		return column.Type, err
+	}
+	call, ok, err := parseFunctionCall(v.operand)
+	if err != nil {
+		return "", err
+	}
+	if ok {
+		return call.columnType(tables)
+	}
+	expr, err := parseArithmetic(v.operand)
+	if err != nil {
+		return "", err
+	}
+	return arithType(expr, tables)
+}
+
+// currentDate is the value of CURRENT_DATE in a statement started at now,
+// in the format DATE columns store
+func currentDate(now time.Time) string {
+	return now.Format(dateLayout)
+}
+
+// currentTimestamp is the value of NOW() and CURRENT_TIMESTAMP in a statement
+// started at now, which keep the time of day, in the format TIMESTAMP columns
+// store
+func currentTimestamp(now time.Time) string {
+	return now.Format(timestampLayout)
+}
+
+// currentTime returns the value of CURRENT_DATE, NOW() or CURRENT_TIMESTAMP
+// in a statement started at now, and false for any other value
+func currentTime(val string, now time.Time) (string, bool) {
+	switch strings.ToUpper(strings.Join(strings.Fields(val), "")) {
+	case "CURRENT_DATE":
+		return currentDate(now), true
+	case "NOW()", "CURRENT_TIMESTAMP":
+		return currentTimestamp(now), true
+	}
+	return "", false
+}
@@ -0,0 +1,88 @@
+package database
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// groupRows runs a SELECT with GROUP BY. The matched rows are split into
+// groups that share the values of the GROUP BY columns, nulls forming a group
+// of their own, and each group gives one result row in the order the groups
+// were first seen. Selected columns must be GROUP BY columns or aggregates.
+func (db *Database) groupRows(mainTable *Table, projections []projection, whereClause string, joinClause string, groupByClause string, now time.Time) ([]Row, []Column, error) {
+	var groupBy []string
+	for _, col := range splitList(groupByClause) {
+		groupBy = append(groupBy, strings.TrimSpace(col))
+	}
+
+	rows, tables, err := db.matchedRows(mainTable, whereClause, joinClause, now)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, col := range groupBy {
+		if _, err := findColumn(tables, col); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	// Each selected column is a key column or an aggregate
+	var resultColumns []Column
+	aggregates := make(map[int]aggregate)
+	for i, p := range projections {
+		agg, ok, err := p.aggregate()
+		if err != nil {
+			return nil, nil, err
+		}
+		if ok {
+			if err := agg.validate(tables); err != nil {
+				return nil, nil, err
+			}
+			aggregates[i] = agg
+			resultColumns = append(resultColumns, agg.column(tables))
+			continue
+		}
+		if !slices.Contains(groupBy, p.expr) {
+			return nil, nil, fmt.Errorf("column %s must appear in GROUP BY or be used in an aggregate function", p.expr)
+		}
+		column, _ := findColumn(tables, p.expr)
+		column.Name = p.name
+		resultColumns = append(resultColumns, column)
+	}
+
+	var keys []string
+	groups := make(map[string][]Row)
+	for _, row := range rows {
+		values := make([]string, len(groupBy))
+		for i, col := range groupBy {
+			// The type keeps the string "1" apart from the number 1
+			values[i] = fmt.Sprintf("%T:%v", row[col], row[col])
+		}
+		key := strings.Join(values, "\x00")
+		if _, exists := groups[key]; !exists {
+			keys = append(keys, key)
+		}
+		groups[key] = append(groups[key], row)
+	}
+
+	results := make([]Row, 0, len(keys))
+	for _, key := range keys {
+		group := groups[key]
+		result := make(Row)
+		for i, p := range projections {
+			agg, ok := aggregates[i]
+			if !ok {
+				result[p.name] = group[0][p.expr]
+				continue
+			}
+			val, err := agg.compute(group, agg.argType(tables))
+			if err != nil {
+				return nil, nil, err
+			}
+			result[agg.name()] = val
+		}
+		results = append(results, result)
+	}
+	return results, resultColumns, nil
+}
@@ -0,0 +1,180 @@
+package database
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+var createIndexRegex = regexp.MustCompile(`(?i)^CREATE\s+INDEX\s+(\w+)\s+ON\s+(\w+)\s*\(\s*(\w+)\s*\)\s*$`)
+
+// Index maps the values of a column to the positions of the rows holding
+// them. Only the definition is saved, the map is rebuilt on load. Besides
+// the indexes made by CREATE INDEX every table with a primary key has one
+// on it.
+type Index struct {
+	Name    string
+	Column  string
+	entries map[any][]int
+}
+
+// CreateIndex adds an index on a column of a table. Equality conditions on
+// the column then look up the matching rows instead of scanning the table.
+func (db *Database) CreateIndex(name string, tableName string, columnName string) (string, error) {
+	if err := db.lock(); err != nil {
+		return "", err
+	}
+	defer db.mu.Unlock()
+	table, err := db.getTable(tableName)
+	if err != nil {
+		return "", err
+	}
+	for _, other := range db.Tables {
+		if other.index(name) != nil {
+			return "", fmt.Errorf("index %s already exists", name)
+		}
+	}
+	if !table.columnExists(columnName) {
+		return "", fmt.Errorf("column %s does not exist in table %s", columnName, tableName)
+	}
+	index := &Index{Name: name, Column: columnName}
+	index.build(table.Rows)
+	table.Indexes = append(table.Indexes, index)
+	if err := db.save(); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("Index %s created on %s (%s)", name, tableName, columnName), nil
+}
+
+// index returns the index with the given name, or nil
+func (t *Table) index(name string) *Index {
+	for _, index := range t.Indexes {
+		if index.Name == name {
+			return index
+		}
+	}
+	return nil
+}
+
+// build fills the index from the rows, nulls are left out as no equality
+// matches them
+func (idx *Index) build(rows []Row) {
+	idx.entries = make(map[any][]int)
+	for i, row := range rows {
+		idx.add(row, i)
+	}
+}
+
+func (idx *Index) add(row Row, pos int) {
+	if val := row[idx.Column]; val != nil {
+		idx.entries[val] = append(idx.entries[val], pos)
+	}
+}
+
+// reindex rebuilds every index of the table, after rows were removed or
+// changed or the primary key was defined or renamed
+func (t *Table) reindex() {
+	t.primaryIndex = nil
+	if t.PrimaryKey != "" {
+		t.primaryIndex = &Index{Column: t.PrimaryKey}
+	}
+	for _, index := range t.allIndexes() {
+		index.build(t.Rows)
+	}
+}
+
+// allIndexes returns the indexes of the table, the primary key's first
+func (t *Table) allIndexes() []*Index {
+	if t.primaryIndex == nil {
+		return t.Indexes
+	}
+	return append([]*Index{t.primaryIndex}, t.Indexes...)
+}
+
+// candidates returns the positions of the rows that may satisfy a WHERE
+// clause, in table order. When the clause, or one of the conditions it
+// combines with AND, compares an indexed column for equality with a literal
+// only the rows the index finds are returned, otherwise all of them. The
+// clause still has to be evaluated on each.
+func (t *Table) candidates(whereClause string, now time.Time) []int {
+	if positions, ok := t.lookup(whereClause, now); ok {
+		return positions
+	}
+	all := make([]int, len(t.Rows))
+	for i := range all {
+		all[i] = i
+	}
+	return all
+}
+
+func (t *Table) lookup(whereClause string, now time.Time) ([]int, bool) {
+	index, _, key, ok := t.indexFor(whereClause, now)
+	if !ok {
+		return nil, false
+	}
+	return index.entries[key], true
+}
+
+// indexFor returns the index candidates uses for a WHERE clause, along with
+// the condition it serves and the key it looks up
+func (t *Table) indexFor(whereClause string, now time.Time) (*Index, string, any, bool) {
+	if whereClause == "" || (t.primaryIndex == nil && len(t.Indexes) == 0) {
+		return nil, "", nil, false
+	}
+	expr, err := parseWhere(whereClause)
+	if err != nil {
+		return nil, "", nil, false
+	}
+	operands := []*whereExpr{expr}
+	if expr.op == "AND" {
+		operands = expr.operands
+	}
+	for _, operand := range operands {
+		if operand.op != "" {
+			continue
+		}
+		if index, key, ok := t.lookupCondition(operand.condition, now); ok {
+			return index, operand.condition, key, true
+		}
+	}
+	return nil, "", nil, false
+}
+
+// lookupCondition finds an index for a condition of the form column = literal
+// and the key to look up. The literal is converted to the column type as an
+// INSERT would store it, a literal that does not convert cleanly is left to
+// the scan.
+func (t *Table) lookupCondition(condition string, now time.Time) (*Index, any, bool) {
+	col, op, val, ok := splitComparison(condition)
+	if !ok || op != "=" || !columnRegex.MatchString(col) || strings.Contains(col, ".") || strings.EqualFold(val, "NULL") || qualifiedNameRegex.FindString(val) == val {
+		return nil, nil, false
+	}
+	var index *Index
+	for _, idx := range t.allIndexes() {
+		if idx.Column == col {
+			index = idx
+			break
+		}
+	}
+	if index == nil {
+		return nil, nil, false
+	}
+	column, err := t.GetColumn(col)
+	if err != nil || !slices.Contains(indexableTypes, column.Type) {
+		return nil, nil, false
+	}
+	key, err := columnTypeConversion(column.Type, val, now)
+	if err != nil || key == nil {
+		return nil, nil, false
+	}
+	return index, key, true
+}
+
+// indexableTypes are the column types whose stored values are equal exactly
+// when WHERE compares them as equal
+var indexableTypes = []ColumnType{
+	COLUMN_TYPE_INT, COLUMN_TYPE_DOUBLE, COLUMN_TYPE_FLOAT, COLUMN_TYPE_VARCHAR, COLUMN_TYPE_BOOL, COLUMN_TYPE_DATE,
+	COLUMN_TYPE_TIMESTAMP,
+}
@@ -0,0 +1,201 @@
+package database
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenIdent
+	tokenNumber
+	tokenString
+	tokenSymbol
+)
+
+// token is a lexical unit of a SQL statement
+type token struct {
+	kind tokenKind
+	text string // for strings, the value without quotes
+	pos  int    // byte offset of the token in the statement
+}
+
+// is reports whether the token is the given keyword or symbol, ignoring case
+func (t token) is(text string) bool {
+	return (t.kind == tokenIdent || t.kind == tokenSymbol) && strings.EqualFold(t.text, text)
+}
+
+// ErrSyntax is returned when a statement cannot be parsed
+type ErrSyntax struct {
+	Position int    // byte offset of the error in the statement, without leading whitespace
+	Near     string // text found at Position, empty at the end of the statement
+	Msg      string
+}
+
+func (e *ErrSyntax) Error() string {
+	if e.Near == "" {
+		return fmt.Sprintf("syntax error at end of statement: %s", e.Msg)
+	}
+	return fmt.Sprintf("syntax error at position %d near %q: %s", e.Position, e.Near, e.Msg)
+}
+
+// tokenize splits a statement into tokens, it fails only on unterminated strings
+func tokenize(sql string) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(sql); {
+		r, size := utf8.DecodeRuneInString(sql[i:])
+		switch {
+		case unicode.IsSpace(r):
+			i += size
+		case r == '\'' || r == '"':
+			end, value, ok := scanString(sql, i)
+			if !ok {
+				return nil, &ErrSyntax{Position: i, Near: sql[i:], Msg: "unterminated string"}
+			}
+			tokens = append(tokens, token{tokenString, value, i})
+			i = end
+		case unicode.IsDigit(r) || (r == '.' && i+1 < len(sql) && isDigit(sql[i+1])):
+			end := scanNumber(sql, i)
+			tokens = append(tokens, token{tokenNumber, sql[i:end], i})
+			i = end
+		case unicode.IsLetter(r) || r == '_':
+			end := i
+			for end < len(sql) {
+				r, size := utf8.DecodeRuneInString(sql[end:])
+				if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_' {
+					break
+				}
+				end += size
+			}
+			tokens = append(tokens, token{tokenIdent, sql[i:end], i})
+			i = end
+		default:
+			end := i + size
+			if end < len(sql) {
+				switch sql[i : end+1] {
+				case "<=", ">=", "!=", "<>":
+					end++
+				}
+			}
+			tokens = append(tokens, token{tokenSymbol, sql[i:end], i})
+			i = end
+		}
+	}
+	tokens = append(tokens, token{tokenEOF, "", len(sql)})
+	return tokens, nil
+}
+
+// scanString reads a quoted string starting at start, a doubled quote inside it stands for one quote
+func scanString(sql string, start int) (int, string, bool) {
+	quote := sql[start]
+	var value strings.Builder
+	for i := start + 1; i < len(sql); i++ {
+		if sql[i] != quote {
+			value.WriteByte(sql[i])
+			continue
+		}
+		if i+1 < len(sql) && sql[i+1] == quote {
+			value.WriteByte(quote)
+			i++
+			continue
+		}
+		return i + 1, value.String(), true
+	}
+	return 0, "", false
+}
+
+// splitList splits a comma separated list, commas inside quoted strings or
+// parentheses do not separate items. Items keep their quotes and spacing.
+func splitList(list string) []string {
+	var items []string
+	start, depth := 0, 0
+	for i := 0; i < len(list); i++ {
+		switch list[i] {
+		case '\'', '"':
+			if end, _, ok := scanString(list, i); ok {
+				i = end - 1
+			}
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				items = append(items, list[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(items, list[start:])
+}
+
+// scanNumber reads an integer or decimal literal with an optional exponent
+func scanNumber(sql string, start int) int {
+	i := start
+	for i < len(sql) && (isDigit(sql[i]) || sql[i] == '.') {
+		i++
+	}
+	if i < len(sql) && (sql[i] == 'e' || sql[i] == 'E') {
+		j := i + 1
+		if j < len(sql) && (sql[j] == '+' || sql[j] == '-') {
+			j++
+		}
+		if j < len(sql) && isDigit(sql[j]) {
+			i = j
+			for i < len(sql) && isDigit(sql[i]) {
+				i++
+			}
+		}
+	}
+	return i
+}
+
+func isDigit(b byte) bool {
+	return b >= '0' && b <= '9'
+}
+
+// maskStrings returns the statement with the text inside its quoted strings
+// replaced, byte for byte, by underscores. The regular expressions that split
+// a statement into clauses match against it, so that a value such as
+// 'a ORDER BY b' is not taken for a clause.
+func maskStrings(sql string) string {
+	if !strings.ContainsAny(sql, `'"`) {
+		return sql
+	}
+	masked := []byte(sql)
+	for i := 0; i < len(masked); i++ {
+		if masked[i] != '\'' && masked[i] != '"' {
+			continue
+		}
+		end, _, ok := scanString(sql, i)
+		if !ok {
+			break
+		}
+		for j := i + 1; j < end-1; j++ {
+			masked[j] = '_'
+		}
+		i = end - 1
+	}
+	return string(masked)
+}
+
+// findClauses is re.FindStringSubmatch with the keywords of quoted strings
+// left out, see maskStrings
+func findClauses(re *regexp.Regexp, sql string) []string {
+	loc := re.FindStringSubmatchIndex(maskStrings(sql))
+	if loc == nil {
+		return nil
+	}
+	matches := make([]string, len(loc)/2)
+	for i := range matches {
+		if loc[2*i] >= 0 {
+			matches[i] = sql[loc[2*i]:loc[2*i+1]]
+		}
+	}
+	return matches
+}
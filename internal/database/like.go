@@ -0,0 +1,54 @@
+package database
+
+import (
+	"regexp"
+	"strings"
+)
+
+// likeCache holds compiled LIKE patterns, keyed by likeCacheKey
+var likeCache = newParseCache[likeCacheKey, *regexp.Regexp]()
+
+type likeCacheKey struct {
+	pattern         string
+	caseInsensitive bool
+}
+
+// matchLike reports whether value matches a SQL LIKE pattern, where % stands
+// for any sequence of characters and _ for exactly one. The whole value must match.
+func matchLike(value string, pattern string, caseInsensitive bool) bool {
+	key := likeCacheKey{pattern, caseInsensitive}
+	if re, ok := likeCache.Load(key); ok {
+		return re.MatchString(value)
+	}
+	re := compileLike(pattern, caseInsensitive)
+	likeCache.Store(key, re)
+	return re.MatchString(value)
+}
+
+// compileLike translates a LIKE pattern into an anchored regular expression
+func compileLike(pattern string, caseInsensitive bool) *regexp.Regexp {
+	var expr, literal strings.Builder
+	if caseInsensitive {
+		expr.WriteString("(?i)")
+	}
+	expr.WriteString("(?s)^")
+	flush := func() {
+		expr.WriteString(regexp.QuoteMeta(literal.String()))
+		literal.Reset()
+	}
+	for _, r := range pattern {
+		switch r {
+		case '%':
+			flush()
+			expr.WriteString(".*")
+		case '_':
+			flush()
+			expr.WriteString(".")
+		default:
+			literal.WriteRune(r)
+		}
+	}
+	flush()
+	expr.WriteString("$")
+	return regexp.MustCompile(expr.String())
+}
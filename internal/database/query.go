@@ -0,0 +1,449 @@
+package database
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Query runs a SELECT statement and returns its rows along with the
+// projected columns, in the order they were selected. Unlike Execute the
+// values keep their stored types, integers stay int64.
+func (db *Database) Query(sql string) ([]Row, []Column, error) {
+	if err := db.checkOpen(); err != nil {
+		return nil, nil, err
+	}
+	sql = strings.TrimSpace(sql)
+	tokens, err := tokenize(sql)
+	if err != nil {
+		return nil, nil, err
+	}
+	stmt, ok := parseSelect(sql)
+	if !ok {
+		if tokens[0].is("SELECT") {
+			return nil, nil, diagnose(tokens)
+		}
+		return nil, nil, fmt.Errorf("only SELECT statements can be queried, use Execute for: %s", sql)
+	}
+	if err := db.rlock(); err != nil {
+		return nil, nil, err
+	}
+	defer db.mu.RUnlock()
+	now := time.Now()
+	return db.selectRows(stmt.table, stmt.columns, stmt.where, stmt.join, stmt.groupBy, stmt.orderBy, stmt.limit, stmt.offset, now)
+}
+
+// hasKeyword reports whether the keyword appears in sql outside of strings
+func hasKeyword(sql string, keyword string) bool {
+	tokens, err := tokenize(sql)
+	if err != nil {
+		return false
+	}
+	return slices.ContainsFunc(tokens, func(t token) bool {
+		return t.is(keyword)
+	})
+}
+
+// selectStatement holds the clauses of a SELECT statement
+type selectStatement struct {
+	table   string
+	columns []string
+	where   string
+	join    string
+	groupBy string
+	orderBy string
+	limit   string
+	offset  string
+}
+
+// selectValuesRegex matches a SELECT without FROM, such as SELECT 1 + 1
+var selectValuesRegex = regexp.MustCompile(`(?is)^SELECT\s+(.+?)\s*$`)
+
+// parseSelect splits a SELECT statement into its clauses. A SELECT without
+// FROM has no table and only its columns are set.
+func parseSelect(sql string) (*selectStatement, bool) {
+	matches := findClauses(selectRegex, sql)
+	if matches == nil {
+		values := selectValuesRegex.FindStringSubmatch(sql)
+		if values == nil || hasKeyword(sql, "FROM") {
+			return nil, false
+		}
+		return &selectStatement{columns: splitList(values[1])}, true
+	}
+	// NOTE: FindStringSubmatch always returns a slice with len = 1 + number of capture groups.
+	// If a capture group doesn't match, its value will be an empty string ("").
+	limit, offset := matches[7], matches[8]
+	// LIMIT offset, count is shorthand for LIMIT count OFFSET offset
+	if skip, count, ok := strings.Cut(limit, ","); ok && offset == "" {
+		limit, offset = strings.TrimSpace(count), strings.TrimSpace(skip)
+	}
+	return &selectStatement{
+		table:   matches[2],
+		columns: splitList(matches[1]),
+		join:    matches[3],
+		where:   matches[4],
+		groupBy: matches[5],
+		orderBy: matches[6],
+		limit:   limit,
+		offset:  offset,
+	}, true
+}
+
+// projection is a column of a SELECT list
+type projection struct {
+	expr  string     // column name, *, CASE expression, which keeps its alias, or computed value
+	name  string     // key of the value in result rows
+	alias bool       // whether the name was given with AS
+	value *exprValue // a computed value such as price * quantity or UPPER(name), else nil
+}
+
+// parseProjections parses a SELECT list. A column may be renamed with
+// AS alias, and two columns cannot end up with the same alias.
+func parseProjections(columns []string) ([]projection, error) {
+	var projections []projection
+	for _, col := range columns {
+		col = strings.TrimSpace(col)
+		p := projection{expr: col, name: col}
+		if isCase(col) {
+			expr, err := parseCase(col)
+			if err != nil {
+				return nil, err
+			}
+			p.name, p.alias = expr.name, true
+		} else {
+			expr, alias, err := cutAlias(col)
+			if err != nil {
+				return nil, err
+			}
+			if alias != "" {
+				p = projection{expr: expr, name: alias, alias: true}
+			}
+			if p.expr != "*" && !isColumnName(p.expr) && !tableStarRegex.MatchString(p.expr) && !aggregateRegex.MatchString(p.expr) {
+				value, err := parseExprValue(p.expr)
+				if err != nil {
+					return nil, err
+				}
+				p.value = &value
+			}
+		}
+		for _, other := range projections {
+			if other.name == p.name && (other.alias || p.alias) {
+				return nil, fmt.Errorf("duplicate column name %s in SELECT list", p.name)
+			}
+		}
+		projections = append(projections, p)
+	}
+	return projections, nil
+}
+
+// tableStarRegex matches table.*, which selects every column of a table
+var tableStarRegex = regexp.MustCompile(`^(\w+)\s*\.\s*\*$`)
+
+// expandTableStars replaces each table.* projection with the columns of
+// that table, named table.column so that they never collide with the
+// columns of another table
+func expandTableStars(projections []projection, tables []*Table) ([]projection, error) {
+	var expanded []projection
+	for _, p := range projections {
+		matches := tableStarRegex.FindStringSubmatch(p.expr)
+		if matches == nil {
+			expanded = append(expanded, p)
+			continue
+		}
+		if p.alias {
+			return nil, fmt.Errorf("%s cannot have an alias", p.expr)
+		}
+		i := slices.IndexFunc(tables, func(table *Table) bool {
+			return table.Name == matches[1]
+		})
+		if i < 0 {
+			return nil, fmt.Errorf("table %s in %s is not in the query", matches[1], p.expr)
+		}
+		for _, column := range tables[i].Columns {
+			name := tables[i].Name + "." + column.Name
+			expanded = append(expanded, projection{expr: name, name: name})
+		}
+	}
+	return expanded, nil
+}
+
+// cutAlias splits a projected column into its expression and its alias,
+// empty when there is none. The alias is given after AS, or else follows the
+// expression directly as in "price * quantity total".
+func cutAlias(col string) (string, string, error) {
+	tokens, err := tokenize(col)
+	if err != nil {
+		return "", "", err
+	}
+	last := len(tokens) - 1 // the EOF token
+	for i, depth := 0, 0; i < last; i++ {
+		switch t := tokens[i]; {
+		case t.is("("):
+			depth++
+		case t.is(")"):
+			depth--
+		case depth == 0 && t.is("AS"):
+			if i == 0 || i+2 != last || tokens[i+1].kind != tokenIdent {
+				return "", "", fmt.Errorf("AS must be followed by a single alias name in %s", col)
+			}
+			return strings.TrimSpace(col[:t.pos]), tokens[i+1].text, nil
+		}
+	}
+	// An implicit alias is a name after a token that ends an expression
+	if last >= 2 && tokens[last-1].kind == tokenIdent {
+		switch prev := tokens[last-2]; {
+		case prev.kind == tokenIdent, prev.kind == tokenNumber, prev.kind == tokenString, prev.is(")"):
+			return strings.TrimSpace(col[:tokens[last-1].pos]), tokens[last-1].text, nil
+		}
+	}
+	return col, "", nil
+}
+
+// isColumnName reports whether a projected column names a column, possibly
+// qualified by its table, rather than computing a value
+func isColumnName(expr string) bool {
+	return columnRegex.MatchString(expr) && !unicode.IsDigit(rune(expr[0])) && expr[0] != '.' && !isNiladicCall(expr)
+}
+
+// project stores the value of the projection for a row in the result row,
+// under its name. The row holds the columns of the tables, and * is left to
+// the caller since joined rows expand it differently.
+func (db *Database) project(resultRow Row, row Row, p projection, now time.Time) error {
+	switch {
+	case isCase(p.expr):
+		return db.projectCase(resultRow, row, p.expr, now)
+	case p.value != nil:
+		val, err := p.value.value(row, now)
+		if err != nil {
+			return err
+		}
+		resultRow[p.name] = val
+	default:
+		val, exists := row[p.expr]
+		if !exists {
+			return fmt.Errorf("column %s not found", p.expr)
+		}
+		resultRow[p.name] = val
+	}
+	return nil
+}
+
+// selectValues evaluates the projections of a SELECT without FROM, such as
+// SELECT 1 + 1 or SELECT UPPER('hello'), to a single row. With no table to
+// read there are no columns to name.
+func (db *Database) selectValues(projections []projection, now time.Time) ([]Row, []Column, error) {
+	for _, p := range projections {
+		if p.value == nil && !isCase(p.expr) {
+			return nil, nil, fmt.Errorf("%s needs a FROM clause", p.expr)
+		}
+	}
+	columns, err := projectedColumns(projections, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	row := make(Row)
+	for _, p := range projections {
+		if err := db.project(row, Row{}, p, now); err != nil {
+			return nil, nil, err
+		}
+	}
+	return []Row{row}, columns, nil
+}
+
+// projectedColumns returns the columns a SELECT list produces from the
+// tables, the first of which is the main table
+func projectedColumns(projections []projection, tables []*Table) ([]Column, error) {
+	var result []Column
+	for _, p := range projections {
+		if isCase(p.expr) {
+			expr, err := parseCase(p.expr)
+			if err != nil {
+				return nil, err
+			}
+			column, err := expr.column(tables)
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, column)
+			continue
+		}
+		if p.value != nil {
+			colType, err := p.value.columnType(tables)
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, Column{Name: p.name, Type: colType})
+			continue
+		}
+		if p.expr != "*" {
+			column, err := findColumn(tables, p.expr)
+			if err != nil {
+				return nil, err
+			}
+			column.Name = p.name
+			result = append(result, column)
+			continue
+		}
+		// Joined rows hold one value per column name, the join table's
+		seen := make(map[string]int)
+		for _, table := range tables {
+			for _, column := range table.Columns {
+				if i, ok := seen[column.Name]; ok {
+					result[i] = column
+					continue
+				}
+				seen[column.Name] = len(result)
+				result = append(result, column)
+			}
+		}
+	}
+	return result, nil
+}
+
+// findColumn looks up a column, which may be qualified by its table name, in
+// the tables. An unqualified name must belong to only one of them.
+func findColumn(tables []*Table, name string) (Column, error) {
+	tableName, colName, qualified := strings.Cut(name, ".")
+	if !qualified {
+		owners := columnOwners(tables, name)
+		if len(owners) > 1 {
+			return Column{}, ambiguousError(owners, name)
+		}
+		colName = name
+	}
+	for _, table := range tables {
+		if qualified && table.Name != tableName {
+			continue
+		}
+		if column, err := table.GetColumn(colName); err == nil {
+			return column, nil
+		}
+	}
+	return Column{}, fmt.Errorf("column %s not found", name)
+}
+
+// mentionsTable reports whether any of the clauses names a column qualified
+// by the table, as in table.column
+func mentionsTable(table string, clauses ...string) bool {
+	for _, clause := range clauses {
+		for _, ref := range qualifiedNameRegex.FindAllStringSubmatch(clause, -1) {
+			if ref[1] == table {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// columnOwners returns the names of the tables that have the column
+func columnOwners(tables []*Table, name string) []string {
+	var owners []string
+	for _, table := range tables {
+		if table.columnExists(name) {
+			owners = append(owners, table.Name)
+		}
+	}
+	return owners
+}
+
+func ambiguousError(owners []string, name string) error {
+	qualified := make([]string, len(owners))
+	for i, owner := range owners {
+		qualified[i] = owner + "." + name
+	}
+	return fmt.Errorf("column %s is ambiguous, use %s", name, strings.Join(qualified, " or "))
+}
+
+// checkAmbiguous returns an error for the first unqualified name in a clause
+// that is a column of more than one of the tables
+func checkAmbiguous(tables []*Table, clause string) error {
+	tokens, err := tokenize(clause)
+	if err != nil {
+		return err
+	}
+	for i, t := range tokens {
+		if t.kind != tokenIdent || tokens[i+1].is(".") || (i > 0 && tokens[i-1].is(".")) {
+			continue
+		}
+		if owners := columnOwners(tables, t.text); len(owners) > 1 {
+			return ambiguousError(owners, t.text)
+		}
+	}
+	return nil
+}
+
+// checkWhereColumns returns an error when the left side of a WHERE condition
+// names a column that none of the tables have, or when a condition is a
+// column on its own that is not a BOOL. IS NULL is left alone, and so is
+// EXISTS, whose subquery is checked against its own table.
+func checkWhereColumns(tables []*Table, whereClause string) error {
+	if strings.TrimSpace(whereClause) == "" {
+		return nil
+	}
+	expr, err := parseWhere(whereClause)
+	if err != nil {
+		return err
+	}
+	for _, condition := range expr.conditions() {
+		if err := checkConditionColumns(tables, condition); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func checkConditionColumns(tables []*Table, whereClause string) error {
+	if existsRegex.MatchString(whereClause) || isNullRegex.MatchString(whereClause) {
+		return nil
+	}
+	left, _, _, ok := splitComparison(whereClause)
+	for _, re := range []*regexp.Regexp{betweenRegex, likeRegex, inRegex} {
+		if matches := findClauses(re, whereClause); matches != nil {
+			left, ok = matches[1], true
+			break
+		}
+	}
+	// A BOOL column on its own is a condition, as if compared with true
+	bare := false
+	if !ok {
+		if _, isBool := parseBool(whereClause); isBool || !columnRegex.MatchString(whereClause) {
+			return nil
+		}
+		left, bare = whereClause, true
+	}
+	tokens, err := tokenize(left)
+	if err != nil {
+		return err
+	}
+	for i := 0; tokens[i].kind != tokenEOF; i++ {
+		// Function names are followed by their arguments, or stand alone
+		// as CURRENT_DATE does, and TRUE, FALSE and NULL are literals
+		if tokens[i].kind != tokenIdent || tokens[i+1].is("(") || isNiladicCall(tokens[i].text) || isLiteral(tokens[i].text) {
+			continue
+		}
+		name := tokens[i].text
+		if tokens[i+1].is(".") && tokens[i+2].kind == tokenIdent {
+			name += "." + tokens[i+2].text
+			i += 2
+		}
+		if _, err := findColumn(tables, name); err != nil {
+			if len(columnOwners(tables, name)) > 1 {
+				return err
+			}
+			names := make([]string, len(tables))
+			for i, table := range tables {
+				names[i] = table.Name
+			}
+			return fmt.Errorf("column %s does not exist in table %s", name, strings.Join(names, " or "))
+		}
+	}
+	if bare {
+		if column, err := findColumn(tables, left); err == nil && column.Type != COLUMN_TYPE_BOOL {
+			return fmt.Errorf("condition %s has no operator, only a BOOL column can stand alone", left)
+		}
+	}
+	return nil
+}
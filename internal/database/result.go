@@ -0,0 +1,47 @@
+package database
+
+import "time"
+
+// Result describes the outcome of a statement run through Exec, or of a
+// change made with InsertResult, UpdateResult, DeleteResult or
+// TruncateResult
+type Result struct {
+	Output      string
+	ElapsedTime time.Duration
+	// RowsAffected is the number of rows an INSERT, UPDATE, DELETE or
+	// TRUNCATE added, changed or removed, not counting rows removed by ON
+	// DELETE CASCADE; other statements affect none
+	RowsAffected int64
+	// LastInsertId is the value an INSERT stored in the AUTO_INCREMENT
+	// column of the table, 0 when it has none
+	LastInsertId int64
+}
+
+// Exec runs a SQL statement like Execute and also reports how long it took
+// and the rows it affected
+func (db *Database) Exec(sql string) (*Result, error) {
+	start := time.Now()
+	result, err := db.execute(sql)
+	if err != nil {
+		return nil, err
+	}
+	result.ElapsedTime = time.Since(start)
+	return result, nil
+}
+
+// output makes the result of a statement that only has an output
+func output(out string, err error) (*Result, error) {
+	if err != nil {
+		return nil, err
+	}
+	return &Result{Output: out}, nil
+}
+
+// outputOf returns the output of a result, for the methods that only
+// return that
+func outputOf(result *Result, err error) (string, error) {
+	if err != nil {
+		return "", err
+	}
+	return result.Output, nil
+}
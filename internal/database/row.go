@@ -1,8 +1,13 @@
 package database
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
+	"maps"
+	"slices"
 	"strings"
+	"time"
 )
 
 // Row represents a table row (record)
@@ -19,8 +24,110 @@ func (r Row) String() string {
 		first = false
 		result.WriteString(col)
 		result.WriteString(": ")
-		result.WriteString(fmt.Sprint(val))
+		result.WriteString(formatValue(val))
 	}
 	result.WriteString("}")
 	return result.String()
 }
+
+// MarshalJSON writes the row as a JSON object, with DATE and TIMESTAMP
+// values written as formatTime writes them
+func (r Row) MarshalJSON() ([]byte, error) {
+	values := make(map[string]any, len(r))
+	for col, val := range r {
+		values[col] = jsonValue(val)
+	}
+	return json.Marshal(values)
+}
+
+// jsonValue returns the value to marshal for a stored value
+func jsonValue(val any) any {
+	if t, ok := val.(time.Time); ok {
+		return formatTime(t)
+	}
+	return val
+}
+
+// orderedRow is a result row along with the order of its columns, it
+// marshals to a JSON object with the keys in that order. Columns the row
+// has no value for are left out, and values of other columns follow by name.
+type orderedRow struct {
+	row     Row
+	columns []string
+}
+
+func (r orderedRow) MarshalJSON() ([]byte, error) {
+	keys := slices.DeleteFunc(slices.Clone(r.columns), func(col string) bool {
+		_, ok := r.row[col]
+		return !ok
+	})
+	if len(keys) < len(r.row) {
+		for _, col := range slices.Sorted(maps.Keys(r.row)) {
+			if !slices.Contains(r.columns, col) {
+				keys = append(keys, col)
+			}
+		}
+	}
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, col := range keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, err := json.Marshal(col)
+		if err != nil {
+			return nil, err
+		}
+		val, err := json.Marshal(jsonValue(r.row[col]))
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(val)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// formatValue formats a value as text, as LIKE and the string functions see
+// it: DATE values as 2006-01-02 and TIMESTAMP values as 2006-01-02 15:04:05
+func formatValue(val any) string {
+	if t, ok := val.(time.Time); ok {
+		return formatTime(t)
+	}
+	return fmt.Sprint(val)
+}
+
+// normalize converts every integer value to int64, the single type used to store integers
+func (r Row) normalize() {
+	for col, val := range r {
+		r[col] = normalizeValue(val)
+	}
+}
+
+// normalizeValue converts any integer type to int64 and leaves other values untouched
+func normalizeValue(val any) any {
+	switch v := val.(type) {
+	case int:
+		return int64(v)
+	case int8:
+		return int64(v)
+	case int16:
+		return int64(v)
+	case int32:
+		return int64(v)
+	case uint:
+		return int64(v)
+	case uint8:
+		return int64(v)
+	case uint16:
+		return int64(v)
+	case uint32:
+		return int64(v)
+	case uint64:
+		return int64(v)
+	default:
+		return val
+	}
+}
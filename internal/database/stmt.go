@@ -0,0 +1,492 @@
+package database
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Stmt is a prepared statement whose ? placeholders are bound to arguments
+// each time it runs. The values of an INSERT and of the SET clause of an
+// UPDATE are converted from their Go types and stored directly, they never
+// pass through the SQL parser, nor do the numbers bound into arithmetic in
+// SET such as n + ?. Elsewhere arguments are written into the
+// statement as literals, strings quoted with their quotes doubled, and read
+// back as single values whatever characters they hold.
+type Stmt struct {
+	db        *Database
+	sql       string
+	numParams int
+
+	// Parsed parts of an INSERT or UPDATE, statement is empty for the
+	// statements that run through Execute
+	statement  string
+	table      string
+	columns    []string
+	values     []stmtValue
+	where      string // WHERE clause of an UPDATE
+	whereParam int    // index of the first argument used by the WHERE clause
+}
+
+// stmtValue is a value of a prepared INSERT or SET clause
+type stmtValue struct {
+	param   int    // index of the argument for a placeholder, -1 for a literal
+	literal string // text of a literal, strings without their quotes
+	quoted  bool
+
+	// expr is arithmetic over the row in a SET clause, such as n + ?, its
+	// placeholders are bound to the arguments from param on
+	expr       arithExpr
+	exprParams int
+}
+
+// Prepare parses a statement with ? placeholders for values, run it with
+// Exec or Query
+func (db *Database) Prepare(sql string) (*Stmt, error) {
+	if err := db.checkOpen(); err != nil {
+		return nil, err
+	}
+	sql = strings.TrimSpace(sql)
+	tokens, err := tokenize(sql)
+	if err != nil {
+		return nil, err
+	}
+	stmt := &Stmt{db: db, sql: sql}
+	c := &tokenCursor{tokens: tokens}
+	switch first := c.next(); {
+	case first.is("INSERT"):
+		err = stmt.parseInsert(c)
+	case first.is("UPDATE"):
+		err = stmt.parseUpdate(c)
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range tokens {
+		if t.is("?") {
+			stmt.numParams++
+		}
+	}
+	return stmt, nil
+}
+
+// NumInput returns the number of placeholders in the statement
+func (s *Stmt) NumInput() int {
+	return s.numParams
+}
+
+// Exec runs the statement with args bound to its placeholders, in order
+func (s *Stmt) Exec(args ...any) (*Result, error) {
+	if err := s.checkArgs(args); err != nil {
+		return nil, err
+	}
+	start := time.Now()
+	var result *Result
+	var err error
+	switch s.statement {
+	case "INSERT":
+		if err = s.db.lock(); err == nil {
+			result, err = s.execInsert(args, start)
+			s.db.mu.Unlock()
+		}
+	case "UPDATE":
+		if err = s.db.lock(); err == nil {
+			result, err = s.execUpdate(args, start)
+			s.db.mu.Unlock()
+		}
+	default:
+		var sql string
+		if sql, err = bindParams(s.sql, args); err == nil {
+			result, err = s.db.execute(sql)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	result.ElapsedTime = time.Since(start)
+	return result, nil
+}
+
+// Query runs a prepared SELECT with args bound to its placeholders
+func (s *Stmt) Query(args ...any) ([]Row, []Column, error) {
+	if err := s.checkArgs(args); err != nil {
+		return nil, nil, err
+	}
+	sql, err := bindParams(s.sql, args)
+	if err != nil {
+		return nil, nil, err
+	}
+	return s.db.Query(sql)
+}
+
+func (s *Stmt) checkArgs(args []any) error {
+	if len(args) != s.numParams {
+		return fmt.Errorf("statement has %d placeholders but %d arguments were given", s.numParams, len(args))
+	}
+	return nil
+}
+
+func (s *Stmt) parseInsert(c *tokenCursor) error {
+	s.statement = "INSERT"
+	if err := c.expectKeyword("INTO"); err != nil {
+		return err
+	}
+	table := c.next()
+	if table.kind != tokenIdent {
+		return errorAt(table, "expected table name")
+	}
+	s.table = table.text
+	if c.peek().is("(") {
+		c.next()
+		for {
+			col := c.next()
+			if col.kind != tokenIdent {
+				return errorAt(col, "expected column name")
+			}
+			s.columns = append(s.columns, col.text)
+			if sep := c.next(); sep.is(")") {
+				break
+			} else if !sep.is(",") {
+				return errorAt(sep, "expected , or )")
+			}
+		}
+	}
+	if err := c.expectKeyword("VALUES"); err != nil {
+		return err
+	}
+	if err := c.expectKeyword("("); err != nil {
+		return err
+	}
+	for {
+		val, err := s.parseValue(c)
+		if err != nil {
+			return err
+		}
+		s.values = append(s.values, val)
+		if sep := c.next(); sep.is(")") {
+			break
+		} else if !sep.is(",") {
+			return errorAt(sep, "expected , or )")
+		}
+	}
+	if s.columns != nil && len(s.columns) != len(s.values) {
+		return fmt.Errorf("column count does not match value count")
+	}
+	return c.expectEnd()
+}
+
+func (s *Stmt) parseUpdate(c *tokenCursor) error {
+	s.statement = "UPDATE"
+	table := c.next()
+	if table.kind != tokenIdent {
+		return errorAt(table, "expected table name")
+	}
+	s.table = table.text
+	if err := c.expectKeyword("SET"); err != nil {
+		return err
+	}
+	for {
+		col := c.next()
+		if col.kind != tokenIdent {
+			return errorAt(col, "expected column name")
+		}
+		if err := c.expectKeyword("="); err != nil {
+			return err
+		}
+		val, err := s.parseAssignment(c)
+		if err != nil {
+			return err
+		}
+		s.columns = append(s.columns, col.text)
+		s.values = append(s.values, val)
+		if !c.peek().is(",") {
+			break
+		}
+		c.next()
+	}
+	s.whereParam = s.paramCount()
+	// Without WHERE every row is updated
+	where := c.next()
+	if where.kind == tokenEOF {
+		return nil
+	}
+	if !where.is("WHERE") {
+		return errorAt(where, "expected WHERE")
+	}
+	s.where = strings.TrimSpace(s.sql[where.pos+len(where.text):])
+	return nil
+}
+
+// parseValue reads a placeholder or a literal
+func (s *Stmt) parseValue(c *tokenCursor) (stmtValue, error) {
+	t := c.next()
+	switch {
+	case t.is("?"):
+		return stmtValue{param: s.paramCount()}, nil
+	case t.kind == tokenString:
+		return stmtValue{param: -1, literal: t.text, quoted: true}, nil
+	case t.is("NOW") && c.peek().is("("):
+		c.next()
+		if !c.next().is(")") {
+			return stmtValue{}, errorAt(t, "expected NOW()")
+		}
+		return stmtValue{param: -1, literal: "NOW()"}, nil
+	case t.kind == tokenNumber, t.kind == tokenIdent:
+		return stmtValue{param: -1, literal: t.text}, nil
+	case t.is("-") && c.peek().kind == tokenNumber:
+		return stmtValue{param: -1, literal: "-" + c.next().text}, nil
+	default:
+		return stmtValue{}, errorAt(t, "expected a value or ?")
+	}
+}
+
+// parseAssignment reads the value of a SET clause, a placeholder, a literal
+// or arithmetic over the row such as n + ?
+func (s *Stmt) parseAssignment(c *tokenCursor) (stmtValue, error) {
+	start := c.i
+	if val, err := s.parseValue(c); err == nil && endsAssignment(c.peek()) {
+		return val, nil
+	}
+	c.i = start
+	first := c.peek()
+	val := stmtValue{param: s.paramCount()}
+	for depth := 0; c.peek().kind != tokenEOF && (depth > 0 || !endsAssignment(c.peek())); {
+		switch t := c.next(); {
+		case t.is("("):
+			depth++
+		case t.is(")"):
+			depth--
+		case t.is("?"):
+			val.exprParams++
+		}
+	}
+	val.literal = strings.TrimSpace(s.sql[first.pos:c.peek().pos])
+	if val.literal == "" {
+		return stmtValue{}, errorAt(first, "expected a value or ?")
+	}
+	expr, err := parseArithmetic(val.literal)
+	if err != nil {
+		return stmtValue{}, errorAt(first, "%v", err)
+	}
+	val.expr = expr
+	return val, nil
+}
+
+// endsAssignment reports whether t follows the value of a SET clause
+func endsAssignment(t token) bool {
+	return t.kind == tokenEOF || t.is(",") || t.is("WHERE")
+}
+
+// paramCount returns the number of placeholders parsed so far
+func (s *Stmt) paramCount() int {
+	count := 0
+	for _, val := range s.values {
+		switch {
+		case val.expr != nil:
+			count += val.exprParams
+		case val.param >= 0:
+			count++
+		}
+	}
+	return count
+}
+
+// bindRow converts the values of the statement, with args in place of the
+// placeholders, into a row of table. Arithmetic is left to bindComputed.
+func (s *Stmt) bindRow(table *Table, columns []string, args []any, now time.Time) (Row, error) {
+	row := make(Row)
+	for i, name := range columns {
+		column, err := table.GetColumn(name)
+		if err != nil {
+			return nil, err
+		}
+		val := s.values[i]
+		var converted any
+		switch {
+		case val.expr != nil:
+			continue
+		case val.param >= 0:
+			converted, err = bindValue(column, args[val.param], now)
+		case val.quoted:
+			converted, err = rawValueConversion(column.Type, val.literal, now)
+		default:
+			converted, err = columnTypeConversion(column.Type, val.literal, now)
+		}
+		if err != nil {
+			return nil, err
+		}
+		row[name] = converted
+	}
+	return row, nil
+}
+
+func (s *Stmt) execInsert(args []any, now time.Time) (*Result, error) {
+	table, err := s.db.getTable(s.table)
+	if err != nil {
+		return nil, err
+	}
+	columns := s.columns
+	if columns == nil {
+		if columns, err = table.insertColumns(len(s.values)); err != nil {
+			return nil, err
+		}
+	}
+	row, err := s.bindRow(table, columns, args, now)
+	if err != nil {
+		return nil, err
+	}
+	return s.db.insertRow(table, row)
+}
+
+func (s *Stmt) execUpdate(args []any, now time.Time) (*Result, error) {
+	table, err := s.db.getTable(s.table)
+	if err != nil {
+		return nil, err
+	}
+	assignments, err := s.bindRow(table, s.columns, args, now)
+	if err != nil {
+		return nil, err
+	}
+	computed, err := s.bindComputed(table, args)
+	if err != nil {
+		return nil, err
+	}
+	where, err := bindParams(s.where, args[s.whereParam:])
+	if err != nil {
+		return nil, err
+	}
+	return s.db.updateRows(table, assignments, computed, where, now)
+}
+
+// bindComputed binds the arithmetic of a SET clause to args, keyed by the
+// column it is assigned to
+func (s *Stmt) bindComputed(table *Table, args []any) (map[string]arithExpr, error) {
+	var computed map[string]arithExpr
+	for i, val := range s.values {
+		if val.expr == nil {
+			continue
+		}
+		column, err := table.GetColumn(s.columns[i])
+		if err != nil {
+			return nil, err
+		}
+		expr, err := bindArith(val.expr, args[val.param:])
+		if err != nil {
+			return nil, err
+		}
+		if err := checkArithAssignment(table, column, expr, val.literal); err != nil {
+			return nil, err
+		}
+		if computed == nil {
+			computed = make(map[string]arithExpr)
+		}
+		computed[column.Name] = expr
+	}
+	return computed, nil
+}
+
+// bindValue converts an argument to the type stored in column
+func bindValue(column Column, arg any, now time.Time) (any, error) {
+	if arg == nil {
+		return nil, nil
+	}
+	if text, ok := arg.(string); ok && column.Type != COLUMN_TYPE_VARCHAR && column.Type != COLUMN_TYPE_ENUM {
+		return rawValueConversion(column.Type, text, now)
+	}
+	switch column.Type {
+	case COLUMN_TYPE_INT:
+		if val := normalizeValue(arg); isInt64(val) {
+			return val, nil
+		}
+	case COLUMN_TYPE_DOUBLE:
+		if num, ok := toFloat64(arg); ok {
+			return num, nil
+		}
+	case COLUMN_TYPE_FLOAT:
+		if num, ok := toFloat64(arg); ok {
+			return float32(num), nil
+		}
+	case COLUMN_TYPE_DECIMAL:
+		switch v := normalizeValue(arg).(type) {
+		case Decimal:
+			return v, nil
+		case int64:
+			return Decimal{Unscaled: v}, nil
+		case float32, float64:
+			num, _ := toFloat64(v)
+			return parseDecimal(strconv.FormatFloat(num, 'f', -1, 64))
+		}
+	case COLUMN_TYPE_BOOL:
+		if b, ok := arg.(bool); ok {
+			return b, nil
+		}
+	case COLUMN_TYPE_DATE:
+		if t, ok := arg.(time.Time); ok {
+			return dateOf(t), nil
+		}
+	case COLUMN_TYPE_TIMESTAMP:
+		// Stored as the time on the clock in UTC, as literals are parsed
+		if t, ok := arg.(time.Time); ok {
+			year, month, day := t.Date()
+			hour, min, sec := t.Clock()
+			return time.Date(year, month, day, hour, min, sec, 0, time.UTC), nil
+		}
+	default:
+		if text, ok := arg.(string); ok {
+			return text, nil
+		}
+	}
+	return nil, fmt.Errorf("cannot store %T value %v in %s column %s", arg, arg, column.Type, column.Name)
+}
+
+func isInt64(val any) bool {
+	_, ok := val.(int64)
+	return ok
+}
+
+// bindParams replaces the ? placeholders of sql with args written as literals
+func bindParams(sql string, args []any) (string, error) {
+	tokens, err := tokenize(sql)
+	if err != nil {
+		return "", err
+	}
+	var bound strings.Builder
+	last, n := 0, 0
+	for _, t := range tokens {
+		if !t.is("?") {
+			continue
+		}
+		if n == len(args) {
+			return "", fmt.Errorf("not enough arguments for the placeholders of %s", sql)
+		}
+		bound.WriteString(sql[last:t.pos])
+		bound.WriteString(sqlLiteral(args[n]))
+		last = t.pos + 1
+		n++
+	}
+	bound.WriteString(sql[last:])
+	return bound.String(), nil
+}
+
+// sqlLiteral formats a value so that it reads back as the same value
+func sqlLiteral(val any) string {
+	switch v := val.(type) {
+	case nil:
+		return "NULL"
+	case string:
+		return "'" + strings.ReplaceAll(v, "'", "''") + "'"
+	case time.Time:
+		return "'" + formatTime(v) + "'"
+	case Decimal:
+		return v.String()
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	case float32:
+		return strconv.FormatFloat(float64(v), 'g', -1, 32)
+	case bool, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return fmt.Sprint(v)
+	default:
+		return sqlLiteral(formatValue(v))
+	}
+}
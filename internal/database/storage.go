@@ -0,0 +1,225 @@
+package database
+
+import (
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// StorageBackend persists the tables of a database. Load returns an error
+// satisfying os.IsNotExist when nothing has been saved yet.
+type StorageBackend interface {
+	Save(db *Database) error
+	Load(db *Database) error
+}
+
+// Option configures a database created by NewDatabase
+type Option func(*Database)
+
+// WithStorage selects how the database is saved, the default is GobStorage
+func WithStorage(storage StorageBackend) Option {
+	return func(db *Database) {
+		db.storage = storage
+	}
+}
+
+// WithPath saves the database in the file at path, such as
+// /var/lib/godb/app.gob, instead of a file named after the database in the
+// working directory. When path is an existing directory the file keeps its
+// default name inside it.
+func WithPath(path string) Option {
+	return func(db *Database) {
+		db.path = path
+	}
+}
+
+// filePath returns the file the storage backends read and write, which is
+// the path set with WithPath or else the database name with ext appended
+func (db *Database) filePath(ext string) string {
+	if db.path == "" {
+		return db.Name + ext
+	}
+	if info, err := os.Stat(db.path); err == nil && info.IsDir() {
+		return filepath.Join(db.path, filepath.Base(db.Name)+ext)
+	}
+	return db.path
+}
+
+// GobStorage saves the database in the gob format, in the file named after
+// the database with a .gob extension
+type GobStorage struct{}
+
+func (GobStorage) Save(db *Database) error {
+	return writeFileAtomic(db.filePath(".gob"), func(w io.Writer) error {
+		return gob.NewEncoder(w).Encode(db)
+	})
+}
+
+func (GobStorage) Load(db *Database) error {
+	file, err := os.Open(db.filePath(".gob"))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if err := gob.NewDecoder(file).Decode(db); err != nil {
+		return err
+	}
+	// Older files may hold integers as int, and dates as text
+	for _, table := range db.Tables {
+		for _, row := range table.Rows {
+			row.normalize()
+		}
+		if err := table.parseDates(); err != nil {
+			return err
+		}
+		table.reindex()
+	}
+	return nil
+}
+
+// parseDates converts the DATE and TIMESTAMP values of a table held as text,
+// as JSON and older gob files save them, to time.Time
+func (t *Table) parseDates() error {
+	for i, column := range t.Columns {
+		if column.Type != COLUMN_TYPE_DATE && column.Type != COLUMN_TYPE_TIMESTAMP {
+			continue
+		}
+		if text, ok := column.Default.(string); ok {
+			date, err := parseTime(text)
+			if err != nil {
+				return fmt.Errorf("table %s, default of column %s: %v", t.Name, column.Name, err)
+			}
+			t.Columns[i].Default = date
+		}
+		for _, row := range t.Rows {
+			text, ok := row[column.Name].(string)
+			if !ok {
+				continue
+			}
+			date, err := parseTime(text)
+			if err != nil {
+				return fmt.Errorf("table %s, column %s: %v", t.Name, column.Name, err)
+			}
+			row[column.Name] = date
+		}
+	}
+	return nil
+}
+
+// JSONStorage saves the database as indented JSON, in the file named after
+// the database with a .json extension, so it can be read and edited by hand
+type JSONStorage struct{}
+
+func (JSONStorage) Save(db *Database) error {
+	return writeFileAtomic(db.filePath(".json"), func(w io.Writer) error {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(db)
+	})
+}
+
+func (JSONStorage) Load(db *Database) error {
+	file, err := os.Open(db.filePath(".json"))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	decoder := json.NewDecoder(file)
+	decoder.UseNumber()
+	if err := decoder.Decode(db); err != nil {
+		return err
+	}
+	// JSON has a single number type, convert numbers back to the column type
+	for _, table := range db.Tables {
+		for i, column := range table.Columns {
+			if num, ok := column.Default.(json.Number); ok {
+				val, err := numberValue(column.Type, num)
+				if err != nil {
+					return fmt.Errorf("table %s, default of column %s: %v", table.Name, column.Name, err)
+				}
+				table.Columns[i].Default = val
+			}
+		}
+		for _, row := range table.Rows {
+			for _, column := range table.Columns {
+				num, ok := row[column.Name].(json.Number)
+				if !ok {
+					continue
+				}
+				val, err := numberValue(column.Type, num)
+				if err != nil {
+					return fmt.Errorf("table %s, column %s: %v", table.Name, column.Name, err)
+				}
+				row[column.Name] = val
+			}
+		}
+		if err := table.parseDates(); err != nil {
+			return err
+		}
+		table.reindex()
+	}
+	return nil
+}
+
+// MemoryStorage keeps the database in memory only, it never reads or
+// writes a file and the tables are lost when the program exits
+type MemoryStorage struct{}
+
+func (MemoryStorage) Save(db *Database) error {
+	return nil
+}
+
+func (MemoryStorage) Load(db *Database) error {
+	return os.ErrNotExist
+}
+
+// WithInMemory keeps the database in memory, without a file, it is short for
+// WithStorage(MemoryStorage{})
+func WithInMemory() Option {
+	return WithStorage(MemoryStorage{})
+}
+
+func numberValue(colType ColumnType, num json.Number) (any, error) {
+	switch colType {
+	case COLUMN_TYPE_INT:
+		return num.Int64()
+	case COLUMN_TYPE_FLOAT:
+		f, err := num.Float64()
+		return float32(f), err
+	case COLUMN_TYPE_DECIMAL:
+		return parseDecimal(num.String())
+	default:
+		return num.Float64()
+	}
+}
+
+// writeFileAtomic writes to a temporary file and renames it over path, so a
+// failed or interrupted save leaves the previous file complete
+func writeFileAtomic(path string, write func(io.Writer) error) error {
+	file, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+	if err := file.Chmod(0o644); err != nil {
+		file.Close()
+		return err
+	}
+	if err := write(file); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(file.Name(), path)
+}
@@ -0,0 +1,173 @@
+package database
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+var (
+	existsRegex        = regexp.MustCompile(`(?i)^(NOT\s+)?EXISTS\s*\(\s*(SELECT\s+.+)\)$`)
+	qualifiedNameRegex = regexp.MustCompile(`\b([A-Za-z_]\w*)\.([A-Za-z_]\w*)\b`)
+)
+
+// subqueryCache holds parsed subqueries, keyed by their SQL, so that a
+// correlated subquery is parsed once and not for every outer row
+var subqueryCache = newParseCache[string, *subquery]()
+
+// subquery is a SELECT nested in a WHERE clause
+type subquery struct {
+	table string
+	name  string // the alias of the table, or else its name
+	where string
+	// outerRefs maps table.column names in the WHERE clause that do not
+	// belong to the subquery's table to the column of the outer row
+	outerRefs map[string]string
+}
+
+// parseSubquery parses the SELECT of an EXISTS predicate
+func parseSubquery(sql string) (*subquery, error) {
+	if q, ok := subqueryCache.Load(sql); ok {
+		return q, nil
+	}
+	matches := findClauses(selectRegex, strings.TrimSpace(sql))
+	if matches == nil {
+		return nil, fmt.Errorf("invalid subquery: %s", sql)
+	}
+	if matches[3] != "" {
+		return nil, fmt.Errorf("JOIN is not supported in a subquery")
+	}
+	table, name, err := splitTableRef(matches[2])
+	if err != nil {
+		return nil, err
+	}
+	q := &subquery{table: table, name: name, where: matches[4], outerRefs: make(map[string]string)}
+	for _, ref := range qualifiedNameRegex.FindAllStringSubmatch(q.where, -1) {
+		if ref[1] != q.name {
+			q.outerRefs[ref[0]] = ref[2]
+		}
+	}
+	subqueryCache.Store(sql, q)
+	return q, nil
+}
+
+// exists reports whether the subquery finds a row for the given outer row
+func (db *Database) exists(q *subquery, outer Row, now time.Time) (bool, error) {
+	table, err := db.getTable(q.table)
+	if err != nil {
+		return false, err
+	}
+	aliased := *table
+	aliased.Name = q.name
+	types := typesOf(&aliased)
+	for _, inner := range table.Rows {
+		row := make(Row, 2*len(inner)+len(q.outerRefs))
+		for col, val := range inner {
+			row[col] = val
+			row[q.name+"."+col] = val
+		}
+		for ref, col := range q.outerRefs {
+			if val, ok := outer[ref]; ok {
+				row[ref] = val
+			} else if val, ok := outer[col]; ok {
+				row[ref] = val
+			}
+		}
+		matched, err := db.evaluateWhere(row, q.where, types, now)
+		if err != nil {
+			return false, err
+		}
+		if matched {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// resolveInSubqueries runs every IN (SELECT ...) subquery of a WHERE clause
+// once, before any row is filtered, and replaces it with the list of values
+// it returns
+func (db *Database) resolveInSubqueries(whereClause string, now time.Time) (string, error) {
+	tokens, err := tokenize(whereClause)
+	if err != nil {
+		return "", err
+	}
+	var resolved strings.Builder
+	last := 0
+	for i := 0; i+2 < len(tokens); i++ {
+		if !tokens[i].is("IN") || !tokens[i+1].is("(") || !tokens[i+2].is("SELECT") {
+			continue
+		}
+		start, end := tokens[i+1].pos, -1
+		depth := 0
+		for j := i + 1; tokens[j].kind != tokenEOF && end < 0; j++ {
+			if tokens[j].is("(") {
+				depth++
+			} else if tokens[j].is(")") {
+				if depth--; depth == 0 {
+					end, i = tokens[j].pos, j
+				}
+			}
+		}
+		if end < 0 {
+			return "", fmt.Errorf("subquery is missing a closing parenthesis: %s", whereClause[start:])
+		}
+		values, err := db.subqueryValues(whereClause[start+1:end], now)
+		if err != nil {
+			return "", err
+		}
+		resolved.WriteString(whereClause[last:start])
+		resolved.WriteString("(" + strings.Join(values, ", ") + ")")
+		last = end + 1
+	}
+	if last == 0 {
+		return whereClause, nil
+	}
+	resolved.WriteString(whereClause[last:])
+	return resolved.String(), nil
+}
+
+// subqueryValues runs the SELECT of an IN subquery and returns the values of
+// its single column as SQL literals, nulls are left out since they never match
+func (db *Database) subqueryValues(sql string, now time.Time) ([]string, error) {
+	sql = strings.TrimSpace(sql)
+	matches := findClauses(selectRegex, sql)
+	if matches == nil {
+		return nil, fmt.Errorf("invalid subquery: %s", sql)
+	}
+	table, err := db.tableRef(matches[2])
+	if err != nil {
+		return nil, err
+	}
+	columns := strings.Split(matches[1], ",")
+	// The alias of the column makes no difference to the values
+	col, _, err := cutAlias(strings.TrimSpace(columns[0]))
+	if err != nil {
+		return nil, err
+	}
+	if col == "*" && len(table.Columns) == 1 {
+		col = table.Columns[0].Name
+	}
+	if len(columns) != 1 || col == "*" {
+		return nil, fmt.Errorf("subquery in IN must select exactly one column: %s", sql)
+	}
+	whereClause, err := db.resolveInSubqueries(matches[4], now)
+	if err != nil {
+		return nil, err
+	}
+	rows, tables, err := db.matchedRows(table, whereClause, matches[3], now)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := findColumn(tables, col); err != nil {
+		return nil, err
+	}
+	var values []string
+	for _, row := range rows {
+		if val := row[col]; val != nil {
+			values = append(values, sqlLiteral(val))
+		}
+	}
+	return values, nil
+}
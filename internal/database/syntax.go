@@ -0,0 +1,257 @@
+package database
+
+import (
+	"fmt"
+	"strings"
+)
+
+var statementKeywords = []string{"ALTER", "BEGIN", "COMMIT", "CREATE", "DELETE", "DROP", "DUMP", "EXPLAIN", "EXPORT", "IMPORT", "INSERT", "ROLLBACK", "SELECT", "TRUNCATE", "UPDATE"}
+
+// tokenCursor walks the tokens of a statement to find where it stops being valid
+type tokenCursor struct {
+	tokens []token
+	i      int
+}
+
+func (c *tokenCursor) peek() token {
+	return c.tokens[c.i]
+}
+
+func (c *tokenCursor) next() token {
+	t := c.tokens[c.i]
+	if t.kind != tokenEOF {
+		c.i++
+	}
+	return t
+}
+
+// errorAt builds a syntax error located at the token t
+func errorAt(t token, format string, args ...any) *ErrSyntax {
+	return &ErrSyntax{Position: t.pos, Near: t.text, Msg: fmt.Sprintf(format, args...)}
+}
+
+func (c *tokenCursor) expectKeyword(keywords ...string) error {
+	t := c.next()
+	for _, kw := range keywords {
+		if t.is(kw) {
+			return nil
+		}
+	}
+	return errorAt(t, "expected %s", strings.Join(keywords, " or "))
+}
+
+func (c *tokenCursor) expectIdent(what string) error {
+	if t := c.next(); t.kind != tokenIdent {
+		return errorAt(t, "expected %s", what)
+	}
+	return nil
+}
+
+// skipParens consumes a parenthesized group, including nested ones
+func (c *tokenCursor) skipParens() error {
+	open := c.next()
+	if !open.is("(") {
+		return errorAt(open, "expected (")
+	}
+	for depth := 1; depth > 0; {
+		t := c.next()
+		switch {
+		case t.kind == tokenEOF:
+			return errorAt(open, "unclosed parenthesis")
+		case t.is("("):
+			depth++
+		case t.is(")"):
+			depth--
+		}
+	}
+	return nil
+}
+
+// skipExpression consumes one projection or value: a literal, a column
+// reference, * or a function call
+func (c *tokenCursor) skipExpression() error {
+	t := c.next()
+	switch {
+	case t.kind == tokenString || t.kind == tokenNumber || t.is("*"):
+		return nil
+	case t.kind == tokenIdent:
+		for c.peek().is(".") {
+			c.next()
+			if n := c.next(); n.kind != tokenIdent && !n.is("*") {
+				return errorAt(n, "expected column name")
+			}
+		}
+		if c.peek().is("(") {
+			return c.skipParens()
+		}
+		return nil
+	default:
+		return errorAt(t, "expected column or value")
+	}
+}
+
+func (c *tokenCursor) expectEnd() error {
+	if t := c.peek(); t.kind != tokenEOF {
+		return errorAt(t, "unexpected text at end of statement")
+	}
+	return nil
+}
+
+// diagnose explains why a statement did not match any supported form,
+// pointing at the first token where it goes wrong
+func diagnose(tokens []token) error {
+	c := &tokenCursor{tokens: tokens}
+	first := c.next()
+	var err error
+	switch {
+	case first.is("SELECT"):
+		err = c.diagnoseSelect()
+	case first.is("INSERT"):
+		err = c.diagnoseInsert()
+	case first.is("UPDATE"):
+		err = c.diagnoseUpdate()
+	case first.is("DELETE"):
+		if err = c.expectKeyword("FROM"); err == nil {
+			err = c.expectIdent("table name")
+		}
+		if err == nil && !c.peek().is("WHERE") {
+			err = c.expectEnd()
+		}
+	case first.is("CREATE") && c.peek().is("INDEX"):
+		c.next()
+		if err = c.expectIdent("index name"); err == nil {
+			err = c.expectKeyword("ON")
+		}
+		if err == nil {
+			err = c.expectIdent("table name")
+		}
+		if err == nil {
+			err = c.skipParens()
+		}
+		if err == nil {
+			err = c.expectEnd()
+		}
+	case first.is("CREATE"), first.is("DROP"):
+		if err = c.expectKeyword("TABLE"); err == nil {
+			err = c.expectIdent("table name")
+		}
+		if err == nil && first.is("CREATE") {
+			err = c.skipParens()
+		}
+		if err == nil {
+			err = c.expectEnd()
+		}
+	case first.is("TRUNCATE"):
+		if c.peek().is("TABLE") {
+			c.next()
+		}
+		if err = c.expectIdent("table name"); err == nil {
+			err = c.expectEnd()
+		}
+	case first.is("DUMP"):
+		if c.peek().is("DATABASE") {
+			c.next()
+		}
+		err = c.expectEnd()
+	case first.is("EXPORT"):
+		if err = c.expectKeyword("DATABASE"); err == nil {
+			err = c.expectKeyword("TO")
+		}
+		if err == nil {
+			if t := c.next(); t.kind != tokenString {
+				err = errorAt(t, "expected a quoted file name")
+			}
+		}
+		if err == nil {
+			err = c.expectEnd()
+		}
+	case first.is("IMPORT"):
+		if err = c.expectKeyword("INTO"); err == nil {
+			err = c.expectIdent("table name")
+		}
+		if err == nil {
+			err = c.expectKeyword("FROM")
+		}
+		if err == nil {
+			if t := c.next(); t.kind != tokenString {
+				err = errorAt(t, "expected a quoted file name")
+			}
+		}
+		if err == nil {
+			err = c.expectEnd()
+		}
+	case first.is("ALTER"):
+		if err = c.expectKeyword("TABLE"); err == nil {
+			err = c.expectIdent("table name")
+		}
+		if err == nil {
+			err = c.expectKeyword("ADD", "RENAME")
+		}
+	case first.kind == tokenEOF:
+		return fmt.Errorf("empty SQL statement")
+	default:
+		return errorAt(first, "expected one of %s", strings.Join(statementKeywords, ", "))
+	}
+	if err != nil {
+		return err
+	}
+	return errorAt(first, "unsupported SQL command")
+}
+
+func (c *tokenCursor) diagnoseSelect() error {
+	for {
+		if err := c.skipExpression(); err != nil {
+			return err
+		}
+		if !c.peek().is(",") {
+			break
+		}
+		c.next()
+	}
+	if err := c.expectKeyword("FROM"); err != nil {
+		return err
+	}
+	if err := c.expectIdent("table name"); err != nil {
+		return err
+	}
+	switch t := c.peek(); {
+	case t.kind == tokenEOF, t.is("JOIN"), t.is("LEFT"), t.is("FULL"), t.is("CROSS"), t.is("INNER"), t.is("WHERE"), t.is("ORDER"), t.is("LIMIT"), t.is("OFFSET"):
+		return nil
+	default:
+		return errorAt(t, "expected JOIN, WHERE, ORDER BY, LIMIT or OFFSET")
+	}
+}
+
+func (c *tokenCursor) diagnoseInsert() error {
+	if err := c.expectKeyword("INTO"); err != nil {
+		return err
+	}
+	if err := c.expectIdent("table name"); err != nil {
+		return err
+	}
+	if c.peek().is("(") {
+		if err := c.skipParens(); err != nil {
+			return err
+		}
+	}
+	if err := c.expectKeyword("VALUES"); err != nil {
+		return err
+	}
+	if err := c.skipParens(); err != nil {
+		return err
+	}
+	return c.expectEnd()
+}
+
+func (c *tokenCursor) diagnoseUpdate() error {
+	if err := c.expectIdent("table name"); err != nil {
+		return err
+	}
+	if err := c.expectKeyword("SET"); err != nil {
+		return err
+	}
+	for t := c.peek(); !t.is("WHERE") && t.kind != tokenEOF; t = c.peek() {
+		c.next()
+	}
+	return nil
+}
@@ -1,7 +1,9 @@
 package database
 
 import (
+	"cmp"
 	"fmt"
+	"slices"
 	"sort"
 	"strings"
 	"time"
@@ -12,7 +14,10 @@ type Table struct {
 	Columns     []Column
 	Rows        []Row
 	PrimaryKey  string
-	ForeignKeys map[string]string
+	ForeignKeys map[string]string // column name -> referenced "table.column"
+	Indexes     []*Index
+	// primaryIndex finds rows by primary key, it is rebuilt rather than saved
+	primaryIndex *Index
 }
 
 func newTable(name string) *Table {
@@ -44,20 +49,88 @@ func (t *Table) addColumn(column Column) {
 	t.Columns = append(t.Columns, column)
 }
 
+// insertColumns returns the columns filled by an INSERT without a column list,
+// in declaration order. AUTO_INCREMENT columns are left out when there is no
+// value for them.
+func (t *Table) insertColumns(valueCount int) ([]string, error) {
+	var all, withoutAuto []string
+	for _, column := range t.Columns {
+		all = append(all, column.Name)
+		if !column.HasConstraint(COLUMN_CONSTRAINT_AUTO_INCREMENT) {
+			withoutAuto = append(withoutAuto, column.Name)
+		}
+	}
+	switch valueCount {
+	case len(all):
+		return all, nil
+	case len(withoutAuto):
+		return withoutAuto, nil
+	default:
+		return nil, fmt.Errorf("table %s has %d columns but %d values were supplied", t.Name, len(all), valueCount)
+	}
+}
+
+// buildRow converts the values of an insert started at now into a row using
+// convert
+func (t *Table) buildRow(columns []string, values []string, convert func(ColumnType, string, time.Time) (any, error), now time.Time) (Row, error) {
+	if len(columns) != len(values) {
+		return nil, fmt.Errorf("column count does not match value count")
+	}
+
+	row := make(Row)
+	for i, col := range columns {
+		col = strings.TrimSpace(col)
+		val := strings.TrimSpace(values[i])
+
+		column, err := t.GetColumn(col)
+		if err != nil {
+			return nil, err
+		}
+		// Simple type conversion
+		convertedVal, err := convert(column.Type, val, now)
+		if err != nil {
+			return nil, err
+		}
+		row[col] = convertedVal
+	}
+	return row, nil
+}
+
 func (t *Table) addRow(row Row) error {
-	if err := t.validatePrimaryKey(row); err != nil {
+	row.normalize()
+	t.applyDefaults(row)
+	if err := t.applyAutoIncrement(&row); err != nil {
 		return err
 	}
-	if err := t.validateUnique(row); err != nil {
+	if err := t.validateNotNull(row, true); err != nil {
 		return err
 	}
-	if err := t.applyAutoIncrement(&row); err != nil {
+	if err := t.validateValues(row); err != nil {
+		return err
+	}
+	if err := t.validatePrimaryKey(row); err != nil {
+		return err
+	}
+	if err := t.validateUnique(row); err != nil {
 		return err
 	}
 	t.Rows = append(t.Rows, row)
+	for _, index := range t.allIndexes() {
+		index.add(row, len(t.Rows)-1)
+	}
 	return nil
 }
 
+// nullRow returns a row with every column of the table set to null, it
+// stands in for the missing match of a LEFT or FULL JOIN
+func (t *Table) nullRow() Row {
+	row := make(Row)
+	for _, column := range t.Columns {
+		row[column.Name] = nil
+	}
+	return row
+}
+
 func (t Table) columnExists(columnName string) bool {
 	for _, column := range t.Columns {
 		if column.Name == columnName {
@@ -67,19 +140,108 @@ func (t Table) columnExists(columnName string) bool {
 	return false
 }
 
+// columnIndex returns the position of a column in the table, or -1
+func (t Table) columnIndex(columnName string) int {
+	for i, column := range t.Columns {
+		if column.Name == columnName {
+			return i
+		}
+	}
+	return -1
+}
+
+// validateNotNull rejects empty values for NOT NULL columns. When the row is
+// complete, as on insert, a missing NOT NULL column is rejected too.
+func (t *Table) validateNotNull(row Row, complete bool) error {
+	for _, column := range t.Columns {
+		if !column.HasConstraint(COLUMN_CONSTRAINT_NOT_NULL) {
+			continue
+		}
+		val, exists := row[column.Name]
+		if !exists && !complete {
+			continue
+		}
+		if !exists || val == nil || val == "" {
+			return fmt.Errorf("column %s cannot be null", column.Name)
+		}
+	}
+	return nil
+}
+
+// validateValues rejects values outside the limits of their column type, such
+// as the values of an ENUM or the length of a VARCHAR(n), and stores ENUM
+// values spelled as declared
+func (t *Table) validateValues(row Row) error {
+	for _, column := range t.Columns {
+		val, exists := row[column.Name]
+		if !exists {
+			continue
+		}
+		val, err := column.checkValue(val)
+		if err != nil {
+			return err
+		}
+		row[column.Name] = val
+	}
+	return nil
+}
+
 func (t *Table) validatePrimaryKey(row Row) error {
 	if t.PrimaryKey == "" {
 		return nil
 	}
 
-	pkValue, exists := row[t.PrimaryKey]
-	if !exists {
+	pkValue := row[t.PrimaryKey]
+	if pkValue == nil {
 		return fmt.Errorf("primary key column %s not provided", t.PrimaryKey)
 	}
 
-	for _, existingRow := range t.Rows {
-		if existingRow[t.PrimaryKey] == pkValue {
-			return fmt.Errorf("primary key value %v already exists", pkValue)
+	if len(t.primaryIndex.entries[pkValue]) > 0 {
+		return fmt.Errorf("primary key value %v already exists", pkValue)
+	}
+	return nil
+}
+
+// validateKeyChanges checks the rows at positions, changed by the matching
+// values of changes, against the primary key and the UNIQUE columns: no two
+// rows may end up with the same key, whether the other row is changed too or
+// not. Nulls are never equal, except that a primary key cannot be null.
+func (t *Table) validateKeyChanges(positions []int, changes []Row) error {
+	changed := make(map[int]bool, len(positions))
+	for _, i := range positions {
+		changed[i] = true
+	}
+	for _, column := range t.Columns {
+		col := column.Name
+		primary := col == t.PrimaryKey
+		if !primary && !column.HasConstraint(COLUMN_CONSTRAINT_UNIQUE) {
+			continue
+		}
+		if !slices.ContainsFunc(changes, func(values Row) bool { _, ok := values[col]; return ok }) {
+			continue
+		}
+		taken := make(map[any]bool)
+		for i, row := range t.Rows {
+			if !changed[i] && row[col] != nil {
+				taken[row[col]] = true
+			}
+		}
+		for n, i := range positions {
+			val, ok := changes[n][col]
+			if !ok {
+				val = t.Rows[i][col]
+			}
+			switch {
+			case val == nil && primary:
+				return fmt.Errorf("primary key column %s not provided", col)
+			case val == nil:
+				continue
+			case taken[val] && primary:
+				return fmt.Errorf("primary key value %v already exists", val)
+			case taken[val]:
+				return fmt.Errorf("unique constraint violation on column %s", col)
+			}
+			taken[val] = true
 		}
 	}
 	return nil
@@ -88,7 +250,11 @@ func (t *Table) validatePrimaryKey(row Row) error {
 func (t *Table) validateUnique(row Row) error {
 	for _, column := range t.Columns {
 		if column.HasConstraint(COLUMN_CONSTRAINT_UNIQUE) {
+			// Nulls are never equal, so any number of rows may leave it unset
 			val := row[column.Name]
+			if val == nil {
+				continue
+			}
 			for _, existingRow := range t.Rows {
 				if existingRow[column.Name] == val {
 					return fmt.Errorf("unique constraint violation on column %s", column.Name)
@@ -99,13 +265,23 @@ func (t *Table) validateUnique(row Row) error {
 	return nil
 }
 
+// applyDefaults fills the columns the row leaves out with their defaults, a
+// column set to NULL explicitly stays null
+func (t *Table) applyDefaults(row Row) {
+	for _, column := range t.Columns {
+		if _, exists := row[column.Name]; !exists && column.Default != nil {
+			row[column.Name] = column.Default
+		}
+	}
+}
+
 func (t *Table) applyAutoIncrement(row *Row) error {
 	for _, col := range t.Columns {
 		if col.HasConstraint(COLUMN_CONSTRAINT_AUTO_INCREMENT) {
-			if _, exists := (*row)[col.Name]; !exists {
-				max := 0
+			if (*row)[col.Name] == nil {
+				var max int64
 				for _, existingRow := range t.Rows {
-					if val, ok := existingRow[col.Name].(int); ok && val > max {
+					if val, ok := existingRow[col.Name].(int64); ok && val > max {
 						max = val
 					}
 				}
@@ -129,96 +305,138 @@ func (t Table) String() string {
 	return name + columns + rows
 }
 
-func sortRows(rows []Row, col Column, dir string) []Row {
-	sort.Slice(rows, func(i, j int) bool {
-		vi, iok := rows[i][col.Name]
-		vj, jok := rows[j][col.Name]
-		if !iok || !jok {
-			return false
+// sortKey is a column rows are sorted by, in the direction ASC or DESC, with
+// nulls FIRST or LAST, or where the direction puts them when empty
+type sortKey struct {
+	column Column
+	dir    string
+	nulls  string
+}
+
+// sortRows sorts rows by the first key, rows it finds equal by the next one
+// and so on. Rows equal on every key keep their order. The values of the keys
+// are read from keyRows, which holds a row for each of rows and is sorted
+// along with them.
+func sortRows(rows []Row, keyRows []Row, keys []sortKey) {
+	sort.Stable(rowSorter{rows, keyRows, keys})
+}
+
+type rowSorter struct {
+	rows, keyRows []Row
+	keys          []sortKey
+}
+
+func (s rowSorter) Len() int {
+	return len(s.rows)
+}
+
+func (s rowSorter) Swap(i, j int) {
+	s.rows[i], s.rows[j] = s.rows[j], s.rows[i]
+	s.keyRows[i], s.keyRows[j] = s.keyRows[j], s.keyRows[i]
+}
+
+func (s rowSorter) Less(i, j int) bool {
+	for _, key := range s.keys {
+		if order := key.compare(s.keyRows[i], s.keyRows[j]); order != 0 {
+			return order < 0
 		}
+	}
+	return false
+}
 
-		switch col.Type {
-		case COLUMN_TYPE_INT:
-			viInt, ok1 := vi.(int)
-			vjInt, ok2 := vj.(int)
-			if !ok1 || !ok2 {
-				return false
-			}
-			if dir == "ASC" {
-				return viInt < vjInt
-			} else {
-				return viInt > vjInt
-			}
+// compare orders two rows by the key. A missing value is a null, and nulls
+// sort after every value: last in ascending order and first in descending
+// order, unless the key places them FIRST or LAST.
+func (k sortKey) compare(a, b Row) int {
+	vi, vj := a[k.column.Name], b[k.column.Name]
+	if vi == nil || vj == nil {
+		var order int
+		switch {
+		case vi == nil && vj == nil:
+			return 0
+		case vi == nil:
+			order = 1
+		default:
+			order = -1
+		}
+		if k.nulls == "FIRST" || (k.nulls == "" && k.dir == "DESC") {
+			return -order
+		}
+		return order
+	}
+	order := compareTyped(k.column.Type, vi, vj)
+	if k.dir == "DESC" {
+		return -order
+	}
+	return order
+}
 
-		case COLUMN_TYPE_DOUBLE, COLUMN_TYPE_FLOAT:
-			viFloat, ok1 := vi.(float64)
-			vjFloat, ok2 := vj.(float64)
-			if !ok1 || !ok2 {
-				return false
-			}
-			if dir == "ASC" {
-				return viFloat < vjFloat
-			} else {
-				return viFloat > vjFloat
-			}
+// compareTyped orders two values of a column by its type: numbers by value,
+// decimals exactly, false before true, dates and timestamps chronologically
+// and enums ignoring case. Values that do not hold the column type compare as equal.
+func compareTyped(colType ColumnType, vi, vj any) int {
+	switch colType {
+	case COLUMN_TYPE_INT:
+		viInt, ok1 := vi.(int64)
+		vjInt, ok2 := vj.(int64)
+		if !ok1 || !ok2 {
+			return 0
+		}
+		return cmp.Compare(viInt, vjInt)
 
-		case COLUMN_TYPE_VARCHAR:
-			viStr, ok1 := vi.(string)
-			vjStr, ok2 := vj.(string)
-			if !ok1 || !ok2 {
-				return false
-			}
-			if dir == "ASC" {
-				return viStr < vjStr
-			} else {
-				return viStr > vjStr
-			}
+	case COLUMN_TYPE_DOUBLE, COLUMN_TYPE_FLOAT:
+		viFloat, ok1 := toFloat64(vi)
+		vjFloat, ok2 := toFloat64(vj)
+		if !ok1 || !ok2 {
+			return 0
+		}
+		return cmp.Compare(viFloat, vjFloat)
 
-		case COLUMN_TYPE_BOOL:
-			viBool, ok1 := vi.(bool)
-			vjBool, ok2 := vj.(bool)
-			if !ok1 || !ok2 {
-				return false
-			}
-			// false is considered "less than" true
-			if dir == "ASC" {
-				return !viBool && vjBool
-			} else {
-				return viBool && !vjBool
-			}
-		case COLUMN_TYPE_DATE:
-			viStr, ok1 := vi.(string)
-			vjStr, ok2 := vj.(string)
-			if !ok1 || !ok2 {
-				return false
-			}
-			viTime, err1 := time.Parse("2006-01-02", viStr)
-			vjTime, err2 := time.Parse("2006-01-02", vjStr)
-			if err1 != nil || err2 != nil {
-				return false // handle invalid dates
-			}
+	case COLUMN_TYPE_DECIMAL:
+		viDec, ok1 := vi.(Decimal)
+		vjDec, ok2 := vj.(Decimal)
+		if !ok1 || !ok2 {
+			return 0
+		}
+		return viDec.Cmp(vjDec)
 
-			if dir == "ASC" {
-				return viTime.Before(vjTime)
-			} else {
-				return viTime.After(vjTime)
-			}
+	case COLUMN_TYPE_VARCHAR:
+		viStr, ok1 := vi.(string)
+		vjStr, ok2 := vj.(string)
+		if !ok1 || !ok2 {
+			return 0
+		}
+		return strings.Compare(viStr, vjStr)
 
-		case COLUMN_TYPE_ENUM:
-			viStr, ok1 := vi.(string)
-			vjStr, ok2 := vj.(string)
-			if !ok1 || !ok2 {
-				return false
-			}
-			if dir == "ASC" {
-				return strings.ToLower(viStr) < strings.ToLower(vjStr)
-			} else {
-				return strings.ToLower(viStr) > strings.ToLower(vjStr)
-			}
+	case COLUMN_TYPE_BOOL:
+		viBool, ok1 := vi.(bool)
+		vjBool, ok2 := vj.(bool)
+		if !ok1 || !ok2 || viBool == vjBool {
+			return 0
+		}
+		// false is considered "less than" true
+		if viBool {
+			return 1
+		}
+		return -1
 
-		default:
-			return false
+	case COLUMN_TYPE_DATE, COLUMN_TYPE_TIMESTAMP:
+		viTime, ok1 := vi.(time.Time)
+		vjTime, ok2 := vj.(time.Time)
+		if !ok1 || !ok2 {
+			return 0
+		}
+		return viTime.Compare(vjTime)
+
+	case COLUMN_TYPE_ENUM:
+		viStr, ok1 := vi.(string)
+		vjStr, ok2 := vj.(string)
+		if !ok1 || !ok2 {
+			return 0
 		}
-	})
-	return rows
+		return strings.Compare(strings.ToLower(viStr), strings.ToLower(vjStr))
+
+	default:
+		return 0
+	}
 }
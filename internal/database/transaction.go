@@ -0,0 +1,85 @@
+package database
+
+import (
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+)
+
+var transactionRegex = regexp.MustCompile(`(?i)^(BEGIN|COMMIT|ROLLBACK)(?:\s+TRANSACTION)?$`)
+
+// Begin starts a transaction. Until Commit, changes stay in memory and the
+// database file keeps the state from before Begin; Rollback restores it.
+// Statements that fail inside a transaction do not end it.
+func (db *Database) Begin() (string, error) {
+	if err := db.lock(); err != nil {
+		return "", err
+	}
+	defer db.mu.Unlock()
+	if db.snapshot != nil {
+		return "", fmt.Errorf("a transaction is already in progress")
+	}
+	db.snapshot = make(map[string]*Table, len(db.Tables))
+	for name, table := range db.Tables {
+		db.snapshot[name] = table.clone()
+	}
+	return "Transaction started", nil
+}
+
+// Commit ends the transaction and saves its changes
+func (db *Database) Commit() (string, error) {
+	if err := db.lock(); err != nil {
+		return "", err
+	}
+	defer db.mu.Unlock()
+	if db.snapshot == nil {
+		return "", fmt.Errorf("no transaction in progress")
+	}
+	db.snapshot = nil
+	if err := db.save(); err != nil {
+		return "", err
+	}
+	return "Transaction committed", nil
+}
+
+// Rollback ends the transaction and discards its changes
+func (db *Database) Rollback() (string, error) {
+	if err := db.lock(); err != nil {
+		return "", err
+	}
+	defer db.mu.Unlock()
+	if db.snapshot == nil {
+		return "", fmt.Errorf("no transaction in progress")
+	}
+	db.Tables = db.snapshot
+	db.snapshot = nil
+	return "Transaction rolled back", nil
+}
+
+// InTransaction reports whether a transaction is in progress
+func (db *Database) InTransaction() bool {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	return db.snapshot != nil
+}
+
+// clone returns a copy of the table that shares no rows or columns with it
+func (t *Table) clone() *Table {
+	c := *t
+	c.Columns = slices.Clone(t.Columns)
+	for i := range c.Columns {
+		c.Columns[i].Constraints = slices.Clone(c.Columns[i].Constraints)
+	}
+	c.Rows = make([]Row, len(t.Rows))
+	for i, row := range t.Rows {
+		c.Rows[i] = maps.Clone(row)
+	}
+	c.ForeignKeys = maps.Clone(t.ForeignKeys)
+	c.Indexes = make([]*Index, len(t.Indexes))
+	for i, index := range t.Indexes {
+		c.Indexes[i] = &Index{Name: index.Name, Column: index.Column}
+	}
+	c.reindex()
+	return &c
+}
@@ -8,7 +8,9 @@ func isValidColumnType(t ColumnType) bool {
 		COLUMN_TYPE_FLOAT,
 		COLUMN_TYPE_BOOL,
 		COLUMN_TYPE_DATE,
-		COLUMN_TYPE_ENUM:
+		COLUMN_TYPE_ENUM,
+		COLUMN_TYPE_DECIMAL,
+		COLUMN_TYPE_TIMESTAMP:
 		return true
 	default:
 		return false
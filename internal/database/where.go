@@ -0,0 +1,235 @@
+package database
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// whereCache holds parsed WHERE clauses, keyed by their source, so a clause
+// is split into its conditions once rather than for every row
+var whereCache = newParseCache[string, *whereExpr]()
+
+// whereExpr is a WHERE clause split at its AND and OR connectives and its
+// leading NOTs. NOT binds tightest and AND tighter than OR, so
+// "NOT a OR b AND c" is "(NOT a) OR (b AND c)", and parentheses group as
+// usual.
+type whereExpr struct {
+	op        string // "AND", "OR" or "NOT", empty for a single condition
+	operands  []*whereExpr
+	condition string
+}
+
+// parseWhere splits a WHERE clause into a tree of conditions
+func parseWhere(clause string) (*whereExpr, error) {
+	if expr, ok := whereCache.Load(clause); ok {
+		return expr, nil
+	}
+	expr, err := splitWhere(strings.TrimSpace(clause))
+	if err != nil {
+		return nil, err
+	}
+	whereCache.Store(clause, expr)
+	return expr, nil
+}
+
+func splitWhere(clause string) (*whereExpr, error) {
+	tokens, err := tokenize(clause)
+	if err != nil {
+		return nil, err
+	}
+	for _, op := range []string{"OR", "AND"} {
+		parts, err := splitAt(clause, tokens, op)
+		if err != nil {
+			return nil, err
+		}
+		if len(parts) == 1 {
+			continue
+		}
+		expr := &whereExpr{op: op}
+		for _, part := range parts {
+			operand, err := splitWhere(part)
+			if err != nil {
+				return nil, err
+			}
+			expr.operands = append(expr.operands, operand)
+		}
+		return expr, nil
+	}
+	if tokens[0].is("NOT") {
+		negated := strings.TrimSpace(clause[tokens[0].pos+len(tokens[0].text):])
+		if negated == "" {
+			return nil, fmt.Errorf("missing condition after NOT in %q", clause)
+		}
+		operand, err := splitWhere(negated)
+		if err != nil {
+			return nil, err
+		}
+		return &whereExpr{op: "NOT", operands: []*whereExpr{operand}}, nil
+	}
+	if inner, ok := unwrapParens(clause, tokens); ok {
+		return splitWhere(inner)
+	}
+	return &whereExpr{condition: clause}, nil
+}
+
+// splitAt splits a clause at the connective op outside of parentheses. The
+// AND of a BETWEEN belongs to it and does not split.
+func splitAt(clause string, tokens []token, op string) ([]string, error) {
+	var parts []string
+	start, depth := 0, 0
+	between := false
+	for _, t := range tokens {
+		switch {
+		case t.is("("):
+			depth++
+		case t.is(")"):
+			depth--
+		case depth > 0:
+		case t.is("BETWEEN"):
+			between = true
+		case t.is("AND") && between:
+			between = false
+		case t.is(op), t.kind == tokenEOF && len(parts) > 0:
+			part := strings.TrimSpace(clause[start:t.pos])
+			if part == "" && t.kind == tokenEOF {
+				return nil, fmt.Errorf("missing condition after %s in %q", op, clause)
+			}
+			if part == "" {
+				return nil, fmt.Errorf("missing condition before %s in %q", op, clause)
+			}
+			parts = append(parts, part)
+			start = t.pos + len(t.text)
+		}
+	}
+	if parts == nil {
+		return []string{clause}, nil
+	}
+	return parts, nil
+}
+
+// unwrapParens returns the clause inside the parentheses that enclose all of it
+func unwrapParens(clause string, tokens []token) (string, bool) {
+	if len(tokens) < 3 || !tokens[0].is("(") || !tokens[len(tokens)-2].is(")") {
+		return "", false
+	}
+	depth := 0
+	for _, t := range tokens[:len(tokens)-2] {
+		if t.is("(") {
+			depth++
+		} else if t.is(")") {
+			if depth--; depth == 0 {
+				// The first group closes before the end
+				return "", false
+			}
+		}
+	}
+	return clause[1:tokens[len(tokens)-2].pos], true
+}
+
+// conditions returns the single conditions of the clause, in order
+func (e *whereExpr) conditions() []string {
+	if e.op == "" {
+		return []string{e.condition}
+	}
+	var conditions []string
+	for _, operand := range e.operands {
+		conditions = append(conditions, operand.conditions()...)
+	}
+	return conditions
+}
+
+// columnTypes maps the names a WHERE clause may use for the columns of the
+// tables of a query to their types. Every column is there as table.column,
+// and by its own name unless two tables share it.
+type columnTypes map[string]ColumnType
+
+// typesOf returns the column types of the tables, under the names they have
+// in the query
+func typesOf(tables ...*Table) columnTypes {
+	types := make(columnTypes)
+	shared := make(map[string]bool)
+	for _, table := range tables {
+		for _, column := range table.Columns {
+			types[table.Name+"."+column.Name] = column.Type
+			if _, exists := types[column.Name]; exists {
+				shared[column.Name] = true
+			}
+			types[column.Name] = column.Type
+		}
+	}
+	for name := range shared {
+		delete(types, name)
+	}
+	return types
+}
+
+// truth is the value of a WHERE condition. A condition on a null is neither
+// true nor false but unknown, which NOT leaves unknown, so a row with a null
+// matches neither "age > 30" nor "NOT age > 30".
+type truth int
+
+const (
+	truthFalse truth = iota
+	truthTrue
+	truthUnknown
+)
+
+func truthOf(b bool) truth {
+	if b {
+		return truthTrue
+	}
+	return truthFalse
+}
+
+// evaluateWhere reports whether a row satisfies a WHERE clause, the
+// conditions it combines are evaluated by evaluateCondition. types holds the
+// column types of the row, values compared with a column of a known type are
+// read as that type; it may be nil when they are not known. now is the time
+// the statement started, the value of NOW() for all of its rows.
+func (db *Database) evaluateWhere(row Row, whereClause string, types columnTypes, now time.Time) (bool, error) {
+	if strings.TrimSpace(whereClause) == "" {
+		return true, nil
+	}
+	expr, err := parseWhere(whereClause)
+	if err != nil {
+		return false, err
+	}
+	result, err := db.evaluateExpr(row, expr, types, now)
+	return result == truthTrue, err
+}
+
+// evaluateExpr evaluates a clause in three-valued logic: AND is false when
+// any operand is false, OR true when any is true, and otherwise either is
+// unknown when an operand is
+func (db *Database) evaluateExpr(row Row, expr *whereExpr, types columnTypes, now time.Time) (truth, error) {
+	switch expr.op {
+	case "AND", "OR":
+		// decisive is the value that decides the connective on its own
+		decisive, result := truthFalse, truthTrue
+		if expr.op == "OR" {
+			decisive, result = truthTrue, truthFalse
+		}
+		for _, operand := range expr.operands {
+			value, err := db.evaluateExpr(row, operand, types, now)
+			if err != nil || value == decisive {
+				return value, err
+			}
+			if value == truthUnknown {
+				result = truthUnknown
+			}
+		}
+		return result, nil
+	case "NOT":
+		value, err := db.evaluateExpr(row, expr.operands[0], types, now)
+		switch value {
+		case truthTrue:
+			return truthFalse, err
+		case truthFalse:
+			return truthTrue, err
+		}
+		return value, err
+	default:
+		return db.evaluateCondition(row, expr.condition, types, now)
+	}
+}
@@ -0,0 +1,213 @@
+package repl
+
+import (
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/AYGA2K/db/internal/database"
+)
+
+var statementKeywords = []string{"ALTER", "BEGIN", "COMMIT", "CREATE", "DEFAULT", "DELETE", "DROP", "DUMP", "EXPLAIN", "EXPORT", "IMPORT", "INSERT", "ROLLBACK", "SELECT", "TRUNCATE", "UPDATE"}
+
+var sqlKeywords = []string{
+	"ADD", "ALTER", "AND", "AS", "ASC", "BY", "CASCADE", "CASE", "COLUMN", "CREATE", "CROSS", "CSV", "DEFAULT", "DELETE", "DESC", "DISTINCT", "DROP", "ELSE", "END",
+	"FIRST", "FORMAT", "FROM", "FULL", "GROUP", "INDEX", "INNER", "INSERT", "INTO", "JOIN", "JSON", "LAST", "LEFT", "LIKE", "LIMIT", "NULLS", "OFFSET", "ON", "OR", "ORDER", "OUTER", "RENAME", "RESTRICT",
+	"SELECT", "SET", "TABLE", "THEN", "TO", "TRUNCATE", "UPDATE", "VALUES", "WHEN", "WHERE",
+}
+
+// Completer suggests SQL keywords, table names and column names for readline
+type Completer struct {
+	db      *database.Database
+	mu      sync.RWMutex
+	columns map[string][]string // table name -> column names
+}
+
+// NewCompleter creates a completer backed by the tables of db
+func NewCompleter(db *database.Database) *Completer {
+	c := &Completer{db: db}
+	c.Refresh()
+	return c
+}
+
+// Refresh reloads the cached table and column names, it should be called after DDL runs
+func (c *Completer) Refresh() {
+	columns := make(map[string][]string)
+	tables, err := c.db.AllTables()
+	if err == nil {
+		for name, table := range tables {
+			for _, col := range table.GetColumns() {
+				columns[name] = append(columns[name], col.Name)
+			}
+		}
+	}
+	c.mu.Lock()
+	c.columns = columns
+	c.mu.Unlock()
+}
+
+// Do implements readline.AutoCompleter
+func (c *Completer) Do(line []rune, pos int) ([][]rune, int) {
+	word := currentWord(line[:pos])
+	var suffixes [][]rune
+	for _, candidate := range c.Complete(string(line), pos) {
+		suffixes = append(suffixes, []rune(candidate)[len([]rune(word)):])
+	}
+	return suffixes, len([]rune(word))
+}
+
+// Complete returns the words that could complete the word under the cursor at pos
+func (c *Completer) Complete(line string, pos int) []string {
+	runes := []rune(line)
+	if pos > len(runes) {
+		pos = len(runes)
+	}
+	before := runes[:pos]
+	if insideString(before) {
+		return nil
+	}
+	word := string(currentWord(before))
+	words := strings.Fields(strings.ReplaceAll(string(before[:len(before)-len([]rune(word))]), ",", " , "))
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	var options []string
+	switch context := clauseContext(words); context {
+	case "":
+		options = matchKeywords(statementKeywords, word)
+	case "SELECT":
+		options = matchNames(c.allColumns(), word)
+		if strings.HasPrefix("*", word) {
+			options = append(options, "*")
+		}
+		if len(words) > 0 && words[len(words)-1] != "," && !strings.EqualFold(words[len(words)-1], "SELECT") {
+			options = matchKeywords([]string{"FROM"}, word)
+		}
+	case "FROM", "JOIN", "INTO", "UPDATE", "TABLE":
+		options = matchNames(c.tableNames(), word)
+	case "WHERE", "AND", "OR", "ON", "SET", "BY":
+		options = matchNames(c.mentionedColumns(line), word)
+	default:
+		options = matchKeywords(sqlKeywords, word)
+	}
+	sort.Strings(options)
+	return options
+}
+
+// clauseContext returns the keyword that decides what can be typed next, or "" at the start of a statement
+func clauseContext(words []string) string {
+	if len(words) == 0 {
+		return ""
+	}
+	last := strings.ToUpper(words[len(words)-1])
+	switch last {
+	case "FROM", "JOIN", "INTO", "UPDATE", "TABLE", "WHERE", "AND", "OR", "ON", "SET", "BY":
+		return last
+	}
+	for i := len(words) - 1; i >= 0; i-- {
+		if strings.EqualFold(words[i], "SELECT") {
+			return "SELECT"
+		}
+		if slices.Contains(sqlKeywords, strings.ToUpper(words[i])) {
+			break
+		}
+	}
+	return "KEYWORD"
+}
+
+func (c *Completer) tableNames() []string {
+	var names []string
+	for name := range c.columns {
+		names = append(names, name)
+	}
+	return names
+}
+
+func (c *Completer) allColumns() []string {
+	var names []string
+	for _, cols := range c.columns {
+		for _, col := range cols {
+			if !slices.Contains(names, col) {
+				names = append(names, col)
+			}
+		}
+	}
+	return names
+}
+
+// mentionedColumns returns the columns of the tables named anywhere in the statement
+func (c *Completer) mentionedColumns(line string) []string {
+	var names []string
+	words := strings.Fields(line)
+	for i := 0; i+1 < len(words); i++ {
+		switch strings.ToUpper(words[i]) {
+		case "FROM", "JOIN", "INTO", "UPDATE":
+			for _, col := range c.columns[strings.Trim(words[i+1], "(,;")] {
+				if !slices.Contains(names, col) {
+					names = append(names, col)
+				}
+			}
+		}
+	}
+	return names
+}
+
+// matchKeywords returns keywords starting with word, spelled in the case the user is typing in
+func matchKeywords(keywords []string, word string) []string {
+	var matches []string
+	lower := word != "" && word == strings.ToLower(word)
+	for _, keyword := range keywords {
+		if hasPrefixFold(keyword, word) {
+			if lower {
+				keyword = strings.ToLower(keyword)
+			}
+			matches = append(matches, word+keyword[len(word):])
+		}
+	}
+	return matches
+}
+
+// matchNames returns names starting with word, ignoring case
+func matchNames(names []string, word string) []string {
+	var matches []string
+	for _, name := range names {
+		if hasPrefixFold(name, word) {
+			matches = append(matches, word+name[len(word):])
+		}
+	}
+	return matches
+}
+
+func hasPrefixFold(s, prefix string) bool {
+	return len(s) >= len(prefix) && strings.EqualFold(s[:len(prefix)], prefix)
+}
+
+// currentWord returns the partial identifier that ends at the cursor
+func currentWord(before []rune) []rune {
+	start := len(before)
+	for start > 0 {
+		r := before[start-1]
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_' && r != '.' {
+			break
+		}
+		start--
+	}
+	return before[start:]
+}
+
+// insideString reports whether the cursor sits inside an unterminated quoted string
+func insideString(before []rune) bool {
+	var quote rune
+	for _, r := range before {
+		switch {
+		case quote == 0 && (r == '\'' || r == '"'):
+			quote = r
+		case quote != 0 && r == quote:
+			quote = 0
+		}
+	}
+	return quote != 0
+}
@@ -0,0 +1,158 @@
+package repl
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/AYGA2K/db/internal/database"
+)
+
+// Config holds the startup options of the REPL
+type Config struct {
+	DB             string
+	Dir            string
+	File           string
+	History        string
+	ReadOnly       bool
+	Force          bool
+	Mode           string
+	Init           string
+	InitStatements []string
+}
+
+const usageHeader = `Usage: godb [options]
+
+Starts an interactive SQL prompt. Defaults for --db, --dir and --history can
+also be set with the GODB_DB, GODB_DIR and GODB_HISTORY environment variables.
+
+Options:
+`
+
+// ParseConfig reads the command line arguments, falling back to environment
+// variables looked up with getenv, and validates the resulting configuration.
+// Usage is written to output when --help is given or the flags are invalid.
+func ParseConfig(args []string, getenv func(string) string, output io.Writer) (*Config, error) {
+	cfg := &Config{}
+	fs := flag.NewFlagSet("godb", flag.ContinueOnError)
+	fs.SetOutput(output)
+	fs.Usage = func() {
+		fmt.Fprint(output, usageHeader)
+		fs.PrintDefaults()
+	}
+	fs.StringVar(&cfg.DB, "db", envOr(getenv, "GODB_DB", "testdb"), "name of the database")
+	fs.StringVar(&cfg.Dir, "dir", envOr(getenv, "GODB_DIR", "."), "directory holding the database file")
+	fs.StringVar(&cfg.File, "file", "", "full path of the database file, instead of --db and --dir")
+	fs.StringVar(&cfg.History, "history", envOr(getenv, "GODB_HISTORY", "/tmp/sql_history.tmp"), "file that stores the prompt history")
+	fs.BoolVar(&cfg.ReadOnly, "read-only", false, "reject statements that modify the database")
+	fs.BoolVar(&cfg.Force, "force", false, "run destructive statements without confirmation when input is not a terminal")
+	fs.StringVar(&cfg.Mode, "mode", "json", "output mode: "+strings.Join(outputModes, ", "))
+	fs.StringVar(&cfg.Init, "init", "", "SQL script to run before the prompt appears")
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	if fs.NArg() > 0 {
+		return nil, fmt.Errorf("unexpected argument %q", fs.Arg(0))
+	}
+
+	set := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	if err := cfg.validate(set); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+func (c *Config) validate(set map[string]bool) error {
+	if c.File != "" {
+		if set["db"] || set["dir"] {
+			return fmt.Errorf("--file cannot be combined with --db or --dir")
+		}
+	} else if c.DB == "" {
+		return fmt.Errorf("--db must not be empty")
+	}
+	if !isOutputMode(c.Mode) {
+		return fmt.Errorf("unknown --mode %q, expected one of: %s", c.Mode, strings.Join(outputModes, ", "))
+	}
+	if c.Init == "" {
+		return nil
+	}
+
+	script, err := os.ReadFile(c.Init)
+	if err != nil {
+		return fmt.Errorf("cannot read --init script: %v", err)
+	}
+	c.InitStatements = splitScript(string(script))
+	if c.ReadOnly {
+		for _, stmt := range c.InitStatements {
+			if !isReadOnly(stmt) {
+				return fmt.Errorf("--read-only cannot be combined with --init script %s, it modifies the database: %s", c.Init, stmt)
+			}
+		}
+	}
+	return nil
+}
+
+// StorageName returns the name to open the database with, which is its file
+// path without the extension
+func (c *Config) StorageName() string {
+	if c.File != "" {
+		return strings.TrimSuffix(c.File, filepath.Ext(c.File))
+	}
+	return filepath.Join(c.Dir, c.DB)
+}
+
+// Open opens the database of the configuration, stored in the file given
+// with --file whatever its extension, or else named by --db in --dir. With
+// --read-only it is opened with database.WithReadOnly, so its file is never
+// written.
+func (c *Config) Open() (*database.Database, error) {
+	var opts []database.Option
+	if c.File != "" {
+		opts = append(opts, database.WithPath(c.File))
+	}
+	if c.ReadOnly {
+		opts = append(opts, database.WithReadOnly())
+	}
+	return database.NewDatabase(c.StorageName(), opts...)
+}
+
+func envOr(getenv func(string) string, key, def string) string {
+	if val := getenv(key); val != "" {
+		return val
+	}
+	return def
+}
+
+// splitScript returns the statements of a script, one per line with an optional
+// trailing semicolon. Blank lines and lines starting with -- are skipped.
+func splitScript(script string) []string {
+	var statements []string
+	for line := range strings.SplitSeq(script, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "--") {
+			continue
+		}
+		line = strings.TrimSpace(strings.TrimSuffix(line, ";"))
+		if line != "" {
+			statements = append(statements, line)
+		}
+	}
+	return statements
+}
+
+// readOnlyStatements are the statements that only read from the database,
+// a SELECT with FORMAT included
+var readOnlyStatements = []string{"SELECT", "EXPLAIN", "DUMP"}
+
+// isReadOnly reports whether a statement only reads from the database
+func isReadOnly(sql string) bool {
+	fields := strings.Fields(sql)
+	return len(fields) > 0 && slices.ContainsFunc(readOnlyStatements, func(keyword string) bool {
+		return strings.EqualFold(fields[0], keyword)
+	})
+}
@@ -0,0 +1,230 @@
+package repl
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	importProgressEvery = 10000 // rows between progress lines
+	importInferRows     = 100   // rows sampled when inferring a schema
+	importShownErrors   = 5     // rejection reasons printed in the summary
+)
+
+var identifierRegex = regexp.MustCompile(`^\w+$`)
+
+type importOptions struct {
+	path      string
+	table     string
+	header    bool
+	delimiter rune
+	create    bool
+	partial   bool
+}
+
+// importCSV handles `.import FILE TABLE [--header] [--delimiter C] [--create] [--partial]`
+func (s *Shell) importCSV(args []string) error {
+	opts, err := parseImportArgs(args)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Open(opts.path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.Comma = opts.delimiter
+	reader.FieldsPerRecord = -1
+
+	var header []string
+	if opts.header {
+		header, err = reader.Read()
+		if err != nil {
+			return fmt.Errorf("cannot read header: %v", err)
+		}
+		for i := range header {
+			header[i] = strings.TrimSpace(header[i])
+		}
+	}
+
+	var records [][]string
+	var lines []int
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		line, _ := reader.FieldPos(0)
+		records = append(records, record)
+		lines = append(lines, line)
+		if len(records)%importProgressEvery == 0 {
+			fmt.Fprintf(s.out, "... %d rows loaded\n", len(records))
+		}
+	}
+
+	created := false
+	tables, _ := s.db.AllTables()
+	table, exists := tables[opts.table]
+	if !exists {
+		if !opts.create {
+			return fmt.Errorf("table %s does not exist, use --create to create it", opts.table)
+		}
+		if err := s.createImportTable(opts.table, header, records); err != nil {
+			return err
+		}
+		created = true
+		tables, _ = s.db.AllTables()
+		table = tables[opts.table]
+	}
+
+	columns := header
+	if columns == nil {
+		for _, col := range table.GetColumns() {
+			columns = append(columns, col.Name)
+		}
+	}
+
+	inserted, rejected, err := s.db.InsertRows(opts.table, columns, records, opts.partial)
+	if err == nil && created && inserted == 0 && len(rejected) > 0 {
+		_, err = s.db.DropTable(opts.table)
+	}
+	if err != nil {
+		return err
+	}
+	if created {
+		s.completer.Refresh()
+	}
+
+	fmt.Fprintf(s.out, "%d rows inserted, %d rejected\n", inserted, len(rejected))
+	for i, rowErr := range rejected {
+		if i == importShownErrors {
+			fmt.Fprintf(s.out, "  ... and %d more\n", len(rejected)-importShownErrors)
+			break
+		}
+		fmt.Fprintf(s.out, "  line %d: %v\n", lines[rowErr.Index], rowErr.Err)
+	}
+	if len(rejected) > 0 && !opts.partial {
+		fmt.Fprintln(s.out, "Nothing was imported, use --partial to keep the valid rows")
+	}
+	return nil
+}
+
+func parseImportArgs(args []string) (importOptions, error) {
+	opts := importOptions{delimiter: ','}
+	var positional []string
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--header":
+			opts.header = true
+		case "--create":
+			opts.create = true
+		case "--partial":
+			opts.partial = true
+		case "--delimiter":
+			if i+1 == len(args) {
+				return opts, fmt.Errorf("--delimiter needs a value")
+			}
+			i++
+			delimiter := args[i]
+			if delimiter == `\t` || delimiter == "tab" {
+				delimiter = "\t"
+			}
+			r, size := utf8.DecodeRuneInString(delimiter)
+			if size != len(delimiter) || r == '"' || r == '\n' {
+				return opts, fmt.Errorf("invalid delimiter %q", args[i])
+			}
+			opts.delimiter = r
+		default:
+			if strings.HasPrefix(args[i], "--") {
+				return opts, fmt.Errorf("unknown option %s", args[i])
+			}
+			positional = append(positional, args[i])
+		}
+	}
+	if len(positional) != 2 {
+		return opts, fmt.Errorf("usage: .import FILE TABLE [--header] [--delimiter C] [--create] [--partial]")
+	}
+	opts.path, opts.table = positional[0], positional[1]
+	return opts, nil
+}
+
+// createImportTable creates a table whose column types are inferred from the first rows of the file
+func (s *Shell) createImportTable(name string, header []string, records [][]string) error {
+	if !identifierRegex.MatchString(name) {
+		return fmt.Errorf("invalid table name %s", name)
+	}
+	columns := header
+	if columns == nil {
+		if len(records) == 0 {
+			return fmt.Errorf("cannot infer a schema from an empty file")
+		}
+		for i := range records[0] {
+			columns = append(columns, fmt.Sprintf("col%d", i+1))
+		}
+	}
+
+	sample := records[:min(len(records), importInferRows)]
+	defs := make([]string, len(columns))
+	for i, col := range columns {
+		if !identifierRegex.MatchString(col) {
+			return fmt.Errorf("invalid column name %q in header", col)
+		}
+		var values []string
+		for _, record := range sample {
+			if i < len(record) {
+				values = append(values, strings.TrimSpace(record[i]))
+			}
+		}
+		defs[i] = col + " " + inferColumnType(values)
+	}
+
+	_, err := s.db.Execute(fmt.Sprintf("CREATE TABLE %s (%s)", name, strings.Join(defs, ", ")))
+	return err
+}
+
+// inferColumnType returns the narrowest type that accepts every non-empty value
+func inferColumnType(values []string) string {
+	candidates := []struct {
+		name  string
+		valid func(string) bool
+	}{
+		{"INT", func(v string) bool { _, err := strconv.ParseInt(v, 10, 64); return err == nil }},
+		{"DOUBLE", func(v string) bool { _, err := strconv.ParseFloat(v, 64); return err == nil }},
+		{"BOOL", func(v string) bool { return v == "true" || v == "false" }},
+		{"DATE", func(v string) bool { _, err := time.Parse("2006-01-02", v); return err == nil }},
+		{"TIMESTAMP", func(v string) bool { _, err := time.Parse("2006-01-02 15:04:05", v); return err == nil }},
+	}
+
+	seen := false
+	for _, candidate := range candidates {
+		valid := true
+		for _, val := range values {
+			if val == "" {
+				continue
+			}
+			seen = true
+			if !candidate.valid(val) {
+				valid = false
+				break
+			}
+		}
+		if valid && seen {
+			return candidate.name
+		}
+	}
+	return "VARCHAR"
+}
@@ -0,0 +1,152 @@
+package repl
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/chzyer/readline"
+)
+
+// resultSet is a query result decoded from the JSON output of a SELECT
+type resultSet struct {
+	columns []string
+	rows    [][]string
+}
+
+// parseResultSet decodes a JSON array of objects, keeping the key order of
+// the output. It reports false when the output is not a result set, such as
+// the message of an INSERT.
+func parseResultSet(output string) (*resultSet, bool) {
+	dec := json.NewDecoder(strings.NewReader(output))
+	dec.UseNumber()
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('[') {
+		return nil, false
+	}
+
+	rs := &resultSet{}
+	index := make(map[string]int)
+	var decoded []map[int]string
+	for dec.More() {
+		if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+			return nil, false
+		}
+		values := make(map[int]string)
+		for dec.More() {
+			key, err := dec.Token()
+			if err != nil {
+				return nil, false
+			}
+			var val any
+			if err := dec.Decode(&val); err != nil {
+				return nil, false
+			}
+			col := key.(string)
+			i, seen := index[col]
+			if !seen {
+				i = len(rs.columns)
+				index[col] = i
+				rs.columns = append(rs.columns, col)
+			}
+			values[i] = formatJSONValue(val)
+		}
+		if _, err := dec.Token(); err != nil {
+			return nil, false
+		}
+		decoded = append(decoded, values)
+	}
+
+	// A row may lack columns that only appear in other rows
+	for _, values := range decoded {
+		row := make([]string, len(rs.columns))
+		for i := range row {
+			val, ok := values[i]
+			if !ok {
+				val = "NULL"
+			}
+			row[i] = val
+		}
+		rs.rows = append(rs.rows, row)
+	}
+	return rs, true
+}
+
+func formatJSONValue(val any) string {
+	switch v := val.(type) {
+	case nil:
+		return "NULL"
+	case string:
+		return v
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// renderVertical prints each row as a block of `column: value` lines
+func renderVertical(rs *resultSet) string {
+	if len(rs.rows) == 0 {
+		return "Empty set"
+	}
+	width := 0
+	for _, col := range rs.columns {
+		width = max(width, utf8.RuneCountInString(col))
+	}
+
+	var b strings.Builder
+	for i, row := range rs.rows {
+		fmt.Fprintf(&b, "%s %d. row %s\n", strings.Repeat("*", 27), i+1, strings.Repeat("*", 27))
+		for j, col := range rs.columns {
+			fmt.Fprintf(&b, "%*s: %s\n", width, col, row[j])
+		}
+	}
+	if len(rs.rows) == 1 {
+		b.WriteString("1 row in set")
+	} else {
+		fmt.Fprintf(&b, "%d rows in set", len(rs.rows))
+	}
+	return b.String()
+}
+
+// render formats the output of a statement for the given mode
+func render(output string, mode string) string {
+	if mode != "vertical" {
+		return output
+	}
+	rs, ok := parseResultSet(output)
+	if !ok {
+		return output
+	}
+	return renderVertical(rs)
+}
+
+// print writes text to the shell output, through a pager when it does not fit on the terminal
+func (s *Shell) print(text string) {
+	if s.pager {
+		if file, ok := s.out.(*os.File); ok && readline.IsTerminal(int(file.Fd())) {
+			_, height, err := readline.GetSize(int(file.Fd()))
+			if err == nil && strings.Count(text, "\n")+1 >= height && runPager(file, text) == nil {
+				return
+			}
+		}
+	}
+	fmt.Fprintln(s.out, text)
+}
+
+// runPager pipes text through $PAGER, or less when it is not set
+func runPager(out *os.File, text string) error {
+	pager := os.Getenv("PAGER")
+	if pager == "" {
+		if _, err := exec.LookPath("less"); err != nil {
+			return err
+		}
+		pager = "less -FRX"
+	}
+	cmd := exec.Command("sh", "-c", pager)
+	cmd.Stdin = strings.NewReader(text + "\n")
+	cmd.Stdout = out
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
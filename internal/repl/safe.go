@@ -0,0 +1,102 @@
+package repl
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/AYGA2K/db/internal/database"
+)
+
+// LineReader reads one line of input after showing prompt
+type LineReader func(prompt string) (string, error)
+
+// ReaderInput returns a LineReader that writes prompts to w and reads answers from r
+func ReaderInput(r io.Reader, w io.Writer) LineReader {
+	scanner := bufio.NewScanner(r)
+	return func(prompt string) (string, error) {
+		fmt.Fprint(w, prompt)
+		if !scanner.Scan() {
+			if err := scanner.Err(); err != nil {
+				return "", err
+			}
+			return "", io.EOF
+		}
+		return scanner.Text(), nil
+	}
+}
+
+// SetInput sets where confirmations are read from
+func (s *Shell) SetInput(input LineReader) {
+	s.input = input
+}
+
+// SetSafe turns the confirmation of destructive statements on or off
+func (s *Shell) SetSafe(safe bool) {
+	s.safe = safe
+}
+
+// SetInteractive tells the shell whether a person is typing the statements.
+// Outside interactive sessions destructive statements are refused unless
+// forced, since nobody can confirm them.
+func (s *Shell) SetInteractive(interactive bool) {
+	s.interactive = interactive
+}
+
+// SetForce allows destructive statements in non-interactive sessions
+func (s *Shell) SetForce(force bool) {
+	s.force = force
+}
+
+// allowStatement decides whether a statement may run, asking for
+// confirmation when it would drop a table or change every row
+func (s *Shell) allowStatement(sql string) (bool, error) {
+	if !s.safe && (s.interactive || s.force) {
+		return true, nil
+	}
+	impact, err := s.db.DryRun(sql)
+	if err != nil || impact == nil || !isDestructive(impact) {
+		// Errors are reported when the statement runs
+		return true, nil
+	}
+
+	if !s.interactive {
+		if s.force {
+			return true, nil
+		}
+		return false, fmt.Errorf("refusing to run in non-interactive mode, %s; pass --force to allow it", describeImpact(impact))
+	}
+	if s.input == nil {
+		return false, fmt.Errorf("cannot confirm statement, %s", describeImpact(impact))
+	}
+
+	fmt.Fprintf(s.out, "Warning: %s\n", describeImpact(impact))
+	answer, err := s.input("Type 'yes' to continue: ")
+	if err != nil {
+		return false, err
+	}
+	if strings.TrimSpace(strings.ToLower(answer)) != "yes" {
+		fmt.Fprintln(s.out, "Cancelled")
+		return false, nil
+	}
+	return true, nil
+}
+
+// isDestructive reports whether a statement drops a table or is not limited by a WHERE clause
+func isDestructive(impact *database.Impact) bool {
+	return impact.Statement == "DROP TABLE" || !impact.Filtered
+}
+
+func describeImpact(impact *database.Impact) string {
+	switch impact.Statement {
+	case "DROP TABLE":
+		return fmt.Sprintf("DROP TABLE would remove table %s and its %d rows", impact.Table, impact.Rows)
+	case "TRUNCATE TABLE":
+		return fmt.Sprintf("TRUNCATE TABLE would remove all %d rows of %s", impact.Rows, impact.Table)
+	case "DELETE":
+		return fmt.Sprintf("DELETE without WHERE would remove all %d rows of %s", impact.Rows, impact.Table)
+	default:
+		return fmt.Sprintf("%s without WHERE would change all %d rows of %s", impact.Statement, impact.Rows, impact.Table)
+	}
+}
@@ -0,0 +1,202 @@
+package repl
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/AYGA2K/db/internal/database"
+)
+
+var outputModes = []string{"json", "vertical"}
+
+// Shell runs SQL statements and dot-commands on behalf of the REPL
+type Shell struct {
+	db        *database.Database
+	out       io.Writer
+	completer *Completer
+	timing    bool
+	readOnly  bool
+	mode      string
+	pager     bool
+
+	safe        bool
+	interactive bool
+	force       bool
+	input       LineReader
+}
+
+// NewShell creates a shell that writes its output to out
+func NewShell(db *database.Database, out io.Writer) *Shell {
+	return &Shell{
+		db:          db,
+		out:         out,
+		completer:   NewCompleter(db),
+		mode:        "json",
+		pager:       true,
+		interactive: true,
+	}
+}
+
+// SetReadOnly makes the shell reject statements and dot-commands that modify
+// the database
+func (s *Shell) SetReadOnly(readOnly bool) {
+	s.readOnly = readOnly
+}
+
+// SetMode changes how results are printed
+func (s *Shell) SetMode(mode string) error {
+	if !isOutputMode(mode) {
+		return fmt.Errorf("unknown mode %q, expected one of: %s", mode, strings.Join(outputModes, ", "))
+	}
+	s.mode = mode
+	return nil
+}
+
+// Completer returns the tab completer that follows the shell's schema changes
+func (s *Shell) Completer() *Completer {
+	return s.completer
+}
+
+// Run handles a single line of input
+func (s *Shell) Run(line string) {
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return
+	}
+	if strings.HasPrefix(line, ".") {
+		if err := s.runCommand(line); err != nil {
+			fmt.Fprintln(s.out, "Error:", err)
+		}
+		return
+	}
+
+	mode := s.mode
+	if strings.HasSuffix(line, `\G`) {
+		line = strings.TrimSpace(strings.TrimSuffix(line, `\G`))
+		mode = "vertical"
+	}
+	if s.readOnly && !isReadOnly(line) {
+		fmt.Fprintln(s.out, "Error:", database.ErrReadOnly)
+		return
+	}
+	allowed, err := s.allowStatement(line)
+	if err != nil {
+		fmt.Fprintln(s.out, "Error:", err)
+		return
+	}
+	if !allowed {
+		return
+	}
+	result, err := s.db.Exec(line)
+	if err != nil {
+		s.printError(line, err)
+		return
+	}
+	if isDDL(line) {
+		s.completer.Refresh()
+	}
+	s.print(render(result.Output, mode))
+	if s.timing {
+		fmt.Fprintf(s.out, "Time: %s\n", formatDuration(result.ElapsedTime))
+	}
+}
+
+// printError reports a failed statement, pointing at the position of syntax errors
+func (s *Shell) printError(sql string, err error) {
+	fmt.Fprintln(s.out, "Error:", err)
+	var syntaxErr *database.ErrSyntax
+	if errors.As(err, &syntaxErr) && syntaxErr.Position <= len(sql) {
+		fmt.Fprintln(s.out, "  "+sql)
+		fmt.Fprintln(s.out, "  "+strings.Repeat(" ", utf8.RuneCountInString(sql[:syntaxErr.Position]))+"^")
+	}
+}
+
+// runCommand handles dot-commands such as .timing and .import
+func (s *Shell) runCommand(line string) error {
+	fields := strings.Fields(line)
+	switch strings.ToLower(fields[0]) {
+	case ".timing":
+		on, err := parseToggle(fields)
+		if err != nil {
+			return err
+		}
+		s.timing = on
+		return nil
+	case ".mode":
+		if len(fields) != 2 {
+			return fmt.Errorf("usage: .mode %s", strings.Join(outputModes, "|"))
+		}
+		return s.SetMode(strings.ToLower(fields[1]))
+	case ".pager":
+		on, err := parseToggle(fields)
+		if err != nil {
+			return err
+		}
+		s.pager = on
+		return nil
+	case ".safe":
+		on, err := parseToggle(fields)
+		if err != nil {
+			return err
+		}
+		s.safe = on
+		return nil
+	case ".import":
+		if s.readOnly {
+			return database.ErrReadOnly
+		}
+		return s.importCSV(fields[1:])
+	default:
+		return fmt.Errorf("unknown command %s", fields[0])
+	}
+}
+
+// parseToggle reads the on/off argument of a dot-command
+func parseToggle(fields []string) (bool, error) {
+	if len(fields) != 2 {
+		return false, fmt.Errorf("usage: %s on|off", fields[0])
+	}
+	switch strings.ToLower(fields[1]) {
+	case "on":
+		return true, nil
+	case "off":
+		return false, nil
+	default:
+		return false, fmt.Errorf("usage: %s on|off", fields[0])
+	}
+}
+
+// formatDuration picks a unit that keeps the number readable
+func formatDuration(d time.Duration) string {
+	switch {
+	case d < time.Millisecond:
+		return fmt.Sprintf("%.0fµs", float64(d)/float64(time.Microsecond))
+	case d < time.Second:
+		return fmt.Sprintf("%.2fms", float64(d)/float64(time.Millisecond))
+	default:
+		return fmt.Sprintf("%.3fs", d.Seconds())
+	}
+}
+
+func isOutputMode(mode string) bool {
+	return slices.Contains(outputModes, mode)
+}
+
+// isDDL reports whether a statement changes the schema
+func isDDL(sql string) bool {
+	fields := strings.Fields(sql)
+	if len(fields) == 0 {
+		return false
+	}
+	switch strings.ToUpper(fields[0]) {
+	case "CREATE", "DROP", "ALTER", "ROLLBACK":
+		return true
+	default:
+		return false
+	}
+}
@@ -1,24 +1,63 @@
 package main
 
 import (
+	"bufio"
+	"errors"
+	"flag"
 	"fmt"
 	"log"
+	"os"
 	"strings"
 
-	"github.com/AYGA2K/db/internal/database"
+	"github.com/AYGA2K/db/internal/repl"
 	"github.com/chzyer/readline"
 )
 
 func main() {
-	fmt.Println("Simple SQL Database in Go")
-	db, err := database.NewDatabase("testdb")
+	cfg, err := repl.ParseConfig(os.Args[1:], os.Getenv, os.Stderr)
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(2)
+	}
+
+	db, err := cfg.Open()
 	if err != nil {
 		log.Fatal(err)
 	}
+	defer func() {
+		if err := db.Close(); err != nil {
+			fmt.Fprintln(os.Stderr, "Error:", err)
+		}
+	}()
+
+	shell := repl.NewShell(db, os.Stdout)
+	shell.SetReadOnly(cfg.ReadOnly)
+	shell.SetForce(cfg.Force)
+	if err := shell.SetMode(cfg.Mode); err != nil {
+		log.Fatal(err)
+	}
 
+	// Init scripts run unattended, like piped input
+	shell.SetInteractive(false)
+	for _, stmt := range cfg.InitStatements {
+		shell.Run(stmt)
+	}
+
+	if !readline.IsTerminal(int(os.Stdin.Fd())) {
+		runScript(shell)
+		return
+	}
+	shell.SetInteractive(true)
+	shell.SetSafe(true)
+
+	fmt.Println("Simple SQL Database in Go")
 	rl, err := readline.NewEx(&readline.Config{
 		Prompt:          "sql> ",
-		HistoryFile:     "/tmp/sql_history.tmp", // Stores history between sessions
+		AutoComplete:    shell.Completer(),
+		HistoryFile:     cfg.History, // Stores history between sessions
 		InterruptPrompt: "^C",
 		EOFPrompt:       "exit",
 	})
@@ -27,6 +66,11 @@ func main() {
 	}
 	defer rl.Close()
 
+	shell.SetInput(func(prompt string) (string, error) {
+		rl.SetPrompt(prompt)
+		defer rl.SetPrompt("sql> ")
+		return rl.Readline()
+	})
 	for {
 		sql, err := rl.Readline()
 		if err != nil { // Handles Ctrl+C or Ctrl+D
@@ -34,18 +78,24 @@ func main() {
 		}
 
 		sql = strings.TrimSpace(sql)
-		if sql == "" {
-			continue
-		}
 		if sql == "exit" {
 			break
 		}
+		shell.Run(sql)
+	}
+}
 
-		result, err := db.Execute(sql)
-		if err != nil {
-			fmt.Println("Error:", err)
-		} else {
-			fmt.Println(result)
+// runScript runs statements piped on stdin, one per line
+func runScript(shell *repl.Shell) {
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		sql := strings.TrimSpace(scanner.Text())
+		if sql == "exit" {
+			break
+		}
+		if sql == "" || strings.HasPrefix(sql, "--") {
+			continue
 		}
+		shell.Run(strings.TrimSuffix(sql, ";"))
 	}
 }
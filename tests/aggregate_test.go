@@ -0,0 +1,281 @@
+package database_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/AYGA2K/db/internal/database"
+)
+
+// selectAggregate runs a query that returns a single row and returns that row
+func selectAggregate(t *testing.T, db *database.Database, query string) map[string]any {
+	t.Helper()
+	res, err := db.Execute(query)
+	if err != nil {
+		t.Fatalf("Query %q failed: %v", query, err)
+	}
+	var rows []map[string]any
+	if err := json.Unmarshal([]byte(res), &rows); err != nil {
+		t.Fatalf("Failed to unmarshal results: %v", err)
+	}
+	if len(rows) != 1 {
+		t.Fatalf("Expected 1 row from %q, got %d", query, len(rows))
+	}
+	return rows[0]
+}
+
+func TestAggregates(t *testing.T) {
+	defer cleanupTestDB("testdb")
+	db := newPeopleDB(t)
+
+	tests := []struct {
+		query    string
+		key      string
+		expected any
+	}{
+		{"SELECT COUNT(*) FROM people", "COUNT(*)", float64(4)},
+		{"SELECT count(*) FROM people WHERE age > 30", "COUNT(*)", float64(2)},
+		{"SELECT COUNT(*) FROM people WHERE age > 100", "COUNT(*)", float64(0)},
+		{"SELECT COUNT(name) FROM people", "COUNT(name)", float64(4)},
+		{"SELECT SUM(age) FROM people", "SUM(age)", float64(130)},
+		{"SELECT AVG(age) FROM people WHERE age > 20", "AVG(age)", 32.5},
+		{"SELECT MIN(age) FROM people", "MIN(age)", float64(25)},
+		{"SELECT MAX(height) FROM people", "MAX(height)", 1.90},
+		{"SELECT MIN(birthdate) FROM people", "MIN(birthdate)", "1984-07-22"},
+		{"SELECT MAX(name) FROM people", "MAX(name)", "David"},
+		{"SELECT SUM(age) FROM people WHERE age > 100", "SUM(age)", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			row := selectAggregate(t, db, tt.query)
+			if got, exists := row[tt.key]; !exists || got != tt.expected {
+				t.Errorf("Expected %s = %v, got %v", tt.key, tt.expected, row)
+			}
+		})
+	}
+}
+
+func TestAggregateMultipleColumns(t *testing.T) {
+	defer cleanupTestDB("testdb")
+	db := newPeopleDB(t)
+
+	row := selectAggregate(t, db, "SELECT COUNT(*), MIN(age), MAX(age) FROM people")
+	if row["COUNT(*)"] != float64(4) || row["MIN(age)"] != float64(25) || row["MAX(age)"] != float64(40) {
+		t.Errorf("Unexpected result %v", row)
+	}
+}
+
+func TestAggregateSkipsNulls(t *testing.T) {
+	defer cleanupTestDB("testdb")
+	db, _ := database.NewDatabase("testdb")
+	_, _ = db.Execute("CREATE TABLE scores (id INT, score INT)")
+	_, _ = db.Execute("INSERT INTO scores (id, score) VALUES (1, 10)")
+	_, _ = db.Execute("INSERT INTO scores (id) VALUES (2)")
+	_, _ = db.Execute("INSERT INTO scores (id, score) VALUES (3, 20)")
+
+	row := selectAggregate(t, db, "SELECT COUNT(*), COUNT(score), AVG(score) FROM scores")
+	if row["COUNT(*)"] != float64(3) || row["COUNT(score)"] != float64(2) || row["AVG(score)"] != float64(15) {
+		t.Errorf("Unexpected result %v", row)
+	}
+}
+
+func TestAggregateErrors(t *testing.T) {
+	defer cleanupTestDB("testdb")
+	db := newPeopleDB(t)
+
+	for _, query := range []string{
+		"SELECT SUM(name) FROM people",
+		"SELECT AVG(name) FROM people",
+		"SELECT SUM(name) FROM people WHERE age > 100",
+		"SELECT AVG(birthdate) FROM people",
+		"SELECT SUM(*) FROM people",
+		"SELECT COUNT(DISTINCT *) FROM people",
+		"SELECT SUM(DISTINCT age) FROM people",
+		"SELECT COUNT(missing) FROM people",
+		"SELECT name, COUNT(*) FROM people",
+	} {
+		if _, err := db.Execute(query); err == nil {
+			t.Errorf("Expected an error for %q", query)
+		}
+	}
+}
+
+func TestAggregateOrders(t *testing.T) {
+	defer cleanupTestDB("testdb")
+	db, _ := database.NewDatabase("testdb")
+	_, _ = db.Execute("CREATE TABLE orders (id INT, amount FLOAT, status VARCHAR, code VARCHAR, placed DATE)")
+	_, _ = db.Execute("INSERT INTO orders (id, amount, status, code, placed) VALUES (1, 12.5, 'paid', '9', '2024-03-01')")
+	_, _ = db.Execute("INSERT INTO orders (id, amount, status, code, placed) VALUES (2, 7.25, 'paid', '10', '2023-12-31')")
+	_, _ = db.Execute("INSERT INTO orders (id, amount, status, code, placed) VALUES (3, 100, 'open', '5', '2024-01-15')")
+	_, _ = db.Execute("INSERT INTO orders (id, status) VALUES (4, 'paid')")
+
+	row := selectAggregate(t, db, "SELECT SUM(amount), AVG(amount), MIN(amount), MAX(amount) FROM orders WHERE status = 'paid'")
+	expected := map[string]any{"SUM(amount)": 19.75, "AVG(amount)": 9.875, "MIN(amount)": 7.25, "MAX(amount)": 12.5}
+	for key, want := range expected {
+		if row[key] != want {
+			t.Errorf("Expected %s = %v, got %v", key, want, row[key])
+		}
+	}
+
+	// VARCHAR compares as text, as ORDER BY sorts it, so '10' comes before '9'
+	row = selectAggregate(t, db, "SELECT MIN(code), MAX(code), MIN(placed), MAX(placed) FROM orders")
+	expected = map[string]any{"MIN(code)": "10", "MAX(code)": "9", "MIN(placed)": "2023-12-31", "MAX(placed)": "2024-03-01"}
+	for key, want := range expected {
+		if row[key] != want {
+			t.Errorf("Expected %s = %v, got %v", key, want, row[key])
+		}
+	}
+}
+
+func TestGroupBy(t *testing.T) {
+	defer cleanupTestDB("testdb")
+	db := newBlogDB(t)
+	_, _ = db.Execute("INSERT INTO posts (post_id, title) VALUES (13, 'Orphan')")
+
+	rows, columns, err := db.Query("SELECT user_id, COUNT(*), MAX(title) FROM posts GROUP BY user_id")
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	if names := columnNames(columns); len(names) != 3 || names[0] != "user_id" || names[1] != "COUNT(*)" {
+		t.Errorf("Unexpected columns %v", names)
+	}
+	// Groups come in the order they are first seen, nulls form a group
+	expected := []database.Row{
+		{"user_id": int64(1), "COUNT(*)": int64(2), "MAX(title)": "Hello"},
+		{"user_id": int64(2), "COUNT(*)": int64(1), "MAX(title)": "World"},
+		{"user_id": nil, "COUNT(*)": int64(1), "MAX(title)": "Orphan"},
+	}
+	if len(rows) != len(expected) {
+		t.Fatalf("Expected %d groups, got %v", len(expected), rows)
+	}
+	for i, want := range expected {
+		for col, val := range want {
+			if rows[i][col] != val {
+				t.Errorf("Group %d: expected %s = %v, got %v", i, col, val, rows[i])
+			}
+		}
+	}
+}
+
+func TestCountDistinct(t *testing.T) {
+	defer cleanupTestDB("testdb")
+	db := newBlogDB(t)
+	_, _ = db.Execute("INSERT INTO posts (post_id, title) VALUES (13, 'Orphan')")
+
+	row := selectAggregate(t, db, "SELECT COUNT(DISTINCT user_id), COUNT(user_id) FROM posts")
+	if row["COUNT(DISTINCT user_id)"] != float64(2) || row["COUNT(user_id)"] != float64(3) {
+		t.Errorf("Unexpected counts %v", row)
+	}
+	row = selectAggregate(t, db, "SELECT count(distinct user_id) FROM posts WHERE post_id > 10")
+	if row["COUNT(DISTINCT user_id)"] != float64(2) {
+		t.Errorf("Expected 2 users after WHERE, got %v", row)
+	}
+
+	rows, _, err := db.Query("SELECT user_id, COUNT(DISTINCT title) FROM posts GROUP BY user_id ORDER BY COUNT(DISTINCT title) DESC")
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	if len(rows) != 3 || rows[0]["user_id"] != int64(1) || rows[0]["COUNT(DISTINCT title)"] != int64(2) {
+		t.Errorf("Unexpected groups %v", rows)
+	}
+
+	// Numbers count once whatever way they are written
+	_, _ = db.Execute("CREATE TABLE scores (id INT, score DOUBLE)")
+	_, _ = db.Execute("INSERT INTO scores (id, score) VALUES (1, 1)")
+	_, _ = db.Execute("INSERT INTO scores (id, score) VALUES (2, 1.0)")
+	_, _ = db.Execute("INSERT INTO scores (id, score) VALUES (3, 2.5)")
+	_, _ = db.Execute("INSERT INTO scores (id, score) VALUES (4, NULL)")
+	row = selectAggregate(t, db, "SELECT COUNT(DISTINCT score) FROM scores")
+	if row["COUNT(DISTINCT score)"] != float64(2) {
+		t.Errorf("Expected 2 distinct scores, got %v", row)
+	}
+}
+
+func TestGroupByOrderAndLimit(t *testing.T) {
+	defer cleanupTestDB("testdb")
+	db := newBlogDB(t)
+
+	rows, _, err := db.Query("SELECT user_id, COUNT(*) FROM posts WHERE post_id > 0 GROUP BY user_id ORDER BY count(*) DESC LIMIT 1")
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	if len(rows) != 1 || rows[0]["user_id"] != int64(1) || rows[0]["COUNT(*)"] != int64(2) {
+		t.Errorf("Expected the user with most posts, got %v", rows)
+	}
+
+	rows, _, err = db.Query("SELECT user_id FROM posts GROUP BY user_id ORDER BY user_id DESC")
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	if len(rows) != 2 || rows[0]["user_id"] != int64(2) || rows[1]["user_id"] != int64(1) {
+		t.Errorf("Expected distinct user ids in descending order, got %v", rows)
+	}
+}
+
+func TestGroupByJoin(t *testing.T) {
+	defer cleanupTestDB("testdb")
+	db := newBlogDB(t)
+
+	rows := selectRows(t, db, "SELECT users.name, COUNT(posts.title) FROM users LEFT JOIN posts ON users.id = posts.user_id GROUP BY users.name ORDER BY users.name")
+	expected := map[string]float64{"Alice": 2, "Bob": 1, "Carol": 0}
+	if len(rows) != len(expected) {
+		t.Fatalf("Expected %d groups, got %v", len(expected), rows)
+	}
+	for _, row := range rows {
+		name, _ := row["users.name"].(string)
+		if row["COUNT(posts.title)"] != expected[name] {
+			t.Errorf("Unexpected count for %s: %v", name, row)
+		}
+	}
+}
+
+func TestGroupByErrors(t *testing.T) {
+	defer cleanupTestDB("testdb")
+	db := newBlogDB(t)
+
+	for _, query := range []string{
+		"SELECT title, COUNT(*) FROM posts GROUP BY user_id",
+		"SELECT * FROM posts GROUP BY user_id",
+		"SELECT user_id FROM posts GROUP BY missing",
+		"SELECT user_id, SUM(title) FROM posts GROUP BY user_id",
+	} {
+		if _, err := db.Execute(query); err == nil {
+			t.Errorf("Expected an error for %q", query)
+		}
+	}
+}
+
+func TestTableCount(t *testing.T) {
+	defer cleanupTestDB("testdb")
+	db := newPeopleDB(t)
+	table := db.Tables["people"]
+
+	tests := []struct {
+		where    string
+		expected int
+	}{
+		{"", 4},
+		{"age > 30", 2},
+		{"people.age > 30 AND id IN (SELECT id FROM people WHERE age < 40)", 1},
+		{"age > 100", 0},
+	}
+	for _, tt := range tests {
+		count, err := table.Count(tt.where)
+		if err != nil || count != tt.expected {
+			t.Errorf("Count(%q): expected %d, got %d (%v)", tt.where, tt.expected, count, err)
+		}
+	}
+	if _, err := table.Count("missing = 1"); err == nil {
+		t.Error("Expected an error for an unknown column")
+	}
+
+	row := selectAggregate(t, db, "SELECT COUNT(*) AS n FROM people p WHERE p.age > 30")
+	if row["n"] != float64(2) {
+		t.Errorf("Expected n = 2, got %v", row)
+	}
+	rows, columns, err := db.Query("SELECT COUNT(*) FROM people")
+	if err != nil || len(rows) != 1 || rows[0]["COUNT(*)"] != int64(4) || columns[0].Type != database.COLUMN_TYPE_INT {
+		t.Errorf("Expected an INT count of 4, got %v %v (%v)", rows, columns, err)
+	}
+}
@@ -0,0 +1,157 @@
+package database_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/AYGA2K/db/internal/database"
+)
+
+func TestAlterTableAddColumn(t *testing.T) {
+	defer cleanupTestDB("testdb")
+	db := newPeopleDB(t)
+
+	res, err := db.Execute("ALTER TABLE people ADD COLUMN email VARCHAR UNIQUE")
+	if err != nil {
+		t.Fatalf("ALTER TABLE failed: %v", err)
+	}
+	if res != "Column email added to table people" {
+		t.Errorf("Unexpected result: %s", res)
+	}
+	assertIDs(t, selectIDs(t, db, "SELECT * FROM people WHERE email IS NULL"), 1, 2, 3, 4)
+
+	if _, err := db.Execute("UPDATE people SET email = 'alice@example.com' WHERE id = 1"); err != nil {
+		t.Fatalf("Update of the new column failed: %v", err)
+	}
+	if _, err := db.Execute("ALTER TABLE people ADD active BOOL"); err != nil {
+		t.Fatalf("ALTER TABLE without COLUMN failed: %v", err)
+	}
+
+	reopened, err := database.NewDatabase("testdb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows, columns, err := reopened.Query("SELECT * FROM people WHERE email = 'alice@example.com'")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 1 || rows[0]["id"] != int64(1) {
+		t.Errorf("Expected Alice, got %v", rows)
+	}
+	if names := columnNames(columns); len(names) != 7 || names[5] != "email" || names[6] != "active" {
+		t.Errorf("Expected the new columns last, got %v", names)
+	}
+
+	for query, want := range map[string]string{
+		"ALTER TABLE people ADD COLUMN name VARCHAR":       "already exists",
+		"ALTER TABLE people ADD COLUMN code INT NOT NULL":  "NOT NULL",
+		"ALTER TABLE people ADD COLUMN pk INT PRIMARY KEY": "PRIMARY KEY",
+		"ALTER TABLE people ADD COLUMN bad NUMBER":         "invalid column type",
+		"ALTER TABLE missing ADD COLUMN email VARCHAR":     "does not exist",
+	} {
+		if _, err := db.Execute(query); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("Expected an error containing %q for %q, got %v", want, query, err)
+		}
+	}
+}
+
+func TestAlterTableAddNotNullToEmptyTable(t *testing.T) {
+	defer cleanupTestDB("testdb")
+	db, err := database.NewDatabase("testdb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = db.Execute("CREATE TABLE tags (name VARCHAR)")
+	if _, err := db.Execute("ALTER TABLE tags ADD COLUMN id INT PRIMARY KEY NOT NULL"); err != nil {
+		t.Fatalf("Expected NOT NULL to be allowed on an empty table, got %v", err)
+	}
+	if _, err := db.Execute("INSERT INTO tags (name) VALUES ('go')"); err == nil {
+		t.Error("Expected the new NOT NULL column to be enforced")
+	}
+}
+
+func TestAlterTableAddColumnDefault(t *testing.T) {
+	defer cleanupTestDB("testdb")
+	db := newPeopleDB(t)
+
+	if _, err := db.Execute("ALTER TABLE people ADD COLUMN score DOUBLE NOT NULL DEFAULT 1.5"); err != nil {
+		t.Fatalf("Expected NOT NULL with a default to be allowed, got %v", err)
+	}
+	assertIDs(t, selectIDs(t, db, "SELECT * FROM people WHERE score = 1.5"), 1, 2, 3, 4)
+
+	if _, err := db.Execute("ALTER TABLE people ADD COLUMN code VARCHAR UNIQUE DEFAULT 'x'"); err == nil || !strings.Contains(err.Error(), "UNIQUE") {
+		t.Errorf("Expected a UNIQUE column with a default to be rejected for several rows, got %v", err)
+	}
+	if _, err := db.Execute("SELECT code FROM people"); err == nil {
+		t.Error("Expected the rejected column not to be added")
+	}
+	if _, err := db.Execute("DELETE FROM people WHERE id > 1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Execute("ALTER TABLE people ADD COLUMN code VARCHAR UNIQUE DEFAULT 'x'"); err != nil {
+		t.Fatalf("Expected a UNIQUE column with a default to be allowed for one row, got %v", err)
+	}
+	if _, err := db.Execute("INSERT INTO people (id, name, age) VALUES (5, 'Eve', 40)"); err == nil || !strings.Contains(strings.ToLower(err.Error()), "unique") {
+		t.Errorf("Expected the default of a second row to violate the UNIQUE column, got %v", err)
+	}
+}
+
+func TestAlterTableRename(t *testing.T) {
+	defer cleanupTestDB("testdb")
+	db, err := database.NewDatabase("testdb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = db.Execute("CREATE TABLE users (id INT PRIMARY KEY, name VARCHAR)")
+	_, _ = db.Execute("CREATE TABLE posts (post_id INT, user_id INT FOREIGN KEY REFERENCES users(id), title VARCHAR)")
+	_, _ = db.Execute("INSERT INTO users (id, name) VALUES (1, 'Alice')")
+	_, _ = db.Execute("INSERT INTO posts (post_id, user_id, title) VALUES (10, 1, 'Hello')")
+
+	if _, err := db.Execute("ALTER TABLE users RENAME TO members"); err != nil {
+		t.Fatalf("Rename table failed: %v", err)
+	}
+	if _, err := db.Execute("SELECT * FROM users"); err == nil {
+		t.Error("Expected the old table name to be gone")
+	}
+	if _, err := db.Execute("ALTER TABLE members RENAME COLUMN id TO member_id"); err != nil {
+		t.Fatalf("Rename column failed: %v", err)
+	}
+
+	reopened, err := database.NewDatabase("testdb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows := selectRows(t, reopened, "SELECT members.name, posts.title FROM members JOIN posts ON members.member_id = posts.user_id WHERE member_id = 1")
+	if len(rows) != 1 || rows[0]["members.name"] != "Alice" {
+		t.Errorf("Expected Alice's post through the renamed names, got %v", rows)
+	}
+	members := reopened.Tables["members"]
+	if members.Name != "members" || members.PrimaryKey != "member_id" {
+		t.Errorf("Expected the table and primary key to be renamed, got %s and %s", members.Name, members.PrimaryKey)
+	}
+	posts := reopened.Tables["posts"]
+	if ref := posts.ForeignKeys["user_id"]; ref != "members.member_id" {
+		t.Errorf("Expected the foreign key to follow the renames, got %s", ref)
+	}
+	if column, _ := posts.GetColumn("user_id"); column.ReferenceTable != "members" || column.ReferenceColumn != "member_id" {
+		t.Errorf("Expected the column reference to follow the renames, got %+v", column)
+	}
+
+	if _, err := reopened.Execute("ALTER TABLE posts RENAME COLUMN title TO heading"); err != nil {
+		t.Fatal(err)
+	}
+	if rows := selectRows(t, reopened, "SELECT heading FROM posts"); len(rows) != 1 || rows[0]["heading"] != "Hello" {
+		t.Errorf("Expected the row values under the new column name, got %v", rows)
+	}
+
+	for query, want := range map[string]string{
+		"ALTER TABLE members RENAME TO posts":                 "already exists",
+		"ALTER TABLE members RENAME COLUMN name TO member_id": "already exists",
+		"ALTER TABLE members RENAME COLUMN nope TO other":     "does not exist",
+		"ALTER TABLE missing RENAME TO other":                 "does not exist",
+	} {
+		if _, err := reopened.Execute(query); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("Expected an error containing %q for %q, got %v", want, query, err)
+		}
+	}
+}
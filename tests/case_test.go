@@ -0,0 +1,96 @@
+package database_test
+
+import (
+	"testing"
+
+	"github.com/AYGA2K/db/internal/database"
+)
+
+func TestCaseWhen(t *testing.T) {
+	defer cleanupTestDB("testdb")
+	db := newPeopleDB(t)
+
+	rows := selectRows(t, db, "SELECT name, CASE WHEN age >= 35 THEN 'senior' WHEN age >= 30 THEN 'mid' ELSE 'junior' END AS level FROM people")
+	expected := []string{"junior", "mid", "senior", "senior"}
+	if len(rows) != len(expected) {
+		t.Fatalf("Expected %d rows, got %v", len(expected), rows)
+	}
+	for i, row := range rows {
+		if row["level"] != expected[i] {
+			t.Errorf("Expected level %s for %v, got %v", expected[i], row["name"], row["level"])
+		}
+	}
+}
+
+func TestCaseWhenWithoutElse(t *testing.T) {
+	defer cleanupTestDB("testdb")
+	db := newPeopleDB(t)
+
+	rows, _, err := db.Query("SELECT id, CASE WHEN age < 30 OR name = 'David' THEN height END AS h FROM people")
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	for _, row := range rows {
+		id := row["id"].(int64)
+		val, present := row["h"]
+		if !present {
+			t.Fatalf("Expected h in every row, got %v", row)
+		}
+		if (id == 1 || id == 4) != (val != nil) {
+			t.Errorf("Unexpected h %v for id %d", val, id)
+		}
+	}
+}
+
+func TestCaseWhenColumns(t *testing.T) {
+	defer cleanupTestDB("testdb")
+	db := newPeopleDB(t)
+
+	_, columns, err := db.Query("SELECT CASE WHEN age > 30 THEN 1 ELSE 0 END, CASE WHEN age > 30 THEN name END label FROM people")
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	names := columnNames(columns)
+	if len(names) != 2 || names[0] != "CASE WHEN age > 30 THEN 1 ELSE 0 END" || names[1] != "label" {
+		t.Errorf("Unexpected columns %v", names)
+	}
+	if columns[0].Type != database.COLUMN_TYPE_INT || columns[1].Type != database.COLUMN_TYPE_VARCHAR {
+		t.Errorf("Unexpected column types %v", columns)
+	}
+}
+
+func TestCaseWhenJoin(t *testing.T) {
+	defer cleanupTestDB("testdb")
+	db := newBlogDB(t)
+
+	rows := selectRows(t, db, "SELECT posts.title, CASE WHEN users.name = 'Alice' THEN 'mine' ELSE 'theirs' END AS owner FROM posts JOIN users ON posts.user_id = users.id")
+	if len(rows) != 3 {
+		t.Fatalf("Expected 3 rows, got %v", rows)
+	}
+	for _, row := range rows {
+		expected := "mine"
+		if row["posts.title"] == "World" {
+			expected = "theirs"
+		}
+		if row["owner"] != expected {
+			t.Errorf("Expected owner %s for %v, got %v", expected, row["posts.title"], row["owner"])
+		}
+	}
+}
+
+func TestCaseWhenErrors(t *testing.T) {
+	defer cleanupTestDB("testdb")
+	db := newPeopleDB(t)
+
+	for _, query := range []string{
+		"SELECT CASE WHEN age > 30 THEN 'x' FROM people",
+		"SELECT CASE WHEN wage > 30 THEN 'x' END FROM people",
+		"SELECT CASE WHEN age > 30 THEN CASE WHEN age > 35 THEN 'a' END END FROM people",
+		"SELECT CASE WHEN THEN 'x' END FROM people",
+		"SELECT CASE ELSE 'x' END FROM people",
+	} {
+		if _, _, err := db.Query(query); err == nil {
+			t.Errorf("Expected an error for %s", query)
+		}
+	}
+}
@@ -2,11 +2,15 @@ package database_test
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"path/filepath"
 	"strings"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/AYGA2K/db/internal/database"
 )
@@ -59,6 +63,148 @@ func TestInsertAndSelect(t *testing.T) {
 	}
 }
 
+func TestInsertWithoutColumnList(t *testing.T) {
+	defer cleanupTestDB("testdb")
+
+	db, err := database.NewDatabase("testdb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = db.Execute("CREATE TABLE users (id INT, name VARCHAR)")
+	_, _ = db.Execute("CREATE TABLE posts (id INT PRIMARY KEY AUTO_INCREMENT, title VARCHAR)")
+
+	if _, err := db.Execute("INSERT INTO users VALUES (1, 'Alice')"); err != nil {
+		t.Fatalf("Insert error: %v", err)
+	}
+	res, err := db.Execute("SELECT * FROM users")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(res, `"id": 1`) || !strings.Contains(res, `"name": "Alice"`) {
+		t.Errorf("Expected values to map to the declared columns, got: %s", res)
+	}
+
+	if _, err := db.Execute("INSERT INTO users VALUES (2)"); err == nil {
+		t.Errorf("Expected an error when the value count does not match the columns")
+	}
+
+	if _, err := db.Execute("INSERT INTO posts VALUES ('Hello')"); err != nil {
+		t.Fatalf("Insert without the auto increment value error: %v", err)
+	}
+	if _, err := db.Execute("INSERT INTO posts VALUES (10, 'World')"); err != nil {
+		t.Fatalf("Insert with every value error: %v", err)
+	}
+	res, err = db.Execute("SELECT * FROM posts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(res, `"id": 1`) || !strings.Contains(res, `"title": "Hello"`) ||
+		!strings.Contains(res, `"id": 10`) || !strings.Contains(res, `"title": "World"`) {
+		t.Errorf("Unexpected posts: %s", res)
+	}
+}
+
+func TestInsertUnknownColumn(t *testing.T) {
+	defer cleanupTestDB("testdb")
+
+	db, err := database.NewDatabase("testdb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = db.Execute("CREATE TABLE users (id INT, name VARCHAR)")
+
+	if _, err := db.Execute("INSERT INTO users (nope) VALUES (1)"); err == nil || err.Error() != "column nope does not exist" {
+		t.Errorf("Expected an unknown column error, got %v", err)
+	}
+	if _, err := db.Execute("INSERT INTO users (id, nope) VALUES (1, 'x')"); err == nil || err.Error() != "column nope does not exist" {
+		t.Errorf("Expected an unknown column error, got %v", err)
+	}
+	_, rejected, err := db.InsertRows("users", []string{"id", "nope"}, [][]string{{"1", "x"}}, false)
+	if err != nil || len(rejected) != 1 || rejected[0].Err.Error() != "column nope does not exist" {
+		t.Errorf("Expected the row to be rejected for the unknown column, got %v, %v", rejected, err)
+	}
+	if res, err := db.Execute("SELECT * FROM users"); err != nil || res != "[]" {
+		t.Errorf("Expected no rows to be inserted, got %s, %v", res, err)
+	}
+}
+
+func TestInsertQuotedValues(t *testing.T) {
+	defer cleanupTestDB("testdb")
+
+	db, err := database.NewDatabase("testdb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = db.Execute("CREATE TABLE books (id INT, title VARCHAR, note VARCHAR)")
+
+	tests := []struct {
+		query string
+		title string
+		note  string
+	}{
+		{"INSERT INTO books (id, title, note) VALUES (1, 'War, and Peace', 'x')", "War, and Peace", "x"},
+		{"INSERT INTO books (id, title, note) VALUES (2, 'Dune (1965)', 'a) b, (c')", "Dune (1965)", "a) b, (c"},
+		{"INSERT INTO books (id, title, note) VALUES (3,   '  padded  ' , \"double, quoted\")", "  padded  ", "double, quoted"},
+		{"INSERT INTO books VALUES (4, ',', ')')", ",", ")"},
+	}
+	for i, tt := range tests {
+		if _, err := db.Execute(tt.query); err != nil {
+			t.Fatalf("Insert %q failed: %v", tt.query, err)
+		}
+		rows, _, err := db.Query(fmt.Sprintf("SELECT title, note FROM books WHERE id = %d", i+1))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(rows) != 1 || rows[0]["title"] != tt.title || rows[0]["note"] != tt.note {
+			t.Errorf("%q: expected title %q and note %q, got %v", tt.query, tt.title, tt.note, rows)
+		}
+	}
+
+	_, err = db.Execute("CREATE TABLE kinds (id INT, kind ENUM('a',b))")
+	var syntaxErr *database.ErrSyntax
+	if !errors.As(err, &syntaxErr) || syntaxErr.Near != "kind ENUM('a',b)" {
+		t.Errorf("Expected the whole ENUM definition in the error, got %v", err)
+	}
+}
+
+func TestInsertEscapedQuotesAndEmptyStrings(t *testing.T) {
+	defer cleanupTestDB("testdb")
+
+	db, err := database.NewDatabase("testdb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = db.Execute("CREATE TABLE t (a VARCHAR, b INT)")
+	for _, query := range []string{
+		"INSERT INTO t (a, b) VALUES ('Hello, World', 1)",
+		"INSERT INTO t (a, b) VALUES ('It''s, fine', 2)",
+		"INSERT INTO t (a, b) VALUES ('', 3)",
+		"INSERT INTO t (a, b) VALUES ('''', 4)",
+		`INSERT INTO t (a, b) VALUES ("say ""hi"", then", 5)`,
+	} {
+		if _, err := db.Execute(query); err != nil {
+			t.Fatalf("Insert %q failed: %v", query, err)
+		}
+	}
+	if _, err := db.Execute("UPDATE t SET a = 'a, b = c', b = 6 WHERE b = 1"); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+
+	rows, _, err := db.Query("SELECT a, b FROM t ORDER BY b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"It's, fine", "", "'", `say "hi", then`, "a, b = c"}
+	if len(rows) != len(expected) {
+		t.Fatalf("Expected %d rows, got %v", len(expected), rows)
+	}
+	for i, want := range expected {
+		if rows[i]["a"] != want {
+			t.Errorf("Row %d: expected %q, got %q", i, want, rows[i]["a"])
+		}
+	}
+}
+
 func TestWhereClause(t *testing.T) {
 	defer cleanupTestDB("testdb")
 
@@ -94,7 +240,7 @@ func TestDelete(t *testing.T) {
 	if err != nil {
 		t.Fatalf("Delete error: %v", err)
 	}
-	if res != "1 rows deleted" {
+	if res != "1 row deleted" {
 		t.Errorf("Unexpected delete result: %s", res)
 	}
 	selectRes, err := db.Execute("SELECT * FROM users")
@@ -106,6 +252,38 @@ func TestDelete(t *testing.T) {
 	}
 }
 
+func TestDeleteCount(t *testing.T) {
+	defer cleanupTestDB("testdb")
+
+	db, err := database.NewDatabase("testdb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = db.Execute("CREATE TABLE users (id INT, name VARCHAR)")
+	for i := 1; i <= 5; i++ {
+		_, _ = db.Execute(fmt.Sprintf("INSERT INTO users (id, name) VALUES (%d, 'User%d')", i, i))
+	}
+
+	res, err := db.Execute("DELETE FROM users WHERE id > 3")
+	if err != nil {
+		t.Fatalf("Delete error: %v", err)
+	}
+	if res != "2 rows deleted" {
+		t.Errorf("Unexpected delete result: %s", res)
+	}
+
+	res, err = db.Execute("DELETE FROM users")
+	if err != nil {
+		t.Fatalf("Delete error: %v", err)
+	}
+	if res != "3 rows deleted" {
+		t.Errorf("Unexpected delete result: %s", res)
+	}
+	if len(db.Tables["users"].Rows) != 0 {
+		t.Errorf("Expected DELETE without WHERE to remove every row, got %d rows", len(db.Tables["users"].Rows))
+	}
+}
+
 func TestUpdate(t *testing.T) {
 	defer cleanupTestDB("testdb")
 
@@ -133,6 +311,126 @@ func TestUpdate(t *testing.T) {
 		!strings.Contains(selectRes, `"name": "Bob"`) {
 		t.Errorf("Expected Charlie for id 1 and Bob to remain unchanged, got: %s", selectRes)
 	}
+
+	if _, err := db.Execute("UPDATE users SET nmae = 'Dave' WHERE id = 2"); err == nil || err.Error() != "column nmae does not exist" {
+		t.Errorf("Expected an unknown column error, got %v", err)
+	}
+}
+
+func TestUpdateWithoutWhere(t *testing.T) {
+	defer cleanupTestDB("testdb")
+	db := newPeopleDB(t)
+
+	res, err := db.Execute("UPDATE people SET age = 50, name = 'Same'")
+	if err != nil || res != "4 rows updated" {
+		t.Fatalf("Expected every row to be updated, got %q, %v", res, err)
+	}
+	assertIDs(t, selectIDs(t, db, "SELECT * FROM people WHERE age = 50 AND name = 'Same'"), 1, 2, 3, 4)
+
+	update, err := db.Prepare("UPDATE people SET age = ?")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result, err := update.Exec(60); err != nil || result.Output != "4 rows updated" {
+		t.Errorf("Expected the prepared update to change every row, got %v, %v", result, err)
+	}
+	assertIDs(t, selectIDs(t, db, "SELECT * FROM people WHERE age = 60"), 1, 2, 3, 4)
+
+	_, _ = db.Execute("CREATE TABLE empty (id INT)")
+	if res, err := db.Execute("UPDATE empty SET id = 1"); err != nil || res != "0 rows updated" {
+		t.Errorf("Expected no rows updated in an empty table, got %q, %v", res, err)
+	}
+}
+
+func TestUpdateExpressions(t *testing.T) {
+	defer cleanupTestDB("testdb")
+	db := newPeopleDB(t)
+
+	if res, err := db.Execute("UPDATE people SET age = age + 1 WHERE id = 1"); err != nil || res != "1 rows updated" {
+		t.Fatalf("Expected one row updated, got %q, %v", res, err)
+	}
+	assertIDs(t, selectIDs(t, db, "SELECT * FROM people WHERE age = 26"), 1)
+
+	// Every matching row is computed from its own values
+	if _, err := db.Execute("UPDATE people SET age = age * 2 - 10, height = height + id WHERE id > 2"); err != nil {
+		t.Fatal(err)
+	}
+	assertIDs(t, selectIDs(t, db, "SELECT * FROM people WHERE age = 60 AND height = 4.75"), 3)
+	assertIDs(t, selectIDs(t, db, "SELECT * FROM people WHERE age = 70 AND height = 5.9"), 4)
+	assertIDs(t, selectIDs(t, db, "SELECT * FROM people WHERE age = 30"), 2)
+
+	// A DOUBLE column accepts any numeric expression
+	if _, err := db.Execute("UPDATE people SET height = age / 4 WHERE id = 2"); err != nil {
+		t.Fatal(err)
+	}
+	assertIDs(t, selectIDs(t, db, "SELECT * FROM people WHERE height = 7.5"), 2)
+
+	// A null operand gives a null result
+	_, _ = db.Execute("INSERT INTO people (id, name) VALUES (5, 'Eve')")
+	if _, err := db.Execute("UPDATE people SET age = age + 1 WHERE id = 5"); err != nil {
+		t.Fatal(err)
+	}
+	assertIDs(t, selectIDs(t, db, "SELECT * FROM people WHERE age IS NULL"), 5)
+
+	errorTests := []struct {
+		name  string
+		query string
+		want  string
+	}{
+		{"division into INT", "UPDATE people SET age = age / 2", "not an integer"},
+		{"DOUBLE column into INT", "UPDATE people SET age = height + 1", "not an integer"},
+		{"non-numeric column", "UPDATE people SET age = name + 1", "not numeric"},
+		{"unknown column", "UPDATE people SET age = weight + 1", "column weight not found"},
+		{"division by zero", "UPDATE people SET height = age / 0 WHERE id = 1", "division by zero"},
+	}
+	for _, tt := range errorTests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := db.Execute(tt.query); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Expected an error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+	assertIDs(t, selectIDs(t, db, "SELECT * FROM people WHERE age = 26"), 1)
+}
+
+func TestTruncateTable(t *testing.T) {
+	defer cleanupTestDB("testdb")
+	db, err := database.NewDatabase("testdb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = db.Execute("CREATE TABLE users (id INT PRIMARY KEY AUTO_INCREMENT, name VARCHAR)")
+	_, _ = db.Execute("CREATE TABLE posts (id INT, user_id INT FOREIGN KEY REFERENCES users(id) ON DELETE CASCADE)")
+	_, _ = db.Execute("CREATE TABLE sessions (id INT, user_id INT FOREIGN KEY REFERENCES users(id))")
+	_, _ = db.Execute("CREATE INDEX idx_name ON users (name)")
+	_, _ = db.Execute("INSERT INTO users (name) VALUES ('Alice')")
+	_, _ = db.Execute("INSERT INTO users (name) VALUES ('Bob')")
+	_, _ = db.Execute("INSERT INTO posts (id, user_id) VALUES (10, 1)")
+	_, _ = db.Execute("INSERT INTO sessions (id, user_id) VALUES (100, 2)")
+
+	// Sessions restrict, nothing is removed
+	if _, err := db.Execute("TRUNCATE TABLE users"); err == nil || !strings.Contains(err.Error(), "sessions.user_id") {
+		t.Errorf("Expected the referenced users to be kept, got %v", err)
+	}
+	assertIDs(t, selectIDs(t, db, "SELECT * FROM users"), 1, 2)
+
+	if res, err := db.Execute("truncate sessions"); err != nil || res != "Table sessions truncated" {
+		t.Fatalf("Expected sessions to be truncated, got %q, %v", res, err)
+	}
+	if res, err := db.Execute("TRUNCATE TABLE users"); err != nil || res != "Table users truncated" {
+		t.Fatalf("Expected users to be truncated, got %q, %v", res, err)
+	}
+	assertIDs(t, selectIDs(t, db, "SELECT * FROM users"))
+	assertIDs(t, selectIDs(t, db, "SELECT * FROM posts"))
+	assertIDs(t, selectIDs(t, db, "SELECT * FROM users WHERE name = 'Alice'"))
+
+	// The schema stays and AUTO_INCREMENT starts over
+	_, _ = db.Execute("INSERT INTO users (name) VALUES ('Carol')")
+	assertIDs(t, selectIDs(t, db, "SELECT * FROM users WHERE name = 'Carol'"), 1)
+
+	if _, err := db.Execute("TRUNCATE TABLE nope"); err == nil || err.Error() != "table nope does not exist" {
+		t.Errorf("Expected a missing table error, got %v", err)
+	}
 }
 
 func TestDropTable(t *testing.T) {
@@ -157,6 +455,9 @@ func TestDropTable(t *testing.T) {
 	if exists {
 		t.Errorf("Table users still exists")
 	}
+	if _, err := db.Execute("DROP TABLE users"); err == nil || !strings.Contains(err.Error(), "does not exist") {
+		t.Errorf("Expected dropping a missing table to fail, got %v", err)
+	}
 }
 
 func TestColumnTypeParsing(t *testing.T) {
@@ -342,130 +643,674 @@ func TestPrimaryKeyAutoIncrement(t *testing.T) {
 	}
 }
 
-func TestForeignKey(t *testing.T) {
+func TestAutoIncrementAfterExplicitIDs(t *testing.T) {
 	defer cleanupTestDB("testdb")
+
 	db, err := database.NewDatabase("testdb")
 	if err != nil {
 		t.Fatal(err)
 	}
-	_, _ = db.Execute("CREATE TABLE users (id INT, name VARCHAR)")
-	_, _ = db.Execute("CREATE TABLE posts (id INT, user_id INT FOREIGN KEY REFERENCES users(id) , title VARCHAR)")
-	_, _ = db.Execute("INSERT INTO users (id, name) VALUES (1, 'Alice')")
-	_, _ = db.Execute("INSERT INTO posts (id, user_id, title) VALUES (1, 1, 'Hello')")
+	_, _ = db.Execute("CREATE TABLE users (id INT PRIMARY KEY AUTO_INCREMENT, name VARCHAR)")
+	_, _ = db.Execute("INSERT INTO users (id, name) VALUES (5, 'Alice')")
+	_, _ = db.Execute("INSERT INTO users (name) VALUES ('Bob')")
 
-	res, err := db.Execute("SELECT * FROM posts where user_id = 1")
+	// Reload from disk to make sure stored ids keep their type
+	db, err = database.NewDatabase("testdb")
 	if err != nil {
-		t.Fatalf("Select with where error: %v", err)
+		t.Fatal(err)
 	}
-	if !strings.Contains(res, `"user_id": 1`) {
-		t.Errorf("Expected result to contain user_id 1, got: %s", res)
+	_, _ = db.Execute("INSERT INTO users (name) VALUES ('Charlie')")
+
+	res, err := db.Execute("SELECT * FROM users ORDER BY id DESC")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var results []map[string]any
+	if err := json.Unmarshal([]byte(res), &results); err != nil {
+		t.Fatalf("Failed to unmarshal results: %v", err)
+	}
+	expected := []struct {
+		id   float64
+		name string
+	}{{7, "Charlie"}, {6, "Bob"}, {5, "Alice"}}
+	if len(results) != len(expected) {
+		t.Fatalf("Expected %d rows, got: %s", len(expected), res)
+	}
+	for i, row := range results {
+		if row["id"] != expected[i].id || row["name"] != expected[i].name {
+			t.Errorf("Expected row %d to be %v, got %v", i, expected[i], row)
+		}
 	}
 }
 
-func TestSelectJoin(t *testing.T) {
+func TestDuplicatePrimaryKey(t *testing.T) {
 	defer cleanupTestDB("testdb")
+
 	db, err := database.NewDatabase("testdb")
 	if err != nil {
 		t.Fatal(err)
 	}
-
-	_, _ = db.Execute("CREATE TABLE users (id INT, name VARCHAR)")
-	_, _ = db.Execute("CREATE TABLE posts (id INT, user_id INT FOREIGN KEY REFERENCES users(id), title VARCHAR)")
-	_, _ = db.Execute("INSERT INTO users (id, name) VALUES (1, 'Alice')")
-	_, _ = db.Execute("INSERT INTO users (id, name) VALUES (2, 'Bob')")
-	_, _ = db.Execute("INSERT INTO posts (id, user_id, title) VALUES (1, 1, 'Hello')")
-	_, _ = db.Execute("INSERT INTO posts (id, user_id, title) VALUES (2, 2, 'World')")
-
-	res, err := db.Execute("SELECT posts.title, users.name FROM posts JOIN users ON posts.user_id = users.id")
-	if err != nil {
-		t.Fatalf("Select with join error: %v", err)
+	_, _ = db.Execute("CREATE TABLE users (id INT PRIMARY KEY, name VARCHAR)")
+	_, _ = db.Execute("CREATE TABLE posts (id INT, user_id INT FOREIGN KEY REFERENCES users(id))")
+	tables, _ := db.AllTables()
+	if tables["users"].PrimaryKey != "id" {
+		t.Errorf("Expected primary key id, got %q", tables["users"].PrimaryKey)
+	}
+	if tables["posts"].ForeignKeys["user_id"] != "users.id" {
+		t.Errorf("Expected user_id to reference users.id, got %v", tables["posts"].ForeignKeys)
 	}
 
-	if !strings.Contains(res, `"posts.title": "Hello"`) || !strings.Contains(res, `"users.name": "Alice"`) {
-		t.Errorf("Expected result to contain post 'Hello' by 'Alice', got: %s", res)
+	if _, err := db.Execute("INSERT INTO users (id, name) VALUES (1, 'Alice')"); err != nil {
+		t.Fatalf("Insert error: %v", err)
 	}
-	if !strings.Contains(res, `"posts.title": "World"`) || !strings.Contains(res, `"users.name": "Bob"`) {
-		t.Errorf("Expected result to contain post 'World' by 'Bob', got: %s", res)
+	if _, err := db.Execute("INSERT INTO users (id, name) VALUES (1, 'Bob')"); err == nil {
+		t.Errorf("Expected an error when inserting a duplicate primary key")
+	}
+	if len(tables["users"].Rows) != 1 {
+		t.Errorf("Expected the duplicate row to be rejected, got %d rows", len(tables["users"].Rows))
+	}
+
+	if _, err := db.Execute("CREATE TABLE bad (a INT PRIMARY KEY, b INT PRIMARY KEY)"); err == nil {
+		t.Errorf("Expected an error for a table with two primary keys")
 	}
 }
 
-func TestSelectLimit(t *testing.T) {
+func TestUpdateUnique(t *testing.T) {
 	defer cleanupTestDB("testdb")
 	db, err := database.NewDatabase("testdb")
 	if err != nil {
 		t.Fatal(err)
 	}
-	_, _ = db.Execute("CREATE TABLE users (id INT, name VARCHAR)")
-	_, _ = db.Execute("INSERT INTO users (id, name) VALUES (1, 'Alice')")
-	_, _ = db.Execute("INSERT INTO users (id, name) VALUES (2, 'Bob')")
-	_, _ = db.Execute("INSERT INTO users (id, name) VALUES (3, 'Charlie')")
-	_, _ = db.Execute("INSERT INTO users (id, name) VALUES (4, 'David')")
+	_, _ = db.Execute("CREATE TABLE users (id INT, email VARCHAR UNIQUE)")
+	_, _ = db.Execute("INSERT INTO users (id, email) VALUES (1, 'a@x.io')")
+	_, _ = db.Execute("INSERT INTO users (id, email) VALUES (2, 'b@x.io')")
+	_, _ = db.Execute("INSERT INTO users (id) VALUES (3)")
+	_, _ = db.Execute("INSERT INTO users (id) VALUES (4)")
 
-	res, err := db.Execute(fmt.Sprintf("SELECT * FROM users LIMIT %d", 3))
-	if err != nil {
-		t.Fatalf("Select with limit error: %v", err)
-	}
-	if !strings.Contains(res, `"id": 1`) || !strings.Contains(res, `"id": 2`) || !strings.Contains(res, `"id": 3`) {
-		t.Errorf("Expected result to contain id 1, 2, 3, got: %s", res)
+	for _, sql := range []string{
+		"UPDATE users SET email = 'a@x.io' WHERE id = 2",
+		"UPDATE users SET email = 'c@x.io' WHERE id > 2",
+	} {
+		if _, err := db.Execute(sql); err == nil || !strings.Contains(err.Error(), "unique constraint violation") {
+			t.Errorf("Expected a unique constraint error for %q, got %v", sql, err)
+		}
 	}
-	if strings.Contains(res, `"id": 4`) {
-		t.Errorf("Expected result to not contain id 4, got: %s", res)
+	assertIDs(t, selectIDs(t, db, "SELECT * FROM users WHERE email = 'a@x.io'"), 1)
+
+	// Nulls never collide, and a row may keep its own value
+	for _, sql := range []string{
+		"UPDATE users SET email = NULL WHERE id = 2",
+		"UPDATE users SET email = 'a@x.io' WHERE id = 1",
+		"UPDATE users SET email = 'c@x.io' WHERE id = 3",
+	} {
+		if _, err := db.Execute(sql); err != nil {
+			t.Errorf("Update %q failed: %v", sql, err)
+		}
 	}
+	assertIDs(t, selectIDs(t, db, "SELECT * FROM users WHERE email IS NULL"), 2, 4)
 }
 
-func TestSelectOrderBy(t *testing.T) {
+func TestNotNull(t *testing.T) {
 	defer cleanupTestDB("testdb")
+
 	db, err := database.NewDatabase("testdb")
 	if err != nil {
 		t.Fatal(err)
 	}
-	_, _ = db.Execute("CREATE TABLE users (id INT, name VARCHAR)")
-	_, _ = db.Execute("INSERT INTO users (id, name) VALUES (1, 'Charlie')")
-	_, _ = db.Execute("INSERT INTO users (id, name) VALUES (2, 'Alice')")
-	_, _ = db.Execute("INSERT INTO users (id, name) VALUES (3, 'David')")
-	_, _ = db.Execute("INSERT INTO users (id, name) VALUES (4, 'Bob')")
+	_, _ = db.Execute("CREATE TABLE users (id INT, name VARCHAR NOT NULL)")
 
-	res, err := db.Execute("SELECT * FROM users ORDER BY name")
-	if err != nil {
-		t.Fatalf("Select with order by error: %v", err)
+	if _, err := db.Execute("INSERT INTO users (id) VALUES (1)"); err == nil || !strings.Contains(err.Error(), "name") {
+		t.Errorf("Expected an error naming the missing column, got: %v", err)
 	}
-
-	// Check that results are in alphabetical order by name
-	expectedOrder := []string{"Alice", "Bob", "Charlie", "David"}
-	var results []map[string]interface{}
-	if err := json.Unmarshal([]byte(res), &results); err != nil {
-		t.Fatalf("Failed to unmarshal results: %v", err)
+	if _, err := db.Execute("INSERT INTO users (id, name) VALUES (1, '')"); err == nil {
+		t.Errorf("Expected an error for an empty NOT NULL value")
 	}
-
-	if len(results) != len(expectedOrder) {
-		t.Fatalf("Expected %d results, got %d", len(expectedOrder), len(results))
+	if _, err := db.Execute("INSERT INTO users (id, name) VALUES (1, 'Alice')"); err != nil {
+		t.Fatalf("Insert error: %v", err)
+	}
+	if _, err := db.Execute("UPDATE users SET id = 2, name = '' WHERE id = 1"); err == nil {
+		t.Errorf("Expected an error when updating a NOT NULL column to an empty value")
 	}
 
-	for i, row := range results {
-
-		name, ok := row["name"].(string)
-		if !ok {
-			t.Errorf("Name field is not a string in row %d", i)
-			continue
-		}
-		if name != expectedOrder[i] {
-			t.Errorf("Expected name at position %d to be %s, got %s", i, expectedOrder[i], name)
-		}
+	res, err := db.Execute("SELECT * FROM users")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(res, `"id": 1`) || !strings.Contains(res, `"name": "Alice"`) || strings.Contains(res, `"id": 2`) {
+		t.Errorf("Expected the table to be unchanged by rejected statements, got: %s", res)
 	}
 }
 
-func TestComparisonOperators(t *testing.T) {
+func TestDefaultValues(t *testing.T) {
 	defer cleanupTestDB("testdb")
 
 	db, err := database.NewDatabase("testdb")
 	if err != nil {
 		t.Fatal(err)
 	}
-	_, _ = db.Execute("CREATE TABLE users (id INT, name VARCHAR, age INT, birthdate DATE)")
-	_, _ = db.Execute("INSERT INTO users (id, name, age, birthdate) VALUES (1, 'Alice', 25,'2000-01-01')")
-	_, _ = db.Execute("INSERT INTO users (id, name, age,birthdate) VALUES (2, 'Bob', 30,'1995-03-20')")
-	_, _ = db.Execute("INSERT INTO users (id, name, age,birthdate) VALUES (3, 'Charlie', 35,'1990-03-12')")
-	_, _ = db.Execute("INSERT INTO users (id, name, age,birthdate) VALUES (4, 'David', 40,'1985-02-03')")
+	if _, err := db.Execute("CREATE TABLE users (id INT, status VARCHAR DEFAULT 'active user', created DATE DEFAULT '2020-01-01', score INT DEFAULT -5 NOT NULL)"); err != nil {
+		t.Fatalf("Create table failed: %v", err)
+	}
+	if _, err := db.Execute("INSERT INTO users (id) VALUES (1)"); err != nil {
+		t.Fatalf("Insert failed: %v", err)
+	}
+	if _, err := db.Execute("INSERT INTO users (id, status, score) VALUES (2, NULL, 7)"); err != nil {
+		t.Fatalf("Insert failed: %v", err)
+	}
+
+	rows, _, err := db.Query("SELECT * FROM users ORDER BY id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := database.Row{"id": int64(1), "status": "active user", "created": time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC), "score": int64(-5)}
+	for col, want := range expected {
+		if got := rows[0][col]; got != want {
+			t.Errorf("Column %s: expected %T %v, got %T %v", col, want, want, got, got)
+		}
+	}
+	if rows[1]["status"] != nil || rows[1]["score"] != int64(7) {
+		t.Errorf("Expected an explicit NULL and value to override the defaults, got %v", rows[1])
+	}
+
+	for _, sql := range []string{
+		"CREATE TABLE bad (id INT DEFAULT 'x')",
+		"CREATE TABLE bad (created DATE DEFAULT 'yesterday')",
+		"CREATE TABLE bad (id INT DEFAULT)",
+	} {
+		if _, err := db.Execute(sql); err == nil {
+			t.Errorf("Expected an error for %s", sql)
+		}
+	}
+}
+
+func TestEnumValues(t *testing.T) {
+	defer cleanupTestDB("testdb")
+
+	db, err := database.NewDatabase("testdb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Execute("CREATE TABLE accounts (id INT, status ENUM('active', 'inactive', 'on hold') DEFAULT 'active')"); err != nil {
+		t.Fatalf("Create table failed: %v", err)
+	}
+	tables, _ := db.AllTables()
+	if values := tables["accounts"].Columns[1].EnumValues; len(values) != 3 || values[2] != "on hold" {
+		t.Errorf("Expected the ENUM values to be parsed, got %v", values)
+	}
+
+	for _, sql := range []string{
+		"INSERT INTO accounts (id, status) VALUES (1, 'inactive')",
+		"INSERT INTO accounts (id, status) VALUES (2, 'ON HOLD')",
+		"INSERT INTO accounts (id) VALUES (3)",
+		"INSERT INTO accounts (id, status) VALUES (4, NULL)",
+	} {
+		if _, err := db.Execute(sql); err != nil {
+			t.Fatalf("Insert %q failed: %v", sql, err)
+		}
+	}
+	rows, _, err := db.Query("SELECT status FROM accounts ORDER BY id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []any{"inactive", "on hold", "active", nil}
+	for i, want := range expected {
+		if rows[i]["status"] != want {
+			t.Errorf("Row %d: expected status %v, got %v", i, want, rows[i]["status"])
+		}
+	}
+
+	_, err = db.Execute("INSERT INTO accounts (id, status) VALUES (5, 'banned')")
+	if err == nil || !strings.Contains(err.Error(), "'active', 'inactive', 'on hold'") {
+		t.Errorf("Expected an error listing the allowed values, got %v", err)
+	}
+	if _, err := db.Execute("UPDATE accounts SET status = 'banned' WHERE id = 1"); err == nil {
+		t.Error("Expected an error updating to a value outside the ENUM")
+	}
+	if _, err := db.Execute("UPDATE accounts SET status = 'Active' WHERE id = 1"); err != nil {
+		t.Errorf("Update failed: %v", err)
+	}
+	assertIDs(t, selectIDs(t, db, "SELECT * FROM accounts WHERE status = 'active'"), 1, 3)
+
+	for _, sql := range []string{
+		"CREATE TABLE bad (status ENUM())",
+		"CREATE TABLE bad (status ENUM('a', 'A'))",
+		"CREATE TABLE bad (status ENUM('a') DEFAULT 'b')",
+		"CREATE TABLE bad (id INT(3))",
+	} {
+		if _, err := db.Execute(sql); err == nil {
+			t.Errorf("Expected an error for %s", sql)
+		}
+	}
+}
+
+func TestVarcharLength(t *testing.T) {
+	defer cleanupTestDB("testdb")
+
+	db, err := database.NewDatabase("testdb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Execute("CREATE TABLE codes (id INT, code VARCHAR(5), note VARCHAR)"); err != nil {
+		t.Fatalf("Create table failed: %v", err)
+	}
+	tables, _ := db.AllTables()
+	if columns := tables["codes"].Columns; columns[1].MaxLength != 5 || columns[2].MaxLength != 0 {
+		t.Errorf("Unexpected lengths %v", columns)
+	}
+
+	long := strings.Repeat("x", 1000)
+	for _, sql := range []string{
+		"INSERT INTO codes (id, code) VALUES (1, 'abcde')",
+		"INSERT INTO codes (id, code) VALUES (2, 'héllo')",
+		"INSERT INTO codes (id, code, note) VALUES (3, '', '" + long + "')",
+	} {
+		if _, err := db.Execute(sql); err != nil {
+			t.Errorf("Insert %q failed: %v", sql, err)
+		}
+	}
+	if _, err := db.Execute("INSERT INTO codes (id, code) VALUES (4, 'abcdef')"); err == nil || !strings.Contains(err.Error(), "limit is 5") {
+		t.Errorf("Expected a length error, got %v", err)
+	}
+	if _, err := db.Execute("UPDATE codes SET code = 'abcdef' WHERE id = 1"); err == nil {
+		t.Error("Expected a length error on update")
+	}
+	assertIDs(t, selectIDs(t, db, "SELECT * FROM codes WHERE code = 'abcde'"), 1)
+
+	for _, sql := range []string{
+		"CREATE TABLE bad (code VARCHAR(0))",
+		"CREATE TABLE bad (code VARCHAR(x))",
+		"CREATE TABLE bad (code VARCHAR(2) DEFAULT 'abc')",
+	} {
+		if _, err := db.Execute(sql); err == nil {
+			t.Errorf("Expected an error for %s", sql)
+		}
+	}
+}
+
+func TestDecimal(t *testing.T) {
+	defer cleanupTestDB("testdb")
+
+	db, err := database.NewDatabase("testdb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Execute("CREATE TABLE payments (id INT, amount DECIMAL(6,2), rate NUMERIC(4))"); err != nil {
+		t.Fatalf("Create table failed: %v", err)
+	}
+	tables, _ := db.AllTables()
+	if columns := tables["payments"].Columns; columns[1].Precision != 6 || columns[1].Scale != 2 || columns[2].Type != database.COLUMN_TYPE_DECIMAL || columns[2].Scale != 0 {
+		t.Errorf("Unexpected columns %v", columns)
+	}
+	for _, sql := range []string{
+		"INSERT INTO payments (id, amount) VALUES (1, 0.1)",
+		"INSERT INTO payments (id, amount) VALUES (2, 0.2)",
+		"INSERT INTO payments (id, amount) VALUES (3, 1234.005)",
+		"INSERT INTO payments (id, amount) VALUES (4, -5)",
+	} {
+		if _, err := db.Execute(sql); err != nil {
+			t.Errorf("Insert %q failed: %v", sql, err)
+		}
+	}
+	if _, err := db.Execute("INSERT INTO payments (id, amount) VALUES (5, 10000)"); err == nil || !strings.Contains(err.Error(), "does not fit") {
+		t.Errorf("Expected a precision error, got %v", err)
+	}
+	if _, err := db.Execute("INSERT INTO payments (id, amount) VALUES (5, 'abc')"); err == nil {
+		t.Error("Expected an invalid decimal error")
+	}
+
+	// Values are rounded to the scale and compare exactly
+	rows, _, err := db.Query("SELECT amount FROM payments WHERE id = 3")
+	if err != nil || len(rows) != 1 || rows[0]["amount"] != (database.Decimal{Unscaled: 123401, Scale: 2}) {
+		t.Errorf("Expected 1234.01, got %v %v", rows, err)
+	}
+	assertIDs(t, selectIDs(t, db, "SELECT * FROM payments WHERE amount = 0.10"), 1)
+	assertIDs(t, selectIDs(t, db, "SELECT * FROM payments WHERE amount > 0.1"), 2, 3)
+	assertIDs(t, selectIDs(t, db, "SELECT * FROM payments ORDER BY amount DESC"), 3, 2, 1, 4)
+
+	row, _, err := db.Query("SELECT SUM(amount) FROM payments WHERE id < 3")
+	if err != nil || row[0]["SUM(amount)"] != (database.Decimal{Unscaled: 30, Scale: 2}) {
+		t.Errorf("Expected an exact sum of 0.30, got %v %v", row, err)
+	}
+	if res, _ := db.Execute("SELECT SUM(amount) FROM payments WHERE id < 3"); !strings.Contains(res, "0.30") {
+		t.Errorf("Expected 0.30 in the result, got %s", res)
+	}
+
+	// Values survive saving and loading
+	reopened, err := database.NewDatabase("testdb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows, _, err = reopened.Query("SELECT amount FROM payments WHERE id = 4")
+	if err != nil || len(rows) != 1 || rows[0]["amount"] != (database.Decimal{Unscaled: -500, Scale: 2}) {
+		t.Errorf("Expected -5.00 after reloading, got %v %v", rows, err)
+	}
+
+	for _, sql := range []string{
+		"CREATE TABLE bad (amount DECIMAL(0))",
+		"CREATE TABLE bad (amount DECIMAL(19,2))",
+		"CREATE TABLE bad (amount DECIMAL(4,5))",
+		"CREATE TABLE bad (amount DECIMAL(4,2) DEFAULT 100)",
+	} {
+		if _, err := db.Execute(sql); err == nil {
+			t.Errorf("Expected an error for %s", sql)
+		}
+	}
+}
+
+func TestTimestamp(t *testing.T) {
+	defer cleanupTestDB("testdb")
+
+	db, err := database.NewDatabase("testdb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Execute("CREATE TABLE events (id INT, created TIMESTAMP, updated DATETIME)"); err != nil {
+		t.Fatalf("Create table failed: %v", err)
+	}
+	for _, sql := range []string{
+		"INSERT INTO events (id, created) VALUES (1, '2020-01-01 09:30:00')",
+		"INSERT INTO events (id, created) VALUES (2, '2019-12-31 23:59:59')",
+		"INSERT INTO events (id, created) VALUES (3, '2020-01-01')",
+		"INSERT INTO events (id, created) VALUES (4, '2021-06-15 18:00:00')",
+	} {
+		if _, err := db.Execute(sql); err != nil {
+			t.Errorf("Insert %q failed: %v", sql, err)
+		}
+	}
+	if _, err := db.Execute("INSERT INTO events (id, created) VALUES (5, '2020-01-01 25:00:00')"); err == nil {
+		t.Error("Expected an invalid timestamp error")
+	}
+
+	assertIDs(t, selectIDs(t, db, "SELECT * FROM events WHERE created > '2020-01-01 00:00:00'"), 1, 4)
+	assertIDs(t, selectIDs(t, db, "SELECT * FROM events WHERE created = '2020-01-01'"), 3)
+	assertIDs(t, selectIDs(t, db, "SELECT * FROM events WHERE created BETWEEN '2020-01-01' AND '2020-12-31'"), 1, 3)
+	assertIDs(t, selectIDs(t, db, "SELECT * FROM events ORDER BY created"), 2, 3, 1, 4)
+
+	// A date is stored as its midnight, and the values survive reloading
+	reopened, err := database.NewDatabase("testdb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows, _, err := reopened.Query("SELECT created FROM events WHERE id = 3")
+	if err != nil || len(rows) != 1 || rows[0]["created"] != time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC) {
+		t.Errorf("Expected the midnight timestamp, got %v %v", rows, err)
+	}
+
+	// NOW() and CURRENT_TIMESTAMP keep the time of day
+	for id, now := range []string{"NOW()", "CURRENT_TIMESTAMP"} {
+		if _, err := reopened.Execute(fmt.Sprintf("INSERT INTO events (id, created) VALUES (%d, %s)", 10+id, now)); err != nil {
+			t.Fatalf("Insert with %s failed: %v", now, err)
+		}
+		rows, _, err := reopened.Query(fmt.Sprintf("SELECT created FROM events WHERE id = %d", 10+id))
+		if err != nil || len(rows) != 1 {
+			t.Fatalf("Query failed: %v %v", rows, err)
+		}
+		clock := time.Now()
+		clock = time.Date(clock.Year(), clock.Month(), clock.Day(), clock.Hour(), clock.Minute(), clock.Second(), 0, time.UTC)
+		if created, ok := rows[0]["created"].(time.Time); !ok || clock.Sub(created).Abs() > 5*time.Second {
+			t.Errorf("Expected %s to store the time now, got %v", now, rows[0]["created"])
+		}
+	}
+
+	tables, _ := reopened.AllTables()
+	if columns := tables["events"].Columns; columns[1].Type != database.COLUMN_TYPE_TIMESTAMP || columns[2].Type != database.COLUMN_TYPE_TIMESTAMP {
+		t.Errorf("Unexpected column types %v", columns)
+	}
+}
+
+func TestForeignKey(t *testing.T) {
+	defer cleanupTestDB("testdb")
+	db, err := database.NewDatabase("testdb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = db.Execute("CREATE TABLE users (id INT, name VARCHAR)")
+	_, _ = db.Execute("CREATE TABLE posts (id INT, user_id INT FOREIGN KEY REFERENCES users(id) , title VARCHAR)")
+	_, _ = db.Execute("INSERT INTO users (id, name) VALUES (1, 'Alice')")
+	_, _ = db.Execute("INSERT INTO posts (id, user_id, title) VALUES (1, 1, 'Hello')")
+
+	res, err := db.Execute("SELECT * FROM posts where user_id = 1")
+	if err != nil {
+		t.Fatalf("Select with where error: %v", err)
+	}
+	if !strings.Contains(res, `"user_id": 1`) {
+		t.Errorf("Expected result to contain user_id 1, got: %s", res)
+	}
+}
+
+func TestForeignKeyOnDelete(t *testing.T) {
+	defer cleanupTestDB("testdb")
+	db, err := database.NewDatabase("testdb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = db.Execute("CREATE TABLE users (id INT PRIMARY KEY, name VARCHAR)")
+	_, _ = db.Execute("CREATE TABLE posts (id INT PRIMARY KEY, user_id INT FOREIGN KEY REFERENCES users(id) ON DELETE CASCADE, title VARCHAR)")
+	_, _ = db.Execute("CREATE TABLE comments (id INT, post_id INT FOREIGN KEY REFERENCES posts(id) ON DELETE CASCADE)")
+	_, _ = db.Execute("CREATE TABLE sessions (id INT, user_id INT FOREIGN KEY REFERENCES users(id))")
+	_, _ = db.Execute("INSERT INTO users (id, name) VALUES (1, 'Alice')")
+	_, _ = db.Execute("INSERT INTO users (id, name) VALUES (2, 'Bob')")
+	_, _ = db.Execute("INSERT INTO posts (id, user_id, title) VALUES (10, 1, 'Hello')")
+	_, _ = db.Execute("INSERT INTO posts (id, user_id, title) VALUES (11, 2, 'World')")
+	_, _ = db.Execute("INSERT INTO comments (id, post_id) VALUES (100, 10)")
+	_, _ = db.Execute("INSERT INTO comments (id, post_id) VALUES (101, 11)")
+	_, _ = db.Execute("INSERT INTO sessions (id, user_id) VALUES (1000, 2)")
+
+	// Sessions restrict by default, nothing is removed
+	if _, err := db.Execute("DELETE FROM users WHERE id = 2"); err == nil || !strings.Contains(err.Error(), "sessions.user_id") {
+		t.Errorf("Expected the referenced user to be kept, got %v", err)
+	}
+	assertIDs(t, selectIDs(t, db, "SELECT * FROM posts"), 10, 11)
+	assertIDs(t, selectIDs(t, db, "SELECT * FROM comments"), 100, 101)
+
+	// Posts and their comments cascade
+	if _, err := db.Execute("DELETE FROM users WHERE id = 1"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	assertIDs(t, selectIDs(t, db, "SELECT * FROM users"), 2)
+	assertIDs(t, selectIDs(t, db, "SELECT * FROM posts"), 11)
+	assertIDs(t, selectIDs(t, db, "SELECT * FROM comments"), 101)
+
+	if _, err := db.Execute("DELETE FROM sessions WHERE id = 1000"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if _, err := db.Execute("DELETE FROM users WHERE id = 2"); err != nil {
+		t.Fatalf("Expected the user to be deletable once unreferenced, got %v", err)
+	}
+	assertIDs(t, selectIDs(t, db, "SELECT * FROM comments"))
+
+	for _, sql := range []string{
+		"CREATE TABLE bad (user_id INT FOREIGN KEY REFERENCES users(id) ON DELETE)",
+		"CREATE TABLE bad (user_id INT FOREIGN KEY REFERENCES users(id) ON DELETE NOTHING)",
+	} {
+		if _, err := db.Execute(sql); err == nil {
+			t.Errorf("Expected an error for %s", sql)
+		}
+	}
+}
+
+func TestSelectJoin(t *testing.T) {
+	defer cleanupTestDB("testdb")
+	db, err := database.NewDatabase("testdb")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, _ = db.Execute("CREATE TABLE users (id INT, name VARCHAR)")
+	_, _ = db.Execute("CREATE TABLE posts (id INT, user_id INT FOREIGN KEY REFERENCES users(id), title VARCHAR)")
+	_, _ = db.Execute("INSERT INTO users (id, name) VALUES (1, 'Alice')")
+	_, _ = db.Execute("INSERT INTO users (id, name) VALUES (2, 'Bob')")
+	_, _ = db.Execute("INSERT INTO posts (id, user_id, title) VALUES (1, 1, 'Hello')")
+	_, _ = db.Execute("INSERT INTO posts (id, user_id, title) VALUES (2, 2, 'World')")
+
+	res, err := db.Execute("SELECT posts.title, users.name FROM posts JOIN users ON posts.user_id = users.id")
+	if err != nil {
+		t.Fatalf("Select with join error: %v", err)
+	}
+
+	if !strings.Contains(res, `"posts.title": "Hello"`) || !strings.Contains(res, `"users.name": "Alice"`) {
+		t.Errorf("Expected result to contain post 'Hello' by 'Alice', got: %s", res)
+	}
+	if !strings.Contains(res, `"posts.title": "World"`) || !strings.Contains(res, `"users.name": "Bob"`) {
+		t.Errorf("Expected result to contain post 'World' by 'Bob', got: %s", res)
+	}
+}
+
+func TestSelectLimit(t *testing.T) {
+	defer cleanupTestDB("testdb")
+	db, err := database.NewDatabase("testdb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = db.Execute("CREATE TABLE users (id INT, name VARCHAR)")
+	_, _ = db.Execute("INSERT INTO users (id, name) VALUES (1, 'Alice')")
+	_, _ = db.Execute("INSERT INTO users (id, name) VALUES (2, 'Bob')")
+	_, _ = db.Execute("INSERT INTO users (id, name) VALUES (3, 'Charlie')")
+	_, _ = db.Execute("INSERT INTO users (id, name) VALUES (4, 'David')")
+
+	res, err := db.Execute(fmt.Sprintf("SELECT * FROM users LIMIT %d", 3))
+	if err != nil {
+		t.Fatalf("Select with limit error: %v", err)
+	}
+	if !strings.Contains(res, `"id": 1`) || !strings.Contains(res, `"id": 2`) || !strings.Contains(res, `"id": 3`) {
+		t.Errorf("Expected result to contain id 1, 2, 3, got: %s", res)
+	}
+	if strings.Contains(res, `"id": 4`) {
+		t.Errorf("Expected result to not contain id 4, got: %s", res)
+	}
+}
+
+func TestSelectOrderBy(t *testing.T) {
+	defer cleanupTestDB("testdb")
+	db, err := database.NewDatabase("testdb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = db.Execute("CREATE TABLE users (id INT, name VARCHAR)")
+	_, _ = db.Execute("INSERT INTO users (id, name) VALUES (1, 'Charlie')")
+	_, _ = db.Execute("INSERT INTO users (id, name) VALUES (2, 'Alice')")
+	_, _ = db.Execute("INSERT INTO users (id, name) VALUES (3, 'David')")
+	_, _ = db.Execute("INSERT INTO users (id, name) VALUES (4, 'Bob')")
+
+	res, err := db.Execute("SELECT * FROM users ORDER BY name")
+	if err != nil {
+		t.Fatalf("Select with order by error: %v", err)
+	}
+
+	// Check that results are in alphabetical order by name
+	expectedOrder := []string{"Alice", "Bob", "Charlie", "David"}
+	var results []map[string]interface{}
+	if err := json.Unmarshal([]byte(res), &results); err != nil {
+		t.Fatalf("Failed to unmarshal results: %v", err)
+	}
+
+	if len(results) != len(expectedOrder) {
+		t.Fatalf("Expected %d results, got %d", len(expectedOrder), len(results))
+	}
+
+	for i, row := range results {
+
+		name, ok := row["name"].(string)
+		if !ok {
+			t.Errorf("Name field is not a string in row %d", i)
+			continue
+		}
+		if name != expectedOrder[i] {
+			t.Errorf("Expected name at position %d to be %s, got %s", i, expectedOrder[i], name)
+		}
+	}
+}
+
+func TestSelectOrderByMultiple(t *testing.T) {
+	defer cleanupTestDB("testdb")
+	db, err := database.NewDatabase("testdb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = db.Execute("CREATE TABLE users (id INT, name VARCHAR, age INT)")
+	for _, values := range []string{"1, 'Carol', 30", "2, 'Alice', 25", "3, 'Bob', 30", "4, 'Alice', 30", "5, 'Dave', NULL", "6, 'Bob', 30"} {
+		_, _ = db.Execute("INSERT INTO users (id, name, age) VALUES (" + values + ")")
+	}
+
+	tests := []struct {
+		query    string
+		expected []int
+	}{
+		{"SELECT * FROM users ORDER BY age DESC, name ASC", []int{5, 4, 3, 6, 1, 2}},
+		{"SELECT * FROM users ORDER BY age, name DESC", []int{2, 1, 3, 6, 4, 5}},
+		// Rows equal on every key keep their table order
+		{"SELECT * FROM users ORDER BY name, age", []int{2, 4, 3, 6, 1, 5}},
+		{"SELECT * FROM users ORDER BY age DESC, name LIMIT 2 OFFSET 1", []int{4, 3}},
+	}
+	for _, tt := range tests {
+		assertIDs(t, selectIDs(t, db, tt.query), tt.expected...)
+	}
+
+	for _, query := range []string{
+		"SELECT * FROM users ORDER BY age, missing",
+		"SELECT * FROM users ORDER BY age SIDEWAYS, name",
+		"SELECT * FROM users ORDER BY age,",
+	} {
+		if _, err := db.Execute(query); err == nil {
+			t.Errorf("Expected an error for %q", query)
+		}
+	}
+}
+
+func TestSelectOrderByNotSelected(t *testing.T) {
+	defer cleanupTestDB("testdb")
+	db := newBlogDB(t)
+	_, _ = db.Execute("ALTER TABLE users ADD COLUMN age INT")
+	_, _ = db.Execute("UPDATE users SET age = 40 WHERE id = 1")
+	_, _ = db.Execute("UPDATE users SET age = 20 WHERE id = 2")
+	_, _ = db.Execute("UPDATE users SET age = 30 WHERE id = 3")
+
+	names := func(query string) []any {
+		t.Helper()
+		var names []any
+		for _, row := range selectRows(t, db, query) {
+			names = append(names, row["name"])
+		}
+		return names
+	}
+	tests := []struct {
+		query    string
+		expected []any
+	}{
+		{"SELECT name FROM users ORDER BY age", []any{"Bob", "Carol", "Alice"}},
+		{"SELECT name FROM users ORDER BY age DESC LIMIT 2", []any{"Alice", "Carol"}},
+		{"SELECT users.name AS name FROM posts JOIN users ON posts.user_id = users.id ORDER BY posts.title", []any{"Alice", "Alice", "Bob"}},
+		// An alias takes precedence over the column it shares its name with
+		{"SELECT age AS name FROM users ORDER BY name", []any{float64(20), float64(30), float64(40)}},
+	}
+	for _, tt := range tests {
+		if got := names(tt.query); fmt.Sprint(got) != fmt.Sprint(tt.expected) {
+			t.Errorf("%s: expected %v, got %v", tt.query, tt.expected, got)
+		}
+	}
+
+	for _, query := range []string{
+		"SELECT name FROM users ORDER BY missing",
+		"SELECT user_id, COUNT(*) FROM posts GROUP BY user_id ORDER BY title",
+	} {
+		if _, err := db.Execute(query); err == nil {
+			t.Errorf("Expected an error for %q", query)
+		}
+	}
+}
+
+func TestComparisonOperators(t *testing.T) {
+	defer cleanupTestDB("testdb")
+
+	db, err := database.NewDatabase("testdb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = db.Execute("CREATE TABLE users (id INT, name VARCHAR, age INT, birthdate DATE)")
+	_, _ = db.Execute("INSERT INTO users (id, name, age, birthdate) VALUES (1, 'Alice', 25,'2000-01-01')")
+	_, _ = db.Execute("INSERT INTO users (id, name, age,birthdate) VALUES (2, 'Bob', 30,'1995-03-20')")
+	_, _ = db.Execute("INSERT INTO users (id, name, age,birthdate) VALUES (3, 'Charlie', 35,'1990-03-12')")
+	_, _ = db.Execute("INSERT INTO users (id, name, age,birthdate) VALUES (4, 'David', 40,'1985-02-03')")
 
 	tests := []struct {
 		name     string
@@ -479,7 +1324,7 @@ func TestComparisonOperators(t *testing.T) {
 		{"Not equal", "SELECT * FROM users WHERE age != 30", []int{1, 3, 4}},
 		{"String less than", "SELECT * FROM users WHERE name < 'Bob'", []int{1}},
 		{"String greater than", "SELECT * FROM users WHERE name > 'Bob'", []int{3, 4}},
-		{"Strign Like", "SELECT * FROM users WHERE name LIKE 'li'", []int{1, 3}},
+		{"Strign Like", "SELECT * FROM users WHERE name LIKE '%li%'", []int{1, 3}},
 	}
 
 	for _, tt := range tests {
@@ -533,3 +1378,467 @@ func TestConcurrentInserts(t *testing.T) {
 		}
 	}
 }
+
+func TestConcurrentAutoIncrement(t *testing.T) {
+	defer cleanupTestDB("testdbconcurrent")
+	db, err := database.NewDatabase("testdbconcurrent")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = db.Execute("CREATE TABLE events (id INT PRIMARY KEY AUTO_INCREMENT, name VARCHAR)")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := db.Execute("INSERT INTO events (name) VALUES ('tick')"); err != nil {
+				t.Errorf("Insert failed: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	count, err := db.Tables["events"].Count("")
+	if err != nil || count != 20 {
+		t.Errorf("Expected 20 events with distinct ids, got %d (%v)", count, err)
+	}
+}
+
+func TestConcurrentMutations(t *testing.T) {
+	defer cleanupTestDB("testdbconcurrent")
+	db, err := database.NewDatabase("testdbconcurrent")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = db.Execute("CREATE TABLE users (id INT PRIMARY KEY, name VARCHAR)")
+	_, _ = db.Execute("CREATE TABLE posts (id INT, user_id INT)")
+	insert, err := db.Prepare("INSERT INTO posts (id, user_id) VALUES (?, ?)")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for _, sql := range []string{
+				fmt.Sprintf("INSERT INTO users (id, name) VALUES (%d, 'User%d')", i, i),
+				fmt.Sprintf("UPDATE users SET name = 'Renamed%d' WHERE id = %d", i, i),
+				fmt.Sprintf("SELECT * FROM users WHERE id = %d", i),
+				// The subquery reads posts while the delete holds the lock
+				fmt.Sprintf("DELETE FROM users WHERE id = %d AND NOT EXISTS (SELECT 1 FROM posts WHERE posts.user_id = users.id)", i),
+				fmt.Sprintf("CREATE TABLE extra%d (id INT)", i),
+				fmt.Sprintf("CREATE INDEX extra%d_id ON extra%d (id)", i, i),
+				fmt.Sprintf("ALTER TABLE extra%d ADD COLUMN note VARCHAR", i),
+				fmt.Sprintf("DROP TABLE extra%d", i),
+			} {
+				if _, err := db.Execute(sql); err != nil {
+					t.Errorf("%s failed: %v", sql, err)
+				}
+			}
+			if _, err := insert.Exec(i, i); err != nil {
+				t.Errorf("Prepared insert failed: %v", err)
+			}
+			if _, _, err := db.InsertRows("posts", []string{"id", "user_id"}, [][]string{{"100", "100"}}, false); err != nil {
+				t.Errorf("InsertRows failed: %v", err)
+			}
+			if _, _, err := db.Query("SELECT COUNT(*) FROM posts"); err != nil {
+				t.Errorf("Query failed: %v", err)
+			}
+			if _, err := db.DryRun("DELETE FROM posts WHERE user_id = 100"); err != nil {
+				t.Errorf("DryRun failed: %v", err)
+			}
+			if err := db.Dump(io.Discard); err != nil {
+				t.Errorf("Dump failed: %v", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	count, err := db.Tables["posts"].Count("")
+	if err != nil || count != 20 {
+		t.Errorf("Expected 20 posts, got %d (%v)", count, err)
+	}
+	if count, _ := db.Tables["users"].Count(""); count != 0 {
+		t.Errorf("Expected every user to be deleted, got %d", count)
+	}
+}
+
+func TestExecElapsedTime(t *testing.T) {
+	defer cleanupTestDB("testdb")
+
+	db, err := database.NewDatabase("testdb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = db.Execute("CREATE TABLE users (id INT, name VARCHAR)")
+	_, _ = db.Execute("INSERT INTO users (id, name) VALUES (1, 'Alice')")
+
+	result, err := db.Exec("SELECT * FROM users")
+	if err != nil {
+		t.Fatalf("Exec error: %v", err)
+	}
+	if result.ElapsedTime <= 0 {
+		t.Errorf("Expected a positive elapsed time, got: %v", result.ElapsedTime)
+	}
+	if !strings.Contains(result.Output, `"name": "Alice"`) {
+		t.Errorf("Unexpected output: %s", result.Output)
+	}
+}
+
+func TestMutationResults(t *testing.T) {
+	defer cleanupTestDB("testdb")
+	db, err := database.NewDatabase("testdb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = db.Execute("CREATE TABLE posts (id INT AUTO_INCREMENT, title VARCHAR)")
+	_, _ = db.Execute("CREATE TABLE tags (name VARCHAR)")
+
+	for i, title := range []string{"'Hello'", "'Again'"} {
+		result, err := db.Exec("INSERT INTO posts (title) VALUES (" + title + ")")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.Output != "1 row inserted" || result.RowsAffected != 1 || result.LastInsertId != int64(i+1) {
+			t.Errorf("Unexpected insert result %+v", result)
+		}
+	}
+	result, err := db.InsertResult("tags", []string{"name"}, []string{"'go'"})
+	if err != nil || result.RowsAffected != 1 || result.LastInsertId != 0 {
+		t.Errorf("Expected no id from a table without AUTO_INCREMENT, got %+v (%v)", result, err)
+	}
+
+	result, err = db.UpdateResult("posts", "title = 'Hi'", "id > 0")
+	if err != nil || result.Output != "2 rows updated" || result.RowsAffected != 2 {
+		t.Errorf("Unexpected update result %+v (%v)", result, err)
+	}
+	result, err = db.DeleteResult("posts", "id = 1")
+	if err != nil || result.Output != "1 row deleted" || result.RowsAffected != 1 {
+		t.Errorf("Unexpected delete result %+v (%v)", result, err)
+	}
+	result, err = db.Exec("DELETE FROM posts WHERE id = 7")
+	if err != nil || result.RowsAffected != 0 {
+		t.Errorf("Unexpected delete result %+v (%v)", result, err)
+	}
+	result, err = db.TruncateResult("posts")
+	if err != nil || result.RowsAffected != 1 {
+		t.Errorf("Unexpected truncate result %+v (%v)", result, err)
+	}
+	result, err = db.Exec("SELECT * FROM tags")
+	if err != nil || result.RowsAffected != 0 {
+		t.Errorf("Expected a SELECT to affect no rows, got %+v (%v)", result, err)
+	}
+
+	insert, err := db.Prepare("INSERT INTO posts (title) VALUES (?)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result, err := insert.Exec("Fresh"); err != nil || result.RowsAffected != 1 || result.LastInsertId != 1 {
+		t.Errorf("Unexpected prepared insert result %+v (%v)", result, err)
+	}
+	if _, err := db.InsertResult("missing", nil, []string{"1"}); err == nil {
+		t.Error("Expected an error for a missing table")
+	}
+}
+
+func TestNegativeNumbers(t *testing.T) {
+	defer cleanupTestDB("testdb")
+	db, _ := database.NewDatabase("testdb")
+	_, _ = db.Execute("CREATE TABLE accounts (id INT, balance INT, rate DOUBLE, fee FLOAT)")
+	for _, sql := range []string{
+		"INSERT INTO accounts (id, balance, rate, fee) VALUES (1, -50, -1.5, -0.25)",
+		"INSERT INTO accounts (id, balance, rate, fee) VALUES (2, 20, 2.5, 1.5)",
+		"INSERT INTO accounts (id, balance, rate, fee) VALUES (3, - 5, -0.5, -3)",
+	} {
+		if _, err := db.Execute(sql); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+	}
+
+	assertIDs(t, selectIDs(t, db, "SELECT * FROM accounts WHERE balance < -10"), 1)
+	assertIDs(t, selectIDs(t, db, "SELECT * FROM accounts WHERE balance >= -5"), 2, 3)
+	assertIDs(t, selectIDs(t, db, "SELECT * FROM accounts WHERE balance = -50"), 1)
+	assertIDs(t, selectIDs(t, db, "SELECT * FROM accounts WHERE rate > - 1"), 2, 3)
+	assertIDs(t, selectIDs(t, db, "SELECT * FROM accounts WHERE fee <= -0.25"), 1, 3)
+	assertIDs(t, selectIDs(t, db, "SELECT * FROM accounts WHERE balance BETWEEN -60 AND -1"), 1, 3)
+
+	if _, err := db.Execute("UPDATE accounts SET balance = -70 WHERE id = 2"); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	assertIDs(t, selectIDs(t, db, "SELECT * FROM accounts ORDER BY balance"), 2, 1, 3)
+	assertIDs(t, selectIDs(t, db, "SELECT * FROM accounts ORDER BY rate DESC"), 2, 3, 1)
+	assertIDs(t, selectIDs(t, db, "SELECT * FROM accounts ORDER BY fee"), 3, 1, 2)
+}
+
+func TestLimitOffset(t *testing.T) {
+	defer cleanupTestDB("testdb")
+	db := newPeopleDB(t)
+
+	tests := []struct {
+		name     string
+		query    string
+		expected []int
+	}{
+		{"LIMIT after ORDER BY", "SELECT * FROM people ORDER BY age DESC LIMIT 2", []int{4, 3}},
+		{"LIMIT and OFFSET", "SELECT * FROM people ORDER BY age LIMIT 2 OFFSET 1", []int{2, 3}},
+		{"OFFSET without LIMIT", "SELECT * FROM people ORDER BY age DESC OFFSET 3", []int{1}},
+		{"OFFSET with WHERE", "SELECT * FROM people WHERE age > 25 LIMIT 5 OFFSET 1", []int{3, 4}},
+		{"OFFSET past the end", "SELECT * FROM people LIMIT 2 OFFSET 10", []int{}},
+		{"LIMIT offset, count", "SELECT * FROM people ORDER BY age LIMIT 1, 2", []int{2, 3}},
+		{"LIMIT offset, count past the end", "SELECT * FROM people LIMIT 10,2", []int{}},
+		{"LIMIT 0", "SELECT * FROM people LIMIT 0", []int{}},
+		{"LIMIT 0 with OFFSET", "SELECT * FROM people ORDER BY age LIMIT 0 OFFSET 1", []int{}},
+		{"LIMIT offset, 0", "SELECT * FROM people LIMIT 1, 0", []int{}},
+		{"LIMIT 0 with GROUP BY", "SELECT id FROM people GROUP BY id LIMIT 0", []int{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assertIDs(t, selectIDs(t, db, tt.query), tt.expected...)
+		})
+	}
+
+	res, err := db.Execute("SELECT * FROM people OFFSET 4")
+	if err != nil || res != "[]" {
+		t.Errorf("Expected an empty result, got %q, %v", res, err)
+	}
+	for _, query := range []string{
+		"SELECT * FROM people LIMIT 2 OFFSET -1",
+		"SELECT * FROM people OFFSET ten",
+	} {
+		if _, err := db.Execute(query); err == nil || !strings.HasPrefix(err.Error(), "invalid offset clause") {
+			t.Errorf("Expected an offset error for %q, got %v", query, err)
+		}
+	}
+	if _, err := db.Execute("SELECT * FROM people LIMIT 1, 2 OFFSET 1"); err == nil {
+		t.Error("Expected an error for LIMIT offset, count with OFFSET")
+	}
+}
+
+func TestPagination(t *testing.T) {
+	defer cleanupTestDB("testdb")
+	db, err := database.NewDatabase("testdb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = db.Execute("CREATE TABLE items (id INT, name VARCHAR)")
+	for i := 25; i >= 1; i-- {
+		if _, err := db.Execute(fmt.Sprintf("INSERT INTO items (id, name) VALUES (%d, 'Item %d')", i, i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, page := range []func(offset int) string{
+		func(offset int) string {
+			return fmt.Sprintf("SELECT * FROM items ORDER BY id LIMIT 10 OFFSET %d", offset)
+		},
+		func(offset int) string { return fmt.Sprintf("SELECT * FROM items ORDER BY id LIMIT %d, 10", offset) },
+	} {
+		var seen []int
+		for offset := 0; offset < 30; offset += 10 {
+			ids := selectIDs(t, db, page(offset))
+			if len(ids) > 10 {
+				t.Fatalf("Expected at most 10 rows from %q, got %v", page(offset), ids)
+			}
+			seen = append(seen, ids...)
+		}
+		if len(seen) != 25 {
+			t.Fatalf("Expected 25 rows across the pages, got %v", seen)
+		}
+		for i, id := range seen {
+			if id != i+1 {
+				t.Fatalf("Expected every id once and in order, got %v", seen)
+			}
+		}
+	}
+}
+
+func TestSaveReplacesFileAtomically(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "app")
+	db, err := database.NewDatabase(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = db.Execute("CREATE TABLE users (id INT, name VARCHAR)")
+	for i := 1; i <= 3; i++ {
+		if _, err := db.Execute(fmt.Sprintf("INSERT INTO users (id, name) VALUES (%d, 'User')", i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Name() != "app.gob" {
+		t.Errorf("Expected only app.gob in the directory, got %v", entries)
+	}
+	reopened, err := database.NewDatabase(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows, _, err := reopened.Query("SELECT * FROM users")
+	if err != nil || len(rows) != 3 {
+		t.Errorf("Expected 3 saved rows, got %v (%v)", rows, err)
+	}
+}
+
+func TestImportStatement(t *testing.T) {
+	defer cleanupTestDB("testdb")
+	db, err := database.NewDatabase("testdb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = db.Execute("CREATE TABLE users (id INT PRIMARY KEY, name VARCHAR, born DATE, active BOOL)")
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	// The header may list the columns in any order, an empty field leaves a column unset
+	path := write("users.csv", "name,id,born,active\n\"Smith, Jr.\",1,1990-05-01,true\nBob,2,,false\n")
+	res, err := db.Execute(fmt.Sprintf("IMPORT INTO users FROM '%s'", path))
+	if err != nil || res != "2 rows imported" {
+		t.Fatalf("Expected 2 rows imported, got %q (%v)", res, err)
+	}
+	rows, _, err := db.Query("SELECT * FROM users ORDER BY id")
+	if err != nil || len(rows) != 2 {
+		t.Fatalf("Expected 2 rows, got %v (%v)", rows, err)
+	}
+	if rows[0]["name"] != "Smith, Jr." || rows[0]["born"] != time.Date(1990, 5, 1, 0, 0, 0, 0, time.UTC) || rows[0]["active"] != true {
+		t.Errorf("Unexpected first row %v", rows[0])
+	}
+	if _, exists := rows[1]["born"]; exists {
+		t.Errorf("Expected no birth date for Bob, got %v", rows[1])
+	}
+
+	// A bad row stops the import before any row is kept
+	tests := []struct {
+		content string
+		message string
+	}{
+		{"id,name\n3,Carol\nfour,Dave\n", "line 3"},
+		{"id,name\n3,Carol\n1,Again\n", "line 3"},
+		{"id,name\n3,Carol\n4,Dave,extra\n", "line 3"},
+		{"id,name\n3,\"Carol\n", "line 2"},
+		{"id,nickname\n3,Caz\n", "no column nickname"},
+	}
+	for i, tt := range tests {
+		path := write(fmt.Sprintf("bad%d.csv", i), tt.content)
+		_, err := db.Execute(fmt.Sprintf("IMPORT INTO users FROM '%s'", path))
+		if err == nil || !strings.Contains(err.Error(), tt.message) {
+			t.Errorf("Expected an error with %q for %q, got %v", tt.message, tt.content, err)
+		}
+	}
+	if rows, _, _ := db.Query("SELECT * FROM users"); len(rows) != 2 {
+		t.Errorf("Expected failed imports to keep no rows, got %v", rows)
+	}
+
+	for _, query := range []string{
+		"IMPORT INTO missing FROM 'users.csv'",
+		fmt.Sprintf("IMPORT INTO users FROM '%s'", filepath.Join(dir, "none.csv")),
+		"IMPORT INTO users FROM users.csv",
+	} {
+		if _, err := db.Execute(query); err == nil {
+			t.Errorf("Expected an error for %q", query)
+		}
+	}
+}
+
+func TestOrderByNulls(t *testing.T) {
+	defer cleanupTestDB("testdb")
+	db, err := database.NewDatabase("testdb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = db.Execute("CREATE TABLE tasks (id INT, priority INT)")
+	// Tasks 2 and 5 have no priority at all, task 4 has an explicit NULL
+	for _, sql := range []string{
+		"INSERT INTO tasks (id, priority) VALUES (1, 3)",
+		"INSERT INTO tasks (id) VALUES (2)",
+		"INSERT INTO tasks (id, priority) VALUES (3, 1)",
+		"INSERT INTO tasks (id, priority) VALUES (4, NULL)",
+		"INSERT INTO tasks (id) VALUES (5)",
+		"INSERT INTO tasks (id, priority) VALUES (6, 2)",
+	} {
+		if _, err := db.Execute(sql); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Missing values are nulls, which keep their table order among themselves
+	tests := []struct {
+		query    string
+		expected []int
+	}{
+		{"SELECT * FROM tasks ORDER BY priority", []int{3, 6, 1, 2, 4, 5}},
+		{"SELECT * FROM tasks ORDER BY priority DESC", []int{2, 4, 5, 1, 6, 3}},
+		{"SELECT * FROM tasks ORDER BY priority NULLS FIRST", []int{2, 4, 5, 3, 6, 1}},
+		{"SELECT * FROM tasks ORDER BY priority ASC nulls last", []int{3, 6, 1, 2, 4, 5}},
+		{"SELECT * FROM tasks ORDER BY priority DESC NULLS LAST", []int{1, 6, 3, 2, 4, 5}},
+		{"SELECT * FROM tasks ORDER BY priority DESC NULLS FIRST, id DESC LIMIT 4", []int{5, 4, 2, 1}},
+	}
+	for _, tt := range tests {
+		for i := 0; i < 3; i++ {
+			assertIDs(t, selectIDs(t, db, tt.query), tt.expected...)
+		}
+	}
+
+	for _, query := range []string{
+		"SELECT * FROM tasks ORDER BY priority NULLS",
+		"SELECT * FROM tasks ORDER BY priority NULLS MIDDLE",
+		"SELECT * FROM tasks ORDER BY priority DESC NULLS FIRST LAST",
+	} {
+		if _, err := db.Execute(query); err == nil {
+			t.Errorf("Expected an error for %q", query)
+		}
+	}
+}
+
+func TestEmptyMatches(t *testing.T) {
+	defer cleanupTestDB("testdb")
+	db := newPeopleDB(t)
+	_, _ = db.Execute("CREATE TABLE empty (id INT)")
+
+	for _, query := range []string{
+		"SELECT * FROM people WHERE age > 100",
+		"SELECT * FROM empty",
+		"SELECT age, COUNT(*) FROM people WHERE age > 100 GROUP BY age",
+	} {
+		res, err := db.Execute(query)
+		if err != nil || res != "[]" {
+			t.Errorf("Expected an empty array from %q, got %q, %v", query, res, err)
+		}
+	}
+	for query, want := range map[string]string{
+		"DELETE FROM people WHERE age > 100":        "0 rows deleted",
+		"DELETE FROM empty WHERE id = 1":            "0 rows deleted",
+		"UPDATE people SET age = 1 WHERE age > 100": "0 rows updated",
+		"UPDATE empty SET id = 2 WHERE id = 1":      "0 rows updated",
+	} {
+		res, err := db.Execute(query)
+		if err != nil || res != want {
+			t.Errorf("Expected %q from %q, got %q, %v", want, query, res, err)
+		}
+	}
+	assertIDs(t, selectIDs(t, db, "SELECT * FROM people"), 1, 2, 3, 4)
+
+	// Broken queries are still errors
+	for _, query := range []string{
+		"SELECT * FROM missing",
+		"SELECT * FROM people WHERE weight > 1",
+		"DELETE FROM people WHERE weight > 1",
+		"UPDATE empty SET id = 'x' WHERE id = 1",
+	} {
+		if _, err := db.Execute(query); err == nil {
+			t.Errorf("Expected an error for %q", query)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package database_test
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+
+	_ "github.com/AYGA2K/db/internal/database"
+)
+
+func TestSQLDriver(t *testing.T) {
+	dsn := filepath.Join(t.TempDir(), "app")
+	db, err := sql.Open("godb", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	if _, err := db.Exec("CREATE TABLE users (id INT PRIMARY KEY, name VARCHAR, score DOUBLE, active BOOL, born DATE, seen TIMESTAMP, balance DECIMAL(8,2))"); err != nil {
+		t.Fatal(err)
+	}
+	insert := "INSERT INTO users (id, name, score, active, born, seen, balance) VALUES (?, ?, ?, ?, ?, ?, ?)"
+	born := time.Date(1990, 5, 1, 0, 0, 0, 0, time.UTC)
+	seen := time.Date(2024, 3, 1, 10, 30, 0, 0, time.UTC)
+	res, err := db.Exec(insert, 1, "O'Brien, Pat", 9.5, true, born, seen, "12.50")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, err := res.RowsAffected(); err != nil || n != 1 {
+		t.Errorf("Expected 1 row affected, got %d (%v)", n, err)
+	}
+	if _, err := db.Exec("INSERT INTO users (id, name, score) VALUES (?, ?, ?)", 2, "Bob", nil); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := res.LastInsertId(); err != nil || n != 0 {
+		t.Errorf("Expected no id from a table without AUTO_INCREMENT, got %d (%v)", n, err)
+	}
+	if _, err := db.Exec("CREATE TABLE notes (id INT AUTO_INCREMENT, body VARCHAR)"); err != nil {
+		t.Fatal(err)
+	}
+	for want := int64(1); want <= 2; want++ {
+		res, err := db.Exec("INSERT INTO notes (body) VALUES (?)", "note")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if id, err := res.LastInsertId(); err != nil || id != want {
+			t.Errorf("Expected id %d, got %d (%v)", want, id, err)
+		}
+	}
+
+	var (
+		name    string
+		score   float64
+		active  bool
+		gotBorn time.Time
+		gotSeen time.Time
+		balance string
+	)
+	err = db.QueryRow("SELECT name, score, active, born, seen, balance FROM users WHERE id = ?", 1).Scan(&name, &score, &active, &gotBorn, &gotSeen, &balance)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name != "O'Brien, Pat" || score != 9.5 || !active || !gotBorn.Equal(born) || !gotSeen.Equal(seen) || balance != "12.50" {
+		t.Errorf("Unexpected values %q %v %v %v %v %q", name, score, active, gotBorn, gotSeen, balance)
+	}
+
+	rows, err := db.Query("SELECT id, score FROM users ORDER BY id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	columns, _ := rows.Columns()
+	types, _ := rows.ColumnTypes()
+	if len(columns) != 2 || columns[1] != "score" || types[1].DatabaseTypeName() != "DOUBLE" {
+		t.Errorf("Unexpected columns %v", columns)
+	}
+	var ids []int
+	var scores []sql.NullFloat64
+	for rows.Next() {
+		var id int
+		var s sql.NullFloat64
+		if err := rows.Scan(&id, &s); err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, id)
+		scores = append(scores, s)
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != 2 || ids[1] != 2 || !scores[0].Valid || scores[1].Valid {
+		t.Errorf("Expected a score for user 1 only, got %v %v", ids, scores)
+	}
+
+	res, err = db.Exec("UPDATE users SET score = ? WHERE id > ?", 1.5, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := res.RowsAffected(); n != 2 {
+		t.Errorf("Expected 2 rows updated, got %d", n)
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tx.Exec("DELETE FROM users WHERE id = ?", 2); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+	var count int
+	if err := db.QueryRow("SELECT COUNT(*) FROM users").Scan(&count); err != nil || count != 2 {
+		t.Errorf("Expected the rollback to keep 2 users, got %d (%v)", count, err)
+	}
+
+	if _, err := db.Exec("INSERT INTO users (id, name) VALUES (?, ?)", 1, "Again"); err == nil {
+		t.Error("Expected a primary key violation")
+	}
+	if _, err := db.Query("SELECT * FROM users WHERE id = ?"); err == nil {
+		t.Error("Expected an error for a missing argument")
+	}
+
+	// Closing the last connection closes the database, reopening loads it
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+	db, err = sql.Open("godb", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if err := db.QueryRow("SELECT COUNT(*) FROM users").Scan(&count); err != nil || count != 2 {
+		t.Errorf("Expected 2 users after reopening, got %d (%v)", count, err)
+	}
+}
@@ -0,0 +1,68 @@
+package database_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/AYGA2K/db/internal/database"
+)
+
+func TestExplain(t *testing.T) {
+	defer cleanupTestDB("testdb")
+	db, err := database.NewDatabase("testdb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = db.Execute("CREATE TABLE users (id INT PRIMARY KEY, name VARCHAR, age INT)")
+	_, _ = db.Execute("CREATE TABLE posts (id INT, user_id INT, title VARCHAR)")
+	_, _ = db.Execute("CREATE INDEX idx_name ON users (name)")
+	_, _ = db.Execute("INSERT INTO users (id, name, age) VALUES (1, 'Alice', 30)")
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"EXPLAIN SELECT * FROM users", []string{"SCAN users"}},
+		{"EXPLAIN SELECT * FROM users WHERE id = 1", []string{"SEARCH users USING PRIMARY KEY (id) FOR id = 1", "FILTER id = 1"}},
+		{
+			"explain SELECT name FROM users WHERE age > 20 AND name = 'Alice' ORDER BY age DESC LIMIT 5 OFFSET 1",
+			[]string{"SEARCH users USING INDEX idx_name (name) FOR name = 'Alice'", "FILTER age > 20 AND name = 'Alice'", "SORT BY age DESC", "OFFSET 1", "LIMIT 5"},
+		},
+		{"EXPLAIN SELECT * FROM users WHERE age = 30", []string{"SCAN users", "FILTER age = 30"}},
+		{
+			"EXPLAIN SELECT users.name, posts.title FROM users LEFT JOIN posts ON users.id = posts.user_id",
+			[]string{"SCAN users", "HASH LEFT JOIN posts ON users.id = posts.user_id, hashing posts by user_id and probing with each row of users", "KEEP unmatched rows of users"},
+		},
+		{
+			"EXPLAIN SELECT * FROM users JOIN posts USING (id)",
+			[]string{"SCAN users", "HASH JOIN posts USING (id), hashing posts by id and probing with each row of users"},
+		},
+		{
+			"EXPLAIN SELECT * FROM users JOIN posts ON users.id < posts.user_id",
+			[]string{"SCAN users", "NESTED LOOP JOIN posts ON users.id < posts.user_id, scanning posts for each row of users"},
+		},
+		{"EXPLAIN SELECT age, COUNT(*) FROM users GROUP BY age", []string{"SCAN users", "GROUP BY age", "AGGREGATE COUNT(*)"}},
+		{"EXPLAIN SELECT 1 + 1", []string{"VALUES one row, no table is read"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			res, err := db.Execute(tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := strings.Split(res, "\n"); strings.Join(got, "|") != strings.Join(tt.want, "|") {
+				t.Errorf("Expected plan %q, got %q", tt.want, got)
+			}
+		})
+	}
+
+	// EXPLAIN does not run the query
+	if res, err := db.Explain("SELECT * FROM users WHERE id = 1"); err != nil || strings.Contains(res, "Alice") {
+		t.Errorf("Expected only a plan, got %q, %v", res, err)
+	}
+	for _, query := range []string{"EXPLAIN SELECT * FROM nope", "EXPLAIN DELETE FROM users", "EXPLAIN SELECT name users"} {
+		if _, err := db.Execute(query); err == nil {
+			t.Errorf("Expected an error for %s", query)
+		}
+	}
+}
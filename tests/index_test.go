@@ -0,0 +1,202 @@
+package database_test
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/AYGA2K/db/internal/database"
+)
+
+func TestCreateIndex(t *testing.T) {
+	defer cleanupTestDB("testdb")
+	db := newPeopleDB(t)
+
+	if _, err := db.Execute("CREATE INDEX idx_age ON people (age)"); err != nil {
+		t.Fatalf("Create index failed: %v", err)
+	}
+	assertIDs(t, selectIDs(t, db, "SELECT * FROM people WHERE age = 30"), 2)
+	assertIDs(t, selectIDs(t, db, "SELECT * FROM people WHERE age = 30 AND name = 'Bob'"), 2)
+	assertIDs(t, selectIDs(t, db, "SELECT * FROM people WHERE age = 30 AND name = 'Alice'"))
+	assertIDs(t, selectIDs(t, db, "SELECT * FROM people WHERE age = 30 OR id = 4"), 2, 4)
+	// Literals that an INT column would not store as such still compare by value
+	assertIDs(t, selectIDs(t, db, "SELECT * FROM people WHERE age = 30.0"), 2)
+	assertIDs(t, selectIDs(t, db, "SELECT * FROM people WHERE age = '35'"), 3)
+	assertIDs(t, selectIDs(t, db, "SELECT * FROM people WHERE age = 99"))
+
+	for _, sql := range []string{
+		"CREATE INDEX idx_age ON people (name)",
+		"CREATE INDEX idx_wage ON people (wage)",
+		"CREATE INDEX idx_x ON nobody (id)",
+	} {
+		if _, err := db.Execute(sql); err == nil {
+			t.Errorf("Expected an error for %s", sql)
+		}
+	}
+}
+
+func TestIndexMaintained(t *testing.T) {
+	defer cleanupTestDB("testdb")
+	db := newPeopleDB(t)
+
+	_, _ = db.Execute("CREATE INDEX idx_name ON people (name)")
+	_, _ = db.Execute("INSERT INTO people (id, name, age) VALUES (5, 'Eve', 28)")
+	assertIDs(t, selectIDs(t, db, "SELECT * FROM people WHERE name = 'Eve'"), 5)
+
+	if _, err := db.Execute("DELETE FROM people WHERE name = 'Alice'"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	// Rows after the deleted one moved up
+	assertIDs(t, selectIDs(t, db, "SELECT * FROM people WHERE name = 'Eve'"), 5)
+	assertIDs(t, selectIDs(t, db, "SELECT * FROM people WHERE name = 'Alice'"))
+
+	if _, err := db.Execute("UPDATE people SET name = 'Robert' WHERE name = 'Bob'"); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	assertIDs(t, selectIDs(t, db, "SELECT * FROM people WHERE name = 'Bob'"))
+	assertIDs(t, selectIDs(t, db, "SELECT * FROM people WHERE name = 'Robert'"), 2)
+
+	_, _ = db.Execute("BEGIN")
+	_, _ = db.Execute("DELETE FROM people WHERE name = 'Robert'")
+	_, _ = db.Execute("ROLLBACK")
+	assertIDs(t, selectIDs(t, db, "SELECT * FROM people WHERE name = 'Robert'"), 2)
+
+	_, _ = db.Execute("ALTER TABLE people RENAME COLUMN name TO full_name")
+	assertIDs(t, selectIDs(t, db, "SELECT * FROM people WHERE full_name = 'Eve'"), 5)
+}
+
+func TestIndexPersisted(t *testing.T) {
+	defer cleanupTestDB("testdb")
+	db := newPeopleDB(t)
+	_, _ = db.Execute("CREATE INDEX idx_name ON people (name)")
+
+	reloaded, err := database.NewDatabase("testdb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tables, _ := reloaded.AllTables()
+	indexes := tables["people"].Indexes
+	if len(indexes) != 1 || indexes[0].Name != "idx_name" || indexes[0].Column != "name" {
+		t.Fatalf("Expected index idx_name on name, got %v", indexes)
+	}
+	assertIDs(t, selectIDs(t, reloaded, "SELECT * FROM people WHERE name = 'Charlie'"), 3)
+	if _, err := reloaded.Execute("CREATE INDEX idx_name ON people (age)"); err == nil {
+		t.Error("Expected an error for a duplicate index name")
+	}
+}
+
+func TestPrimaryKeyLookup(t *testing.T) {
+	defer cleanupTestDB("testdb")
+	db, err := database.NewDatabase("testdb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = db.Execute("CREATE TABLE items (id INT PRIMARY KEY, name VARCHAR)")
+	for _, sql := range []string{
+		"INSERT INTO items (id, name) VALUES (1, 'one')",
+		"INSERT INTO items (id, name) VALUES (2, 'two')",
+		"INSERT INTO items (id, name) VALUES (3, 'three')",
+	} {
+		if _, err := db.Execute(sql); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+	}
+	assertIDs(t, selectIDs(t, db, "SELECT * FROM items WHERE id = 2"), 2)
+
+	if _, err := db.Execute("UPDATE items SET id = 20 WHERE id = 2"); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	assertIDs(t, selectIDs(t, db, "SELECT * FROM items WHERE id = 2"))
+	assertIDs(t, selectIDs(t, db, "SELECT * FROM items WHERE id = 20"), 20)
+	if _, err := db.Execute("INSERT INTO items (id, name) VALUES (2, 'two again')"); err != nil {
+		t.Errorf("Expected the old key to be free after the update: %v", err)
+	}
+	if _, err := db.Execute("INSERT INTO items (id, name) VALUES (20, 'twenty')"); err == nil {
+		t.Error("Expected a duplicate key error for the new key")
+	}
+
+	if _, err := db.Execute("DELETE FROM items WHERE id = 1"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	assertIDs(t, selectIDs(t, db, "SELECT * FROM items WHERE id = 3"), 3)
+	if _, err := db.Execute("INSERT INTO items (id, name) VALUES (1, 'one again')"); err != nil {
+		t.Errorf("Expected the deleted key to be free: %v", err)
+	}
+
+	reloaded, err := database.NewDatabase("testdb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertIDs(t, selectIDs(t, reloaded, "SELECT * FROM items WHERE id = 20"), 20)
+	if _, err := reloaded.Execute("INSERT INTO items (id, name) VALUES (3, 'three again')"); err == nil {
+		t.Error("Expected a duplicate key error after reloading")
+	}
+}
+
+func TestUpdatePrimaryKeyCollision(t *testing.T) {
+	defer cleanupTestDB("testdb")
+	db, err := database.NewDatabase("testdb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = db.Execute("CREATE TABLE items (id INT PRIMARY KEY, name VARCHAR)")
+	for _, sql := range []string{
+		"INSERT INTO items (id, name) VALUES (1, 'one')",
+		"INSERT INTO items (id, name) VALUES (2, 'two')",
+		"INSERT INTO items (id, name) VALUES (3, 'three')",
+	} {
+		if _, err := db.Execute(sql); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+	}
+
+	for _, sql := range []string{
+		// onto the key of a row that is not updated
+		"UPDATE items SET id = 2 WHERE id = 1",
+		// two updated rows onto the same new key
+		"UPDATE items SET id = 10 WHERE id > 1",
+		"UPDATE items SET id = NULL WHERE id = 3",
+	} {
+		if _, err := db.Execute(sql); err == nil {
+			t.Errorf("Expected a primary key error for %q", sql)
+		}
+	}
+	assertIDs(t, selectIDs(t, db, "SELECT * FROM items"), 1, 2, 3)
+
+	// Keys may move past each other as long as they end up distinct
+	if _, err := db.Execute("UPDATE items SET id = id + 1"); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	assertIDs(t, selectIDs(t, db, "SELECT * FROM items"), 2, 3, 4)
+	assertIDs(t, selectIDs(t, db, "SELECT * FROM items WHERE id = 4"), 4)
+}
+
+// BenchmarkPrimaryKeyLookup compares an equality query on the primary key
+// with the same query on a column holding the same values without an index
+func BenchmarkPrimaryKeyLookup(b *testing.B) {
+	defer cleanupTestDB("testdb")
+	db, err := database.NewDatabase("testdb")
+	if err != nil {
+		b.Fatal(err)
+	}
+	_, _ = db.Execute("CREATE TABLE items (id INT PRIMARY KEY, code INT)")
+	const size = 10000
+	rows := make([][]string, size)
+	for i := range rows {
+		n := strconv.Itoa(i)
+		rows[i] = []string{n, n}
+	}
+	if _, rejected, err := db.InsertRows("items", []string{"id", "code"}, rows, false); err != nil || rejected != nil {
+		b.Fatalf("Insert failed: %v %v", err, rejected)
+	}
+
+	for _, column := range []string{"id", "code"} {
+		b.Run(column, func(b *testing.B) {
+			query := "SELECT * FROM items WHERE " + column + " = 7777"
+			for b.Loop() {
+				if rows, _, err := db.Query(query); err != nil || len(rows) != 1 {
+					b.Fatalf("Query failed: %v %v", err, rows)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,668 @@
+package database_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/AYGA2K/db/internal/database"
+)
+
+// newBlogDB creates users and posts, where Carol has no posts
+func newBlogDB(t *testing.T) *database.Database {
+	t.Helper()
+	db, err := database.NewDatabase("testdb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = db.Execute("CREATE TABLE users (id INT, name VARCHAR)")
+	_, _ = db.Execute("CREATE TABLE posts (post_id INT, user_id INT, title VARCHAR)")
+	_, _ = db.Execute("INSERT INTO users (id, name) VALUES (1, 'Alice')")
+	_, _ = db.Execute("INSERT INTO users (id, name) VALUES (2, 'Bob')")
+	_, _ = db.Execute("INSERT INTO users (id, name) VALUES (3, 'Carol')")
+	_, _ = db.Execute("INSERT INTO posts (post_id, user_id, title) VALUES (10, 1, 'Hello')")
+	_, _ = db.Execute("INSERT INTO posts (post_id, user_id, title) VALUES (11, 1, 'Again')")
+	_, _ = db.Execute("INSERT INTO posts (post_id, user_id, title) VALUES (12, 2, 'World')")
+	return db
+}
+
+// selectRows runs a query and returns its result rows
+func selectRows(t *testing.T, db *database.Database, query string) []map[string]any {
+	t.Helper()
+	res, err := db.Execute(query)
+	if err != nil {
+		t.Fatalf("Query %q failed: %v", query, err)
+	}
+	var rows []map[string]any
+	if err := json.Unmarshal([]byte(res), &rows); err != nil {
+		t.Fatalf("Failed to unmarshal results: %v", err)
+	}
+	return rows
+}
+
+func TestLeftJoin(t *testing.T) {
+	defer cleanupTestDB("testdb")
+	db := newBlogDB(t)
+
+	for _, query := range []string{
+		"SELECT users.name, posts.title FROM users LEFT JOIN posts ON users.id = posts.user_id",
+		"SELECT users.name, posts.title FROM users left outer join posts ON users.id = posts.user_id",
+	} {
+		rows := selectRows(t, db, query)
+		if len(rows) != 4 {
+			t.Fatalf("Expected 4 rows from %q, got %v", query, rows)
+		}
+		last := rows[3]
+		if last["users.name"] != "Carol" {
+			t.Errorf("Expected Carol in the last row, got %v", last)
+		}
+		if title, exists := last["posts.title"]; !exists || title != nil {
+			t.Errorf("Expected a null title for Carol, got %v", last)
+		}
+	}
+
+	rows := selectRows(t, db, "SELECT * FROM users LEFT JOIN posts ON users.id = posts.user_id WHERE name = 'Carol'")
+	if len(rows) != 1 || rows[0]["id"] != float64(3) || rows[0]["title"] != nil {
+		t.Errorf("Expected Carol with null post columns, got %v", rows)
+	}
+	if _, exists := rows[0]["post_id"]; !exists {
+		t.Errorf("Expected post_id to be present as null, got %v", rows[0])
+	}
+
+	row := selectAggregate(t, db, "SELECT COUNT(*), COUNT(title) FROM users LEFT JOIN posts ON users.id = posts.user_id")
+	if row["COUNT(*)"] != float64(4) || row["COUNT(title)"] != float64(3) {
+		t.Errorf("Unexpected counts %v", row)
+	}
+}
+
+func TestFullOuterJoin(t *testing.T) {
+	defer cleanupTestDB("testdb")
+	db, err := database.NewDatabase("testdb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = db.Execute("CREATE TABLE ledger_a (txid INT, amount INT)")
+	_, _ = db.Execute("CREATE TABLE ledger_b (txid INT, amount INT)")
+	for _, txid := range []string{"1", "2", "3"} {
+		_, _ = db.Execute("INSERT INTO ledger_a (txid, amount) VALUES (" + txid + ", 100)")
+	}
+	for _, txid := range []string{"2", "3", "4", "5"} {
+		_, _ = db.Execute("INSERT INTO ledger_b (txid, amount) VALUES (" + txid + ", 200)")
+	}
+
+	for _, query := range []string{
+		"SELECT ledger_a.txid, ledger_b.txid FROM ledger_a FULL OUTER JOIN ledger_b ON ledger_a.txid = ledger_b.txid",
+		"SELECT ledger_a.txid, ledger_b.txid FROM ledger_a full join ledger_b ON ledger_a.txid = ledger_b.txid",
+	} {
+		rows := selectRows(t, db, query)
+		var matched, leftOnly, rightOnly []any
+		for _, row := range rows {
+			a, b := row["ledger_a.txid"], row["ledger_b.txid"]
+			switch {
+			case a != nil && b != nil:
+				if a != b {
+					t.Errorf("Expected matched txids to be equal, got %v", row)
+				}
+				matched = append(matched, a)
+			case a != nil:
+				leftOnly = append(leftOnly, a)
+			case b != nil:
+				rightOnly = append(rightOnly, b)
+			}
+		}
+		if len(rows) != 5 || len(matched) != 2 || len(leftOnly) != 1 || len(rightOnly) != 2 {
+			t.Errorf("Expected 2 matched, 1 left-only and 2 right-only rows from %q, got %v", query, rows)
+		}
+		if !slices.Equal(leftOnly, []any{float64(1)}) || !slices.Equal(rightOnly, []any{float64(4), float64(5)}) {
+			t.Errorf("Unexpected unmatched rows %v and %v", leftOnly, rightOnly)
+		}
+	}
+
+	// WHERE filters the joined rows, unmatched ones included
+	rows := selectRows(t, db, "SELECT * FROM ledger_a FULL JOIN ledger_b ON ledger_a.txid = ledger_b.txid WHERE ledger_a.txid IS NULL")
+	if len(rows) != 2 || rows[0]["txid"] != float64(4) || rows[0]["amount"] != float64(200) {
+		t.Errorf("Expected the right-only rows with their own values, got %v", rows)
+	}
+	row := selectAggregate(t, db, "SELECT COUNT(*), COUNT(ledger_a.txid), COUNT(ledger_b.txid) FROM ledger_a FULL JOIN ledger_b ON ledger_a.txid = ledger_b.txid")
+	if row["COUNT(*)"] != float64(5) || row["COUNT(ledger_a.txid)"] != float64(3) || row["COUNT(ledger_b.txid)"] != float64(4) {
+		t.Errorf("Unexpected counts %v", row)
+	}
+}
+
+func TestCrossJoin(t *testing.T) {
+	defer cleanupTestDB("testdb")
+	db, err := database.NewDatabase("testdb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = db.Execute("CREATE TABLE colors (name VARCHAR)")
+	_, _ = db.Execute("CREATE TABLE sizes (name VARCHAR)")
+	for _, color := range []string{"red", "green", "blue"} {
+		_, _ = db.Execute("INSERT INTO colors (name) VALUES ('" + color + "')")
+	}
+	_, _ = db.Execute("INSERT INTO sizes (name) VALUES ('S')")
+	_, _ = db.Execute("INSERT INTO sizes (name) VALUES ('L')")
+
+	rows := selectRows(t, db, "SELECT colors.name, sizes.name FROM colors CROSS JOIN sizes")
+	var pairs []string
+	for _, row := range rows {
+		pairs = append(pairs, row["colors.name"].(string)+"/"+row["sizes.name"].(string))
+	}
+	want := []string{"red/S", "red/L", "green/S", "green/L", "blue/S", "blue/L"}
+	if !slices.Equal(pairs, want) {
+		t.Errorf("Expected every pairing %v, got %v", want, pairs)
+	}
+
+	rows = selectRows(t, db, "SELECT c.name, s.name FROM colors c cross join sizes s WHERE s.name = 'L' ORDER BY c.name LIMIT 2")
+	if len(rows) != 2 || rows[0]["c.name"] != "blue" || rows[1]["c.name"] != "green" || rows[0]["s.name"] != "L" {
+		t.Errorf("Expected WHERE, ORDER BY and LIMIT to apply to the product, got %v", rows)
+	}
+	row := selectAggregate(t, db, "SELECT COUNT(*) FROM colors CROSS JOIN sizes")
+	if row["COUNT(*)"] != float64(6) {
+		t.Errorf("Expected 6 rows, got %v", row)
+	}
+	if _, err := db.Execute("SELECT * FROM colors CROSS JOIN sizes ON colors.name = sizes.name"); err == nil {
+		t.Error("Expected an error for CROSS JOIN with ON")
+	}
+
+	capped, err := database.NewDatabase("capped", database.WithInMemory(), database.WithMaxCrossJoinRows(5))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = capped.Execute("CREATE TABLE colors (name VARCHAR)")
+	_, _ = capped.Execute("CREATE TABLE sizes (name VARCHAR)")
+	for _, name := range []string{"a", "b", "c"} {
+		_, _ = capped.Execute("INSERT INTO colors (name) VALUES ('" + name + "')")
+		_, _ = capped.Execute("INSERT INTO sizes (name) VALUES ('" + name + "')")
+	}
+	_, err = capped.Execute("SELECT * FROM colors CROSS JOIN sizes LIMIT 1")
+	if err == nil || !strings.Contains(err.Error(), "would produce 9 rows, more than the limit of 5") {
+		t.Errorf("Expected the product to exceed the limit, got %v", err)
+	}
+	_, _ = capped.Execute("DELETE FROM sizes WHERE name = 'c'")
+	_, _ = capped.Execute("DELETE FROM colors WHERE name = 'c'")
+	if res, err := capped.Execute("SELECT COUNT(*) FROM colors CROSS JOIN sizes"); err != nil || !strings.Contains(res, "4") {
+		t.Errorf("Expected a product within the limit to run, got %s, %v", res, err)
+	}
+}
+
+func TestJoinKeywords(t *testing.T) {
+	defer cleanupTestDB("testdb")
+	db := newBlogDB(t)
+
+	tests := []struct {
+		join string
+		rows int
+	}{
+		{"JOIN", 3},
+		{"INNER JOIN", 3},
+		{"inner join", 3},
+		{"Inner  Join", 3},
+		{"LEFT JOIN", 4},
+		{"LEFT OUTER JOIN", 4},
+		{"left outer join", 4},
+		{"FULL JOIN", 4},
+		{"FULL OUTER JOIN", 4},
+		{"full Outer JOIN", 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.join, func(t *testing.T) {
+			rows := selectRows(t, db, "SELECT users.name, posts.title FROM users "+tt.join+" posts ON users.id = posts.user_id")
+			if len(rows) != tt.rows {
+				t.Errorf("Expected %d rows, got %v", tt.rows, rows)
+			}
+		})
+	}
+
+	rows := selectRows(t, db, "SELECT u.name FROM users AS u INNER JOIN posts p ON u.id = p.user_id WHERE p.title = 'World'")
+	if len(rows) != 1 || rows[0]["u.name"] != "Bob" {
+		t.Errorf("Expected Bob through aliased tables, got %v", rows)
+	}
+	for _, query := range []string{
+		"SELECT * FROM users INNER OUTER JOIN posts ON users.id = posts.user_id",
+		"SELECT * FROM users OUTER JOIN posts ON users.id = posts.user_id",
+		"SELECT * FROM users INNER posts ON users.id = posts.user_id",
+	} {
+		if _, err := db.Execute(query); err == nil {
+			t.Errorf("Expected an error for %s", query)
+		}
+	}
+}
+
+func TestJoinOnConditions(t *testing.T) {
+	defer cleanupTestDB("testdb")
+	db := newBlogDB(t)
+
+	// The ON columns may name either table first
+	for _, query := range []string{
+		"SELECT users.name, posts.title FROM users JOIN posts ON users.id = posts.user_id ORDER BY posts.post_id",
+		"SELECT users.name, posts.title FROM users JOIN posts ON posts.user_id = users.id ORDER BY posts.post_id",
+	} {
+		rows := selectRows(t, db, query)
+		if len(rows) != 3 || rows[0]["users.name"] != "Alice" || rows[2]["users.name"] != "Bob" {
+			t.Errorf("Expected each post with its author from %q, got %v", query, rows)
+		}
+	}
+
+	rows := selectRows(t, db, "SELECT users.name, posts.title FROM users LEFT JOIN posts ON users.id = posts.user_id AND posts.title != 'Again' ORDER BY users.id")
+	if len(rows) != 3 || rows[0]["posts.title"] != "Hello" || rows[1]["posts.title"] != "World" || rows[2]["posts.title"] != nil {
+		t.Errorf("Expected the compound condition to pick the posts, got %v", rows)
+	}
+	rows = selectRows(t, db, "SELECT users.name, posts.title FROM users JOIN posts ON users.id != posts.user_id ORDER BY posts.post_id, users.id")
+	if len(rows) != 6 || rows[0]["users.name"] != "Bob" || rows[0]["posts.title"] != "Hello" {
+		t.Errorf("Expected every pair of a user and a post by someone else, got %v", rows)
+	}
+
+	// Null keys match nothing
+	_, _ = db.Execute("INSERT INTO users (name) VALUES ('Nobody')")
+	_, _ = db.Execute("INSERT INTO posts (post_id, title) VALUES (13, 'Orphan')")
+	if row := selectAggregate(t, db, "SELECT COUNT(*) FROM users JOIN posts ON users.id = posts.user_id"); row["COUNT(*)"] != float64(3) {
+		t.Errorf("Expected null keys not to join, got %v", row)
+	}
+
+	for query, want := range map[string]string{
+		"SELECT * FROM users JOIN posts ON users.id = posts.nope":       "column posts.nope does not exist",
+		"SELECT * FROM users JOIN posts ON users.id > posts.user_id OR": "invalid join condition",
+	} {
+		if _, err := db.Execute(query); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("Expected an error containing %q for %q, got %v", want, query, err)
+		}
+	}
+}
+
+func TestJoinUsing(t *testing.T) {
+	defer cleanupTestDB("testdb")
+	db, err := database.NewDatabase("testdb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = db.Execute("CREATE TABLE staff (id INT, name VARCHAR, role_id INT, team VARCHAR)")
+	_, _ = db.Execute("CREATE TABLE roles (role_id INT, team VARCHAR, title VARCHAR)")
+	_, _ = db.Execute("INSERT INTO staff (id, name, role_id, team) VALUES (1, 'Alice', 10, 'core')")
+	_, _ = db.Execute("INSERT INTO staff (id, name, role_id, team) VALUES (2, 'Bob', 20, 'web')")
+	_, _ = db.Execute("INSERT INTO staff (id, name, role_id, team) VALUES (3, 'Carol', 30, 'core')")
+	_, _ = db.Execute("INSERT INTO roles (role_id, team, title) VALUES (10, 'core', 'Lead')")
+	_, _ = db.Execute("INSERT INTO roles (role_id, team, title) VALUES (20, 'core', 'Designer')")
+	_, _ = db.Execute("INSERT INTO roles (role_id, team, title) VALUES (40, 'ops', 'Admin')")
+
+	for _, query := range []string{
+		"SELECT * FROM staff JOIN roles USING (role_id) ORDER BY id",
+		"SELECT * FROM staff JOIN roles USING(role_id) ORDER BY id",
+		"SELECT * FROM staff s INNER JOIN roles AS r using ( role_id ) ORDER BY id",
+	} {
+		rows := selectRows(t, db, query)
+		if len(rows) != 2 || rows[0]["title"] != "Lead" || rows[1]["title"] != "Designer" {
+			t.Fatalf("Expected Alice and Bob with their roles from %q, got %v", query, rows)
+		}
+		// The shared column is there once
+		if len(rows[0]) != 5 || rows[0]["role_id"] != float64(10) {
+			t.Errorf("Expected 5 columns with role_id once from %q, got %v", query, rows[0])
+		}
+	}
+
+	rows := selectRows(t, db, "SELECT staff.name AS name, roles.title AS title FROM staff JOIN roles USING (role_id, team)")
+	if len(rows) != 1 || rows[0]["name"] != "Alice" {
+		t.Errorf("Expected only Alice to match on both columns, got %v", rows)
+	}
+
+	// Unmatched rows keep the shared column of the table they come from
+	rows = selectRows(t, db, "SELECT * FROM staff FULL JOIN roles USING (role_id) WHERE staff.id IS NULL OR roles.role_id IS NULL")
+	if len(rows) != 2 || rows[0]["role_id"] != float64(30) || rows[1]["role_id"] != float64(40) {
+		t.Errorf("Expected Carol's and the Admin role_id, got %v", rows)
+	}
+
+	if row := selectAggregate(t, db, "SELECT COUNT(*) FROM staff LEFT JOIN roles USING (role_id)"); row["COUNT(*)"] != float64(3) {
+		t.Errorf("Expected every staff member, got %v", row)
+	}
+
+	for query, want := range map[string]string{
+		"SELECT * FROM staff JOIN roles USING (id)":               "column id in USING does not exist in table roles",
+		"SELECT * FROM staff JOIN roles USING (title)":            "column title in USING does not exist in table staff",
+		"SELECT * FROM staff JOIN roles USING ()":                 "USING needs at least one column",
+		"SELECT * FROM staff JOIN roles USING (role_id, role_id)": "listed twice",
+		"SELECT * FROM staff JOIN roles USING (staff.role_id)":    "USING takes column names",
+	} {
+		if _, err := db.Execute(query); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("Expected an error containing %q for %q, got %v", want, query, err)
+		}
+	}
+}
+
+func TestHashJoin(t *testing.T) {
+	defer cleanupTestDB("testdb")
+	db, err := database.NewDatabase("testdb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = db.Execute("CREATE TABLE orders (id INT, item_id INT)")
+	_, _ = db.Execute("CREATE TABLE prices (item_id DOUBLE, price INT)")
+	for _, values := range []string{"(1, 2)", "(2, 1)", "(3, 2)", "(4, 3)", "(5, NULL)"} {
+		_, _ = db.Execute("INSERT INTO orders (id, item_id) VALUES " + values)
+	}
+	_, _ = db.Execute("INSERT INTO prices (item_id, price) VALUES (2.0, 20)")
+	_, _ = db.Execute("INSERT INTO prices (item_id, price) VALUES (1, 10)")
+	_, _ = db.Execute("INSERT INTO prices (item_id, price) VALUES (2, 21)")
+	_, _ = db.Execute("INSERT INTO prices (item_id, price) VALUES (NULL, 0)")
+
+	// Whichever table is smaller, and so hashed, pairs come in the order of
+	// the first table and then of the second, and INT keys match DOUBLE ones
+	want := "1/20 1/21 2/10 3/20 3/21"
+	for _, query := range []string{
+		"SELECT orders.id AS id, prices.price AS price FROM orders JOIN prices ON orders.item_id = prices.item_id",
+		"SELECT orders.id AS id, prices.price AS price FROM orders JOIN prices ON prices.item_id = orders.item_id",
+	} {
+		var got []string
+		for _, row := range selectRows(t, db, query) {
+			got = append(got, fmt.Sprintf("%v/%v", row["id"], row["price"]))
+		}
+		if strings.Join(got, " ") != want {
+			t.Errorf("Expected %s from %q, got %v", want, query, got)
+		}
+	}
+	rows := selectRows(t, db, "SELECT prices.price AS price, orders.id AS id FROM prices JOIN orders ON prices.item_id = orders.item_id")
+	if len(rows) != 5 || rows[0]["id"] != float64(1) || rows[1]["id"] != float64(3) || rows[2]["price"] != float64(10) {
+		t.Errorf("Expected the pairs in the order of prices, got %v", rows)
+	}
+
+	// Null keys match nothing, unmatched rows are still kept
+	row := selectAggregate(t, db, "SELECT COUNT(*) FROM orders FULL JOIN prices ON orders.item_id = prices.item_id")
+	if row["COUNT(*)"] != float64(8) {
+		t.Errorf("Expected 5 matched and 3 unmatched rows, got %v", row)
+	}
+
+	// The hash join pairs the same rows as the nested loop of an equivalent
+	// condition, for integers beyond 2^53 and for a DATE and the TIMESTAMP of
+	// its midnight
+	_, _ = db.Execute("CREATE TABLE big_a (id INT, k INT)")
+	_, _ = db.Execute("CREATE TABLE big_b (id INT, k INT)")
+	_, _ = db.Execute("INSERT INTO big_a (id, k) VALUES (1, 9007199254740992)")
+	_, _ = db.Execute("INSERT INTO big_a (id, k) VALUES (2, 9007199254740993)")
+	_, _ = db.Execute("INSERT INTO big_b (id, k) VALUES (1, 9007199254740993)")
+	_, _ = db.Execute("CREATE TABLE days (id INT, day DATE)")
+	_, _ = db.Execute("CREATE TABLE stamps (id INT, ts TIMESTAMP)")
+	_, _ = db.Execute("INSERT INTO days (id, day) VALUES (1, '2024-01-05')")
+	_, _ = db.Execute("INSERT INTO days (id, day) VALUES (2, '2024-01-06')")
+	_, _ = db.Execute("INSERT INTO stamps (id, ts) VALUES (1, '2024-01-05 00:00:00')")
+	_, _ = db.Execute("INSERT INTO stamps (id, ts) VALUES (2, '2024-01-06 12:00:00')")
+	for _, tc := range []struct {
+		query, hash, nested, want string
+	}{
+		{"SELECT big_a.id AS a, big_b.id AS b FROM big_a JOIN big_b ON ", "big_a.k = big_b.k", "big_a.k >= big_b.k AND big_a.k <= big_b.k", "2/1"},
+		{"SELECT days.id AS a, stamps.id AS b FROM days JOIN stamps ON ", "days.day = stamps.ts", "days.day >= stamps.ts AND days.day <= stamps.ts", "1/1"},
+	} {
+		for _, condition := range []string{tc.hash, tc.nested} {
+			var got []string
+			for _, row := range selectRows(t, db, tc.query+condition) {
+				got = append(got, fmt.Sprintf("%v/%v", row["a"], row["b"]))
+			}
+			if strings.Join(got, " ") != tc.want {
+				t.Errorf("Expected %s from ON %s, got %v", tc.want, condition, got)
+			}
+		}
+	}
+}
+
+// BenchmarkJoin compares the hash join of an equality with the nested loop
+// of an equivalent condition that is not one, on two tables of 10000 rows.
+// The nested loop tests 100 million pairs and takes minutes, it is skipped
+// with -short and may need a longer -timeout.
+func BenchmarkJoin(b *testing.B) {
+	defer cleanupTestDB("testdb")
+	db, err := database.NewDatabase("testdb")
+	if err != nil {
+		b.Fatal(err)
+	}
+	_, _ = db.Execute("CREATE TABLE a (id INT, k INT)")
+	_, _ = db.Execute("CREATE TABLE b (id INT, k INT)")
+	const size = 10000
+	rows := make([][]string, size)
+	for i := range rows {
+		rows[i] = []string{strconv.Itoa(i), strconv.Itoa(size - i)}
+	}
+	for _, table := range []string{"a", "b"} {
+		if _, rejected, err := db.InsertRows(table, []string{"id", "k"}, rows, false); err != nil || rejected != nil {
+			b.Fatalf("Insert failed: %v %v", err, rejected)
+		}
+	}
+
+	for _, bm := range []struct{ name, condition string }{
+		{"hash", "a.k = b.k"},
+		{"nested loop", "a.k >= b.k AND a.k <= b.k"},
+	} {
+		b.Run(bm.name, func(b *testing.B) {
+			if bm.name == "nested loop" && testing.Short() {
+				b.Skip("the nested loop takes minutes")
+			}
+			query := "SELECT a.id, b.id FROM a JOIN b ON " + bm.condition
+			for b.Loop() {
+				if rows, _, err := db.Query(query); err != nil || len(rows) != size {
+					b.Fatalf("Query failed: %v %d", err, len(rows))
+				}
+			}
+		})
+	}
+}
+
+func TestIntervalJoin(t *testing.T) {
+	defer cleanupTestDB("testdb")
+	db, err := database.NewDatabase("testdb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = db.Execute("CREATE TABLE windows (name VARCHAR, start TIMESTAMP, finish TIMESTAMP)")
+	_, _ = db.Execute("CREATE TABLE events (id INT, ts TIMESTAMP)")
+	_, _ = db.Execute("INSERT INTO windows (name, start, finish) VALUES ('morning', '2024-01-05 06:00:00', '2024-01-05 12:00:00')")
+	_, _ = db.Execute("INSERT INTO windows (name, start, finish) VALUES ('afternoon', '2024-01-05 12:00:00', '2024-01-05 18:00:00')")
+	_, _ = db.Execute("INSERT INTO events (id, ts) VALUES (1, '2024-01-05 05:59:59')")
+	_, _ = db.Execute("INSERT INTO events (id, ts) VALUES (2, '2024-01-05 06:00:00')")
+	_, _ = db.Execute("INSERT INTO events (id, ts) VALUES (3, '2024-01-05 11:59:59')")
+	_, _ = db.Execute("INSERT INTO events (id, ts) VALUES (4, '2024-01-05 12:00:00')")
+	_, _ = db.Execute("INSERT INTO events (id, ts) VALUES (5, '2024-01-05 20:00:00')")
+
+	rows := selectRows(t, db, "SELECT events.id, windows.name FROM events JOIN windows ON events.ts >= windows.start AND events.ts < windows.finish ORDER BY events.id")
+	var pairs []string
+	for _, row := range rows {
+		pairs = append(pairs, fmt.Sprintf("%v:%v", row["events.id"], row["windows.name"]))
+	}
+	want := []string{"2:morning", "3:morning", "4:afternoon"}
+	if !slices.Equal(pairs, want) {
+		t.Errorf("Expected pairings %v, got %v", want, pairs)
+	}
+
+	rows = selectRows(t, db, "SELECT events.id, windows.name FROM events LEFT JOIN windows ON events.ts >= windows.start AND events.ts < windows.finish WHERE windows.name IS NULL ORDER BY events.id")
+	if len(rows) != 2 || rows[0]["events.id"] != float64(1) || rows[1]["events.id"] != float64(5) {
+		t.Errorf("Expected the events outside every window, got %v", rows)
+	}
+	row := selectAggregate(t, db, "SELECT COUNT(*) FROM windows JOIN events ON events.ts >= windows.start AND events.ts <= windows.finish")
+	if row["COUNT(*)"] != float64(4) {
+		t.Errorf("Expected 4 pairs with inclusive bounds, got %v", row)
+	}
+}
+
+func TestInnerJoinDropsUnmatched(t *testing.T) {
+	defer cleanupTestDB("testdb")
+	db := newBlogDB(t)
+
+	rows := selectRows(t, db, "SELECT users.name, posts.title FROM users JOIN posts ON users.id = posts.user_id")
+	if len(rows) != 3 {
+		t.Fatalf("Expected 3 rows, got %v", rows)
+	}
+	for _, row := range rows {
+		if row["users.name"] == "Carol" {
+			t.Errorf("Expected Carol to be left out, got %v", rows)
+		}
+	}
+}
+
+func TestJoinQualifiedWhere(t *testing.T) {
+	defer cleanupTestDB("testdb")
+	db, err := database.NewDatabase("testdb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = db.Execute("CREATE TABLE users (id INT, name VARCHAR)")
+	_, _ = db.Execute("CREATE TABLE posts (id INT, user_id INT, title VARCHAR)")
+	_, _ = db.Execute("INSERT INTO users (id, name) VALUES (1, 'Alice')")
+	_, _ = db.Execute("INSERT INTO users (id, name) VALUES (2, 'Bob')")
+	_, _ = db.Execute("INSERT INTO posts (id, user_id, title) VALUES (1, 2, 'Hello')")
+	_, _ = db.Execute("INSERT INTO posts (id, user_id, title) VALUES (2, 1, 'World')")
+
+	join := "SELECT users.name, posts.title FROM users JOIN posts ON users.id = posts.user_id"
+	rows := selectRows(t, db, join+" WHERE users.id = 1")
+	if len(rows) != 1 || rows[0]["users.name"] != "Alice" || rows[0]["posts.title"] != "World" {
+		t.Errorf("Expected Alice's post, got %v", rows)
+	}
+	rows = selectRows(t, db, join+" WHERE posts.id = 1")
+	if len(rows) != 1 || rows[0]["users.name"] != "Bob" || rows[0]["posts.title"] != "Hello" {
+		t.Errorf("Expected post 1 by Bob, got %v", rows)
+	}
+	rows = selectRows(t, db, join+" WHERE name = 'Bob'")
+	if len(rows) != 1 || rows[0]["posts.title"] != "Hello" {
+		t.Errorf("Expected an unambiguous unqualified name to work, got %v", rows)
+	}
+
+	row := selectAggregate(t, db, "SELECT COUNT(*) FROM users JOIN posts ON users.id = posts.user_id WHERE users.id = 2")
+	if row["COUNT(*)"] != float64(1) {
+		t.Errorf("Expected 1 joined row for user 2, got %v", row)
+	}
+
+	for _, query := range []string{
+		join + " WHERE id = 1",
+		"SELECT id FROM users JOIN posts ON users.id = posts.user_id",
+		"SELECT COUNT(*) FROM users JOIN posts ON users.id = posts.user_id WHERE id > 0",
+	} {
+		_, err := db.Execute(query)
+		if err == nil || !strings.Contains(err.Error(), "ambiguous") {
+			t.Errorf("Expected an ambiguity error for %q, got %v", query, err)
+		}
+	}
+}
+
+func TestTableAliases(t *testing.T) {
+	defer cleanupTestDB("testdb")
+	db := newBlogDB(t)
+
+	for _, query := range []string{
+		"SELECT name AS full_name FROM users AS u WHERE u.id = 2",
+		"SELECT u.name full_name FROM users u WHERE u.id = 2",
+		"SELECT users.name AS full_name FROM users WHERE users.id = 2",
+	} {
+		rows := selectRows(t, db, query)
+		if len(rows) != 1 || rows[0]["full_name"] != "Bob" {
+			t.Errorf("Expected Bob as full_name from %q, got %v", query, rows)
+		}
+	}
+
+	rows := selectRows(t, db, "SELECT u.name, p.title AS post FROM users u LEFT JOIN posts AS p ON u.id = p.user_id WHERE p.post_id > 10 ORDER BY p.post_id DESC")
+	if len(rows) != 2 || rows[0]["u.name"] != "Bob" || rows[0]["post"] != "World" || rows[1]["post"] != "Again" {
+		t.Errorf("Expected Bob's and Alice's later posts, got %v", rows)
+	}
+	rows = selectRows(t, db, "SELECT u.name FROM users u ORDER BY u.id DESC LIMIT 1")
+	if len(rows) != 1 || rows[0]["u.name"] != "Carol" {
+		t.Errorf("Expected Carol first in descending order, got %v", rows)
+	}
+
+	row := selectAggregate(t, db, "SELECT COUNT(p.post_id) posts FROM users u JOIN posts p ON u.id = p.user_id WHERE u.name = 'Alice'")
+	if row["posts"] != float64(2) {
+		t.Errorf("Expected 2 posts by Alice, got %v", row)
+	}
+
+	// Aliases let a table be joined with itself
+	rows = selectRows(t, db, "SELECT a.name, b.name FROM users a JOIN users b ON a.id = b.id WHERE a.id = 3")
+	if len(rows) != 1 || rows[0]["a.name"] != "Carol" || rows[0]["b.name"] != "Carol" {
+		t.Errorf("Expected the row of Carol joined with itself, got %v", rows)
+	}
+
+	// Once aliased, the table is only known by its alias
+	_, err := db.Execute("SELECT name FROM users u WHERE users.id = 1")
+	if err == nil {
+		t.Error("Expected an error for a column qualified by the table name instead of its alias")
+	}
+}
+
+func TestJoinOrderBy(t *testing.T) {
+	defer cleanupTestDB("testdb")
+	db := newBlogDB(t)
+
+	join := "SELECT posts.title, users.name FROM posts JOIN users ON posts.user_id = users.id"
+	titles := func(rows []map[string]any) []any {
+		var got []any
+		for _, row := range rows {
+			got = append(got, row["posts.title"])
+		}
+		return got
+	}
+	for _, tc := range []struct {
+		orderBy string
+		want    []any
+	}{
+		{"users.name, posts.post_id", []any{"Hello", "Again", "World"}},
+		{"users.name DESC, posts.post_id", []any{"World", "Hello", "Again"}},
+		{"name DESC, title", []any{"World", "Again", "Hello"}},
+		{"users.id DESC, title DESC", []any{"World", "Hello", "Again"}},
+	} {
+		rows := selectRows(t, db, join+" ORDER BY "+tc.orderBy)
+		if got := titles(rows); !slices.Equal(got, tc.want) {
+			t.Errorf("ORDER BY %s: expected %v, got %v", tc.orderBy, tc.want, got)
+		}
+	}
+
+	// name is only in the joined table, so it resolves without a qualifier
+	rows := selectRows(t, db, "SELECT title FROM posts JOIN users ON posts.user_id = users.id ORDER BY name DESC LIMIT 1")
+	if len(rows) != 1 || rows[0]["title"] != "World" {
+		t.Errorf("Expected Bob's post first, got %v", rows)
+	}
+
+	if _, err := db.Execute(join + " ORDER BY nickname"); err == nil {
+		t.Error("Expected an error for an unknown ORDER BY column")
+	}
+}
+
+func TestTableStar(t *testing.T) {
+	defer cleanupTestDB("testdb")
+	db := newBlogDB(t)
+
+	rows, columns, err := db.Query("SELECT users.*, posts.title FROM posts JOIN users ON posts.user_id = users.id ORDER BY posts.post_id")
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	var names []string
+	for _, column := range columns {
+		names = append(names, column.Name)
+	}
+	if !slices.Equal(names, []string{"users.id", "users.name", "posts.title"}) {
+		t.Errorf("Expected the users columns then the title, got %v", names)
+	}
+	if len(rows) != 3 || len(rows[2]) != 3 || rows[2]["users.id"] != int64(2) || rows[2]["users.name"] != "Bob" || rows[2]["posts.title"] != "World" {
+		t.Errorf("Expected qualified user columns, got %v", rows)
+	}
+
+	// Both tables have a column named like the other's, qualified keys keep them apart
+	joined := selectRows(t, db, "SELECT u.*, p.* FROM users u LEFT JOIN posts p ON u.id = p.user_id WHERE u.id = 3")
+	expected := map[string]any{"u.id": float64(3), "u.name": "Carol", "p.post_id": nil, "p.user_id": nil, "p.title": nil}
+	if len(joined) != 1 || len(joined[0]) != len(expected) {
+		t.Fatalf("Expected one row with %d columns, got %v", len(expected), joined)
+	}
+	for key, want := range expected {
+		if got, ok := joined[0][key]; !ok || got != want {
+			t.Errorf("%s: expected %v, got %v", key, want, joined[0])
+		}
+	}
+
+	joined = selectRows(t, db, "SELECT users.* FROM users WHERE id = 1")
+	if len(joined) != 1 || joined[0]["users.name"] != "Alice" {
+		t.Errorf("Expected users.* without a join, got %v", joined)
+	}
+
+	for _, query := range []string{
+		"SELECT comments.* FROM posts JOIN users ON posts.user_id = users.id",
+		"SELECT users.* AS u FROM users",
+	} {
+		if _, err := db.Execute(query); err == nil {
+			t.Errorf("Expected an error for %q", query)
+		}
+	}
+}
@@ -0,0 +1,586 @@
+package database_test
+
+import (
+	"encoding/json"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/AYGA2K/db/internal/database"
+)
+
+func columnNames(columns []database.Column) []string {
+	var names []string
+	for _, column := range columns {
+		names = append(names, column.Name)
+	}
+	return names
+}
+
+func TestQuery(t *testing.T) {
+	defer cleanupTestDB("testdb")
+	db := newPeopleDB(t)
+
+	rows, columns, err := db.Query("SELECT name, age FROM people WHERE age > 30 ORDER BY age DESC")
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	if names := columnNames(columns); len(names) != 2 || names[0] != "name" || names[1] != "age" {
+		t.Errorf("Expected columns [name age], got %v", names)
+	}
+	if columns[0].Type != database.COLUMN_TYPE_VARCHAR || columns[1].Type != database.COLUMN_TYPE_INT {
+		t.Errorf("Unexpected column types %v", columns)
+	}
+	if len(rows) != 2 {
+		t.Fatalf("Expected 2 rows, got %v", rows)
+	}
+	if age, ok := rows[0]["age"].(int64); !ok || age != 40 {
+		t.Errorf("Expected age to be int64 40, got %T %v", rows[0]["age"], rows[0]["age"])
+	}
+	if rows[1]["name"] != "Charlie" {
+		t.Errorf("Expected Charlie second, got %v", rows[1])
+	}
+}
+
+func TestQueryColumns(t *testing.T) {
+	defer cleanupTestDB("testdb")
+	db := newPeopleDB(t)
+
+	_, columns, err := db.Query("SELECT * FROM people")
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	expected := []string{"id", "name", "age", "height", "birthdate"}
+	names := columnNames(columns)
+	if len(names) != len(expected) {
+		t.Fatalf("Expected columns %v, got %v", expected, names)
+	}
+	for i := range expected {
+		if names[i] != expected[i] {
+			t.Errorf("Expected columns %v, got %v", expected, names)
+		}
+	}
+
+	rows, columns, err := db.Query("SELECT COUNT(*), AVG(age), MAX(birthdate) FROM people")
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	if len(rows) != 1 || rows[0]["COUNT(*)"] != int64(4) {
+		t.Errorf("Unexpected aggregate rows %v", rows)
+	}
+	types := []database.ColumnType{database.COLUMN_TYPE_INT, database.COLUMN_TYPE_DOUBLE, database.COLUMN_TYPE_DATE}
+	for i, column := range columns {
+		if column.Type != types[i] {
+			t.Errorf("Expected %s to be %s, got %s", column.Name, types[i], column.Type)
+		}
+	}
+}
+
+func TestQueryEmptyAndErrors(t *testing.T) {
+	defer cleanupTestDB("testdb")
+	db := newPeopleDB(t)
+
+	rows, columns, err := db.Query("SELECT id FROM people WHERE age > 100")
+	if err != nil || len(rows) != 0 || len(columns) != 1 {
+		t.Errorf("Expected no rows and one column, got %v %v %v", rows, columns, err)
+	}
+	if _, _, err := db.Query("SELECT missing FROM people"); err == nil || err.Error() != "column missing not found" {
+		t.Errorf("Expected a missing column error, got %v", err)
+	}
+	if _, _, err := db.Query("DELETE FROM people"); err == nil {
+		t.Error("Expected Query to refuse a DELETE")
+	}
+	if rows, _, _ := db.Query("SELECT * FROM people"); len(rows) != 4 {
+		t.Errorf("Expected the DELETE not to run, got %d rows", len(rows))
+	}
+}
+
+func TestColumnAliases(t *testing.T) {
+	defer cleanupTestDB("testdb")
+	db := newBlogDB(t)
+
+	rows, columns, err := db.Query("SELECT name AS username, id as user_id FROM users ORDER BY username DESC")
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	if names := columnNames(columns); len(names) != 2 || names[0] != "username" || names[1] != "user_id" {
+		t.Errorf("Unexpected columns %v", names)
+	}
+	if len(rows) != 3 || rows[0]["username"] != "Carol" || rows[0]["user_id"] != int64(3) || rows[0]["name"] != nil {
+		t.Errorf("Unexpected rows %v", rows)
+	}
+
+	joined := selectRows(t, db, "SELECT posts.title AS post, users.name AS author FROM posts JOIN users ON posts.user_id = users.id WHERE users.id = 2")
+	if len(joined) != 1 || joined[0]["post"] != "World" || joined[0]["author"] != "Bob" {
+		t.Errorf("Unexpected joined rows %v", joined)
+	}
+
+	grouped, _, err := db.Query("SELECT user_id AS author, COUNT(*) AS total FROM posts GROUP BY user_id ORDER BY total DESC")
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	if len(grouped) != 2 || grouped[0]["author"] != int64(1) || grouped[0]["total"] != int64(2) {
+		t.Errorf("Unexpected groups %v", grouped)
+	}
+	if row := selectAggregate(t, db, "SELECT MAX(post_id) AS latest FROM posts"); row["latest"] != float64(12) {
+		t.Errorf("Unexpected aggregate %v", row)
+	}
+
+	for _, query := range []string{
+		"SELECT name AS n, id AS n FROM users",
+		"SELECT name, id AS name FROM users",
+		"SELECT name AS FROM users",
+		"SELECT name AS a b FROM users",
+	} {
+		if _, err := db.Execute(query); err == nil {
+			t.Errorf("Expected an error for %q", query)
+		}
+	}
+}
+
+func TestSelectExpressions(t *testing.T) {
+	defer cleanupTestDB("testdb")
+	db, err := database.NewDatabase("testdb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = db.Execute("CREATE TABLE line_items (id INT, price DOUBLE, quantity INT, discount DOUBLE)")
+	_, _ = db.Execute("INSERT INTO line_items (id, price, quantity, discount) VALUES (1, 2.5, 4, 0.5)")
+	_, _ = db.Execute("INSERT INTO line_items (id, price, quantity) VALUES (2, 10, 3)")
+
+	rows, columns, err := db.Query("SELECT id, id * 2, price * quantity AS total, price - discount, quantity / 2 FROM line_items ORDER BY total DESC")
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	types := make(map[string]database.ColumnType)
+	for _, column := range columns {
+		types[column.Name] = column.Type
+	}
+	if types["id * 2"] != database.COLUMN_TYPE_INT || types["total"] != database.COLUMN_TYPE_DOUBLE || types["quantity / 2"] != database.COLUMN_TYPE_DOUBLE {
+		t.Errorf("Unexpected column types %v", types)
+	}
+	expected := []database.Row{
+		{"id": int64(2), "id * 2": int64(4), "total": 30.0, "price - discount": nil, "quantity / 2": 1.5},
+		{"id": int64(1), "id * 2": int64(2), "total": 10.0, "price - discount": 2.0, "quantity / 2": 2.0},
+	}
+	if len(rows) != len(expected) {
+		t.Fatalf("Expected %d rows, got %v", len(expected), rows)
+	}
+	for i, want := range expected {
+		for col, val := range want {
+			if got, exists := rows[i][col]; !exists || got != val {
+				t.Errorf("Row %d: expected %s = %T %v, got %v", i, col, val, val, rows[i])
+			}
+		}
+	}
+
+	tests := []struct {
+		query string
+		err   string
+	}{
+		{"SELECT id / (quantity - quantity) FROM line_items", "division by zero"},
+		{"SELECT id + missing FROM line_items", "column missing not found"},
+	}
+	for _, tt := range tests {
+		if _, err := db.Execute(tt.query); err == nil || err.Error() != tt.err {
+			t.Errorf("Expected error %q for %q, got %v", tt.err, tt.query, err)
+		}
+	}
+	_, _ = db.Execute("CREATE TABLE tags (id INT, label VARCHAR)")
+	if _, err := db.Execute("SELECT label * 2 FROM tags"); err == nil || !strings.Contains(err.Error(), "not numeric") {
+		t.Errorf("Expected a non-numeric error, got %v", err)
+	}
+}
+
+func TestSelectFunctions(t *testing.T) {
+	defer cleanupTestDB("testdb")
+	db := newBlogDB(t)
+	_, _ = db.Execute("INSERT INTO posts (post_id, user_id) VALUES (13, 3)")
+
+	rows, columns, err := db.Query("SELECT post_id, UPPER(title), lower(title) AS quiet, LENGTH(title), SUBSTR(title, 2, 3) FROM posts ORDER BY post_id")
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	types := make(map[string]database.ColumnType)
+	for _, column := range columns {
+		types[column.Name] = column.Type
+	}
+	if types["UPPER(title)"] != database.COLUMN_TYPE_VARCHAR || types["LENGTH(title)"] != database.COLUMN_TYPE_INT {
+		t.Errorf("Unexpected column types %v", types)
+	}
+	expected := []database.Row{
+		{"UPPER(title)": "HELLO", "quiet": "hello", "LENGTH(title)": int64(5), "SUBSTR(title, 2, 3)": "ell"},
+		{"UPPER(title)": "AGAIN", "quiet": "again", "LENGTH(title)": int64(5), "SUBSTR(title, 2, 3)": "gai"},
+		{"UPPER(title)": "WORLD", "quiet": "world", "LENGTH(title)": int64(5), "SUBSTR(title, 2, 3)": "orl"},
+		// A missing title gives NULL
+		{"UPPER(title)": nil, "quiet": nil, "LENGTH(title)": nil, "SUBSTR(title, 2, 3)": nil},
+	}
+	if len(rows) != len(expected) {
+		t.Fatalf("Expected %d rows, got %v", len(expected), rows)
+	}
+	for i, want := range expected {
+		for col, val := range want {
+			if got, exists := rows[i][col]; !exists || got != val {
+				t.Errorf("Row %d: expected %s = %v, got %v", i, col, val, rows[i])
+			}
+		}
+	}
+
+	// SUBSTR counts from 1 and clamps its bounds to the string
+	tests := []struct {
+		expr     string
+		expected string
+	}{
+		{"SUBSTR(title, 1, 2)", "He"},
+		{"SUBSTR(title, 3)", "llo"},
+		{"SUBSTR(title, 0, 2)", "H"},
+		{"SUBSTR(title, -5, 100)", "Hello"},
+		{"SUBSTR(title, 4, 100)", "lo"},
+		{"SUBSTR(title, 10, 2)", ""},
+		{"SUBSTR(title, 2, -1)", ""},
+		{"UPPER(SUBSTR(title, 1, 1))", "H"},
+	}
+	for _, tt := range tests {
+		rows, _, err := db.Query("SELECT " + tt.expr + " FROM posts WHERE post_id = 10")
+		if err != nil || len(rows) != 1 || rows[0][tt.expr] != tt.expected {
+			t.Errorf("%s: expected %q, got %v (%v)", tt.expr, tt.expected, rows, err)
+		}
+	}
+	assertIDs(t, selectIDs(t, db, "SELECT * FROM users WHERE SUBSTR(name, 1, 1) = 'C'"), 3)
+
+	for _, query := range []string{
+		"SELECT REVERSE(title) FROM posts",
+		"SELECT SUBSTR(title) FROM posts",
+		"SELECT UPPER(title, 1) FROM posts",
+		"SELECT UPPER(missing) FROM posts",
+		"SELECT SUBSTR(title, 'a') FROM posts",
+	} {
+		if _, err := db.Execute(query); err == nil {
+			t.Errorf("Expected an error for %q", query)
+		}
+	}
+}
+
+func TestConcat(t *testing.T) {
+	defer cleanupTestDB("testdb")
+	db := newBlogDB(t)
+	_, _ = db.Execute("INSERT INTO posts (post_id, user_id) VALUES (13, 3)")
+
+	rows, columns, err := db.Query("SELECT CONCAT(title, ' #', post_id) AS label, CONCAT(title) FROM posts ORDER BY post_id")
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	if columns[0].Type != database.COLUMN_TYPE_VARCHAR {
+		t.Errorf("Expected a VARCHAR column, got %v", columns[0].Type)
+	}
+	// Numbers are formatted as Row.String does, a missing title adds nothing
+	expected := []string{"Hello #10", "Again #11", "World #12", " #13"}
+	if len(rows) != len(expected) {
+		t.Fatalf("Expected %d rows, got %v", len(expected), rows)
+	}
+	for i, label := range expected {
+		if rows[i]["label"] != label {
+			t.Errorf("Row %d: expected label %q, got %v", i, label, rows[i])
+		}
+	}
+	if rows[3]["CONCAT(title)"] != "" {
+		t.Errorf("Expected an empty string for a missing title, got %v", rows[3])
+	}
+
+	assertIDs(t, selectIDs(t, db, "SELECT * FROM users WHERE CONCAT(name, '-', id) = 'Bob-2'"), 2)
+
+	for _, query := range []string{
+		"SELECT CONCAT() FROM posts",
+		"SELECT CONCAT(title, missing) FROM posts",
+		"SELECT * FROM users WHERE CONCAT(name, missing) = 'Bob'",
+	} {
+		if _, err := db.Execute(query); err == nil {
+			t.Errorf("Expected an error for %q", query)
+		}
+	}
+}
+
+func TestNumericFunctions(t *testing.T) {
+	defer cleanupTestDB("testdb")
+	db, err := database.NewDatabase("testdb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = db.Execute("CREATE TABLE readings (id INT, value DOUBLE, level FLOAT, total INT, label VARCHAR, taken DATE)")
+	_, _ = db.Execute("INSERT INTO readings (id, value, level, total, label, taken) VALUES (1, 2.456, 1.5, -1250, 'a', '2024-01-01')")
+	_, _ = db.Execute("INSERT INTO readings (id, value, level, total, label, taken) VALUES (2, -7.5, -0.25, 349, 'b', '2024-01-02')")
+	_, _ = db.Execute("INSERT INTO readings (id, value, total, label) VALUES (3, 0.5, 51, 'c')")
+
+	rows, columns, err := db.Query("SELECT ROUND(value, 2), ROUND(value) AS whole, ABS(total), ROUND(total, -2) AS hundreds, ABS(level) FROM readings ORDER BY id")
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	for _, column := range columns {
+		if column.Type != database.COLUMN_TYPE_DOUBLE {
+			t.Errorf("Expected %s to be DOUBLE, got %v", column.Name, column.Type)
+		}
+	}
+	expected := []database.Row{
+		{"ROUND(value, 2)": 2.46, "whole": 2.0, "ABS(total)": 1250.0, "hundreds": -1300.0, "ABS(level)": 1.5},
+		{"ROUND(value, 2)": -7.5, "whole": -8.0, "ABS(total)": 349.0, "hundreds": 300.0, "ABS(level)": 0.25},
+		// Halves round away from zero, a missing level gives NULL
+		{"ROUND(value, 2)": 0.5, "whole": 1.0, "ABS(total)": 51.0, "hundreds": 100.0, "ABS(level)": nil},
+	}
+	if len(rows) != len(expected) {
+		t.Fatalf("Expected %d rows, got %v", len(expected), rows)
+	}
+	for i, want := range expected {
+		for col, val := range want {
+			if got, exists := rows[i][col]; !exists || got != val {
+				t.Errorf("Row %d: expected %s = %v, got %v", i, col, val, rows[i])
+			}
+		}
+	}
+
+	assertIDs(t, selectIDs(t, db, "SELECT * FROM readings WHERE ABS(value) > 1 ORDER BY id"), 1, 2)
+	assertIDs(t, selectIDs(t, db, "SELECT * FROM readings WHERE ROUND(total, -2) = 300"), 2)
+	assertIDs(t, selectIDs(t, db, "SELECT * FROM readings ORDER BY ABS(value) DESC"), 2, 1, 3)
+	assertIDs(t, selectIDs(t, db, "SELECT * FROM readings ORDER BY ROUND(total, -3), id DESC"), 1, 3, 2)
+
+	for _, query := range []string{
+		"SELECT ROUND(label) FROM readings",
+		"SELECT ABS(taken) FROM readings",
+		"SELECT * FROM readings WHERE ABS(label) > 1",
+		"SELECT * FROM readings ORDER BY ROUND(label, 1)",
+	} {
+		_, err := db.Execute(query)
+		if err == nil || !strings.Contains(err.Error(), "needs a number") {
+			t.Errorf("Expected an error naming the function and column for %q, got %v", query, err)
+		}
+	}
+	if _, err := db.Execute("SELECT ROUND(value, 1.5) FROM readings"); err == nil {
+		t.Error("Expected an error for a fractional count of decimal places")
+	}
+}
+
+func TestDateFunctions(t *testing.T) {
+	defer cleanupTestDB("testdb")
+	db, err := database.NewDatabase("testdb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = db.Execute("CREATE TABLE tasks (id INT, due DATE, done TIMESTAMP, note VARCHAR)")
+	_, _ = db.Execute("INSERT INTO tasks (id, due, done, note) VALUES (1, '2024-01-10', '2024-01-09 23:30:00', '2024-03-01')")
+	_, _ = db.Execute("INSERT INTO tasks (id, due, done, note) VALUES (2, '2024-02-01', '2024-02-03 08:00:00', 'soon')")
+	_, _ = db.Execute("INSERT INTO tasks (id, due) VALUES (3, '2023-12-25')")
+
+	rows, columns, err := db.Query("SELECT id, DATEDIFF(due, '2024-01-01') AS days, DATE_ADD(due, 7) AS week_later, DATEDIFF(done, due) AS late FROM tasks ORDER BY id")
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	if types := []database.ColumnType{columns[1].Type, columns[2].Type}; types[0] != database.COLUMN_TYPE_INT || types[1] != database.COLUMN_TYPE_DATE {
+		t.Errorf("Expected INT and DATE columns, got %v", types)
+	}
+	expected := []database.Row{
+		{"days": int64(9), "week_later": time.Date(2024, 1, 17, 0, 0, 0, 0, time.UTC), "late": int64(-1)},
+		{"days": int64(31), "week_later": time.Date(2024, 2, 8, 0, 0, 0, 0, time.UTC), "late": int64(2)},
+		{"days": int64(-7), "week_later": time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), "late": nil},
+	}
+	for i, want := range expected {
+		for col, val := range want {
+			if got, exists := rows[i][col]; !exists || got != val {
+				t.Errorf("Row %d: expected %s = %v, got %v", i, col, val, rows[i])
+			}
+		}
+	}
+
+	assertIDs(t, selectIDs(t, db, "SELECT * FROM tasks WHERE due < DATE_ADD('2024-01-01', 14) ORDER BY id"), 1, 3)
+	assertIDs(t, selectIDs(t, db, "SELECT * FROM tasks WHERE DATEDIFF(due, '2024-01-01') > 0 ORDER BY id"), 1, 2)
+	assertIDs(t, selectIDs(t, db, "SELECT * FROM tasks WHERE due < DATE_ADD(NOW(), -1) ORDER BY DATEDIFF(due, '2024-01-01')"), 3, 1, 2)
+
+	// A value that is not a date stops the statement and is named in the error
+	_, err = db.Execute("SELECT DATEDIFF(note, due) FROM tasks")
+	if err == nil || !strings.Contains(err.Error(), "'soon'") {
+		t.Errorf("Expected an error naming the invalid date, got %v", err)
+	}
+	for _, query := range []string{
+		"SELECT DATE_ADD(id, 1) FROM tasks",
+		"SELECT DATEDIFF(due, id) FROM tasks",
+		"SELECT DATE_ADD(due, 1.5) FROM tasks",
+	} {
+		if _, err := db.Execute(query); err == nil {
+			t.Errorf("Expected an error for %q", query)
+		}
+	}
+}
+
+func TestSelectFormatCSV(t *testing.T) {
+	defer cleanupTestDB("testdb")
+	db := newBlogDB(t)
+	_, _ = db.Execute("INSERT INTO posts (post_id, user_id, title) VALUES (13, 3, 'Say \"hi\", then leave')")
+	_, _ = db.Execute("INSERT INTO posts (post_id, user_id, title) VALUES (14, 3, NULL)")
+
+	res, err := db.Execute("SELECT title, post_id AS id FROM posts WHERE post_id > 11 ORDER BY post_id FORMAT CSV")
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	// Columns follow the SELECT list, fields with commas, quotes or line
+	// breaks are quoted and nulls are empty
+	expected := "title,id\nWorld,12\n\"Say \"\"hi\"\", then leave\",13\n,14\n"
+	if res != expected {
+		t.Errorf("Expected CSV\n%s\ngot\n%s", expected, res)
+	}
+
+	res, err = db.Execute("SELECT * FROM users WHERE id > 5 format csv")
+	if err != nil || res != "id,name\n" {
+		t.Errorf("Expected the header alone for no rows, got %q (%v)", res, err)
+	}
+	res, err = db.Execute("SELECT name FROM users WHERE id = 1 FORMAT JSON")
+	if err != nil || !strings.Contains(res, `"name": "Alice"`) {
+		t.Errorf("Expected JSON output, got %q (%v)", res, err)
+	}
+
+	rows, columns, err := db.Query("SELECT name, id FROM users ORDER BY id DESC LIMIT 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows = append(rows, database.Row{"name": "two\nlines", "id": int64(4)})
+	var out strings.Builder
+	if err := database.WriteCSV(&out, rows, columns); err != nil || out.String() != "name,id\nCarol,3\n\"two\nlines\",4\n" {
+		t.Errorf("Expected the rows as CSV, got %q (%v)", out.String(), err)
+	}
+}
+
+func TestNowPerStatement(t *testing.T) {
+	db, err := database.NewDatabase("testdb", database.WithInMemory())
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = db.Execute("CREATE TABLE events (id INT, ts TIMESTAMP)")
+	rows := make([][]string, 20000)
+	for i := range rows {
+		rows[i] = []string{strconv.Itoa(i), "NOW()"}
+	}
+
+	// Each statement starts just before a second turns, so that reading the
+	// clock for every row would see two values
+	time.Sleep(time.Until(time.Now().Truncate(time.Second).Add(time.Second)) - 2*time.Millisecond)
+	if _, _, err := db.InsertRows("events", []string{"id", "ts"}, rows, false); err != nil {
+		t.Fatalf("Insert failed: %v", err)
+	}
+	time.Sleep(time.Until(time.Now().Truncate(time.Second).Add(time.Second)) - 2*time.Millisecond)
+	result, _, err := db.Query("SELECT ts, NOW() AS now FROM events WHERE ts <= NOW() AND CURRENT_DATE <= ts")
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	if len(result) != len(rows) {
+		t.Fatalf("Expected all %d rows, got %d", len(rows), len(result))
+	}
+	for _, row := range result {
+		if row["ts"] != result[0]["ts"] || row["now"] != result[0]["now"] {
+			t.Fatalf("Expected one time per statement, got %v and %v", result[0], row)
+		}
+	}
+}
+
+func TestSelectWithoutFrom(t *testing.T) {
+	db, err := database.NewDatabase("testdb", database.WithInMemory())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rows, columns, err := db.Query("SELECT 1 + 1 AS two, UPPER('hello'), ROUND(2.567, 1), DATEDIFF('2024-03-01', '2024-02-01') days")
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	expected := database.Row{"two": int64(2), "UPPER('hello')": "HELLO", "ROUND(2.567, 1)": 2.6, "days": int64(29)}
+	if len(rows) != 1 || len(rows[0]) != len(expected) {
+		t.Fatalf("Expected a single row of %d values, got %v", len(expected), rows)
+	}
+	for name, want := range expected {
+		if got := rows[0][name]; got != want {
+			t.Errorf("%s: expected %T %v, got %T %v", name, want, want, got, got)
+		}
+	}
+	if len(columns) != 4 || columns[0].Name != "two" || columns[0].Type != database.COLUMN_TYPE_INT || columns[1].Type != database.COLUMN_TYPE_VARCHAR {
+		t.Errorf("Unexpected columns %v", columns)
+	}
+
+	res, err := db.Execute("SELECT CURRENT_DATE AS today, NOW() AS now")
+	today := time.Now().Format("2006-01-02")
+	if err != nil || !strings.Contains(res, `"today": "`+today+`"`) || !strings.Contains(res, `"now": "`+today) {
+		t.Errorf("Expected today's date, got %q (%v)", res, err)
+	}
+	// The current time is a function call, named as written
+	rows, columns, err = db.Query("SELECT NOW(), CURRENT_DATE")
+	if err != nil || len(rows) != 1 || len(columns) != 2 {
+		t.Fatalf("Query failed: %v %v", rows, err)
+	}
+	if columns[0].Name != "NOW()" || columns[0].Type != database.COLUMN_TYPE_TIMESTAMP || columns[1].Name != "CURRENT_DATE" || columns[1].Type != database.COLUMN_TYPE_DATE {
+		t.Errorf("Expected NOW() and CURRENT_DATE columns, got %v", columns)
+	}
+	if now, ok := rows[0]["NOW()"].(time.Time); !ok || now.Format("2006-01-02") != today {
+		t.Errorf("Expected the time now, got %v", rows[0])
+	}
+	res, err = db.Execute("SELECT 'from' FORMAT CSV")
+	if err != nil || res != "'from'\nfrom\n" {
+		t.Errorf("Expected a CSV row, got %q (%v)", res, err)
+	}
+
+	for _, query := range []string{
+		"SELECT name",
+		"SELECT UPPER(name)",
+		"SELECT *",
+		"SELECT COUNT(*)",
+	} {
+		if _, err := db.Execute(query); err == nil {
+			t.Errorf("Expected an error for a column without FROM in %q", query)
+		}
+	}
+}
+
+// resultKeys returns the keys of the first object in a JSON result, in the
+// order they appear
+func resultKeys(t *testing.T, res string) []string {
+	t.Helper()
+	dec := json.NewDecoder(strings.NewReader(res))
+	for _, want := range []json.Delim{'[', '{'} {
+		if tok, err := dec.Token(); err != nil || tok != want {
+			t.Fatalf("Expected %v in %s, got %v (%v)", want, res, tok, err)
+		}
+	}
+	var keys []string
+	for dec.More() {
+		key, err := dec.Token()
+		if err != nil {
+			t.Fatal(err)
+		}
+		keys = append(keys, key.(string))
+		var val any
+		if err := dec.Decode(&val); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return keys
+}
+
+func TestResultKeyOrder(t *testing.T) {
+	defer cleanupTestDB("testdb")
+	db := newPeopleDB(t)
+
+	for _, tc := range []struct {
+		query string
+		want  []string
+	}{
+		{"SELECT name, id, birthdate FROM people", []string{"name", "id", "birthdate"}},
+		{"SELECT * FROM people", []string{"id", "name", "age", "height", "birthdate"}},
+		{"SELECT age * 2 AS double_age, UPPER(name), id FROM people", []string{"double_age", "UPPER(name)", "id"}},
+		{"SELECT age, COUNT(*) AS total, name FROM people GROUP BY age, name", []string{"age", "total", "name"}},
+		{"SELECT MAX(age), MIN(age) FROM people", []string{"MAX(age)", "MIN(age)"}},
+	} {
+		res, err := db.Execute(tc.query)
+		if err != nil {
+			t.Fatalf("%s failed: %v", tc.query, err)
+		}
+		if got := resultKeys(t, res); !slices.Equal(got, tc.want) {
+			t.Errorf("%s: expected keys %v, got %v", tc.query, tc.want, got)
+		}
+	}
+}
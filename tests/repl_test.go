@@ -0,0 +1,352 @@
+package database_test
+
+import (
+	"errors"
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/AYGA2K/db/internal/database"
+	"github.com/AYGA2K/db/internal/repl"
+)
+
+func TestCompleter(t *testing.T) {
+	defer cleanupTestDB("testdb")
+
+	db, err := database.NewDatabase("testdb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = db.Execute("CREATE TABLE users (id INT, name VARCHAR)")
+	_, _ = db.Execute("CREATE TABLE posts (id INT, title VARCHAR)")
+	completer := repl.NewCompleter(db)
+
+	tests := []struct {
+		name     string
+		line     string
+		pos      int
+		expected []string
+	}{
+		{"Statement keyword", "sel", 3, []string{"select"}},
+		{"Statement keyword upper case", "SEL", 3, []string{"SELECT"}},
+		{"Columns after SELECT", "SELECT ", 7, []string{"*", "id", "name", "title"}},
+		{"Column prefix after SELECT", "SELECT N", 8, []string{"Name"}},
+		{"FROM after a column", "SELECT name ", 12, []string{"FROM"}},
+		{"Tables after FROM", "SELECT * FROM ", 14, []string{"posts", "users"}},
+		{"Table prefix after FROM", "select * from U", 15, []string{"Users"}},
+		{"Tables after INTO", "INSERT INTO p", 13, []string{"posts"}},
+		{"Tables after UPDATE", "UPDATE ", 7, []string{"posts", "users"}},
+		{"Columns of mentioned table after WHERE", "SELECT * FROM users WHERE ", 26, []string{"id", "name"}},
+		{"Cursor in the middle of the line", "SELECT * FROM  WHERE id = 1", 14, []string{"posts", "users"}},
+		{"No completion inside a string", "SELECT * FROM users WHERE name = 'Al", 36, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := completer.Complete(tt.line, tt.pos)
+			if !slices.Equal(got, tt.expected) {
+				t.Errorf("Expected candidates %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestCompleterRefreshAfterDDL(t *testing.T) {
+	defer cleanupTestDB("testdb")
+
+	db, err := database.NewDatabase("testdb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	shell := repl.NewShell(db, &strings.Builder{})
+	shell.Run("CREATE TABLE orders (id INT, total DOUBLE)")
+
+	got := shell.Completer().Complete("SELECT * FROM o", 15)
+	if !slices.Equal(got, []string{"orders"}) {
+		t.Errorf("Expected the new table to be suggested, got %v", got)
+	}
+}
+
+func TestParseConfig(t *testing.T) {
+	dir := t.TempDir()
+	initScript := filepath.Join(dir, "init.sql")
+	if err := os.WriteFile(initScript, []byte("-- seed data\nCREATE TABLE users (id INT);\n\nINSERT INTO users (id) VALUES (1);\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	queryScript := filepath.Join(dir, "query.sql")
+	if err := os.WriteFile(queryScript, []byte("SELECT * FROM users\nSELECT * FROM users FORMAT CSV\nEXPLAIN SELECT * FROM users\nDUMP\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	noEnv := func(string) string { return "" }
+
+	t.Run("Defaults", func(t *testing.T) {
+		cfg, err := repl.ParseConfig(nil, noEnv, io.Discard)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cfg.StorageName() != "testdb" || cfg.Mode != "json" || cfg.ReadOnly {
+			t.Errorf("Unexpected defaults: %+v", cfg)
+		}
+	})
+
+	t.Run("Flags", func(t *testing.T) {
+		cfg, err := repl.ParseConfig([]string{"--db", "app", "--dir", "/var/lib/godb", "--history", "/tmp/h", "--init", initScript}, noEnv, io.Discard)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cfg.StorageName() != "/var/lib/godb/app" || cfg.History != "/tmp/h" {
+			t.Errorf("Unexpected config: %+v", cfg)
+		}
+		if len(cfg.InitStatements) != 2 || cfg.InitStatements[0] != "CREATE TABLE users (id INT)" {
+			t.Errorf("Unexpected init statements: %q", cfg.InitStatements)
+		}
+	})
+
+	t.Run("Environment", func(t *testing.T) {
+		env := map[string]string{"GODB_DB": "envdb", "GODB_DIR": "/data"}
+		cfg, err := repl.ParseConfig(nil, func(key string) string { return env[key] }, io.Discard)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cfg.StorageName() != "/data/envdb" {
+			t.Errorf("Expected the environment to set the storage name, got %s", cfg.StorageName())
+		}
+	})
+
+	t.Run("File", func(t *testing.T) {
+		cfg, err := repl.ParseConfig([]string{"--file", "/srv/app.gob"}, noEnv, io.Discard)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cfg.StorageName() != "/srv/app" {
+			t.Errorf("Unexpected storage name: %s", cfg.StorageName())
+		}
+	})
+
+	t.Run("File with any extension", func(t *testing.T) {
+		path := filepath.Join(dir, "app.db")
+		cfg, err := repl.ParseConfig([]string{"--file", path}, noEnv, io.Discard)
+		if err != nil {
+			t.Fatal(err)
+		}
+		db, err := cfg.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, _ = db.Execute("CREATE TABLE users (id INT)")
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("Expected the database to be saved in %s: %v", path, err)
+		}
+	})
+
+	t.Run("Read-only file is never written", func(t *testing.T) {
+		path := filepath.Join(dir, "readonly.db")
+		cfg, err := repl.ParseConfig([]string{"--read-only", "--file", path}, noEnv, io.Discard)
+		if err != nil {
+			t.Fatal(err)
+		}
+		db, err := cfg.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := db.Execute("CREATE TABLE users (id INT)"); !errors.Is(err, database.ErrReadOnly) {
+			t.Errorf("Expected ErrReadOnly, got %v", err)
+		}
+		if err := db.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("Expected %s not to be created, got %v", path, err)
+		}
+	})
+
+	t.Run("Read-only with a query script", func(t *testing.T) {
+		if _, err := repl.ParseConfig([]string{"--read-only", "--init", queryScript}, noEnv, io.Discard); err != nil {
+			t.Errorf("Expected no error, got: %v", err)
+		}
+	})
+
+	invalid := []struct {
+		name string
+		args []string
+	}{
+		{"Read-only with DDL in init script", []string{"--read-only", "--init", initScript}},
+		{"File with db", []string{"--file", "/srv/app.gob", "--db", "other"}},
+		{"Unknown mode", []string{"--mode", "xml"}},
+		{"Missing init script", []string{"--init", filepath.Join(dir, "missing.sql")}},
+	}
+	for _, tt := range invalid {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := repl.ParseConfig(tt.args, noEnv, io.Discard); err == nil {
+				t.Errorf("Expected an error for %v", tt.args)
+			}
+		})
+	}
+
+	t.Run("Help", func(t *testing.T) {
+		var usage strings.Builder
+		_, err := repl.ParseConfig([]string{"--help"}, noEnv, &usage)
+		if !errors.Is(err, flag.ErrHelp) {
+			t.Errorf("Expected flag.ErrHelp, got: %v", err)
+		}
+		if !strings.Contains(usage.String(), "-read-only") {
+			t.Errorf("Expected usage to document the flags, got: %s", usage.String())
+		}
+	})
+}
+
+func TestImportCSVCreate(t *testing.T) {
+	defer cleanupTestDB("testdb")
+
+	db, err := database.NewDatabase("testdb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "people.csv")
+	csv := "id,name,score,joined,active\n" +
+		"1,\"Smith, Jr.\",9.5,2020-01-02,true\n" +
+		"2,\"She said \"\"hi\"\"\",7,2021-03-04,false\n" +
+		"3,Bob,,2022-05-06,true\n"
+	if err := os.WriteFile(path, []byte(csv), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var out strings.Builder
+	shell := repl.NewShell(db, &out)
+	shell.Run(".import " + path + " people --header --create")
+	if !strings.Contains(out.String(), "3 rows inserted, 0 rejected") {
+		t.Fatalf("Unexpected import output: %s", out.String())
+	}
+
+	tables, _ := db.AllTables()
+	expected := map[string]database.ColumnType{
+		"id":     database.COLUMN_TYPE_INT,
+		"name":   database.COLUMN_TYPE_VARCHAR,
+		"score":  database.COLUMN_TYPE_DOUBLE,
+		"joined": database.COLUMN_TYPE_DATE,
+		"active": database.COLUMN_TYPE_BOOL,
+	}
+	for _, col := range tables["people"].GetColumns() {
+		if col.Type != expected[col.Name] {
+			t.Errorf("Expected column %s to be %s, got %s", col.Name, expected[col.Name], col.Type)
+		}
+	}
+
+	res, err := db.Execute("SELECT * FROM people")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(res, `"name": "Smith, Jr."`) || !strings.Contains(res, `"name": "She said \"hi\""`) {
+		t.Errorf("Expected quoted values to survive the import, got: %s", res)
+	}
+}
+
+func TestImportCSVRejections(t *testing.T) {
+	defer cleanupTestDB("testdb")
+
+	db, err := database.NewDatabase("testdb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = db.Execute("CREATE TABLE scores (id INT, score INT)")
+	path := filepath.Join(t.TempDir(), "scores.csv")
+	if err := os.WriteFile(path, []byte("1;10\n2;abc\n3;30\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var out strings.Builder
+	shell := repl.NewShell(db, &out)
+	shell.Run(".import " + path + " scores --delimiter ;")
+	if !strings.Contains(out.String(), "0 rows inserted, 1 rejected") || !strings.Contains(out.String(), "line 2:") {
+		t.Fatalf("Unexpected import output: %s", out.String())
+	}
+	tables, _ := db.AllTables()
+	if len(tables["scores"].GetRows()) != 0 {
+		t.Errorf("Expected no rows to be kept without --partial, got %d", len(tables["scores"].GetRows()))
+	}
+
+	out.Reset()
+	shell.Run(".import " + path + " scores --delimiter ; --partial")
+	if !strings.Contains(out.String(), "2 rows inserted, 1 rejected") {
+		t.Fatalf("Unexpected import output: %s", out.String())
+	}
+}
+
+func TestImportCSVReadOnly(t *testing.T) {
+	defer cleanupTestDB("testdb")
+
+	db, err := database.NewDatabase("testdb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "scores.csv")
+	if err := os.WriteFile(path, []byte("id,score\n1,10\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var out strings.Builder
+	shell := repl.NewShell(db, &out)
+	shell.SetReadOnly(true)
+	shell.Run(".import " + path + " scores --header --create")
+	if !strings.Contains(out.String(), "Error: database is opened read-only") {
+		t.Fatalf("Expected .import to be refused, got: %s", out.String())
+	}
+	if tables, _ := db.AllTables(); len(tables) != 0 {
+		t.Errorf("Expected no table to be created, got %v", tables)
+	}
+
+	// Commands that only change the shell still work
+	out.Reset()
+	shell.Run(".timing on")
+	if out.Len() != 0 {
+		t.Errorf("Expected .timing to be allowed, got: %s", out.String())
+	}
+}
+
+func TestVerticalMode(t *testing.T) {
+	defer cleanupTestDB("testdb")
+
+	db, err := database.NewDatabase("testdb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = db.Execute("CREATE TABLE accounts (id INT, owner VARCHAR, email VARCHAR, country VARCHAR, balance DOUBLE, verified BOOL, opened DATE, plan VARCHAR)")
+	_, _ = db.Execute("INSERT INTO accounts (id, owner, email, country, balance, verified, opened, plan) VALUES (1, 'Alice', 'alice@example.com', 'Morocco', 1500.25, true, '2020-01-15', 'premium')")
+	_, _ = db.Execute("INSERT INTO accounts (id, owner, email, country, balance, verified, opened, plan) VALUES (2, 'Bob', 'bob@example.com', 'France', 20, false, '2021-06-30', 'free')")
+
+	golden, err := os.ReadFile(filepath.Join("testdata", "vertical.golden"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out strings.Builder
+	shell := repl.NewShell(db, &out)
+	shell.Run(`SELECT * FROM accounts\G`)
+	if out.String() != string(golden) {
+		t.Errorf("Unexpected \\G output:\n%s\nexpected:\n%s", out.String(), golden)
+	}
+
+	out.Reset()
+	shell.Run(".mode vertical")
+	shell.Run("SELECT * FROM accounts")
+	if out.String() != string(golden) {
+		t.Errorf("Unexpected .mode vertical output:\n%s\nexpected:\n%s", out.String(), golden)
+	}
+
+	out.Reset()
+	shell.Run("SELECT id, owner FROM accounts WHERE id = 2")
+	if want := strings.Repeat("*", 27) + " 1. row " + strings.Repeat("*", 27) + "\n   id: 2\nowner: Bob\n1 row in set\n"; out.String() != want {
+		t.Errorf("Unexpected vertical output for one row:\n%s\nexpected:\n%s", out.String(), want)
+	}
+
+	out.Reset()
+	shell.Run(".mode json")
+	shell.Run("SELECT * FROM accounts")
+	if !strings.HasPrefix(out.String(), "[") {
+		t.Errorf("Expected JSON output after .mode json, got:\n%s", out.String())
+	}
+}
@@ -0,0 +1,135 @@
+package database_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/AYGA2K/db/internal/database"
+	"github.com/AYGA2K/db/internal/repl"
+)
+
+func newSafeShell(t *testing.T, input string) (*database.Database, *repl.Shell, *strings.Builder) {
+	t.Helper()
+	db, err := database.NewDatabase("testdb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = db.Execute("CREATE TABLE users (id INT, name VARCHAR)")
+	_, _ = db.Execute("INSERT INTO users (id, name) VALUES (1, 'Alice')")
+	_, _ = db.Execute("INSERT INTO users (id, name) VALUES (2, 'Bob')")
+
+	out := &strings.Builder{}
+	shell := repl.NewShell(db, out)
+	shell.SetSafe(true)
+	shell.SetInput(repl.ReaderInput(strings.NewReader(input), out))
+	return db, shell, out
+}
+
+func TestSafeModeConfirmed(t *testing.T) {
+	defer cleanupTestDB("testdb")
+	db, shell, out := newSafeShell(t, "yes\n")
+
+	shell.Run("DROP TABLE users")
+	if !strings.Contains(out.String(), "would remove table users and its 2 rows") {
+		t.Errorf("Expected a warning with the affected rows, got: %s", out.String())
+	}
+	if _, exists := db.Tables["users"]; exists {
+		t.Errorf("Expected the table to be dropped after confirming")
+	}
+}
+
+func TestSafeModeCancelled(t *testing.T) {
+	defer cleanupTestDB("testdb")
+	db, shell, out := newSafeShell(t, "no\n")
+
+	shell.Run("DELETE FROM users")
+	if !strings.Contains(out.String(), "DELETE without WHERE would remove all 2 rows of users") ||
+		!strings.Contains(out.String(), "Cancelled") {
+		t.Errorf("Expected the delete to be cancelled, got: %s", out.String())
+	}
+	if len(db.Tables["users"].Rows) != 2 {
+		t.Errorf("Expected no rows to be deleted, got %d rows", len(db.Tables["users"].Rows))
+	}
+}
+
+func TestSafeModeTruncate(t *testing.T) {
+	defer cleanupTestDB("testdb")
+	db, shell, out := newSafeShell(t, "no\n")
+
+	shell.Run("TRUNCATE TABLE users")
+	if !strings.Contains(out.String(), "TRUNCATE TABLE would remove all 2 rows of users") {
+		t.Errorf("Expected a warning with the affected rows, got: %s", out.String())
+	}
+	if len(db.Tables["users"].Rows) != 2 {
+		t.Errorf("Expected no rows to be removed, got %d rows", len(db.Tables["users"].Rows))
+	}
+}
+
+func TestSafeModeUpdateWithoutWhere(t *testing.T) {
+	defer cleanupTestDB("testdb")
+	db, shell, out := newSafeShell(t, "no\n")
+
+	shell.Run("UPDATE users SET name = 'Carol'")
+	if !strings.Contains(out.String(), "UPDATE without WHERE would change all 2 rows of users") {
+		t.Errorf("Expected a warning for the unfiltered update, got: %s", out.String())
+	}
+	if db.Tables["users"].Rows[0]["name"] != "Alice" {
+		t.Errorf("Expected the update to be cancelled, got %v", db.Tables["users"].Rows)
+	}
+}
+
+func TestSafeModeFilteredStatement(t *testing.T) {
+	defer cleanupTestDB("testdb")
+	db, shell, out := newSafeShell(t, "")
+
+	shell.Run("UPDATE users SET name = 'Carol' WHERE id = 1")
+	if db.Tables["users"].Rows[0]["name"] != "Carol" {
+		t.Errorf("Expected the update to run, got %v", db.Tables["users"].Rows)
+	}
+	shell.Run("DELETE FROM users WHERE 1=1")
+	if strings.Contains(out.String(), "Warning") {
+		t.Errorf("Expected filtered statements to run without confirmation, got: %s", out.String())
+	}
+	if len(db.Tables["users"].Rows) != 0 {
+		t.Errorf("Expected all rows to be deleted, got %d rows", len(db.Tables["users"].Rows))
+	}
+}
+
+func TestSafeModeParsedStatement(t *testing.T) {
+	defer cleanupTestDB("testdb")
+	db, shell, out := newSafeShell(t, "no\n")
+
+	shell.Run("UPDATE users SET name = 'x WHERE id = 1'")
+	if !strings.Contains(out.String(), "UPDATE without WHERE would change all 2 rows of users") {
+		t.Errorf("Expected a WHERE inside a string not to count as a filter, got: %s", out.String())
+	}
+	if db.Tables["users"].Rows[0]["name"] != "Alice" {
+		t.Errorf("Expected the update to be cancelled, got %v", db.Tables["users"].Rows)
+	}
+
+	out.Reset()
+	shell.Run("DROP TABLE nope")
+	if strings.Contains(out.String(), "Warning") || !strings.Contains(out.String(), "table nope does not exist") {
+		t.Errorf("Expected dropping a missing table to fail without confirmation, got: %s", out.String())
+	}
+}
+
+func TestSafeModeNonInteractive(t *testing.T) {
+	defer cleanupTestDB("testdb")
+	db, shell, out := newSafeShell(t, "")
+	shell.SetInteractive(false)
+
+	shell.Run("DROP TABLE users")
+	if !strings.Contains(out.String(), "pass --force") {
+		t.Errorf("Expected the statement to be refused, got: %s", out.String())
+	}
+	if _, exists := db.Tables["users"]; !exists {
+		t.Fatalf("Expected the table to survive")
+	}
+
+	shell.SetForce(true)
+	shell.Run("DROP TABLE users")
+	if _, exists := db.Tables["users"]; exists {
+		t.Errorf("Expected --force to allow the statement")
+	}
+}
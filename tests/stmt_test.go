@@ -0,0 +1,150 @@
+package database_test
+
+import (
+	"testing"
+
+	"github.com/AYGA2K/db/internal/database"
+)
+
+func TestPreparedInsert(t *testing.T) {
+	defer cleanupTestDB("testdb")
+	db, err := database.NewDatabase("testdb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = db.Execute("CREATE TABLE people (id INT, name VARCHAR, score DOUBLE)")
+
+	insert, err := db.Prepare("INSERT INTO people (id, name, score) VALUES (?, ?, ?)")
+	if err != nil {
+		t.Fatalf("Prepare failed: %v", err)
+	}
+	if _, err := insert.Exec(1, "Smith, Jr. 'Junior'", 9.5); err != nil {
+		t.Fatalf("Exec failed: %v", err)
+	}
+	if _, err := insert.Exec(int64(2), "O'Brien", nil); err != nil {
+		t.Fatalf("Exec failed: %v", err)
+	}
+	if _, err := insert.Exec(3, "Too few"); err == nil {
+		t.Error("Expected an error for a missing argument")
+	}
+	if _, err := insert.Exec("three", "Bad", 1.0); err == nil {
+		t.Error("Expected an error binding a non-numeric string to an INT column")
+	}
+
+	query, err := db.Prepare("SELECT * FROM people WHERE name = ?")
+	if err != nil {
+		t.Fatalf("Prepare failed: %v", err)
+	}
+	rows, _, err := query.Query("Smith, Jr. 'Junior'")
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	if len(rows) != 1 || rows[0]["id"] != int64(1) || rows[0]["score"] != 9.5 {
+		t.Errorf("Expected Smith's row, got %v", rows)
+	}
+	rows, _, err = query.Query("O'Brien")
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	if len(rows) != 1 || rows[0]["score"] != nil {
+		t.Errorf("Expected O'Brien with a null score, got %v", rows)
+	}
+
+	// Operators and keywords in an argument are part of its value
+	for i, name := range []string{"a<=b", "x != y", "p AND q", "' OR '1'='1", "(z)", "a ORDER BY id", "b GROUP BY c", "x WHERE y", "s FROM t LIMIT 1"} {
+		if _, err := insert.Exec(10+i, name, nil); err != nil {
+			t.Fatalf("Exec failed: %v", err)
+		}
+		rows, _, err := query.Query(name)
+		if err != nil {
+			t.Fatalf("Query for %q failed: %v", name, err)
+		}
+		if len(rows) != 1 || rows[0]["id"] != int64(10+i) {
+			t.Errorf("Expected the row named %q, got %v", name, rows)
+		}
+	}
+
+	// and so are they in the literals of a statement
+	if _, err := db.Execute("UPDATE people SET score = 1 WHERE name = 'x WHERE y'"); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	rows, _, err = db.Query("SELECT id, score FROM people WHERE name = 'a ORDER BY id' OR score = 1 ORDER BY id DESC")
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	if len(rows) != 2 || rows[0]["id"] != int64(17) || rows[1]["id"] != int64(15) {
+		t.Errorf("Expected the rows named 'x WHERE y' and 'a ORDER BY id', got %v", rows)
+	}
+	if res, err := db.Execute("DELETE FROM people WHERE name = 's FROM t LIMIT 1'"); err != nil || res != "1 row deleted" {
+		t.Errorf("Expected one row deleted, got %q %v", res, err)
+	}
+}
+
+func TestPreparedUpdate(t *testing.T) {
+	defer cleanupTestDB("testdb")
+	db := newPeopleDB(t)
+
+	update, err := db.Prepare("UPDATE people SET name = ?, age = 50 WHERE name = ?")
+	if err != nil {
+		t.Fatalf("Prepare failed: %v", err)
+	}
+	if update.NumInput() != 2 {
+		t.Errorf("Expected 2 placeholders, got %d", update.NumInput())
+	}
+	if _, err := update.Exec("Alice, 'Al'", "Alice"); err != nil {
+		t.Fatalf("Exec failed: %v", err)
+	}
+	rows, _, err := db.Query("SELECT age FROM people WHERE name = 'Alice, ''Al'''")
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	if len(rows) != 1 || rows[0]["age"] != int64(50) {
+		t.Errorf("Expected the renamed row with age 50, got %v", rows)
+	}
+
+	// SET takes arithmetic over the row, with placeholders among its operands
+	bump, err := db.Prepare("UPDATE people SET age = (age + ?) * 2, height = height - ? WHERE id = ?")
+	if err != nil {
+		t.Fatalf("Prepare failed: %v", err)
+	}
+	if bump.NumInput() != 3 {
+		t.Errorf("Expected 3 placeholders, got %d", bump.NumInput())
+	}
+	if _, err := bump.Exec(1, 0.5, 2); err != nil {
+		t.Fatalf("Exec failed: %v", err)
+	}
+	rows, _, err = db.Query("SELECT age, height FROM people WHERE id = 2")
+	if err != nil || len(rows) != 1 || rows[0]["age"] != int64(62) || rows[0]["height"] != 1.3 {
+		t.Errorf("Expected age 62 and height 1.3, got %v (%v)", rows, err)
+	}
+	if _, err := bump.Exec(0.5, 0, 2); err == nil {
+		t.Error("Expected an error storing a fraction in an INT column")
+	}
+	if _, err := bump.Exec("ten", 0, 2); err == nil {
+		t.Error("Expected an error for text in arithmetic")
+	}
+	if _, err := bump.Exec(nil, 0, 2); err != nil {
+		t.Fatalf("Exec failed: %v", err)
+	}
+	if rows, _, _ := db.Query("SELECT * FROM people WHERE id = 2 AND age IS NULL"); len(rows) != 1 {
+		t.Errorf("Expected arithmetic on NULL to store NULL, got %v", rows)
+	}
+	rename, err := db.Prepare("UPDATE people SET name = name + ? WHERE id = 1")
+	if err != nil {
+		t.Fatalf("Prepare failed: %v", err)
+	}
+	if _, err := rename.Exec(1); err == nil {
+		t.Error("Expected an error for arithmetic on a VARCHAR column")
+	}
+
+	remove, err := db.Prepare("DELETE FROM people WHERE age > ?")
+	if err != nil {
+		t.Fatalf("Prepare failed: %v", err)
+	}
+	if _, err := remove.Exec(45); err != nil {
+		t.Fatalf("Exec failed: %v", err)
+	}
+	if rows, _, _ := db.Query("SELECT * FROM people WHERE age = 50"); len(rows) != 0 {
+		t.Errorf("Expected the row to be deleted, got %v", rows)
+	}
+}
@@ -0,0 +1,371 @@
+package database_test
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/AYGA2K/db/internal/database"
+)
+
+func TestInMemoryDatabase(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "app")
+	db, err := database.NewDatabase(name, database.WithInMemory())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, sql := range []string{
+		"CREATE TABLE users (id INT PRIMARY KEY, name VARCHAR)",
+		"CREATE INDEX users_name ON users (name)",
+		"INSERT INTO users (id, name) VALUES (1, 'Alice')",
+		"INSERT INTO users (id, name) VALUES (2, 'Bob')",
+		"UPDATE users SET name = 'Bobby' WHERE id = 2",
+		"DELETE FROM users WHERE id = 1",
+		"BEGIN",
+		"INSERT INTO users (id, name) VALUES (3, 'Carol')",
+		"COMMIT",
+	} {
+		if _, err := db.Execute(sql); err != nil {
+			t.Fatalf("%s failed: %v", sql, err)
+		}
+	}
+	rows, _, err := db.Query("SELECT name FROM users ORDER BY id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 2 || rows[0]["name"] != "Bobby" || rows[1]["name"] != "Carol" {
+		t.Errorf("Expected Bobby and Carol, got %v", rows)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("Expected no files, found %v", entries)
+	}
+	reopened, err := database.NewDatabase(name, database.WithInMemory())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reopened.Tables) != 0 {
+		t.Errorf("Expected a new in-memory database to start empty, got %v", reopened.Tables)
+	}
+}
+
+func TestStoragePath(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "data", "app.db")
+	if err := os.Mkdir(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	db, err := database.NewDatabase("app", database.WithPath(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = db.Execute("CREATE TABLE users (id INT, name VARCHAR)")
+	if _, err := db.Execute("INSERT INTO users (id, name) VALUES (1, 'Alice')"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("Expected the database at %s: %v", path, err)
+	}
+	if _, err := os.Stat("app.gob"); !os.IsNotExist(err) {
+		t.Errorf("Expected nothing in the working directory, got %v", err)
+	}
+	reopened, err := database.NewDatabase("app", database.WithPath(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count, err := reopened.Tables["users"].Count(""); err != nil || count != 1 {
+		t.Errorf("Expected the reopened database to have 1 user, got %d (%v)", count, err)
+	}
+
+	// A directory keeps the default file name, with the extension of the backend
+	inDir, err := database.NewDatabase("other", database.WithPath(dir), database.WithStorage(database.JSONStorage{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = inDir.Execute("CREATE TABLE items (id INT)")
+	if _, err := os.Stat(filepath.Join(dir, "other.json")); err != nil {
+		t.Errorf("Expected other.json in the directory: %v", err)
+	}
+}
+
+func TestClose(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "app")
+	db, err := database.NewDatabase(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = db.Execute("CREATE TABLE users (id INT, name VARCHAR)")
+	_, _ = db.Execute("INSERT INTO users (id, name) VALUES (1, 'Alice')")
+	_, _ = db.Execute("BEGIN")
+	_, _ = db.Execute("INSERT INTO users (id, name) VALUES (2, 'Bob')")
+	if err := db.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if err := db.Close(); err != nil {
+		t.Errorf("Expected a second Close to do nothing, got %v", err)
+	}
+
+	if _, err := db.Execute("SELECT * FROM users"); !errors.Is(err, database.ErrClosed) {
+		t.Errorf("Expected ErrClosed from Execute, got %v", err)
+	}
+	if _, _, err := db.Query("SELECT * FROM users"); !errors.Is(err, database.ErrClosed) {
+		t.Errorf("Expected ErrClosed from Query, got %v", err)
+	}
+	if _, err := db.Prepare("INSERT INTO users (id) VALUES (?)"); !errors.Is(err, database.ErrClosed) {
+		t.Errorf("Expected ErrClosed from Prepare, got %v", err)
+	}
+	if _, _, err := db.InsertRows("users", []string{"id"}, [][]string{{"3"}}, false); !errors.Is(err, database.ErrClosed) {
+		t.Errorf("Expected ErrClosed from InsertRows, got %v", err)
+	}
+	// Every other method refuses too, before it changes anything
+	if _, err := db.CreateTable("posts", []string{"id INT"}); !errors.Is(err, database.ErrClosed) {
+		t.Errorf("Expected ErrClosed from CreateTable, got %v", err)
+	}
+	if _, err := db.Select("users", []string{"*"}, "", "", "", "", "", ""); !errors.Is(err, database.ErrClosed) {
+		t.Errorf("Expected ErrClosed from Select, got %v", err)
+	}
+	if _, err := db.Begin(); !errors.Is(err, database.ErrClosed) {
+		t.Errorf("Expected ErrClosed from Begin, got %v", err)
+	}
+	if err := db.Dump(io.Discard); !errors.Is(err, database.ErrClosed) {
+		t.Errorf("Expected ErrClosed from Dump, got %v", err)
+	}
+	if _, err := db.AllTables(); !errors.Is(err, database.ErrClosed) {
+		t.Errorf("Expected ErrClosed from AllTables, got %v", err)
+	}
+	if strings.Contains(db.String(), "posts") {
+		t.Errorf("Expected CreateTable to leave a closed database alone, got %s", db)
+	}
+
+	reopened, err := database.NewDatabase(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.Close()
+	rows, _, err := reopened.Query("SELECT * FROM users")
+	if err != nil || len(rows) != 1 || rows[0]["name"] != "Alice" {
+		t.Errorf("Expected the open transaction to be rolled back, got %v (%v)", rows, err)
+	}
+}
+
+func TestCloseUnchanged(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "app")
+	db, err := database.NewDatabase(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if _, err := os.Stat(name + ".gob"); !os.IsNotExist(err) {
+		t.Errorf("Expected an unchanged database not to be written, got %v", err)
+	}
+
+	db, err = database.NewDatabase(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = db.Execute("CREATE TABLE users (id INT)")
+	// Only the changes that are not saved yet are written by Close
+	if err := os.Remove(name + ".gob"); err != nil {
+		t.Fatal(err)
+	}
+	_, _ = db.Execute("SELECT * FROM users")
+	if err := db.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if _, err := os.Stat(name + ".gob"); !os.IsNotExist(err) {
+		t.Errorf("Expected Close not to save again, got %v", err)
+	}
+}
+
+func TestReadOnly(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "app")
+	db, err := database.NewDatabase(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = db.Execute("CREATE TABLE users (id INT)")
+	_, _ = db.Execute("INSERT INTO users (id) VALUES (1)")
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err = database.NewDatabase(name, database.WithReadOnly())
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows, _, err := db.Query("SELECT * FROM users")
+	if err != nil || len(rows) != 1 {
+		t.Errorf("Expected the saved row, got %v (%v)", rows, err)
+	}
+	for _, sql := range []string{"INSERT INTO users (id) VALUES (2)", "DELETE FROM users", "CREATE TABLE posts (id INT)", "BEGIN"} {
+		if _, err := db.Execute(sql); !errors.Is(err, database.ErrReadOnly) {
+			t.Errorf("Expected ErrReadOnly from %s, got %v", sql, err)
+		}
+	}
+	if err := os.Remove(name + ".gob"); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if _, err := os.Stat(name + ".gob"); !os.IsNotExist(err) {
+		t.Errorf("Expected a read-only database never to be written, got %v", err)
+	}
+}
+
+func TestJSONStorageRoundTrip(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "app")
+	db, err := database.NewDatabase(name, database.WithStorage(database.JSONStorage{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = db.Execute("CREATE TABLE items (id INT PRIMARY KEY, name VARCHAR NOT NULL, price DOUBLE, weight FLOAT, active BOOL, added DATE, stock INT DEFAULT 3, cost DECIMAL(8,2))")
+	if _, err := db.Execute("INSERT INTO items (id, name, price, weight, active, added, cost) VALUES (1, 'Lamp, desk', 19.99, 1.5, true, '2024-02-29', 7.1)"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Execute("INSERT INTO items (id, name, price) VALUES (2, 'Rug', NULL)"); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(name + ".json")
+	if err != nil {
+		t.Fatalf("Expected a JSON file: %v", err)
+	}
+	if !strings.Contains(string(data), `"Lamp, desk"`) || !strings.Contains(string(data), `"cost": 7.10`) || !strings.Contains(string(data), `"added": "2024-02-29"`) {
+		t.Errorf("Expected readable values in the file, got %s", data)
+	}
+	if _, err := os.Stat(name + ".gob"); !os.IsNotExist(err) {
+		t.Errorf("Expected no gob file, got %v", err)
+	}
+
+	reopened, err := database.NewDatabase(name, database.WithStorage(database.JSONStorage{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows, _, err := reopened.Query("SELECT * FROM items ORDER BY id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 2 {
+		t.Fatalf("Expected 2 rows, got %v", rows)
+	}
+	expected := database.Row{
+		"id": int64(1), "name": "Lamp, desk", "price": 19.99, "weight": float32(1.5),
+		"active": true, "added": time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC), "stock": int64(3),
+		"cost": database.Decimal{Unscaled: 710, Scale: 2},
+	}
+	for col, want := range expected {
+		if got := rows[0][col]; got != want {
+			t.Errorf("Column %s: expected %T %v, got %T %v", col, want, want, got, got)
+		}
+	}
+	if rows[1]["stock"] != int64(3) {
+		t.Errorf("Expected the default stock, got %T %v", rows[1]["stock"], rows[1]["stock"])
+	}
+	if _, err := reopened.Execute("INSERT INTO items (id, name) VALUES (3, 'Vase')"); err != nil {
+		t.Fatal(err)
+	}
+	if vase, _, _ := reopened.Query("SELECT stock FROM items WHERE id = 3"); len(vase) != 1 || vase[0]["stock"] != int64(3) {
+		t.Errorf("Expected the default to survive the round trip, got %v", vase)
+	}
+	if rows[1]["price"] != nil {
+		t.Errorf("Expected a null price, got %v", rows[1]["price"])
+	}
+
+	// Constraints survive the round trip
+	if _, err := reopened.Execute("INSERT INTO items (id, name) VALUES (1, 'Duplicate')"); err == nil {
+		t.Error("Expected the primary key to still be enforced")
+	}
+	if rows := selectIDs(t, reopened, "SELECT * FROM items WHERE added BETWEEN '2024-01-01' AND '2024-12-31'"); len(rows) != 1 {
+		t.Errorf("Expected the date to compare after reloading, got %v", rows)
+	}
+}
+
+func TestDumpRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	db, err := database.NewDatabase(filepath.Join(dir, "app"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// articles sorts before the table it references
+	for _, sql := range []string{
+		"CREATE TABLE writers (id INT PRIMARY KEY AUTO_INCREMENT, name VARCHAR(20) NOT NULL, mood ENUM('happy', 'it''s fine') DEFAULT 'happy', joined DATE DEFAULT '2020-01-01', balance DECIMAL(8,2), seen TIMESTAMP, active BOOL, score DOUBLE, rate FLOAT)",
+		"CREATE TABLE articles (id INT PRIMARY KEY, writer_id INT FOREIGN KEY REFERENCES writers(id) ON DELETE CASCADE, title VARCHAR UNIQUE)",
+		"CREATE INDEX idx_title ON articles (title)",
+		"INSERT INTO writers (name, mood, balance, seen, active, score, rate) VALUES ('O''Brien, Pat', 'IT''S FINE', 12.5, '2024-03-01 10:30:00', true, 0.1, 1.25)",
+		"INSERT INTO writers (name, joined, score) VALUES ('Lee', '1999-12-31', NULL)",
+		"INSERT INTO articles (id, writer_id, title) VALUES (1, 1, 'Semi; colons')",
+		"INSERT INTO articles (id, writer_id) VALUES (2, 2)",
+	} {
+		if _, err := db.Execute(sql); err != nil {
+			t.Fatalf("%s: %v", sql, err)
+		}
+	}
+
+	dump, err := db.Execute("DUMP")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Index(dump, "CREATE TABLE writers") > strings.Index(dump, "CREATE TABLE articles") {
+		t.Errorf("Expected writers to be created before articles, got\n%s", dump)
+	}
+
+	path := filepath.Join(dir, "backup.sql")
+	if _, err := db.Execute("EXPORT DATABASE TO '" + path + "'"); err != nil {
+		t.Fatal(err)
+	}
+	exported, err := os.ReadFile(path)
+	if err != nil || string(exported) != dump {
+		t.Fatalf("Expected the file to hold the dump, got %q (%v)", exported, err)
+	}
+
+	restored, err := database.NewDatabase(filepath.Join(dir, "restored"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, sql := range strings.Split(dump, ";\n") {
+		if strings.TrimSpace(sql) == "" {
+			continue
+		}
+		if _, err := restored.Execute(sql); err != nil {
+			t.Fatalf("Replaying %q: %v", sql, err)
+		}
+	}
+	for _, table := range []string{"writers", "articles"} {
+		want, wantColumns, err := db.Query("SELECT * FROM " + table + " ORDER BY id")
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, gotColumns, err := restored.Query("SELECT * FROM " + table + " ORDER BY id")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, want) || !reflect.DeepEqual(gotColumns, wantColumns) {
+			t.Errorf("%s: expected\n%v %v\ngot\n%v %v", table, want, wantColumns, got, gotColumns)
+		}
+	}
+	if _, exists := restored.Tables["articles"].Rows[1]["title"]; exists {
+		t.Error("Expected a value left out to stay unset")
+	}
+	if _, err := restored.Execute("INSERT INTO articles (id, writer_id, title) VALUES (3, 1, 'Semi; colons')"); err == nil {
+		t.Error("Expected the UNIQUE constraint to be restored")
+	}
+	if _, err := restored.Execute("DELETE FROM writers WHERE id = 1"); err != nil {
+		t.Fatal(err)
+	}
+	if rows, _, _ := restored.Query("SELECT * FROM articles"); len(rows) != 1 {
+		t.Errorf("Expected ON DELETE CASCADE to be restored, got %v", rows)
+	}
+}
@@ -0,0 +1,64 @@
+package database_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/AYGA2K/db/internal/database"
+	"github.com/AYGA2K/db/internal/repl"
+)
+
+func TestSyntaxErrorPosition(t *testing.T) {
+	defer cleanupTestDB("testdb")
+
+	db, err := database.NewDatabase("testdb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = db.Execute("CREATE TABLE users (id INT, name VARCHAR)")
+
+	tests := []struct {
+		name     string
+		query    string
+		position int
+		near     string
+	}{
+		{"Unclosed string", "SELECT * FROM users WHERE name = 'Alice", 33, "'Alice"},
+		{"Missing FROM", "SELECT id, name users", 16, "users"},
+		{"Misspelled keyword", "SELEC * FROM users", 0, "SELEC"},
+		{"Misspelled clause", "SELECT * FROM users WHER id = 1", 20, "WHER"},
+		{"Bad column definition", "CREATE TABLE posts (id INT, title VARCHR)", 28, "title VARCHR"},
+		{"Missing VALUES", "INSERT INTO users (id) (1)", 23, "("},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := db.Execute(tt.query)
+			var syntaxErr *database.ErrSyntax
+			if !errors.As(err, &syntaxErr) {
+				t.Fatalf("Expected a syntax error, got: %v", err)
+			}
+			if syntaxErr.Position != tt.position || syntaxErr.Near != tt.near {
+				t.Errorf("Expected error at %d near %q, got %d near %q", tt.position, tt.near, syntaxErr.Position, syntaxErr.Near)
+			}
+		})
+	}
+}
+
+func TestSyntaxErrorCaret(t *testing.T) {
+	defer cleanupTestDB("testdb")
+
+	db, err := database.NewDatabase("testdb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out strings.Builder
+	shell := repl.NewShell(db, &out)
+	shell.Run("SELECT * users")
+
+	expected := "  SELECT * users\n           ^\n"
+	if !strings.HasSuffix(out.String(), expected) {
+		t.Errorf("Expected a caret under the error, got:\n%s", out.String())
+	}
+}
@@ -0,0 +1,82 @@
+package database_test
+
+import (
+	"testing"
+
+	"github.com/AYGA2K/db/internal/database"
+)
+
+func TestTransactionCommit(t *testing.T) {
+	defer cleanupTestDB("testdb")
+	db := newPeopleDB(t)
+
+	if _, err := db.Execute("BEGIN"); err != nil {
+		t.Fatalf("BEGIN failed: %v", err)
+	}
+	if _, err := db.Execute("begin"); err == nil {
+		t.Error("Expected an error for a nested BEGIN")
+	}
+	_, _ = db.Execute("INSERT INTO people (id, name, age) VALUES (5, 'Eve', 22)")
+	_, _ = db.Execute("DELETE FROM people WHERE id = 1")
+
+	// Nothing is written to disk before COMMIT
+	reopened, err := database.NewDatabase("testdb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertIDs(t, selectIDs(t, reopened, "SELECT * FROM people"), 1, 2, 3, 4)
+
+	if _, err := db.Execute("COMMIT"); err != nil {
+		t.Fatalf("COMMIT failed: %v", err)
+	}
+	reopened, err = database.NewDatabase("testdb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertIDs(t, selectIDs(t, reopened, "SELECT * FROM people"), 2, 3, 4, 5)
+
+	if _, err := db.Execute("COMMIT"); err == nil {
+		t.Error("Expected an error for COMMIT without a transaction")
+	}
+}
+
+func TestTransactionRollback(t *testing.T) {
+	defer cleanupTestDB("testdb")
+	db := newPeopleDB(t)
+
+	if _, err := db.Execute("BEGIN TRANSACTION"); err != nil {
+		t.Fatalf("BEGIN failed: %v", err)
+	}
+	_, _ = db.Execute("INSERT INTO people (id, name, age) VALUES (5, 'Eve', 22)")
+	_, _ = db.Execute("UPDATE people SET age = 99 WHERE id = 2")
+	_, _ = db.Execute("CREATE TABLE scratch (id INT)")
+	_, _ = db.Execute("DROP TABLE people")
+
+	// A statement that fails to parse leaves the transaction open
+	if _, err := db.Execute("INSERT INTO"); err == nil {
+		t.Fatal("Expected a syntax error")
+	}
+	if !db.InTransaction() {
+		t.Fatal("Expected the transaction to survive a syntax error")
+	}
+
+	if _, err := db.Execute("ROLLBACK"); err != nil {
+		t.Fatalf("ROLLBACK failed: %v", err)
+	}
+	assertIDs(t, selectIDs(t, db, "SELECT * FROM people"), 1, 2, 3, 4)
+	assertIDs(t, selectIDs(t, db, "SELECT * FROM people WHERE age = 30"), 2)
+	if _, err := db.Execute("SELECT * FROM scratch"); err == nil {
+		t.Error("Expected the table created in the transaction to be gone")
+	}
+	if _, err := db.Execute("ROLLBACK"); err == nil {
+		t.Error("Expected an error for ROLLBACK without a transaction")
+	}
+
+	// Changes after the transaction are saved right away again
+	_, _ = db.Execute("INSERT INTO people (id, name, age) VALUES (6, 'Frank', 50)")
+	reopened, err := database.NewDatabase("testdb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertIDs(t, selectIDs(t, reopened, "SELECT * FROM people"), 1, 2, 3, 4, 6)
+}
@@ -0,0 +1,907 @@
+package database_test
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/AYGA2K/db/internal/database"
+)
+
+// selectIDs runs a query and returns the id column of every result row
+func selectIDs(t *testing.T, db *database.Database, query string) []int {
+	t.Helper()
+	res, err := db.Execute(query)
+	if err != nil {
+		t.Fatalf("Query %q failed: %v", query, err)
+	}
+	var rows []map[string]any
+	if err := json.Unmarshal([]byte(res), &rows); err != nil {
+		t.Fatalf("Failed to unmarshal results: %v", err)
+	}
+	var ids []int
+	for _, row := range rows {
+		id, _ := row["id"].(float64)
+		ids = append(ids, int(id))
+	}
+	return ids
+}
+
+func assertIDs(t *testing.T, got []int, expected ...int) {
+	t.Helper()
+	if len(got) != len(expected) {
+		t.Errorf("Expected ids %v, got %v", expected, got)
+		return
+	}
+	for i := range got {
+		if got[i] != expected[i] {
+			t.Errorf("Expected ids %v, got %v", expected, got)
+			return
+		}
+	}
+}
+
+func newPeopleDB(t *testing.T) *database.Database {
+	t.Helper()
+	db, err := database.NewDatabase("testdb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = db.Execute("CREATE TABLE people (id INT, name VARCHAR, age INT, height DOUBLE, birthdate DATE)")
+	_, _ = db.Execute("INSERT INTO people (id, name, age, height, birthdate) VALUES (1, 'Alice', 25, 1.62, '1999-04-10')")
+	_, _ = db.Execute("INSERT INTO people (id, name, age, height, birthdate) VALUES (2, 'Bob', 30, 1.80, '1994-01-01')")
+	_, _ = db.Execute("INSERT INTO people (id, name, age, height, birthdate) VALUES (3, 'Charlie', 35, 1.75, '1989-12-31')")
+	_, _ = db.Execute("INSERT INTO people (id, name, age, height, birthdate) VALUES (4, 'David', 40, 1.90, '1984-07-22')")
+	return db
+}
+
+func TestWhereBetween(t *testing.T) {
+	defer cleanupTestDB("testdb")
+	db := newPeopleDB(t)
+
+	tests := []struct {
+		name     string
+		query    string
+		expected []int
+	}{
+		{"INT inclusive bounds", "SELECT * FROM people WHERE age BETWEEN 25 AND 35", []int{1, 2, 3}},
+		{"INT NOT BETWEEN", "SELECT * FROM people WHERE age NOT BETWEEN 25 AND 35", []int{4}},
+		{"DOUBLE bounds", "SELECT * FROM people WHERE height BETWEEN 1.7 AND 1.8", []int{2, 3}},
+		{"DATE bounds", "SELECT * FROM people WHERE birthdate BETWEEN '1990-01-01' AND '1999-12-31'", []int{1, 2}},
+		{"DATE bounds on the edge", "SELECT * FROM people WHERE birthdate between '1989-12-31' and '1994-01-01'", []int{2, 3}},
+		{"Reversed bounds match nothing", "SELECT * FROM people WHERE age BETWEEN 35 AND 25", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assertIDs(t, selectIDs(t, db, tt.query), tt.expected...)
+		})
+	}
+}
+
+func TestWhereLike(t *testing.T) {
+	defer cleanupTestDB("testdb")
+	db := newPeopleDB(t)
+
+	tests := []struct {
+		name     string
+		query    string
+		expected []int
+	}{
+		{"Prefix", "SELECT * FROM people WHERE name LIKE 'A%'", []int{1}},
+		{"Suffix", "SELECT * FROM people WHERE name LIKE '%e'", []int{1, 3}},
+		{"Contains", "SELECT * FROM people WHERE name LIKE '%li%'", []int{1, 3}},
+		{"Single character", "SELECT * FROM people WHERE name LIKE 'B_b'", []int{2}},
+		{"Single characters are exact", "SELECT * FROM people WHERE name LIKE 'B_'", nil},
+		{"Anchored to the whole value", "SELECT * FROM people WHERE name LIKE 'li'", nil},
+		{"Case sensitive", "SELECT * FROM people WHERE name LIKE 'alice'", nil},
+		{"Lower case keyword", "SELECT * FROM people WHERE name like 'D%'", []int{4}},
+		{"Regexp characters are literal", "SELECT * FROM people WHERE name LIKE 'A.*'", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assertIDs(t, selectIDs(t, db, tt.query), tt.expected...)
+		})
+	}
+}
+
+func TestWhereILike(t *testing.T) {
+	defer cleanupTestDB("testdb")
+
+	db, err := database.NewDatabase("testdb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = db.Execute("CREATE TABLE people (id INT, name VARCHAR)")
+	_, _ = db.Execute("INSERT INTO people (id, name) VALUES (1, 'Alice')")
+	_, _ = db.Execute("INSERT INTO people (id, name) VALUES (2, 'ALICE')")
+	_, _ = db.Execute("INSERT INTO people (id, name) VALUES (3, 'Bob')")
+	_, _ = db.Execute("INSERT INTO people (id, name) VALUES (4, 'Malice')")
+
+	assertIDs(t, selectIDs(t, db, "SELECT * FROM people WHERE name ILIKE 'alice'"), 1, 2)
+	assertIDs(t, selectIDs(t, db, "SELECT * FROM people WHERE name ilike '%ALI%'"), 1, 2, 4)
+	assertIDs(t, selectIDs(t, db, "SELECT * FROM people WHERE name LIKE 'alice'"))
+
+	res, err := db.Execute("UPDATE people SET name = 'Robert' WHERE name ILIKE 'BOB'")
+	if err != nil || res != "1 rows updated" {
+		t.Errorf("Unexpected update result: %s, %v", res, err)
+	}
+	res, err = db.Execute("DELETE FROM people WHERE name ILIKE 'a%'")
+	if err != nil || res != "2 rows deleted" {
+		t.Errorf("Unexpected delete result: %s, %v", res, err)
+	}
+	assertIDs(t, selectIDs(t, db, "SELECT * FROM people"), 3, 4)
+}
+
+func TestWhereNotLikeAndNotIn(t *testing.T) {
+	defer cleanupTestDB("testdb")
+	db := newPeopleDB(t)
+	_, _ = db.Execute("INSERT INTO people (id, age) VALUES (5, 50)")
+
+	tests := []struct {
+		name     string
+		query    string
+		expected []int
+	}{
+		{"NOT LIKE", "SELECT * FROM people WHERE name NOT LIKE '%li%'", []int{2, 4}},
+		{"NOT ILIKE", "SELECT * FROM people WHERE name not ilike 'a%'", []int{2, 3, 4}},
+		{"IN strings", "SELECT * FROM people WHERE name IN ('Alice', 'Bob')", []int{1, 2}},
+		{"IN numbers", "SELECT * FROM people WHERE age IN (30, 40, 45)", []int{2, 4}},
+		{"NOT IN", "SELECT * FROM people WHERE name NOT IN ('Alice', 'Bob')", []int{3, 4}},
+		{"Empty IN list", "SELECT * FROM people WHERE age IN ()", nil},
+		{"Empty NOT IN list", "SELECT * FROM people WHERE age NOT IN ()", []int{1, 2, 3, 4, 5}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assertIDs(t, selectIDs(t, db, tt.query), tt.expected...)
+		})
+	}
+}
+
+func TestWhereArithmetic(t *testing.T) {
+	defer cleanupTestDB("testdb")
+	db := newPeopleDB(t)
+
+	tests := []struct {
+		name     string
+		query    string
+		expected []int
+	}{
+		{"Addition", "SELECT * FROM people WHERE age + 5 > 30", []int{2, 3, 4}},
+		{"Subtraction", "SELECT * FROM people WHERE age - 10 = 20", []int{2}},
+		{"Multiplication of columns", "SELECT * FROM people WHERE age * height >= 60", []int{3, 4}},
+		{"Precedence", "SELECT * FROM people WHERE age + 10 * 2 = 45", []int{1}},
+		{"Parentheses", "SELECT * FROM people WHERE (age + 10) * 2 = 90", []int{3}},
+		{"Division", "SELECT * FROM people WHERE age / 2 < 15", []int{1}},
+		{"Unary minus", "SELECT * FROM people WHERE -age < -35", []int{4}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assertIDs(t, selectIDs(t, db, tt.query), tt.expected...)
+		})
+	}
+}
+
+func TestWhereArithmeticErrors(t *testing.T) {
+	defer cleanupTestDB("testdb")
+	db := newPeopleDB(t)
+
+	tests := []struct {
+		query string
+		err   string
+	}{
+		{"SELECT * FROM people WHERE age / 0 > 1", "division by zero"},
+		{"SELECT * FROM people WHERE name + 1 > 1", "column name is not numeric and cannot be used in arithmetic"},
+		{"DELETE FROM people WHERE age / (age - age) = 1", "division by zero"},
+		{"UPDATE people SET age = 1 WHERE age * > 1", "invalid expression: missing operand at end"},
+	}
+	for _, tt := range tests {
+		_, err := db.Execute(tt.query)
+		if err == nil || err.Error() != tt.err {
+			t.Errorf("Expected error %q for %q, got %v", tt.err, tt.query, err)
+		}
+	}
+	assertIDs(t, selectIDs(t, db, "SELECT * FROM people"), 1, 2, 3, 4)
+}
+
+func TestWhereDates(t *testing.T) {
+	defer cleanupTestDB("testdb")
+	db := newPeopleDB(t)
+	_, _ = db.Execute("CREATE TABLE events (id INT, person_id INT, at TIMESTAMP)")
+	_, _ = db.Execute("INSERT INTO events (id, person_id, at) VALUES (1, 1, '2024-01-04 23:59:59')")
+	_, _ = db.Execute("INSERT INTO events (id, person_id, at) VALUES (2, 2, '2024-01-05 00:00:00')")
+	_, _ = db.Execute("INSERT INTO events (id, person_id, at) VALUES (3, 3, '2024-01-05 10:30:00')")
+
+	tests := []struct {
+		name  string
+		query string
+		want  []int
+	}{
+		{"DATE after a date", "SELECT * FROM people WHERE birthdate > '1990-01-01'", []int{1, 2}},
+		{"DATE before a timestamp", "SELECT * FROM people WHERE birthdate < '1994-01-01 00:00:01'", []int{2, 3, 4}},
+		{"DATE equal to its midnight", "SELECT * FROM people WHERE birthdate = '1994-01-01 00:00:00'", []int{2}},
+		{"DATE not equal", "SELECT * FROM people WHERE birthdate != '1994-01-01'", []int{1, 3, 4}},
+		{"DATE BETWEEN timestamps", "SELECT * FROM people WHERE birthdate BETWEEN '1989-12-31 00:00:00' AND '1994-01-01 12:00:00'", []int{2, 3}},
+		{"DATE IN", "SELECT * FROM people WHERE birthdate IN ('1984-07-22', '1994-01-01 00:00:00')", []int{2, 4}},
+		{"TIMESTAMP from a date", "SELECT * FROM events WHERE at >= '2024-01-05'", []int{2, 3}},
+		{"TIMESTAMP before a timestamp", "SELECT * FROM events WHERE at < '2024-01-05 10:30:00'", []int{1, 2}},
+		{"TIMESTAMP qualified in a join", "SELECT events.id AS id FROM events JOIN people ON events.person_id = people.id WHERE events.at > '2024-01-05' AND people.birthdate < '1995-01-01'", []int{3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assertIDs(t, selectIDs(t, db, tt.query), tt.want...)
+		})
+	}
+
+	// A literal that is not a date is an error rather than compared as text
+	for _, query := range []string{
+		"SELECT * FROM people WHERE birthdate > '1994-1-1'",
+		"SELECT * FROM people WHERE birthdate BETWEEN 'then' AND '2000-01-01'",
+		"SELECT * FROM events WHERE at IN ('soon')",
+		"DELETE FROM people WHERE birthdate = 'yesterday'",
+		"UPDATE people SET age = 1 WHERE birthdate < 1994",
+	} {
+		if _, err := db.Execute(query); err == nil || !strings.Contains(err.Error(), "invalid") {
+			t.Errorf("Expected an invalid date error for %q, got %v", query, err)
+		}
+	}
+	assertIDs(t, selectIDs(t, db, "SELECT * FROM people WHERE age > 1"), 1, 2, 3, 4)
+}
+
+func TestWhereTypeMismatch(t *testing.T) {
+	defer cleanupTestDB("testdb")
+	db := newPeopleDB(t)
+	_, _ = db.Execute("CREATE TABLE flags (id INT, active BOOL, price DECIMAL(6,2))")
+	_, _ = db.Execute("INSERT INTO flags (id, active, price) VALUES (1, true, 9.99)")
+
+	tests := []struct {
+		query string
+		err   string
+	}{
+		{"SELECT * FROM people WHERE age = 'abc'", `invalid INT value "abc" for column age, expected a number`},
+		{"SELECT * FROM people WHERE height > 'tall'", `invalid DOUBLE value "tall" for column height, expected a number`},
+		{"SELECT * FROM people WHERE age BETWEEN 20 AND 'x'", `invalid INT value "x" for column age, expected a number`},
+		{"SELECT * FROM people WHERE age IN (25, 'thirty')", `invalid INT value "thirty" for column age, expected a number`},
+		{"DELETE FROM people WHERE age = 'abc'", `invalid INT value "abc" for column age, expected a number`},
+		{"UPDATE people SET name = 'x' WHERE age < 'abc'", `invalid INT value "abc" for column age, expected a number`},
+		{"SELECT * FROM flags WHERE active = 'maybe'", `invalid BOOL value "maybe" for column active, expected true or false`},
+		{"SELECT * FROM flags WHERE price = 'cheap'", `invalid DECIMAL value "cheap" for column price, expected a number`},
+	}
+	for _, tt := range tests {
+		if _, err := db.Execute(tt.query); err == nil || err.Error() != tt.err {
+			t.Errorf("Expected error %q for %q, got %v", tt.err, tt.query, err)
+		}
+	}
+	assertIDs(t, selectIDs(t, db, "SELECT * FROM people WHERE name != 'x'"), 1, 2, 3, 4)
+
+	// Literals of the column type still compare, quoted or not, and text
+	// columns take any literal
+	assertIDs(t, selectIDs(t, db, "SELECT * FROM people WHERE age = '30'"), 2)
+	assertIDs(t, selectIDs(t, db, "SELECT * FROM people WHERE age > - 1 AND height < 1.7"), 1)
+	assertIDs(t, selectIDs(t, db, "SELECT * FROM people WHERE name = 123"))
+	assertIDs(t, selectIDs(t, db, "SELECT * FROM flags WHERE active = 1 AND price = 9.99"), 1)
+}
+
+func TestWhereNumericEquality(t *testing.T) {
+	defer cleanupTestDB("testdb")
+	db, err := database.NewDatabase("testdb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = db.Execute("CREATE TABLE items (id INT, price DOUBLE, active BOOL, code VARCHAR)")
+	_, _ = db.Execute("INSERT INTO items (id, price, active, code) VALUES (1, 10.0, true, '007')")
+	_, _ = db.Execute("INSERT INTO items (id, price, active, code) VALUES (2, 10.5, false, '7')")
+
+	tests := []struct {
+		name     string
+		query    string
+		expected []int
+	}{
+		{"INT against float literal", "SELECT * FROM items WHERE id = 1.0", []int{1}},
+		{"INT against zero padded literal", "SELECT * FROM items WHERE id = 01", []int{1}},
+		{"INT inequality", "SELECT * FROM items WHERE id != 1.0", []int{2}},
+		{"DOUBLE against integer literal", "SELECT * FROM items WHERE price = 10", []int{1}},
+		{"DOUBLE inequality", "SELECT * FROM items WHERE price != 10", []int{2}},
+		{"BOOL equality", "SELECT * FROM items WHERE active = true", []int{1}},
+		{"BOOL inequality", "SELECT * FROM items WHERE active != true", []int{2}},
+		{"VARCHAR compares as text", "SELECT * FROM items WHERE code = '7'", []int{2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assertIDs(t, selectIDs(t, db, tt.query), tt.expected...)
+		})
+	}
+}
+
+func TestWhereIn(t *testing.T) {
+	defer cleanupTestDB("testdb")
+	db := newPeopleDB(t)
+	// INSERT splits values on every comma, so add the name holding one directly
+	if _, rejected, err := db.InsertRows("people", []string{"id", "name", "age", "height"}, [][]string{{"5", "Smith, John", "-1", "2"}}, false); err != nil || rejected != nil {
+		t.Fatalf("Insert failed: %v %v", rejected, err)
+	}
+
+	tests := []struct {
+		name     string
+		query    string
+		expected []int
+	}{
+		{"Numbers", "SELECT * FROM people WHERE id IN (1, 2, 3)", []int{1, 2, 3}},
+		{"Strings", "SELECT * FROM people WHERE name IN ('Alice', 'Bob')", []int{1, 2}},
+		{"Comma inside a string", "SELECT * FROM people WHERE name IN ('Smith, John', 'Bob')", []int{2, 5}},
+		{"Numeric comparison", "SELECT * FROM people WHERE height IN (1.8, 2)", []int{2, 5}},
+		{"Negative number", "SELECT * FROM people WHERE age IN (-1)", []int{5}},
+		{"Dates", "SELECT * FROM people WHERE birthdate in ('1994-01-01')", []int{2}},
+		{"Single value", "SELECT * FROM people WHERE id IN (4)", []int{4}},
+		{"Empty list", "SELECT * FROM people WHERE id IN ()", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assertIDs(t, selectIDs(t, db, tt.query), tt.expected...)
+		})
+	}
+
+	for _, query := range []string{
+		"SELECT * FROM people WHERE id IN (1 2)",
+		"SELECT * FROM people WHERE id IN (1, )",
+		"SELECT * FROM people WHERE id IN (1, =)",
+	} {
+		if _, err := db.Execute(query); err == nil {
+			t.Errorf("Expected an error for %q", query)
+		}
+	}
+}
+
+func TestWhereFunctions(t *testing.T) {
+	defer cleanupTestDB("testdb")
+	db := newPeopleDB(t)
+
+	tests := []struct {
+		name     string
+		query    string
+		expected []int
+	}{
+		{"UPPER", "SELECT * FROM people WHERE UPPER(name) = 'ALICE'", []int{1}},
+		{"LOWER", "SELECT * FROM people WHERE lower(name) = 'bob'", []int{2}},
+		{"LENGTH", "SELECT * FROM people WHERE LENGTH(name) > 4", []int{1, 3, 4}},
+		{"LENGTH of a number", "SELECT * FROM people WHERE LENGTH(height) = 4", []int{1, 3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assertIDs(t, selectIDs(t, db, tt.query), tt.expected...)
+		})
+	}
+
+	if _, err := db.Execute("UPDATE people SET age = 26 WHERE UPPER(name) = 'ALICE'"); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	assertIDs(t, selectIDs(t, db, "SELECT * FROM people WHERE age = 26"), 1)
+
+	_, err := db.Execute("SELECT * FROM people WHERE REVERSE(name) = 'ecilA'")
+	if err == nil || err.Error() != "unknown function REVERSE" {
+		t.Errorf("Expected an unknown function error, got %v", err)
+	}
+}
+
+func TestWhereBetweenMalformed(t *testing.T) {
+	defer cleanupTestDB("testdb")
+	db := newPeopleDB(t)
+
+	tests := []struct {
+		query string
+		err   string
+	}{
+		{"SELECT * FROM people WHERE age BETWEEN 20", `malformed BETWEEN in "age BETWEEN 20", expected: column BETWEEN low AND high`},
+		{"SELECT * FROM people WHERE age BETWEEN 20 AND", `malformed BETWEEN in "age BETWEEN 20 AND", expected: column BETWEEN low AND high`},
+		{"SELECT * FROM people WHERE age BETWEEN 20 30 AND 40", `malformed BETWEEN bound "20 30", expected a single value`},
+		{"SELECT * FROM people WHERE age BETWEEN 20 AND 'x", `syntax error at position 46 near "'x": unterminated string`},
+		{"DELETE FROM people WHERE age BETWEEN ( AND 40", `malformed BETWEEN bound "(", expected a single value`},
+	}
+	for _, tt := range tests {
+		_, err := db.Execute(tt.query)
+		if err == nil || err.Error() != tt.err {
+			t.Errorf("Expected error %q for %q, got %v", tt.err, tt.query, err)
+		}
+	}
+	assertIDs(t, selectIDs(t, db, "SELECT * FROM people WHERE age BETWEEN -5 AND 30"), 1, 2)
+}
+
+func TestWhereExists(t *testing.T) {
+	defer cleanupTestDB("testdb")
+	db := newBlogDB(t)
+
+	tests := []struct {
+		name     string
+		query    string
+		expected []int
+	}{
+		{"EXISTS", "SELECT * FROM users WHERE EXISTS (SELECT 1 FROM posts WHERE posts.user_id = users.id)", []int{1, 2}},
+		{"NOT EXISTS", "SELECT * FROM users WHERE NOT EXISTS (SELECT 1 FROM posts WHERE posts.user_id = users.id)", []int{3}},
+		{"Unqualified inner column", "SELECT * FROM users WHERE exists (SELECT * FROM posts WHERE user_id = users.id)", []int{1, 2}},
+		{"Inner filter", "SELECT * FROM users WHERE EXISTS (SELECT 1 FROM posts WHERE title LIKE 'W%')", []int{1, 2, 3}},
+		{"Uncorrelated and empty", "SELECT * FROM users WHERE EXISTS (SELECT 1 FROM posts WHERE post_id > 100)", nil},
+		{"Without WHERE", "SELECT * FROM users WHERE EXISTS (SELECT 1 FROM posts)", []int{1, 2, 3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assertIDs(t, selectIDs(t, db, tt.query), tt.expected...)
+		})
+	}
+
+	res, err := db.Execute("DELETE FROM users WHERE NOT EXISTS (SELECT 1 FROM posts WHERE posts.user_id = users.id)")
+	if err != nil || res != "1 row deleted" {
+		t.Errorf("Unexpected delete result: %s, %v", res, err)
+	}
+	if _, err := db.Execute("SELECT * FROM users WHERE EXISTS (SELECT 1 FROM missing)"); err == nil {
+		t.Error("Expected an error for a subquery on a missing table")
+	}
+}
+
+func TestWhereIsNull(t *testing.T) {
+	defer cleanupTestDB("testdb")
+	db := newPeopleDB(t)
+	_, _ = db.Execute("INSERT INTO people (id, name) VALUES (5, 'Eve')")
+	_, _ = db.Execute("INSERT INTO people (id, age) VALUES (6, 60)")
+
+	tests := []struct {
+		name     string
+		query    string
+		expected []int
+	}{
+		{"IS NULL", "SELECT * FROM people WHERE age IS NULL", []int{5}},
+		{"IS NOT NULL", "SELECT * FROM people WHERE name IS NOT NULL", []int{1, 2, 3, 4, 5}},
+		{"Lower case", "SELECT * FROM people WHERE birthdate is null", []int{5, 6}},
+		{"Never null", "SELECT * FROM people WHERE id IS NULL", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assertIDs(t, selectIDs(t, db, tt.query), tt.expected...)
+		})
+	}
+
+	res, err := db.Execute("UPDATE people SET age = 0 WHERE age IS NULL")
+	if err != nil || res != "1 rows updated" {
+		t.Errorf("Unexpected update result: %s, %v", res, err)
+	}
+	assertIDs(t, selectIDs(t, db, "SELECT * FROM people WHERE age = 0"), 5)
+}
+
+func TestWhereInSubquery(t *testing.T) {
+	defer cleanupTestDB("testdb")
+	db := newBlogDB(t)
+	_, _ = db.Execute("CREATE TABLE active (user_id INT, name VARCHAR, score DOUBLE)")
+	_, _ = db.Execute("INSERT INTO active (user_id, name, score) VALUES (1, 'Alice', 1.0)")
+	_, _ = db.Execute("INSERT INTO active (user_id, name, score) VALUES (3, 'Carol', 3.0)")
+	_, _ = db.Execute("INSERT INTO active (name) VALUES ('Nobody')")
+
+	tests := []struct {
+		name     string
+		query    string
+		expected []int
+	}{
+		{"IN", "SELECT * FROM users WHERE id IN (SELECT user_id FROM active)", []int{1, 3}},
+		{"NOT IN", "SELECT * FROM users WHERE id NOT IN (SELECT user_id FROM active)", []int{2}},
+		{"Inner WHERE", "SELECT * FROM users WHERE id IN (SELECT user_id FROM active WHERE name = 'Carol')", []int{3}},
+		{"Strings", "SELECT * FROM users WHERE name IN (SELECT name FROM active)", []int{1, 3}},
+		{"INT against DOUBLE", "SELECT * FROM users WHERE id in (select score from active)", []int{1, 3}},
+		{"Empty result", "SELECT * FROM users WHERE id IN (SELECT user_id FROM active WHERE user_id > 10)", nil},
+		{"Nested", "SELECT * FROM users WHERE id IN (SELECT user_id FROM posts WHERE user_id IN (SELECT user_id FROM active))", []int{1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assertIDs(t, selectIDs(t, db, tt.query), tt.expected...)
+		})
+	}
+
+	res, err := db.Execute("DELETE FROM posts WHERE user_id NOT IN (SELECT user_id FROM active)")
+	if err != nil || res != "1 row deleted" {
+		t.Errorf("Unexpected delete result: %s, %v", res, err)
+	}
+
+	for query, expected := range map[string]string{
+		"SELECT * FROM users WHERE id IN (SELECT user_id, name FROM active)": "subquery in IN must select exactly one column: SELECT user_id, name FROM active",
+		"SELECT * FROM users WHERE id IN (SELECT * FROM active)":             "subquery in IN must select exactly one column: SELECT * FROM active",
+		"SELECT * FROM users WHERE id IN (SELECT missing FROM active)":       "column missing not found",
+	} {
+		if _, err := db.Execute(query); err == nil || err.Error() != expected {
+			t.Errorf("Expected error %q for %q, got %v", expected, query, err)
+		}
+	}
+}
+
+func TestNullLiteral(t *testing.T) {
+	defer cleanupTestDB("testdb")
+	db := newPeopleDB(t)
+
+	if _, err := db.Execute("INSERT INTO people (id, name, age) VALUES (5, 'Eve', NULL)"); err != nil {
+		t.Fatalf("Insert with NULL failed: %v", err)
+	}
+	if _, err := db.Execute("UPDATE people SET age = null WHERE id = 2"); err != nil {
+		t.Fatalf("Update to NULL failed: %v", err)
+	}
+
+	assertIDs(t, selectIDs(t, db, "SELECT * FROM people WHERE age IS NULL"), 2, 5)
+	assertIDs(t, selectIDs(t, db, "SELECT * FROM people WHERE age = NULL"))
+	assertIDs(t, selectIDs(t, db, "SELECT * FROM people WHERE age != NULL"))
+	assertIDs(t, selectIDs(t, db, "SELECT * FROM people WHERE age != 25"), 3, 4)
+	assertIDs(t, selectIDs(t, db, "SELECT * FROM people WHERE age NOT IN (25)"), 3, 4)
+	assertIDs(t, selectIDs(t, db, "SELECT * FROM people ORDER BY age"), 1, 3, 4, 2, 5)
+	assertIDs(t, selectIDs(t, db, "SELECT * FROM people ORDER BY age DESC"), 2, 5, 4, 3, 1)
+
+	// A quoted NULL is text, not a null
+	if _, err := db.Execute("INSERT INTO people (id, name) VALUES (6, 'NULL')"); err != nil {
+		t.Fatal(err)
+	}
+	assertIDs(t, selectIDs(t, db, "SELECT * FROM people WHERE name = 'NULL'"), 6)
+}
+
+func TestNullLiteralConstraints(t *testing.T) {
+	defer cleanupTestDB("testdb")
+	db, err := database.NewDatabase("testdb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = db.Execute("CREATE TABLE users (id INT PRIMARY KEY AUTO_INCREMENT, name VARCHAR NOT NULL, email VARCHAR UNIQUE)")
+
+	if _, err := db.Execute("INSERT INTO users (id, name) VALUES (1, NULL)"); err == nil {
+		t.Error("Expected an error inserting NULL into a NOT NULL column")
+	}
+	if _, err := db.Execute("INSERT INTO users (id, name, email) VALUES (NULL, 'Alice', NULL)"); err != nil {
+		t.Fatalf("Insert error: %v", err)
+	}
+	if _, err := db.Execute("INSERT INTO users (id, name, email) VALUES (NULL, 'Bob', NULL)"); err != nil {
+		t.Fatalf("Expected nulls not to violate UNIQUE, got: %v", err)
+	}
+	if _, err := db.Execute("UPDATE users SET name = NULL WHERE id = 1"); err == nil {
+		t.Error("Expected an error updating a NOT NULL column to NULL")
+	}
+	assertIDs(t, selectIDs(t, db, "SELECT * FROM users WHERE email IS NULL"), 1, 2)
+}
+
+func TestWhereBoolLiterals(t *testing.T) {
+	defer cleanupTestDB("testdb")
+	db, err := database.NewDatabase("testdb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = db.Execute("CREATE TABLE users (id INT, active BOOL)")
+	_, _ = db.Execute("INSERT INTO users (id, active) VALUES (1, true)")
+	_, _ = db.Execute("INSERT INTO users (id, active) VALUES (2, FALSE)")
+	_, _ = db.Execute("INSERT INTO users (id, active) VALUES (3, 1)")
+	_, _ = db.Execute("INSERT INTO users (id, active) VALUES (4, 0)")
+
+	for _, literal := range []string{"true", "TRUE", "True", "1"} {
+		assertIDs(t, selectIDs(t, db, "SELECT * FROM users WHERE active = "+literal), 1, 3)
+		assertIDs(t, selectIDs(t, db, "SELECT * FROM users WHERE active != "+literal), 2, 4)
+	}
+	for _, literal := range []string{"false", "FALSE", "0"} {
+		assertIDs(t, selectIDs(t, db, "SELECT * FROM users WHERE active = "+literal), 2, 4)
+	}
+	assertIDs(t, selectIDs(t, db, "SELECT * FROM users WHERE active IN (TRUE)"), 1, 3)
+
+	if _, err := db.Execute("UPDATE users SET active = TRUE WHERE id = 2"); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if _, err := db.Execute("UPDATE users SET active = 0 WHERE id = 1"); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	assertIDs(t, selectIDs(t, db, "SELECT * FROM users WHERE active = true"), 2, 3)
+	if _, err := db.Execute("UPDATE users SET active = yes WHERE id = 1"); err == nil {
+		t.Error("Expected an error for an invalid boolean")
+	}
+}
+
+func TestWhereConstantCondition(t *testing.T) {
+	defer cleanupTestDB("testdb")
+	db := newPeopleDB(t)
+
+	// Literals on the left are values, not columns
+	assertIDs(t, selectIDs(t, db, "SELECT * FROM people WHERE 1=1"), 1, 2, 3, 4)
+	assertIDs(t, selectIDs(t, db, "SELECT * FROM people WHERE 'a' = 'a' AND id > 2"), 3, 4)
+	assertIDs(t, selectIDs(t, db, "SELECT * FROM people WHERE TRUE = true"), 1, 2, 3, 4)
+	assertIDs(t, selectIDs(t, db, "SELECT * FROM people WHERE 1 = 0 OR id = 1"), 1)
+	assertIDs(t, selectIDs(t, db, "SELECT * FROM people WHERE NULL = 1"))
+
+	res, err := db.Execute("DELETE FROM people WHERE 1=1")
+	if err != nil || res != "4 rows deleted" {
+		t.Errorf("Expected 4 rows deleted, got %q %v", res, err)
+	}
+}
+
+func TestWhereBoolColumn(t *testing.T) {
+	defer cleanupTestDB("testdb")
+	db, err := database.NewDatabase("testdb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = db.Execute("CREATE TABLE users (id INT, name VARCHAR, active BOOL)")
+	_, _ = db.Execute("INSERT INTO users (id, name, active) VALUES (1, 'Alice', true)")
+	_, _ = db.Execute("INSERT INTO users (id, name, active) VALUES (2, 'Bob', false)")
+	_, _ = db.Execute("INSERT INTO users (id, name, active) VALUES (3, 'Carol', true)")
+	_, _ = db.Execute("INSERT INTO users (id, name) VALUES (4, 'Dan')")
+
+	tests := []struct {
+		where    string
+		expected []int
+	}{
+		{"active", []int{1, 3}},
+		{"active = true", []int{1, 3}},
+		{"users.active", []int{1, 3}},
+		{"(active)", []int{1, 3}},
+		// Dan's active is null, so neither active nor NOT active holds
+		{"NOT active", []int{2}},
+		{"active AND id > 1", []int{3}},
+		{"id = 2 OR active", []int{1, 2, 3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.where, func(t *testing.T) {
+			assertIDs(t, selectIDs(t, db, "SELECT * FROM users WHERE "+tt.where), tt.expected...)
+		})
+	}
+
+	row := selectAggregate(t, db, "SELECT COUNT(*) FROM users WHERE active")
+	if row["COUNT(*)"] != float64(2) {
+		t.Errorf("Expected 2 active users, got %v", row)
+	}
+	rows := selectRows(t, db, "SELECT id, CASE WHEN active THEN 'yes' ELSE 'no' END AS status FROM users ORDER BY id")
+	if len(rows) != 4 || rows[0]["status"] != "yes" || rows[1]["status"] != "no" || rows[3]["status"] != "no" {
+		t.Errorf("Expected CASE to test the column, got %v", rows)
+	}
+
+	// Other columns still need an operator
+	for _, where := range []string{"name", "id AND active", "NOT name", "missing"} {
+		if _, err := db.Execute("SELECT * FROM users WHERE " + where); err == nil {
+			t.Errorf("Expected an error for WHERE %s", where)
+		}
+	}
+
+	if _, err := db.Execute("DELETE FROM users WHERE NOT active"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	assertIDs(t, selectIDs(t, db, "SELECT * FROM users"), 1, 3, 4)
+}
+
+func TestWhereUnknownColumn(t *testing.T) {
+	defer cleanupTestDB("testdb")
+	db := newPeopleDB(t)
+
+	for _, query := range []string{
+		"SELECT * FROM people WHERE agee > 30",
+		"SELECT * FROM people WHERE nmae LIKE 'A%'",
+		"SELECT * FROM people WHERE nmae IN ('Alice')",
+		"SELECT * FROM people WHERE agee BETWEEN 1 AND 2",
+		"SELECT * FROM people WHERE UPPER(nmae) = 'ALICE'",
+		"SELECT * FROM people WHERE agee * 2 > 10",
+		"SELECT * FROM people WHERE people.agee = 1",
+		"SELECT COUNT(*) FROM people WHERE agee > 30",
+		"UPDATE people SET age = 1 WHERE agee = 25",
+		"DELETE FROM people WHERE agee = 25",
+	} {
+		_, err := db.Execute(query)
+		if err == nil || !strings.Contains(err.Error(), "agee") && !strings.Contains(err.Error(), "nmae") ||
+			!strings.Contains(err.Error(), "people") {
+			t.Errorf("Expected an unknown column error for %q, got %v", query, err)
+		}
+	}
+	if _, err := db.DryRun("DELETE FROM people WHERE agee = 25"); err == nil {
+		t.Error("Expected DryRun to report the unknown column")
+	}
+
+	// Absence is what IS NULL looks for, and values on the right are not columns
+	assertIDs(t, selectIDs(t, db, "SELECT * FROM people WHERE nickname IS NULL"), 1, 2, 3, 4)
+	assertIDs(t, selectIDs(t, db, "SELECT * FROM people WHERE name = Alice"), 1)
+}
+
+func TestScientificNotation(t *testing.T) {
+	defer cleanupTestDB("testdb")
+	db, err := database.NewDatabase("testdb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = db.Execute("CREATE TABLE readings (id INT, value DOUBLE, approx FLOAT)")
+	for _, query := range []string{
+		"INSERT INTO readings (id, value, approx) VALUES (1, 1.5e3, 1.1)",
+		"INSERT INTO readings (id, value, approx) VALUES (2, 0.000001, 2.5E-1)",
+		"INSERT INTO readings (id, value, approx) VALUES (3, -2e-7, 3)",
+		"INSERT INTO readings (id, value, approx) VALUES (4, 1E+2, 0.1)",
+	} {
+		if _, err := db.Execute(query); err != nil {
+			t.Fatalf("Insert %q failed: %v", query, err)
+		}
+	}
+
+	assertIDs(t, selectIDs(t, db, "SELECT * FROM readings WHERE value = 1500"), 1)
+	assertIDs(t, selectIDs(t, db, "SELECT * FROM readings WHERE value = 1e-6"), 2)
+	assertIDs(t, selectIDs(t, db, "SELECT * FROM readings WHERE value BETWEEN -1e-6 AND 1e-5 ORDER BY id"), 2, 3)
+	assertIDs(t, selectIDs(t, db, "SELECT * FROM readings WHERE value > 1.0e2 ORDER BY id"), 1)
+	assertIDs(t, selectIDs(t, db, "SELECT * FROM readings WHERE value >= 1e2 ORDER BY id"), 1, 4)
+	assertIDs(t, selectIDs(t, db, "SELECT * FROM readings WHERE approx = 1.1"), 1)
+	assertIDs(t, selectIDs(t, db, "SELECT * FROM readings WHERE approx = 0.1"), 4)
+	assertIDs(t, selectIDs(t, db, "SELECT * FROM readings WHERE approx < 2.5e-1"), 4)
+
+	if _, err := db.Execute("UPDATE readings SET value = 2.5e3 WHERE id = 1"); err != nil {
+		t.Fatal(err)
+	}
+	assertIDs(t, selectIDs(t, db, "SELECT * FROM readings WHERE value = 2500"), 1)
+
+	// FLOAT values print at their own precision
+	res, err := db.Execute("SELECT approx FROM readings WHERE id = 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(res, "1.1") || strings.Contains(res, "1.100000") {
+		t.Errorf("Expected 1.1 in the output, got %s", res)
+	}
+
+	for _, query := range []string{
+		"INSERT INTO readings (id, value) VALUES (12abc, 1)",
+		"INSERT INTO readings (id, value) VALUES (5, 1.5x)",
+		"INSERT INTO readings (id, value) VALUES (1.5, 1)",
+	} {
+		if _, err := db.Execute(query); err == nil {
+			t.Errorf("Expected %q to be rejected", query)
+		}
+	}
+}
+
+func TestCurrentDate(t *testing.T) {
+	defer cleanupTestDB("testdb")
+	db, err := database.NewDatabase("testdb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	today := time.Now().Format("2006-01-02")
+	_, _ = db.Execute("CREATE TABLE events (id INT, created DATE, note VARCHAR)")
+	_, _ = db.Execute("INSERT INTO events (id, created, note) VALUES (1, '2000-01-01', 'NOW()')")
+	if _, err := db.Execute("INSERT INTO events (id, created) VALUES (2, CURRENT_DATE)"); err != nil {
+		t.Fatalf("Insert with CURRENT_DATE failed: %v", err)
+	}
+	if _, err := db.Execute("INSERT INTO events (id, created) VALUES (3, now( ))"); err != nil {
+		t.Fatalf("Insert with NOW() failed: %v", err)
+	}
+	_, _ = db.Execute("INSERT INTO events (id, created) VALUES (4, '2999-12-31')")
+
+	rows, _, err := db.Query("SELECT created FROM events WHERE id = 2")
+	if err != nil || len(rows) != 1 || rows[0].String() != "{created: "+today+"}" {
+		t.Errorf("Expected %s, got %v (%v)", today, rows, err)
+	}
+	// Dates are written as 2006-01-02 and match LIKE patterns that way
+	if rows := selectRows(t, db, "SELECT created FROM events WHERE created LIKE '2000-%'"); len(rows) != 1 || rows[0]["created"] != "2000-01-01" {
+		t.Errorf("Expected the 2000-01-01 event, got %v", rows)
+	}
+	assertIDs(t, selectIDs(t, db, "SELECT * FROM events WHERE created <= NOW()"), 1, 2, 3)
+	assertIDs(t, selectIDs(t, db, "SELECT * FROM events WHERE created = CURRENT_DATE"), 2, 3)
+	assertIDs(t, selectIDs(t, db, "SELECT * FROM events WHERE created BETWEEN '2000-01-01' AND NOW()"), 1, 2, 3)
+	assertIDs(t, selectIDs(t, db, "SELECT * FROM events WHERE created IN (CURRENT_DATE, '2999-12-31')"), 2, 3, 4)
+	assertIDs(t, selectIDs(t, db, "SELECT * FROM events WHERE created < DATE_ADD(CURRENT_DATE, 1)"), 1, 2, 3)
+	if rows, _, err := db.Query("SELECT * FROM events WHERE created > NOW()"); err != nil || len(rows) != 1 {
+		t.Errorf("Expected the future event from Query, got %v (%v)", rows, err)
+	}
+
+	// Inside quotes it is just text
+	assertIDs(t, selectIDs(t, db, "SELECT * FROM events WHERE note = 'NOW()'"), 1)
+
+	if _, err := db.Execute("UPDATE events SET created = CURRENT_DATE WHERE id = 1"); err != nil {
+		t.Fatalf("Update with CURRENT_DATE failed: %v", err)
+	}
+	stmt, err := db.Prepare("INSERT INTO events (id, created) VALUES (?, NOW())")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := stmt.Exec(5); err != nil {
+		t.Fatalf("Prepared insert with NOW() failed: %v", err)
+	}
+	assertIDs(t, selectIDs(t, db, "SELECT * FROM events WHERE created = CURRENT_DATE"), 1, 2, 3, 5)
+
+	update, err := db.Prepare("UPDATE events SET note = ? WHERE created = CURRENT_DATE OR created > NOW()")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res, err := update.Exec("seen"); err != nil || res.RowsAffected != 5 {
+		t.Fatalf("Prepared update with CURRENT_DATE failed: %v %v", res, err)
+	}
+	assertIDs(t, selectIDs(t, db, "SELECT * FROM events WHERE note = 'seen'"), 1, 2, 3, 4, 5)
+}
+
+func TestWherePrecedence(t *testing.T) {
+	defer cleanupTestDB("testdb")
+	db := newPeopleDB(t)
+
+	tests := []struct {
+		where    string
+		expected []int
+	}{
+		// AND binds tighter: id = 1 OR (age > 30 AND name = 'David')
+		{"id = 1 OR age > 30 AND name = 'David'", []int{1, 4}},
+		// Read left to right this would be (id = 1 OR age > 30) AND name = 'David'
+		{"(id = 1 OR age > 30) AND name = 'David'", []int{4}},
+		{"age > 30 AND name = 'David' OR id = 1", []int{1, 4}},
+		{"age > 30 AND (name = 'David' OR id = 1)", []int{4}},
+		{"id = 1 OR id = 2 AND age = 25", []int{1}},
+		{"(id = 1 OR id = 2) AND age = 25", []int{1}},
+		{"(id = 1 OR id = 2) AND age = 30", []int{2}},
+		{"id = 1 OR id = 2 AND age = 30", []int{1, 2}},
+		{"id = 1 AND age = 30 OR id = 3 AND age = 35", []int{3}},
+		{"id = 1 AND (age = 30 OR id = 3) AND age = 35", nil},
+		{"((id = 2))", []int{2}},
+		{"(id = 1) OR (id = 4)", []int{1, 4}},
+		{"age BETWEEN 30 AND 35 OR id = 1", []int{1, 2, 3}},
+		{"id = 4 OR age BETWEEN 30 AND 35 AND name = 'Bob'", []int{2, 4}},
+		{"name = 'Alice AND Bob' OR id IN (3, 4)", []int{3, 4}},
+		{"id = 2 or id = 3 and age = 40", []int{2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.where, func(t *testing.T) {
+			assertIDs(t, selectIDs(t, db, "SELECT * FROM people WHERE "+tt.where), tt.expected...)
+		})
+	}
+}
+
+func TestWhereMissingCondition(t *testing.T) {
+	defer cleanupTestDB("testdb")
+	db := newPeopleDB(t)
+
+	for _, where := range []string{"id = 1 AND", "OR id = 1", "id = 1 AND OR id = 2"} {
+		if _, err := db.Execute("SELECT * FROM people WHERE " + where); err == nil {
+			t.Errorf("Expected an error for WHERE %s", where)
+		}
+	}
+}
+
+func TestWhereNot(t *testing.T) {
+	defer cleanupTestDB("testdb")
+	db := newPeopleDB(t)
+	_, _ = db.Execute("INSERT INTO people (id, name) VALUES (5, 'Eve')")
+
+	tests := []struct {
+		where    string
+		expected []int
+	}{
+		{"NOT (age > 30)", []int{1, 2}},
+		{"NOT age > 30", []int{1, 2}},
+		// NOT binds tightest: (NOT age = 25) AND name = 'Bob'
+		{"NOT age = 25 AND name = 'Bob'", []int{2}},
+		{"NOT (age = 25 AND name = 'Bob')", []int{1, 2, 3, 4, 5}},
+		{"NOT age = 25 OR id = 1", []int{1, 2, 3, 4}},
+		{"NOT (age = 25 OR id = 2)", []int{3, 4}},
+		{"not not id = 2", []int{2}},
+		{"NOT name LIKE 'A%'", []int{2, 3, 4, 5}},
+		{"NOT id IN (1, 2)", []int{3, 4, 5}},
+		// Eve has no age, so age > 30 is unknown and so is its negation
+		{"NOT (age > 30) OR name = 'Eve'", []int{1, 2, 5}},
+		{"NOT (age > 30 AND id = 5)", []int{1, 2, 3, 4}},
+		{"NOT (age > 30 OR id = 5)", []int{1, 2}},
+		{"NOT age IS NULL", []int{1, 2, 3, 4}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.where, func(t *testing.T) {
+			assertIDs(t, selectIDs(t, db, "SELECT * FROM people WHERE "+tt.where), tt.expected...)
+		})
+	}
+
+	for _, where := range []string{"NOT", "id = 1 AND NOT", "NOT OR id = 1"} {
+		if _, err := db.Execute("SELECT * FROM people WHERE " + where); err == nil {
+			t.Errorf("Expected an error for WHERE %s", where)
+		}
+	}
+
+	if _, err := db.Execute("DELETE FROM people WHERE NOT (age < 40)"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	assertIDs(t, selectIDs(t, db, "SELECT * FROM people"), 1, 2, 3, 5)
+}
+
+func TestWhereAndOrUpdateDelete(t *testing.T) {
+	defer cleanupTestDB("testdb")
+	db := newPeopleDB(t)
+
+	if _, err := db.Execute("UPDATE people SET age = 50 WHERE id = 1 OR id = 2 AND age = 25"); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	assertIDs(t, selectIDs(t, db, "SELECT * FROM people WHERE age = 50"), 1)
+
+	if _, err := db.Execute("DELETE FROM people WHERE (id = 3 OR id = 4) AND age > 35"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	assertIDs(t, selectIDs(t, db, "SELECT * FROM people"), 1, 2, 3)
+}